@@ -0,0 +1,80 @@
+// cmd/boundaries/main.go
+// 行政区画ポリゴンの読み込みと、既存の場所データへの一括付与を行うコマンドなのだ
+//
+//	go run ./cmd/boundaries load -file gadm41_JPN_1.json -level 1 -name NAME_1 -code GID_1 -source gadm41
+//	go run ./cmd/boundaries load -file N03-20240101.shp -level 2 -name N03_004 -code N03_007 -source n03 -encoding sjis
+//	go run ./cmd/boundaries backfill -batch 500
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/saku-730/specimen-web/backend/config"
+	"github.com/saku-730/specimen-web/backend/internal/infrastructure"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: boundaries <load|backfill> [flags]")
+		os.Exit(2)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed load config: %v", err)
+	}
+	db, err := database.NewDatabaseConnection(cfg)
+	if err != nil {
+		log.Fatalf("Falied connect database: %v", err)
+	}
+	geocodingService := service.NewGeocodingService(db,
+		repository.NewAdminBoundaryRepository(db),
+		repository.NewPlaceRepository(db))
+
+	switch os.Args[1] {
+	case "load":
+		fs := flag.NewFlagSet("load", flag.ExitOnError)
+		var req service.ImportBoundariesRequest
+		level := fs.Int("level", 0, "0: country, 1: prefecture, 2: municipality")
+		fs.StringVar(&req.FilePath, "file", "", "GeoJSON(.geojson/.json) または Shapefile(.shp) のパス")
+		fs.StringVar(&req.NameField, "name", "", "名前が入っている属性名")
+		fs.StringVar(&req.CodeField, "code", "", "コードが入っている属性名")
+		fs.StringVar(&req.Source, "source", "", "出典 (gadm41 など)")
+		fs.StringVar(&req.Encoding, "encoding", "", ".dbfの文字コード (sjis など、空なら.cpgから判定)")
+		fs.BoolVar(&req.Replace, "replace", false, "同じ出典・レベルの既存データを置き換える")
+		fs.Parse(os.Args[2:])
+		req.AdminLevel = int16(*level)
+
+		count, err := geocodingService.ImportBoundaries(req)
+		if err != nil {
+			log.Fatalf("行政区画の読み込みに失敗しました: %v", err)
+		}
+		log.Printf("%d 件の行政区画を登録しました", count)
+
+	case "backfill":
+		fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+		var req service.BackfillRequest
+		fs.IntVar(&req.BatchSize, "batch", 500, "1トランザクションで処理する件数")
+		fs.Parse(os.Args[2:])
+
+		result, err := geocodingService.BackfillPlaceNames(req)
+		if err != nil {
+			log.Fatalf("行政区画の付与に失敗しました: %v", err)
+		}
+		log.Printf("%d 件中 %d 件に行政区画を付与しました", result.Processed, result.Annotated)
+		for placeID, warnings := range result.Warnings {
+			for _, w := range warnings {
+				log.Printf("place_id=%d: %s", placeID, w.Message)
+			}
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,71 @@
+// cmd/terms/main.go
+// 発生情報の性別・成長段階に自由に書かれていた値を、語彙の値に読み替えるコマンドなのだ
+//
+//	go run ./cmd/terms normalize -dry-run
+//	go run ./cmd/terms normalize -field sex
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/saku-730/specimen-web/backend/config"
+	"github.com/saku-730/specimen-web/backend/internal/infrastructure"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: terms <normalize> [flags]")
+		os.Exit(2)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed load config: %v", err)
+	}
+	db, err := database.NewDatabaseConnection(cfg)
+	if err != nil {
+		log.Fatalf("Falied connect database: %v", err)
+	}
+	termService := service.NewControlledTermService(db,
+		repository.NewControlledTermRepository(db),
+		repository.NewVocabularyRepository(db))
+
+	switch os.Args[1] {
+	case "normalize":
+		fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+		field := fs.String("field", "", "sex か lifestage (空なら両方)")
+		dryRun := fs.Bool("dry-run", false, "書き換えずに、どう読み替えるかだけ表示する")
+		fs.Parse(os.Args[2:])
+
+		fields := []string{"sex", "lifestage"}
+		if *field != "" {
+			fields = []string{*field}
+		}
+		for _, f := range fields {
+			result, err := termService.NormalizeOccurrences(service.NormalizeTermsRequest{Field: f, DryRun: *dryRun})
+			if err != nil {
+				log.Fatalf("%s の読み替えに失敗しました: %v", f, err)
+			}
+			for _, r := range result.Replaced {
+				log.Printf("%s: %q -> %q (%d 件)", f, r.From, r.To, r.Count)
+			}
+			for _, v := range result.Unmatched {
+				log.Printf("%s: %q は語彙にないので読み替えませんでした (%d 件)", f, v.Value, v.Count)
+			}
+			if *dryRun {
+				log.Printf("%s: %d 種類の値を読み替えます (dry-run なので書き換えていません)", f, len(result.Replaced))
+			} else {
+				log.Printf("%s: %d 種類の値を読み替えました", f, len(result.Replaced))
+			}
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		os.Exit(2)
+	}
+}
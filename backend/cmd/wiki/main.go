@@ -0,0 +1,63 @@
+// cmd/wiki/main.go
+// 本文をDBに持つ前に作られたWikiページの content_path のファイルを、最初の版として取り込むコマンドなのだ
+//
+//	go run ./cmd/wiki migrate -dir /var/lib/specimen-web/wiki -dry-run
+//	go run ./cmd/wiki migrate -dir /var/lib/specimen-web/wiki
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/saku-730/specimen-web/backend/config"
+	"github.com/saku-730/specimen-web/backend/internal/infrastructure"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: wiki <migrate> [flags]")
+		os.Exit(2)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed load config: %v", err)
+	}
+	db, err := database.NewDatabaseConnection(cfg)
+	if err != nil {
+		log.Fatalf("Falied connect database: %v", err)
+	}
+	wikiService := service.NewWikiService(db, repository.NewWikiRepository(db))
+
+	switch os.Args[1] {
+	case "migrate":
+		fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+		dir := fs.String("dir", ".", "content_path の起点になるディレクトリ")
+		dryRun := fs.Bool("dry-run", false, "書き込まずに、読めるファイルがどれかだけ表示する")
+		fs.Parse(os.Args[2:])
+
+		result, err := wikiService.MigrateLegacyContent(service.MigrateWikiContentRequest{
+			Files:  os.DirFS(*dir),
+			DryRun: *dryRun,
+		})
+		if err != nil {
+			log.Fatalf("本文の移行に失敗しました: %v", err)
+		}
+		for _, f := range result.Failed {
+			log.Printf("page %d (%s) は移せませんでした: %s", f.PageID, f.ContentPath, f.Reason)
+		}
+		if *dryRun {
+			log.Printf("%d ページを移せます (dry-run なので書き込んでいません)", len(result.Migrated))
+		} else {
+			log.Printf("%d ページの本文を最初の版として保存しました", len(result.Migrated))
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -17,6 +17,21 @@ type Config struct {
 	DBName     string `mapstructure:"DB_NAME"`
 	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
 	ServerPort string `mapstructure:"SERVER_PORT"`
+	// 外から見たAPIのURLなのだ。Darwin Core Archive の画像のURL (ac:accessURI) に使うのだ
+	PublicBaseURL string `mapstructure:"PUBLIC_BASE_URL"`
+
+	// 添付ファイルの保存先なのだ。STORAGE_BACKEND は "local" か "s3"
+	StorageBackend  string `mapstructure:"STORAGE_BACKEND"`
+	StorageLocalDir string `mapstructure:"STORAGE_LOCAL_DIR"`
+	S3Endpoint      string `mapstructure:"S3_ENDPOINT"`
+	S3AccessKey     string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey     string `mapstructure:"S3_SECRET_KEY"`
+	S3Bucket        string `mapstructure:"S3_BUCKET"`
+	S3Region        string `mapstructure:"S3_REGION"`
+	S3UseSSL        bool   `mapstructure:"S3_USE_SSL"`
+
+	// 標本ラベルのPDFに埋め込むTrueTypeフォントなのだ。日本語を印刷するには日本語のフォントを指定するのだ
+	LabelFontPath string `mapstructure:"LABEL_FONT_PATH"`
 }
 
 // DSNはデータベース接続文字列(DSN)を生成するメソッドなのだ
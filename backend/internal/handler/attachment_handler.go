@@ -0,0 +1,307 @@
+// backend/internal/handler/attachment_handler.go
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+// maxUploadSize はアップロードできるファイルの上限(バイト)なのだ
+const maxUploadSize = 200 << 20
+
+type AttachmentHandler struct {
+	attachmentService service.AttachmentService
+}
+
+func NewAttachmentHandler(attachmentService service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: attachmentService}
+}
+
+// RegisterAttachmentRoutes はルーターに添付ファイル関連のエンドポイントを登録するのだ
+func (h *AttachmentHandler) RegisterAttachmentRoutes(router *gin.RouterGroup) {
+	// 添付ファイルに付けられるライセンスの一覧
+	router.GET("/licenses", h.GetLicenses)
+
+	occurrences := router.Group("/occurrences/:id/attachments")
+	{
+		occurrences.GET("", h.ListAttachments)
+		occurrences.POST("", h.UploadAttachment)
+		occurrences.PUT("/:attachment_id", h.UpdatePriority)
+	}
+
+	attachments := router.Group("/attachments")
+	{
+		// 保存せずに写真のEXIFだけ読んで、登録フォームの初期値を返すのだ
+		attachments.POST("/inspect", h.InspectPhoto)
+		attachments.GET("/:id", h.GetAttachment)
+		attachments.PUT("/:id/metadata", h.UpdateMetadata)
+		attachments.GET("/:id/suggestion", h.GetSuggestion)
+		attachments.GET("/:id/download", h.DownloadAttachment)
+		attachments.GET("/:id/thumbnails/:size", h.DownloadThumbnail)
+		attachments.DELETE("/:id", h.DeleteAttachment)
+	}
+}
+
+// UploadAttachment は multipart/form-data の file, user_id, priority を受け取って保存するのだ
+// license, rights_holder, creator, caption, subject_part, capture_device も一緒に送れるのだ
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	// PostForm もフォーム全体を読み込むので、大きさの上限はフォームを読む前にかけるのだ
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileが指定されていないか、大きすぎます"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.PostForm("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_idが正しくありません"})
+		return
+	}
+	var priority *int
+	if p := c.PostForm("priority"); p != "" {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "priorityが正しくありません"})
+			return
+		}
+		priority = &v
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルを読み込めません"})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.attachmentService.Upload(c.Request.Context(), service.UploadAttachmentRequest{
+		OccurrenceID: occurrenceID,
+		UserID:       uint(userID),
+		Priority:     priority,
+		FileName:     fileHeader.Filename,
+		Size:         fileHeader.Size,
+		Content:      file,
+
+		License:       c.PostForm("license"),
+		RightsHolder:  c.PostForm("rights_holder"),
+		Creator:       c.PostForm("creator"),
+		Caption:       c.PostForm("caption"),
+		SubjectPart:   c.PostForm("subject_part"),
+		CaptureDevice: c.PostForm("capture_device"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+		case errors.Is(err, service.ErrInvalidLicense):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ライセンスが正しくありません"})
+		case errors.Is(err, service.ErrUnsupportedFileType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "対応していないファイル形式です: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ファイルのアップロードに失敗しました"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// InspectPhoto は multipart/form-data の file を読んで、EXIFから作った初期値を返すのだ
+func (h *AttachmentHandler) InspectPhoto(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileが指定されていないか、大きすぎます"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルを読み込めません"})
+		return
+	}
+	defer file.Close()
+
+	suggestion, err := h.attachmentService.Inspect(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撮影情報の読み取りに失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// ListAttachments は発生情報の添付ファイルを表示順で返すのだ
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	attachments, err := h.attachmentService.ListByOccurrence(occurrenceID)
+	if err != nil {
+		respondAttachmentError(c, err, "添付ファイルの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UpdatePriority は発生情報の中での添付ファイルの表示順を変更するのだ
+func (h *AttachmentHandler) UpdatePriority(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	attachmentID, ok := parseIDParam(c, "attachment_id")
+	if !ok {
+		return
+	}
+	var req service.UpdatePriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	if err := h.attachmentService.UpdatePriority(occurrenceID, attachmentID, req); err != nil {
+		respondAttachmentError(c, err, "表示順の変更に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetAttachment は添付ファイルの情報を返すのだ
+func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	attachment, err := h.attachmentService.GetAttachment(id)
+	if err != nil {
+		respondAttachmentError(c, err, "添付ファイルの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// UpdateMetadata は添付ファイルのライセンス・権利者・説明などを更新するのだ
+func (h *AttachmentHandler) UpdateMetadata(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateAttachmentMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	attachment, err := h.attachmentService.UpdateMetadata(id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLicense) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ライセンスが正しくありません"})
+			return
+		}
+		respondAttachmentError(c, err, "添付ファイルの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// GetLicenses は選べるライセンスの一覧を返すのだ
+func (h *AttachmentHandler) GetLicenses(c *gin.Context) {
+	c.JSON(http.StatusOK, h.attachmentService.GetLicenses())
+}
+
+// GetSuggestion は保存済みの写真の撮影情報から作った初期値を返すのだ
+func (h *AttachmentHandler) GetSuggestion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	suggestion, err := h.attachmentService.GetSuggestion(id)
+	if err != nil {
+		respondAttachmentError(c, err, "撮影情報の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// DownloadAttachment はファイル本体を返すのだ。Rangeヘッダーによる部分取得にも対応するのだ
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	content, err := h.attachmentService.Open(c.Request.Context(), id)
+	if err != nil {
+		respondAttachmentError(c, err, "添付ファイルの取得に失敗しました")
+		return
+	}
+	defer content.Object.Close()
+
+	attachment := content.Attachment
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.OriginalName}))
+	c.Header("Content-Type", attachment.ContentType)
+	// アップロードされた Content-Type をブラウザに推測で上書きさせない (HTMLとして実行させない) のだ
+	c.Header("X-Content-Type-Options", "nosniff")
+	if attachment.ChecksumSHA256 != "" {
+		c.Header("ETag", `"`+attachment.ChecksumSHA256+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, attachment.OriginalName, attachment.CreatedAt, content.Object)
+}
+
+// DownloadThumbnail はサムネイル (small, medium, large) を返すのだ
+func (h *AttachmentHandler) DownloadThumbnail(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	content, err := h.attachmentService.OpenThumbnail(c.Request.Context(), id, c.Param("size"))
+	if err != nil {
+		respondAttachmentError(c, err, "サムネイルの取得に失敗しました")
+		return
+	}
+	defer content.Object.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, content.Thumbnail.FilePath, content.Attachment.CreatedAt, content.Object)
+}
+
+// DeleteAttachment は添付ファイルを削除するのだ
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.attachmentService.DeleteAttachment(c.Request.Context(), id); err != nil {
+		respondAttachmentError(c, err, "添付ファイルの削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// parseIDParam はパスパラメータのIDを読み取るのだ。不正なときは400を返してfalseになるのだ
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なIDです"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAttachmentError(c *gin.Context, err error, message string) {
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "添付ファイルが見つかりません"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,122 @@
+// backend/internal/handler/controlled_term_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type ControlledTermHandler struct {
+	controlledTermService service.ControlledTermService
+}
+
+func NewControlledTermHandler(controlledTermService service.ControlledTermService) *ControlledTermHandler {
+	return &ControlledTermHandler{controlledTermService: controlledTermService}
+}
+
+// RegisterControlledTermRoutes はルーターに性別・成長段階などの語彙のエンドポイントを登録するのだ
+func (h *ControlledTermHandler) RegisterControlledTermRoutes(router *gin.RouterGroup) {
+	terms := router.Group("/controlled-terms")
+	{
+		terms.GET("", h.GetTerms)
+		terms.POST("", h.CreateTerm)
+		terms.GET("/:id", h.GetTerm)
+		terms.PUT("/:id", h.UpdateTerm)
+		terms.DELETE("/:id", h.DeleteTerm)
+	}
+}
+
+// GetTerms は ?field=sex|lifestage の語彙を返すのだ。?include_deprecated=true で使うのをやめた値も、?lang= でその言語の表示名も付けるのだ
+func (h *ControlledTermHandler) GetTerms(c *gin.Context) {
+	var req service.ListControlledTermsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	terms, err := h.controlledTermService.GetTerms(req)
+	if err != nil {
+		respondControlledTermError(c, err, "語彙の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, terms)
+}
+
+func (h *ControlledTermHandler) GetTerm(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	term, err := h.controlledTermService.GetTerm(id)
+	if err != nil {
+		respondControlledTermError(c, err, "語彙の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, term)
+}
+
+func (h *ControlledTermHandler) CreateTerm(c *gin.Context) {
+	var req service.ControlledTermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	term, err := h.controlledTermService.CreateTerm(req)
+	if err != nil {
+		respondControlledTermError(c, err, "語彙の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, term)
+}
+
+// UpdateTerm は語彙を書き換えるのだ。値を変えると、その値の入った発生情報も書き換わるのだ
+func (h *ControlledTermHandler) UpdateTerm(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.ControlledTermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	term, err := h.controlledTermService.UpdateTerm(id, req)
+	if err != nil {
+		respondControlledTermError(c, err, "語彙の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, term)
+}
+
+// DeleteTerm は使われていない語彙なら消して204を、使われている語彙なら使うのをやめた印を付けて200でその語彙を返すのだ
+func (h *ControlledTermHandler) DeleteTerm(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deprecated, err := h.controlledTermService.DeleteTerm(id)
+	if err != nil {
+		respondControlledTermError(c, err, "語彙の削除に失敗しました")
+		return
+	}
+	if deprecated != nil {
+		c.JSON(http.StatusOK, deprecated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondControlledTermError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "語彙が見つかりません"})
+	case errors.Is(err, service.ErrInvalidTerm):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTermConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,181 @@
+// backend/internal/handler/event_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type EventHandler struct {
+	eventService service.EventService
+}
+
+func NewEventHandler(eventService service.EventService) *EventHandler {
+	return &EventHandler{eventService: eventService}
+}
+
+// RegisterEventRoutes はルーターに採集のまとまり関連のエンドポイントを登録するのだ
+func (h *EventHandler) RegisterEventRoutes(router *gin.RouterGroup) {
+	events := router.Group("/events")
+	{
+		events.GET("", h.GetEvents)
+		events.POST("", h.CreateEvent)
+		events.GET("/:id", h.GetEvent)
+		events.PUT("/:id", h.UpdateEvent)
+		events.DELETE("/:id", h.DeleteEvent)
+
+		// 発生情報の付け外しなのだ
+		events.POST("/:id/occurrences", h.AttachOccurrences)
+		events.DELETE("/:id/occurrences/:occurrence_id", h.DetachOccurrence)
+
+		// 努力量あたりの個体数なのだ
+		events.GET("/:id/abundance", h.GetAbundance)
+	}
+}
+
+// GetEvents は採集のまとまりを探すのだ。条件がなければ一番上のまとまりを返すのだ
+func (h *EventHandler) GetEvents(c *gin.Context) {
+	var req service.ListEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	events, err := h.eventService.ListEvents(req)
+	if err != nil {
+		respondEventError(c, err, "採集のまとまりの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// GetEvent は採集のまとまりを、上のまとまり・すぐ下のまとまり・発生情報の数と一緒に返すのだ
+func (h *EventHandler) GetEvent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	event, err := h.eventService.GetEvent(id)
+	if err != nil {
+		respondEventError(c, err, "採集のまとまりの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func (h *EventHandler) CreateEvent(c *gin.Context) {
+	var req service.EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	event, err := h.eventService.CreateEvent(req)
+	if err != nil {
+		respondEventError(c, err, "採集のまとまりの作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, event)
+}
+
+func (h *EventHandler) UpdateEvent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	event, err := h.eventService.UpdateEvent(id, req)
+	if err != nil {
+		respondEventError(c, err, "採集のまとまりの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func (h *EventHandler) DeleteEvent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.eventService.DeleteEvent(id); err != nil {
+		respondEventError(c, err, "採集のまとまりの削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AttachOccurrences は発生情報をまとまりに付けるのだ。ほかのまとまりに付いていたものは付け替えるのだ
+func (h *EventHandler) AttachOccurrences(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.EventOccurrencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	event, err := h.eventService.AttachOccurrences(id, req)
+	if err != nil {
+		respondEventError(c, err, "発生情報の付け替えに失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func (h *EventHandler) DetachOccurrence(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	occurrenceID, ok := parseIDParam(c, "occurrence_id")
+	if !ok {
+		return
+	}
+	if err := h.eventService.DetachOccurrence(id, occurrenceID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "この発生情報はこのまとまりに付いていません"})
+			return
+		}
+		respondEventError(c, err, "発生情報の取り外しに失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetAbundance は分類群ごとの個体数と努力量あたりの数を返すのだ
+func (h *EventHandler) GetAbundance(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.AbundanceRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	abundance, err := h.eventService.GetAbundance(id, req)
+	if err != nil {
+		respondEventError(c, err, "個体数の集計に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, abundance)
+}
+
+func respondEventError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "採集のまとまりが見つかりません"})
+	case errors.Is(err, service.ErrInvalidEvent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrEventCodeTaken), errors.Is(err, service.ErrEventInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,110 @@
+// backend/internal/handler/export_handler.go
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type ExportHandler struct {
+	darwinCoreService service.DarwinCoreService
+}
+
+func NewExportHandler(darwinCoreService service.DarwinCoreService) *ExportHandler {
+	return &ExportHandler{darwinCoreService: darwinCoreService}
+}
+
+// RegisterExportRoutes はルーターにDarwin Core出力のエンドポイントを登録するのだ
+// 絞り込みには /search と同じクエリパラメータが使えるのだ
+func (h *ExportHandler) RegisterExportRoutes(router *gin.RouterGroup) {
+	export := router.Group("/export")
+	{
+		export.GET("/dwc/occurrences", h.ExportOccurrences)
+		export.GET("/ac/multimedia", h.ExportMultimedia)
+		export.GET("/dwc/measurements", h.ExportMeasurements)
+		export.GET("/dwca", h.DownloadArchive)
+	}
+}
+
+// ExportOccurrences はDarwin CoreのOccurrenceをJSONで返すのだ
+func (h *ExportHandler) ExportOccurrences(c *gin.Context) {
+	var req service.SearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search parameters"})
+		return
+	}
+
+	records, err := h.darwinCoreService.ExportOccurrences(req)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// ExportMultimedia はライセンスの付いた添付ファイルをAudubon CoreのMultimediaとしてJSONで返すのだ
+func (h *ExportHandler) ExportMultimedia(c *gin.Context) {
+	var req service.SearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search parameters"})
+		return
+	}
+
+	records, err := h.darwinCoreService.ExportMultimedia(req)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// ExportMeasurements は計測値をDarwin CoreのMeasurementOrFactとしてJSONで返すのだ
+func (h *ExportHandler) ExportMeasurements(c *gin.Context) {
+	var req service.SearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search parameters"})
+		return
+	}
+
+	records, err := h.darwinCoreService.ExportMeasurements(req)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// DownloadArchive はDarwin Core Archive(zip)をダウンロードさせるのだ
+// core=event なら採集のまとまりをコアにして、発生情報を拡張にするのだ
+func (h *ExportHandler) DownloadArchive(c *gin.Context) {
+	var req service.SearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search parameters"})
+		return
+	}
+
+	// 途中で失敗したときにエラーをJSONで返せるように、一度メモリに書き出すのだ
+	var buf bytes.Buffer
+	write := h.darwinCoreService.WriteArchive
+	if c.Query("core") == "event" {
+		write = h.darwinCoreService.WriteEventArchive
+	}
+	if err := write(&buf, req); err != nil {
+		respondExportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="dwca.zip"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func respondExportError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidSearchRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Darwin Coreの出力に失敗しました"})
+}
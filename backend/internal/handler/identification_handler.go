@@ -0,0 +1,134 @@
+// backend/internal/handler/identification_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type IdentificationHandler struct {
+	identificationService service.IdentificationService
+}
+
+func NewIdentificationHandler(identificationService service.IdentificationService) *IdentificationHandler {
+	return &IdentificationHandler{identificationService: identificationService}
+}
+
+// RegisterIdentificationRoutes はルーターに同定関連のエンドポイントを登録するのだ
+// 同定はいつも発生情報に付くので、発生情報の下に置くのだ
+func (h *IdentificationHandler) RegisterIdentificationRoutes(router *gin.RouterGroup) {
+	identifications := router.Group("/occurrences/:id/identifications")
+	{
+		identifications.GET("", h.ListIdentifications)
+		identifications.POST("", h.CreateIdentification)
+		identifications.GET("/:identification_id", h.GetIdentification)
+		identifications.PUT("/:identification_id", h.UpdateIdentification)
+		identifications.DELETE("/:identification_id", h.DeleteIdentification)
+	}
+}
+
+// ListIdentifications は発生情報の同定の履歴を新しい順に返すのだ。先頭が今の同定なのだ
+func (h *IdentificationHandler) ListIdentifications(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	identifications, err := h.identificationService.GetIdentifications(occurrenceID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "同定の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, identifications)
+}
+
+func (h *IdentificationHandler) GetIdentification(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "identification_id")
+	if !ok {
+		return
+	}
+	identification, err := h.identificationService.GetIdentification(occurrenceID, id)
+	if err != nil {
+		respondIdentificationError(c, err, "同定の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, identification)
+}
+
+// CreateIdentification は同定を追加して、発生情報の分類を新しい同定に合わせるのだ
+func (h *IdentificationHandler) CreateIdentification(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.IdentificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	identification, err := h.identificationService.CreateIdentification(occurrenceID, req)
+	if err != nil {
+		respondIdentificationError(c, err, "同定の追加に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, identification)
+}
+
+func (h *IdentificationHandler) UpdateIdentification(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "identification_id")
+	if !ok {
+		return
+	}
+	var req service.IdentificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	identification, err := h.identificationService.UpdateIdentification(occurrenceID, id, req)
+	if err != nil {
+		respondIdentificationError(c, err, "同定の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, identification)
+}
+
+func (h *IdentificationHandler) DeleteIdentification(c *gin.Context) {
+	occurrenceID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "identification_id")
+	if !ok {
+		return
+	}
+	if err := h.identificationService.DeleteIdentification(occurrenceID, id); err != nil {
+		respondIdentificationError(c, err, "同定の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondIdentificationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "同定が見つかりません"})
+	case errors.Is(err, service.ErrInvalidIdentification):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,247 @@
+// backend/internal/handler/individual_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+// geoJSONContentType は GeoJSON を返すときの Content-Type なのだ (RFC 7946)
+const geoJSONContentType = "application/geo+json"
+
+type IndividualHandler struct {
+	individualService service.IndividualService
+}
+
+func NewIndividualHandler(individualService service.IndividualService) *IndividualHandler {
+	return &IndividualHandler{individualService: individualService}
+}
+
+// RegisterIndividualRoutes はルーターに標識した個体関連のエンドポイントを登録するのだ
+func (h *IndividualHandler) RegisterIndividualRoutes(router *gin.RouterGroup) {
+	individuals := router.Group("/individuals")
+	{
+		individuals.GET("", h.GetIndividuals)
+		individuals.POST("", h.CreateIndividual)
+		individuals.GET("/tracks", h.GetTracks)
+		individuals.GET("/conflicts", h.FindConflicts)
+		individuals.GET("/:id", h.GetIndividual)
+		individuals.PUT("/:id", h.UpdateIndividual)
+		individuals.DELETE("/:id", h.DeleteIndividual)
+
+		// 再捕獲した発生情報の付け外しなのだ
+		individuals.POST("/:id/occurrences", h.AttachOccurrences)
+		individuals.DELETE("/:id/occurrences/:occurrence_id", h.DetachOccurrence)
+
+		// 記録の並びと動いた跡なのだ
+		individuals.GET("/:id/timeline", h.GetTimeline)
+		individuals.GET("/:id/track", h.GetTrack)
+		individuals.GET("/:id/conflicts", h.GetConflicts)
+	}
+}
+
+func (h *IndividualHandler) GetIndividuals(c *gin.Context) {
+	var req service.ListIndividualsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	individuals, err := h.individualService.ListIndividuals(req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, individuals)
+}
+
+// GetIndividual は個体を、記録の数と最初と最後に記録された日時と一緒に返すのだ
+func (h *IndividualHandler) GetIndividual(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	individual, err := h.individualService.GetIndividual(id)
+	if err != nil {
+		respondIndividualError(c, err, "個体の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, individual)
+}
+
+func (h *IndividualHandler) CreateIndividual(c *gin.Context) {
+	var req service.IndividualRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	individual, err := h.individualService.CreateIndividual(req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, individual)
+}
+
+func (h *IndividualHandler) UpdateIndividual(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.IndividualRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	individual, err := h.individualService.UpdateIndividual(id, req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, individual)
+}
+
+func (h *IndividualHandler) DeleteIndividual(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.individualService.DeleteIndividual(id); err != nil {
+		respondIndividualError(c, err, "個体の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AttachOccurrences は再捕獲した発生情報を個体に付けるのだ
+func (h *IndividualHandler) AttachOccurrences(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.IndividualOccurrencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	individual, err := h.individualService.AttachOccurrences(id, req)
+	if err != nil {
+		respondIndividualError(c, err, "発生情報の付け替えに失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, individual)
+}
+
+func (h *IndividualHandler) DetachOccurrence(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	occurrenceID, ok := parseIDParam(c, "occurrence_id")
+	if !ok {
+		return
+	}
+	if err := h.individualService.DetachOccurrence(id, occurrenceID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "この発生情報はこの個体に付いていません"})
+			return
+		}
+		respondIndividualError(c, err, "発生情報の取り外しに失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetTimeline は個体の記録を日時の順に、場所と前の記録からの移動と一緒に返すのだ
+func (h *IndividualHandler) GetTimeline(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	timeline, err := h.individualService.GetTimeline(id)
+	if err != nil {
+		respondIndividualError(c, err, "個体の記録の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, timeline)
+}
+
+// GetTrack は個体の動いた跡を GeoJSON の LineString で返すのだ
+func (h *IndividualHandler) GetTrack(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	track, err := h.individualService.GetTrack(id)
+	if err != nil {
+		respondIndividualError(c, err, "個体の動いた跡の取得に失敗しました")
+		return
+	}
+	c.Header("Content-Type", geoJSONContentType)
+	c.JSON(http.StatusOK, track)
+}
+
+// GetTracks は条件に合う個体の動いた跡を GeoJSON の FeatureCollection で返すのだ
+func (h *IndividualHandler) GetTracks(c *gin.Context) {
+	var req service.ListIndividualsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	tracks, err := h.individualService.GetTracks(req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の動いた跡の取得に失敗しました")
+		return
+	}
+	c.Header("Content-Type", geoJSONContentType)
+	c.JSON(http.StatusOK, tracks)
+}
+
+// GetConflicts は個体の記録の食い違い (同じ日に離れた場所にいる、性別が違うなど) を返すのだ
+func (h *IndividualHandler) GetConflicts(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.IndividualConflictsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	conflicts, err := h.individualService.GetConflicts(id, req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の記録の確認に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, conflicts)
+}
+
+// FindConflicts は条件に合う全ての個体の記録の食い違いを返すのだ
+func (h *IndividualHandler) FindConflicts(c *gin.Context) {
+	var req service.IndividualConflictsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	conflicts, err := h.individualService.FindConflicts(req)
+	if err != nil {
+		respondIndividualError(c, err, "個体の記録の確認に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, conflicts)
+}
+
+func respondIndividualError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "個体が見つかりません"})
+	case errors.Is(err, service.ErrInvalidIndividual):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMarkCodeTaken), errors.Is(err, service.ErrIndividualInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,54 @@
+// backend/internal/handler/label_handler.go
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type LabelHandler struct {
+	labelService service.LabelService
+}
+
+func NewLabelHandler(labelService service.LabelService) *LabelHandler {
+	return &LabelHandler{labelService: labelService}
+}
+
+// RegisterLabelRoutes はルーターに標本ラベル印刷のエンドポイントを登録するのだ
+func (h *LabelHandler) RegisterLabelRoutes(router *gin.RouterGroup) {
+	labels := router.Group("/specimens/labels")
+	{
+		labels.GET("/options", h.GetLabelOptions)
+		labels.POST("", h.RenderLabels)
+	}
+}
+
+// GetLabelOptions は選べるテンプレート・用紙・コードの種類を返すのだ
+func (h *LabelHandler) GetLabelOptions(c *gin.Context) {
+	c.JSON(http.StatusOK, h.labelService.GetLabelOptions())
+}
+
+// RenderLabels は選んだ標本のラベルをPDFで返すのだ
+func (h *LabelHandler) RenderLabels(c *gin.Context) {
+	var req service.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.labelService.RenderLabels(&buf, req); err != nil {
+		if errors.Is(err, service.ErrInvalidLabelRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ラベルの作成に失敗しました"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="labels.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -0,0 +1,179 @@
+// backend/internal/handler/loan_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type LoanHandler struct {
+	loanService service.LoanService
+}
+
+func NewLoanHandler(loanService service.LoanService) *LoanHandler {
+	return &LoanHandler{loanService: loanService}
+}
+
+// RegisterLoanRoutes はルーターに貸し出し関連のエンドポイントを登録するのだ
+func (h *LoanHandler) RegisterLoanRoutes(router *gin.RouterGroup) {
+	loans := router.Group("/loans")
+	{
+		loans.GET("", h.ListLoans)
+		loans.POST("", h.CreateLoan)
+		loans.GET("/overdue", h.GetOverdueLoans)
+		loans.GET("/:id", h.GetLoan)
+		loans.PUT("/:id", h.UpdateLoan)
+		loans.POST("/:id/specimens", h.AddLoanSpecimens)
+		loans.POST("/:id/returns", h.ReturnLoanSpecimens)
+	}
+
+	// 標本ごとの貸し出しの履歴なのだ
+	router.GET("/specimens/:id/loans", h.GetSpecimenLoans)
+}
+
+// ListLoans は ?status=open|closed|overdue&institution_id=&as_of= で絞り込んだ貸し出しを返すのだ
+func (h *LoanHandler) ListLoans(c *gin.Context) {
+	var req service.ListLoansRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	list, err := h.loanService.ListLoans(req)
+	if err != nil {
+		respondLoanError(c, err, "貸し出しの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetOverdueLoans は ?as_of= の時点で返却期限を過ぎた貸し出しを返すのだ
+func (h *LoanHandler) GetOverdueLoans(c *gin.Context) {
+	var req service.OverdueLoansRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	report, err := h.loanService.GetOverdueLoans(req)
+	if err != nil {
+		respondLoanError(c, err, "貸し出しの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *LoanHandler) GetLoan(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	loan, err := h.loanService.GetLoan(id)
+	if err != nil {
+		respondLoanError(c, err, "貸し出しの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+func (h *LoanHandler) CreateLoan(c *gin.Context) {
+	var req service.CreateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	loan, err := h.loanService.CreateLoan(req)
+	if err != nil {
+		respondLoanError(c, err, "貸し出しの作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, loan)
+}
+
+func (h *LoanHandler) UpdateLoan(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateLoanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	loan, err := h.loanService.UpdateLoan(id, req)
+	if err != nil {
+		respondLoanError(c, err, "貸し出しの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+// AddLoanSpecimens は貸し出し中の貸し出しに標本を足すのだ
+func (h *LoanHandler) AddLoanSpecimens(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.AddLoanSpecimensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	loan, err := h.loanService.AddLoanSpecimens(id, req)
+	if err != nil {
+		respondLoanError(c, err, "標本の追加に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+// ReturnLoanSpecimens は標本の返却を受け付けるのだ。一部だけでもいいのだ
+func (h *LoanHandler) ReturnLoanSpecimens(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.ReturnLoanSpecimensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	loan, err := h.loanService.ReturnLoanSpecimens(id, req)
+	if err != nil {
+		respondLoanError(c, err, "返却の登録に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+// GetSpecimenLoans は標本が入った貸し出しを新しい順に返すのだ
+func (h *LoanHandler) GetSpecimenLoans(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	loans, err := h.loanService.GetSpecimenLoans(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "標本が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "貸し出しの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, loans)
+}
+
+func respondLoanError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "貸し出しが見つかりません"})
+	case errors.Is(err, service.ErrInvalidLoan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrLoanClosed), errors.Is(err, service.ErrSpecimenOnLoan):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
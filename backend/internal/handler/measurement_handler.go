@@ -0,0 +1,189 @@
+// backend/internal/handler/measurement_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type MeasurementHandler struct {
+	measurementService service.MeasurementService
+}
+
+func NewMeasurementHandler(measurementService service.MeasurementService) *MeasurementHandler {
+	return &MeasurementHandler{measurementService: measurementService}
+}
+
+// RegisterMeasurementRoutes はルーターに計測値関連のエンドポイントを登録するのだ
+func (h *MeasurementHandler) RegisterMeasurementRoutes(router *gin.RouterGroup) {
+	// 入力フォームの選択肢なのだ。種類の名前や並び順の管理は /vocabularies/measurement-types でするのだ
+	router.GET("/measurement-types", h.GetMeasurementTypes)
+	router.PUT("/measurement-types/:id/unit", h.UpdateTypeUnit)
+	router.GET("/measurement-units", h.GetMeasurementUnits)
+
+	// 発生情報・標本ごとの計測値なのだ
+	router.GET("/occurrences/:id/measurements", h.GetOccurrenceMeasurements)
+	router.POST("/occurrences/:id/measurements", h.CreateMeasurement)
+	router.GET("/specimens/:id/measurements", h.GetSpecimenMeasurements)
+
+	measurements := router.Group("/measurements")
+	{
+		measurements.GET("/:id", h.GetMeasurement)
+		measurements.PUT("/:id", h.UpdateMeasurement)
+		measurements.DELETE("/:id", h.DeleteMeasurement)
+	}
+}
+
+func (h *MeasurementHandler) GetMeasurementTypes(c *gin.Context) {
+	types, err := h.measurementService.GetMeasurementTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "計測の種類の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, types)
+}
+
+func (h *MeasurementHandler) GetMeasurementUnits(c *gin.Context) {
+	c.JSON(http.StatusOK, h.measurementService.GetMeasurementUnits())
+}
+
+// UpdateTypeUnit は計測の種類の単位を変えるのだ。そろえてある計測値も新しい単位に読み替えるのだ
+func (h *MeasurementHandler) UpdateTypeUnit(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.MeasurementTypeUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	measurementType, err := h.measurementService.UpdateTypeUnit(id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "計測の種類が見つかりません"})
+			return
+		}
+		respondMeasurementError(c, err, "計測の種類の単位の変更に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, measurementType)
+}
+
+// GetOccurrenceMeasurements は発生情報の計測値を、計測の種類の並び順で返すのだ
+func (h *MeasurementHandler) GetOccurrenceMeasurements(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	measurements, err := h.measurementService.GetMeasurementsByOccurrence(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "計測値の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, measurements)
+}
+
+// GetSpecimenMeasurements は標本を測った計測値を返すのだ
+func (h *MeasurementHandler) GetSpecimenMeasurements(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	measurements, err := h.measurementService.GetMeasurementsBySpecimen(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "標本が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "計測値の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, measurements)
+}
+
+func (h *MeasurementHandler) GetMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	measurement, err := h.measurementService.GetMeasurement(id)
+	if err != nil {
+		respondMeasurementError(c, err, "計測値の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, measurement)
+}
+
+// CreateMeasurement は発生情報に計測値を追加するのだ
+func (h *MeasurementHandler) CreateMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.MeasurementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	measurement, err := h.measurementService.CreateMeasurement(id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+			return
+		}
+		respondMeasurementError(c, err, "計測値の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, measurement)
+}
+
+func (h *MeasurementHandler) UpdateMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.MeasurementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	measurement, err := h.measurementService.UpdateMeasurement(id, req)
+	if err != nil {
+		respondMeasurementError(c, err, "計測値の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, measurement)
+}
+
+func (h *MeasurementHandler) DeleteMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.measurementService.DeleteMeasurement(id); err != nil {
+		respondMeasurementError(c, err, "計測値の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondMeasurementError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "計測値が見つかりません"})
+	case errors.Is(err, service.ErrInvalidMeasurement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMeasurementUnitInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,232 @@
+// backend/internal/handler/molecular_handler.go
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+// maxFASTAUploadSize はFASTAファイルの大きさの上限なのだ
+const maxFASTAUploadSize = 10 << 20
+
+type MolecularHandler struct {
+	molecularService service.MolecularService
+}
+
+func NewMolecularHandler(molecularService service.MolecularService) *MolecularHandler {
+	return &MolecularHandler{molecularService: molecularService}
+}
+
+// RegisterMolecularRoutes はルーターにDNA用の試料と配列のエンドポイントを登録するのだ
+func (h *MolecularHandler) RegisterMolecularRoutes(router *gin.RouterGroup) {
+	samples := router.Group("/samples")
+	{
+		samples.GET("", h.ListSamples)
+		samples.POST("", h.CreateSample)
+		samples.GET("/:id", h.GetSample)
+		samples.PUT("/:id", h.UpdateSample)
+		samples.DELETE("/:id", h.DeleteSample)
+		samples.POST("/:id/sequences", h.AddSequence)
+		samples.POST("/:id/sequences/fasta", h.ImportFASTA)
+	}
+
+	sequences := router.Group("/sequences")
+	{
+		sequences.GET("/fasta", h.ExportFASTA)
+		sequences.GET("/:id", h.GetSequence)
+		sequences.DELETE("/:id", h.DeleteSequence)
+	}
+}
+
+// ListSamples は ?specimen_id=&occurrence_id=&sample_type= で絞り込んだ試料を返すのだ
+func (h *MolecularHandler) ListSamples(c *gin.Context) {
+	var req service.ListSamplesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	samples, err := h.molecularService.ListSamples(req)
+	if err != nil {
+		respondMolecularError(c, err, "試料の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, samples)
+}
+
+// GetSample は試料を標本・置き場所・配列つきで返すのだ
+func (h *MolecularHandler) GetSample(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	sample, err := h.molecularService.GetSample(id)
+	if err != nil {
+		respondMolecularError(c, err, "試料の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, sample)
+}
+
+func (h *MolecularHandler) CreateSample(c *gin.Context) {
+	var req service.SampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	sample, err := h.molecularService.CreateSample(req)
+	if err != nil {
+		respondMolecularError(c, err, "試料の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, sample)
+}
+
+func (h *MolecularHandler) UpdateSample(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.SampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	sample, err := h.molecularService.UpdateSample(id, req)
+	if err != nil {
+		respondMolecularError(c, err, "試料の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, sample)
+}
+
+func (h *MolecularHandler) DeleteSample(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.molecularService.DeleteSample(id); err != nil {
+		respondMolecularError(c, err, "試料の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddSequence は試料に配列を1件追加するのだ
+func (h *MolecularHandler) AddSequence(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.SequenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	sequence, err := h.molecularService.AddSequence(id, req)
+	if err != nil {
+		respondMolecularError(c, err, "配列の追加に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, sequence)
+}
+
+// ImportFASTA は multipart/form-data の file (FASTA) と marker・accession_source・user_id を読んで配列を追加するのだ
+func (h *MolecularHandler) ImportFASTA(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFASTAUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileが指定されていないか、大きすぎます"})
+		return
+	}
+	req := service.ImportFASTARequest{
+		Marker:          c.PostForm("marker"),
+		AccessionSource: c.PostForm("accession_source"),
+	}
+	if v := c.PostForm("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_idが正しくありません"})
+			return
+		}
+		uid := uint(userID)
+		req.UserID = &uid
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルを読み込めません"})
+		return
+	}
+	defer file.Close()
+
+	sequences, err := h.molecularService.ImportFASTA(id, file, req)
+	if err != nil {
+		respondMolecularError(c, err, "配列の読み込みに失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, sequences)
+}
+
+// ExportFASTA は ?sequence_id=&sample_id=&specimen_id=&occurrence_id=&marker= で絞り込んだ配列をFASTAで返すのだ
+func (h *MolecularHandler) ExportFASTA(c *gin.Context) {
+	var req service.ExportFASTARequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.molecularService.ExportFASTA(&buf, req); err != nil {
+		respondMolecularError(c, err, "配列の書き出しに失敗しました")
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="sequences.fasta"`)
+	c.Data(http.StatusOK, "text/x-fasta; charset=utf-8", buf.Bytes())
+}
+
+func (h *MolecularHandler) GetSequence(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	sequence, err := h.molecularService.GetSequence(id)
+	if err != nil {
+		respondMolecularError(c, err, "配列の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, sequence)
+}
+
+func (h *MolecularHandler) DeleteSequence(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.molecularService.DeleteSequence(id); err != nil {
+		respondMolecularError(c, err, "配列の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondMolecularError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "試料か配列が見つかりません"})
+	case errors.Is(err, service.ErrInvalidSample):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSampleInUse), errors.Is(err, service.ErrSampleCodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,140 @@
+// backend/internal/handler/observation_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type ObservationHandler struct {
+	observationService service.ObservationService
+}
+
+func NewObservationHandler(observationService service.ObservationService) *ObservationHandler {
+	return &ObservationHandler{observationService: observationService}
+}
+
+// RegisterObservationRoutes はルーターに観察情報関連のエンドポイントを登録するのだ
+func (h *ObservationHandler) RegisterObservationRoutes(router *gin.RouterGroup) {
+	// 登録フォームの選択肢なのだ
+	router.GET("/observation-methods", h.GetAllObservationMethods)
+
+	observations := router.Group("/observations")
+	{
+		observations.GET("", h.GetAllObservations)
+		observations.POST("", h.CreateObservation)
+		observations.GET("/:id", h.GetObservation)
+		observations.PUT("/:id", h.UpdateObservation)
+		observations.DELETE("/:id", h.DeleteObservation)
+	}
+
+	// 発生情報ごとの観察なのだ
+	router.GET("/occurrences/:id/observations", h.GetOccurrenceObservations)
+}
+
+func (h *ObservationHandler) GetAllObservations(c *gin.Context) {
+	observations, err := h.observationService.GetAllObservations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "観察情報の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, observations)
+}
+
+func (h *ObservationHandler) GetObservation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	observation, err := h.observationService.GetObservationByID(id)
+	if err != nil {
+		respondObservationError(c, err, "観察情報の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, observation)
+}
+
+// GetOccurrenceObservations は発生情報に付いた観察を、観察した順に返すのだ
+func (h *ObservationHandler) GetOccurrenceObservations(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	observations, err := h.observationService.GetObservationsByOccurrence(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "観察情報の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, observations)
+}
+
+func (h *ObservationHandler) CreateObservation(c *gin.Context) {
+	var req service.CreateObservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	observation, err := h.observationService.CreateObservation(req)
+	if err != nil {
+		respondObservationError(c, err, "観察情報の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, observation)
+}
+
+func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateObservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	observation, err := h.observationService.UpdateObservation(id, req)
+	if err != nil {
+		respondObservationError(c, err, "観察情報の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, observation)
+}
+
+func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.observationService.DeleteObservation(id); err != nil {
+		respondObservationError(c, err, "観察情報の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ObservationHandler) GetAllObservationMethods(c *gin.Context) {
+	methods, err := h.observationService.GetAllObservationMethods()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "観察方法の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, methods)
+}
+
+func respondObservationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "観察情報が見つかりません"})
+	case errors.Is(err, service.ErrInvalidObservation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -2,11 +2,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/saku-730/specimen-web/backend/internal/model"
 	"github.com/saku-730/specimen-web/backend/internal/service"
 )
 
@@ -39,12 +38,23 @@ func (h *OccurrenceHandler) CreateFullOccurrence(c *gin.Context) {
 		return
 	}
 
-	if err := h.occurrenceService.CreateFullOccurrence(req); err != nil {
+	result, err := h.occurrenceService.CreateFullOccurrence(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTerm) || errors.Is(err, service.ErrInvalidPlace) || errors.Is(err, service.ErrInvalidCoordinates) ||
+			errors.Is(err, service.ErrInvalidPreparation) || errors.Is(err, service.ErrInvalidIndividual) ||
+			errors.Is(err, service.ErrInvalidEvent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データの登録に失敗しました: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "データが正常に登録されました"})
+	c.JSON(http.StatusCreated, gin.H{
+		"message":             "データが正常に登録されました",
+		"occurrence_id":       result.OccurrenceID,
+		"place_name_warnings": result.PlaceNameWarnings,
+	})
 }
 
 func (h *OccurrenceHandler) GetAllLanguages(c *gin.Context) {
@@ -66,7 +76,13 @@ func (h *OccurrenceHandler) Search(c *gin.Context) {
 
 	results, err := h.occurrenceService.Search(req)
 	if err != nil{
-		c.JSON(http.StatusInternalServarError, gin.H{"error":"Failed search"})
+		if errors.Is(err, service.ErrInvalidSearchRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error":"Failed search"})
+		return
+	}
 
 	c.JSON(http.StatusOK,results)
 }
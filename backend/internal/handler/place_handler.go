@@ -0,0 +1,61 @@
+// backend/internal/handler/place_handler.go
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type PlaceHandler struct {
+	geocodingService  service.GeocodingService
+	coordinateService service.CoordinateService
+}
+
+func NewPlaceHandler(geocodingService service.GeocodingService, coordinateService service.CoordinateService) *PlaceHandler {
+	return &PlaceHandler{geocodingService: geocodingService, coordinateService: coordinateService}
+}
+
+// RegisterPlaceRoutes はルーターに場所・逆ジオコーディング関連のエンドポイントを登録するのだ
+func (h *PlaceHandler) RegisterPlaceRoutes(router *gin.RouterGroup) {
+	router.GET("/reverse-geocode", h.ReverseGeocode)
+
+	// 度分秒・UTM・MGRS・メッシュコードの座標をWGS84に変換して確認するエンドポイント
+	router.GET("/coordinates/parse", h.ParseCoordinates)
+
+	// 既存の場所データへの一括付与は全件を逆ジオコーディングして時間がかかるので、
+	// リクエストの中では行わず cmd/boundaries の backfill で実行するのだ
+}
+
+// ReverseGeocode は ?lat=&lon= の座標から国・都道府県・市区町村を返すのだ
+func (h *PlaceHandler) ReverseGeocode(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "緯度経度が正しくありません"})
+		return
+	}
+
+	area, err := h.geocodingService.ReverseGeocode(fmt.Sprintf("POINT(%f %f)", lon, lat))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "逆ジオコーディングに失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// ParseCoordinates は ?q=&datum= の座標文字列を解釈した結果を返すのだ
+func (h *PlaceHandler) ParseCoordinates(c *gin.Context) {
+	parsed, err := h.coordinateService.Parse(c.Query("q"), c.Query("datum"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"coordinates": parsed.WKT(),
+		"parsed":      parsed,
+	})
+}
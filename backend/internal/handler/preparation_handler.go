@@ -0,0 +1,93 @@
+// backend/internal/handler/preparation_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type PreparationHandler struct {
+	preparationService service.PreparationService
+}
+
+func NewPreparationHandler(preparationService service.PreparationService) *PreparationHandler {
+	return &PreparationHandler{preparationService: preparationService}
+}
+
+// RegisterPreparationRoutes はルーターに標本の作製の進み具合のエンドポイントを登録するのだ
+// 作製の記録を足すのは /specimens/:id/make_specimens で、部位を足すのは parent_specimen_id を付けた /specimens なのだ
+func (h *PreparationHandler) RegisterPreparationRoutes(router *gin.RouterGroup) {
+	router.GET("/occurrences/:id/preparations", h.GetOccurrencePreparations)
+
+	preparations := router.Group("/preparations")
+	{
+		preparations.GET("/queue", h.GetPreparationQueue)
+		preparations.PUT("/:id", h.UpdatePreparation)
+	}
+}
+
+// GetOccurrencePreparations は個体から作った標本を、取り分けた順の木にして返すのだ
+func (h *PreparationHandler) GetOccurrencePreparations(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	parts, err := h.preparationService.GetOccurrencePreparations(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "発生情報が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "標本の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, parts)
+}
+
+// GetPreparationQueue は ?user_id=&status= で、作製する人ごとの作業待ちを返すのだ
+func (h *PreparationHandler) GetPreparationQueue(c *gin.Context) {
+	var req service.PreparationQueueRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	queues, err := h.preparationService.GetPreparationQueue(req)
+	if err != nil {
+		respondPreparationError(c, err, "作業待ちの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, queues)
+}
+
+// UpdatePreparation は作製の記録の進み具合や作製者を変えるのだ。送った項目だけを変えるのだ
+func (h *PreparationHandler) UpdatePreparation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdatePreparationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	preparation, err := h.preparationService.UpdatePreparation(id, req)
+	if err != nil {
+		respondPreparationError(c, err, "作製の記録の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, preparation)
+}
+
+func respondPreparationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "作製の記録が見つかりません"})
+	case errors.Is(err, service.ErrInvalidPreparation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
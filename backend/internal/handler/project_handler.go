@@ -0,0 +1,190 @@
+// backend/internal/handler/project_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type ProjectHandler struct {
+	projectService service.ProjectService
+}
+
+func NewProjectHandler(projectService service.ProjectService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService}
+}
+
+// RegisterProjectRoutes はルーターにプロジェクト関連のエンドポイントを登録するのだ
+func (h *ProjectHandler) RegisterProjectRoutes(router *gin.RouterGroup) {
+	projects := router.Group("/projects")
+	{
+		projects.GET("", h.GetAllProjects)
+		projects.POST("", h.CreateProject)
+		projects.GET("/:id", h.GetProject)
+		projects.PUT("/:id", h.UpdateProject)
+		projects.DELETE("/:id", h.DeleteProject)
+		projects.GET("/:id/summary", h.GetProjectSummary)
+		projects.POST("/:id/members", h.AddMember)
+		projects.POST("/:id/members/:member_id/end", h.EndMember)
+		projects.DELETE("/:id/members/:member_id", h.RemoveMember)
+	}
+}
+
+func (h *ProjectHandler) GetAllProjects(c *gin.Context) {
+	projects, err := h.projectService.GetAllProjects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロジェクトの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject はプロジェクトをメンバーつきで返すのだ
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	project, err := h.projectService.GetProjectByID(id)
+	if err != nil {
+		respondProjectError(c, err, "プロジェクトの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var req service.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	project, err := h.projectService.CreateProject(req)
+	if err != nil {
+		respondProjectError(c, err, "プロジェクトの作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	project, err := h.projectService.UpdateProject(id, req)
+	if err != nil {
+		respondProjectError(c, err, "プロジェクトの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.projectService.DeleteProject(id); err != nil {
+		respondProjectError(c, err, "プロジェクトの削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetProjectSummary はプロジェクトの発生情報の数・種の数・期間と、記録の多い人を返すのだ
+func (h *ProjectHandler) GetProjectSummary(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	summary, err := h.projectService.GetProjectSummary(id)
+	if err != nil {
+		respondProjectError(c, err, "プロジェクトの集計に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// AddMember はプロジェクトにメンバーを追加して、プロジェクトを返すのだ
+func (h *ProjectHandler) AddMember(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.AddProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	project, err := h.projectService.AddMember(id, req)
+	if err != nil {
+		respondProjectError(c, err, "メンバーの追加に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, project)
+}
+
+// EndMember はメンバーが抜けた日を記録するのだ。body は省略できて、そのときは今日なのだ
+func (h *ProjectHandler) EndMember(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "member_id")
+	if !ok {
+		return
+	}
+	var req service.EndProjectMemberRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+			return
+		}
+	}
+	project, err := h.projectService.EndMember(id, memberID, req)
+	if err != nil {
+		respondProjectError(c, err, "メンバーの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+// RemoveMember はメンバーの記録を消して、プロジェクトを返すのだ
+func (h *ProjectHandler) RemoveMember(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "member_id")
+	if !ok {
+		return
+	}
+	project, err := h.projectService.RemoveMember(id, memberID)
+	if err != nil {
+		respondProjectError(c, err, "メンバーの削除に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func respondProjectError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロジェクトかメンバーが見つかりません"})
+	case errors.Is(err, service.ErrInvalidProject):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProjectInUse), errors.Is(err, service.ErrProjectMemberConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
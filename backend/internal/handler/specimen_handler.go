@@ -0,0 +1,256 @@
+// backend/internal/handler/specimen_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type SpecimenHandler struct {
+	specimenService service.SpecimenService
+}
+
+func NewSpecimenHandler(specimenService service.SpecimenService) *SpecimenHandler {
+	return &SpecimenHandler{specimenService: specimenService}
+}
+
+// RegisterSpecimenRoutes はルーターに標本関連のエンドポイントを登録するのだ
+func (h *SpecimenHandler) RegisterSpecimenRoutes(router *gin.RouterGroup) {
+	specimens := router.Group("/specimens")
+	{
+		specimens.GET("", h.ListSpecimens)
+		specimens.POST("", h.CreateSpecimen)
+		specimens.GET("/methods", h.GetAllSpecimenMethods)
+		specimens.GET("/catalog/:number", h.GetSpecimenByCatalogNumber)
+		specimens.GET("/scan", h.ResolveScan)
+		specimens.POST("/scan/inventory", h.Inventory)
+		specimens.GET("/:id", h.GetSpecimen)
+		specimens.PUT("/:id", h.UpdateSpecimen)
+		specimens.DELETE("/:id", h.DeleteSpecimen)
+		specimens.POST("/:id/make_specimens", h.AddMakeSpecimen)
+		specimens.POST("/:id/catalog_number", h.AssignCatalogNumber)
+	}
+
+	// コレクションごとの登録番号の形なのだ
+	collections := router.Group("/collections")
+	{
+		collections.GET("", h.GetAllCollections)
+		collections.PUT("/:id/catalog_number_pattern", h.UpdateCatalogPattern)
+	}
+
+	// 入力フォームの選択肢なのだ。使うのをやめた値は出さないのだ。管理は /vocabularies でするのだ
+	router.GET("/specimen-methods", h.GetAllSpecimenMethods)
+	router.GET("/institution-codes", h.GetAllInstitutions)
+	router.GET("/collection-codes", h.GetAllCollections)
+}
+
+// ListSpecimens は ?institution_id=&collection_id=&specimen_method_id=&occurrence_id=&storage_location_id=&status= で絞り込んだ一覧を返すのだ
+func (h *SpecimenHandler) ListSpecimens(c *gin.Context) {
+	var req service.ListSpecimensRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	list, err := h.specimenService.ListSpecimens(req)
+	if err != nil {
+		respondSpecimenError(c, err, "標本の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetSpecimen は標本を発生情報と作製の履歴つきで返すのだ
+func (h *SpecimenHandler) GetSpecimen(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	specimen, err := h.specimenService.GetSpecimenByID(id)
+	if err != nil {
+		respondSpecimenError(c, err, "標本の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, specimen)
+}
+
+// GetSpecimenByCatalogNumber はラベルの登録番号から標本を引くのだ
+func (h *SpecimenHandler) GetSpecimenByCatalogNumber(c *gin.Context) {
+	specimen, err := h.specimenService.GetSpecimenByCatalogNumber(c.Param("number"))
+	if err != nil {
+		respondSpecimenError(c, err, "標本の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, specimen)
+}
+
+// ResolveScan は ?code= で読み取ったコード (登録番号かURI) から標本を返すのだ
+func (h *SpecimenHandler) ResolveScan(c *gin.Context) {
+	specimen, err := h.specimenService.ResolveScan(c.Query("code"))
+	if err != nil {
+		respondSpecimenError(c, err, "標本の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, specimen)
+}
+
+// Inventory はまとめて読み取ったコードと、あるはずの標本を突き合わせた結果を返すのだ
+func (h *SpecimenHandler) Inventory(c *gin.Context) {
+	var req service.InventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	report, err := h.specimenService.Inventory(req)
+	if err != nil {
+		respondSpecimenError(c, err, "棚卸しに失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *SpecimenHandler) CreateSpecimen(c *gin.Context) {
+	var req service.CreateSpecimenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	specimen, err := h.specimenService.CreateSpecimen(req)
+	if err != nil {
+		respondSpecimenError(c, err, "標本の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, specimen)
+}
+
+func (h *SpecimenHandler) UpdateSpecimen(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateSpecimenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	specimen, err := h.specimenService.UpdateSpecimen(id, req)
+	if err != nil {
+		respondSpecimenError(c, err, "標本の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, specimen)
+}
+
+func (h *SpecimenHandler) DeleteSpecimen(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.specimenService.DeleteSpecimen(id); err != nil {
+		respondSpecimenError(c, err, "標本の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddMakeSpecimen は標本に作製の履歴を追加するのだ
+func (h *SpecimenHandler) AddMakeSpecimen(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.CreateMakeSpecimenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	specimen, err := h.specimenService.AddMakeSpecimen(id, req)
+	if err != nil {
+		respondSpecimenError(c, err, "作製の履歴の追加に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, specimen)
+}
+
+func (h *SpecimenHandler) GetAllSpecimenMethods(c *gin.Context) {
+	methods, err := h.specimenService.GetAllSpecimenMethods()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作製方法の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, methods)
+}
+
+// AssignCatalogNumber は登録番号のない標本に番号を付けるのだ
+func (h *SpecimenHandler) AssignCatalogNumber(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	specimen, err := h.specimenService.AssignCatalogNumber(id)
+	if err != nil {
+		respondSpecimenError(c, err, "登録番号の採番に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, specimen)
+}
+
+func (h *SpecimenHandler) GetAllInstitutions(c *gin.Context) {
+	institutions, err := h.specimenService.GetAllInstitutions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "機関コードの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, institutions)
+}
+
+func (h *SpecimenHandler) GetAllCollections(c *gin.Context) {
+	collections, err := h.specimenService.GetAllCollections()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "コレクションの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, collections)
+}
+
+// UpdateCatalogPattern はコレクションの登録番号の形を変えるのだ
+func (h *SpecimenHandler) UpdateCatalogPattern(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateCatalogPatternRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	collection, err := h.specimenService.UpdateCatalogPattern(id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "コレクションが見つかりません"})
+		case errors.Is(err, service.ErrInvalidCatalogPattern):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登録番号の形の更新に失敗しました"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+func respondSpecimenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "標本が見つかりません"})
+	case errors.Is(err, service.ErrInvalidSpecimen), errors.Is(err, service.ErrInvalidScanCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCatalogNumberTaken), errors.Is(err, service.ErrCatalogNumberAssigned), errors.Is(err, service.ErrSpecimenOnLoan),
+		errors.Is(err, service.ErrSpecimenHasParts):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,189 @@
+// backend/internal/handler/storage_location_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type StorageLocationHandler struct {
+	storageLocationService service.StorageLocationService
+}
+
+func NewStorageLocationHandler(storageLocationService service.StorageLocationService) *StorageLocationHandler {
+	return &StorageLocationHandler{storageLocationService: storageLocationService}
+}
+
+// RegisterStorageLocationRoutes はルーターに置き場所と標本の移動のエンドポイントを登録するのだ
+func (h *StorageLocationHandler) RegisterStorageLocationRoutes(router *gin.RouterGroup) {
+	locations := router.Group("/storage_locations")
+	{
+		locations.GET("", h.ListStorageLocations)
+		locations.POST("", h.CreateStorageLocation)
+		locations.GET("/tree", h.GetStorageLocationTree)
+		locations.GET("/:id", h.GetStorageLocation)
+		locations.PUT("/:id", h.UpdateStorageLocation)
+		locations.DELETE("/:id", h.DeleteStorageLocation)
+	}
+
+	specimens := router.Group("/specimens")
+	{
+		specimens.POST("/move", h.MoveSpecimens)
+		specimens.POST("/:id/move", h.MoveSpecimen)
+		specimens.GET("/:id/movements", h.GetSpecimenMovements)
+	}
+}
+
+// ListStorageLocations は ?code=&level=&parent_id= で置き場所を探すのだ。条件がなければ一番上の置き場所を返すのだ
+func (h *StorageLocationHandler) ListStorageLocations(c *gin.Context) {
+	var req service.ListStorageLocationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	locations, err := h.storageLocationService.ListStorageLocations(req)
+	if err != nil {
+		respondStorageLocationError(c, err, "置き場所の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, locations)
+}
+
+func (h *StorageLocationHandler) GetStorageLocationTree(c *gin.Context) {
+	tree, err := h.storageLocationService.GetStorageLocationTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "置き場所の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetStorageLocation は置き場所を、上の置き場所・すぐ下の置き場所・標本の数つきで返すのだ
+func (h *StorageLocationHandler) GetStorageLocation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	location, err := h.storageLocationService.GetStorageLocation(id)
+	if err != nil {
+		respondStorageLocationError(c, err, "置き場所の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, location)
+}
+
+func (h *StorageLocationHandler) CreateStorageLocation(c *gin.Context) {
+	var req service.StorageLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	location, err := h.storageLocationService.CreateStorageLocation(req)
+	if err != nil {
+		respondStorageLocationError(c, err, "置き場所の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, location)
+}
+
+func (h *StorageLocationHandler) UpdateStorageLocation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.StorageLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	location, err := h.storageLocationService.UpdateStorageLocation(id, req)
+	if err != nil {
+		respondStorageLocationError(c, err, "置き場所の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, location)
+}
+
+func (h *StorageLocationHandler) DeleteStorageLocation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.storageLocationService.DeleteStorageLocation(id); err != nil {
+		respondStorageLocationError(c, err, "置き場所の削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MoveSpecimens は specimen_ids の標本をまとめて動かすのだ
+func (h *StorageLocationHandler) MoveSpecimens(c *gin.Context) {
+	var req service.MoveSpecimensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	h.moveSpecimens(c, req)
+}
+
+// MoveSpecimen はURLの標本を1件動かすのだ。ボディの specimen_ids は使わないのだ
+func (h *StorageLocationHandler) MoveSpecimen(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.MoveSpecimensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	req.SpecimenIDs = []uint{id}
+	h.moveSpecimens(c, req)
+}
+
+func (h *StorageLocationHandler) moveSpecimens(c *gin.Context, req service.MoveSpecimensRequest) {
+	result, err := h.storageLocationService.MoveSpecimens(req)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		respondStorageLocationError(c, err, "標本の移動に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSpecimenMovements は標本の移動の履歴を古い順に返すのだ
+func (h *StorageLocationHandler) GetSpecimenMovements(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	movements, err := h.storageLocationService.GetSpecimenMovements(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "標本が見つかりません"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移動の履歴の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
+
+func respondStorageLocationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "置き場所が見つかりません"})
+	case errors.Is(err, service.ErrInvalidStorageLocation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrStorageLocationTaken), errors.Is(err, service.ErrStorageLocationInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,44 @@
+// backend/internal/handler/text_search_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type TextSearchHandler struct {
+	textSearchService service.TextSearchService
+}
+
+func NewTextSearchHandler(textSearchService service.TextSearchService) *TextSearchHandler {
+	return &TextSearchHandler{textSearchService: textSearchService}
+}
+
+// RegisterTextSearchRoutes はルーターに全文検索のエンドポイントを登録するのだ
+// 項目ごとの絞り込みは /search で、自由な文章で探すときはこちらなのだ
+func (h *TextSearchHandler) RegisterTextSearchRoutes(router *gin.RouterGroup) {
+	router.GET("/search/text", h.Search)
+}
+
+// Search は ?q=&types=&limit=&offset= で全文検索するのだ
+func (h *TextSearchHandler) Search(c *gin.Context) {
+	var req service.TextSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search parameters"})
+		return
+	}
+
+	result, err := h.textSearchService.Search(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTextSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed search"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
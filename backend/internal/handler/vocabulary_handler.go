@@ -0,0 +1,130 @@
+// backend/internal/handler/vocabulary_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type VocabularyHandler struct {
+	vocabularyService service.VocabularyService
+}
+
+func NewVocabularyHandler(vocabularyService service.VocabularyService) *VocabularyHandler {
+	return &VocabularyHandler{vocabularyService: vocabularyService}
+}
+
+// RegisterVocabularyRoutes はルーターに語彙の管理のエンドポイントを登録するのだ
+// :kind は specimen-methods・institution-codes・collection-codes・observation-methods・file-types・languages なのだ
+func (h *VocabularyHandler) RegisterVocabularyRoutes(router *gin.RouterGroup) {
+	vocabularies := router.Group("/vocabularies")
+	{
+		vocabularies.GET("", h.GetKinds)
+		vocabularies.GET("/:kind", h.GetItems)
+		vocabularies.POST("/:kind", h.CreateItem)
+		vocabularies.GET("/:kind/:id", h.GetItem)
+		vocabularies.PUT("/:kind/:id", h.UpdateItem)
+		vocabularies.DELETE("/:kind/:id", h.DeleteItem)
+	}
+}
+
+// GetKinds は管理できる語彙の名前を返すのだ
+func (h *VocabularyHandler) GetKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, h.vocabularyService.GetKinds())
+}
+
+// GetItems は ?include_deprecated=true で使うのをやめた値も、?lang= でその言語の表示名つきで返すのだ
+func (h *VocabularyHandler) GetItems(c *gin.Context) {
+	var req service.ListVocabularyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	items, err := h.vocabularyService.GetItems(c.Param("kind"), req)
+	if err != nil {
+		respondVocabularyError(c, err, "語彙の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *VocabularyHandler) GetItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	item, err := h.vocabularyService.GetItem(c.Param("kind"), id)
+	if err != nil {
+		respondVocabularyError(c, err, "語彙の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *VocabularyHandler) CreateItem(c *gin.Context) {
+	var req service.VocabularyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	item, err := h.vocabularyService.CreateItem(c.Param("kind"), req)
+	if err != nil {
+		respondVocabularyError(c, err, "語彙の作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+func (h *VocabularyHandler) UpdateItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.VocabularyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	item, err := h.vocabularyService.UpdateItem(c.Param("kind"), id, req)
+	if err != nil {
+		respondVocabularyError(c, err, "語彙の更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteItem は使われていない値なら消して204を、使われている値なら使うのをやめた印を付けて200でその値を返すのだ
+func (h *VocabularyHandler) DeleteItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deprecated, err := h.vocabularyService.DeleteItem(c.Param("kind"), id)
+	if err != nil {
+		respondVocabularyError(c, err, "語彙の削除に失敗しました")
+		return
+	}
+	if deprecated != nil {
+		c.JSON(http.StatusOK, deprecated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondVocabularyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "語彙の値が見つかりません"})
+	case errors.Is(err, service.ErrUnknownVocabulary):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidVocabulary):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrVocabularyConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
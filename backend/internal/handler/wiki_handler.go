@@ -0,0 +1,223 @@
+// backend/internal/handler/wiki_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saku-730/specimen-web/backend/internal/service"
+)
+
+type WikiHandler struct {
+	wikiService service.WikiService
+}
+
+func NewWikiHandler(wikiService service.WikiService) *WikiHandler {
+	return &WikiHandler{wikiService: wikiService}
+}
+
+// RegisterWikiRoutes はルーターにWiki関連のエンドポイントを登録するのだ
+func (h *WikiHandler) RegisterWikiRoutes(router *gin.RouterGroup) {
+	wiki := router.Group("/wiki")
+	{
+		wiki.GET("", h.ListWikiPages)
+		wiki.POST("", h.CreateWikiPage)
+		wiki.GET("/:id", h.GetWikiPage)
+		wiki.PUT("/:id", h.UpdateWikiPage)
+		wiki.DELETE("/:id", h.DeleteWikiPage)
+
+		// 本文のHTML・版の履歴・差分・差し戻し
+		wiki.GET("/:id/html", h.RenderWikiPage)
+		wiki.GET("/:id/revisions", h.GetRevisions)
+		wiki.GET("/:id/revisions/:number", h.GetRevision)
+		wiki.GET("/:id/diff", h.DiffRevisions)
+		wiki.POST("/:id/revert", h.RevertWikiPage)
+	}
+}
+
+// ListWikiPages は ?title=&limit=&offset= でページの一覧を返すのだ
+func (h *WikiHandler) ListWikiPages(c *gin.Context) {
+	var req service.ListWikiPagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "クエリパラメータが正しくありません"})
+		return
+	}
+	list, err := h.wikiService.ListWikiPages(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Wikiページの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetWikiPage はページと最新の版、参照している手法の一覧を返すのだ
+func (h *WikiHandler) GetWikiPage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	page, err := h.wikiService.GetWikiPageDetail(id)
+	if err != nil {
+		respondWikiError(c, err, "Wikiページの取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateWikiPage はタイトルとMarkdownの本文でページを作るのだ
+func (h *WikiHandler) CreateWikiPage(c *gin.Context) {
+	var req service.CreateWikiPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	page, err := h.wikiService.CreateWikiPage(req)
+	if err != nil {
+		respondWikiError(c, err, "Wikiページの作成に失敗しました")
+		return
+	}
+	c.JSON(http.StatusCreated, page)
+}
+
+// UpdateWikiPage は編集内容を新しい版として保存するのだ
+func (h *WikiHandler) UpdateWikiPage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.UpdateWikiPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	page, err := h.wikiService.UpdateWikiPage(id, req)
+	if err != nil {
+		respondWikiError(c, err, "Wikiページの更新に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *WikiHandler) DeleteWikiPage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.wikiService.DeleteWikiPage(id); err != nil {
+		respondWikiError(c, err, "Wikiページの削除に失敗しました")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RenderWikiPage は本文をサニタイズ済みのHTMLで返すのだ。?revision= で過去の版も見られるのだ
+func (h *WikiHandler) RenderWikiPage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var revision *int
+	if r := c.Query("revision"); r != "" {
+		number, err := strconv.Atoi(r)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "revisionが正しくありません"})
+			return
+		}
+		revision = &number
+	}
+	rendered, err := h.wikiService.RenderWikiPage(id, revision)
+	if err != nil {
+		respondWikiError(c, err, "Wikiページの表示に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// GetRevisions は版の一覧を新しい順に返すのだ
+func (h *WikiHandler) GetRevisions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	revisions, err := h.wikiService.GetRevisions(id)
+	if err != nil {
+		respondWikiError(c, err, "版の一覧の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision は指定した版の本文を返すのだ
+func (h *WikiHandler) GetRevision(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "版番号が正しくありません"})
+		return
+	}
+	revision, err := h.wikiService.GetRevision(id, number)
+	if err != nil {
+		respondWikiError(c, err, "版の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffRevisions は ?from=&to= の2つの版の差分を返すのだ
+func (h *WikiHandler) DiffRevisions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fromとtoに版番号を指定してください"})
+		return
+	}
+	diff, err := h.wikiService.DiffRevisions(id, from, to)
+	if err != nil {
+		respondWikiError(c, err, "差分の取得に失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// RevertWikiPage は過去の版に戻すのだ (戻した内容が新しい版になるのだ)
+func (h *WikiHandler) RevertWikiPage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req service.RevertWikiPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が正しくありません"})
+		return
+	}
+	page, err := h.wikiService.RevertWikiPage(id, req)
+	if err != nil {
+		respondWikiError(c, err, "版の差し戻しに失敗しました")
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func respondWikiError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wikiページまたは版が見つかりません"})
+	case errors.Is(err, service.ErrInvalidWikiPage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "タイトルを入力してください"})
+	case errors.Is(err, service.ErrWikiPageInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "手法の手順書として使われているため削除できません: " + err.Error()})
+	case errors.Is(err, service.ErrWikiEditConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "他の人が先に編集を保存しました。最新の版を確認してください"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
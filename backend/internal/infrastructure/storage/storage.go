@@ -0,0 +1,56 @@
+// internal/infrastructure/storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/config"
+)
+
+// ErrObjectNotFound は保存先にファイルがないときのエラーなのだ
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo は保存されたファイルの情報なのだ
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Object は読み出し中のファイルなのだ。Seekできるので範囲指定(Range)のダウンロードに使えるのだ
+type Object interface {
+	io.ReadSeekCloser
+}
+
+// Storage は添付ファイルの保存先の契約書なのだ
+// ローカルのファイルシステムとS3互換ストレージ(MinIOなど)の実装があるのだ
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (Object, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStorage は設定の STORAGE_BACKEND を見て保存先を作るのだ
+func NewStorage(cfg *configs.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return NewLocalStorage(cfg.StorageLocalDir)
+	case "s3":
+		return NewS3Storage(context.Background(), S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("不明なストレージの種類です: %s", cfg.StorageBackend)
+	}
+}
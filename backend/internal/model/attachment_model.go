@@ -1,10 +1,19 @@
 // internal/model/attachment_models.go
 package model
 
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
 // FileType は "file_types" テーブルに対応するのだ
 type FileType struct {
-	FileTypeID uint   `gorm:"primaryKey" json:"file_type_id"`
-	TypeName   string `json:"type_name"`
+	FileTypeID uint           `gorm:"primaryKey" json:"file_type_id"`
+	TypeName   string         `json:"type_name"`
+	SortOrder  int            `json:"sort_order"`
+	Deprecated bool           `json:"deprecated"`
+	Labels     datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 }
 
 // FileExtension は "file_extensions" テーブルに対応するのだ
@@ -20,6 +29,46 @@ type Attachment struct {
 	FilePath     string `gorm:"not null" json:"file_path"`
 	ExtensionID  uint   `json:"extension_id"`
 	UserID       uint   `json:"user_id"`
+
+	OriginalName   string    `json:"original_name"`
+	ContentType    string    `json:"content_type"`
+	SizeBytes      int64     `json:"size_bytes"`
+	ChecksumSHA256 string    `gorm:"column:checksum_sha256" json:"checksum_sha256"`
+	CreatedAt      time.Time `gorm:"default:now()" json:"created_at"`
+
+	// 画像のときだけ入る、大きさとEXIFの撮影情報なのだ
+	Width                *int       `json:"width"`
+	Height               *int       `json:"height"`
+	CameraMake           string     `json:"camera_make"`
+	CameraModel          string     `json:"camera_model"`
+	TakenAt              *time.Time `json:"taken_at"`
+	TakenAtOffsetMinutes *int16     `json:"taken_at_offset_minutes"`
+	GPSLatitude          *float64   `gorm:"column:gps_latitude;type:numeric" json:"gps_latitude"`
+	GPSLongitude         *float64   `gorm:"column:gps_longitude;type:numeric" json:"gps_longitude"`
+	GPSAltitude          *float64   `gorm:"column:gps_altitude;type:numeric" json:"gps_altitude"`
+	GPSAccuracy          *float64   `gorm:"column:gps_accuracy;type:numeric" json:"gps_accuracy"`
+
+	// 公開のための権利情報と説明なのだ (Audubon Core に出力するのだ)
+	License       *string `json:"license"`
+	RightsHolder  string  `json:"rights_holder"`
+	Creator       string  `json:"creator"`
+	Caption       string  `json:"caption"`
+	SubjectPart   string  `json:"subject_part"`
+	CaptureDevice string  `json:"capture_device"`
+
+	// 関連
+	Extension  *FileExtension        `gorm:"foreignKey:ExtensionID" json:"extension,omitempty"`
+	Thumbnails []AttachmentThumbnail `gorm:"foreignKey:AttachmentID" json:"thumbnails,omitempty"`
+}
+
+// AttachmentThumbnail は "attachment_thumbnails" テーブルに対応するのだ
+type AttachmentThumbnail struct {
+	ThumbnailID  uint   `gorm:"primaryKey" json:"thumbnail_id"`
+	AttachmentID uint   `json:"attachment_id"`
+	SizeName     string `json:"size_name"`
+	FilePath     string `json:"file_path"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 // AttachmentGroup は "attachment_goup" (groupのtypo?) 中間テーブルに対応するのだ
@@ -28,3 +77,7 @@ type AttachmentGroup struct {
 	AttachmentID uint `gorm:"primaryKey" json:"attachment_id"`
 	Priority     *int `json:"priority"`
 }
+
+func (AttachmentGroup) TableName() string {
+	return "attachment_goup"
+}
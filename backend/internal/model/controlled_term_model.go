@@ -0,0 +1,17 @@
+// internal/model/controlled_term_model.go
+package model
+
+import "gorm.io/datatypes"
+
+// ControlledTerm は "controlled_terms" テーブルに対応するのだ
+// Field は発生情報の列 (sex・lifestage) で、Value がその列に入れる値なのだ
+type ControlledTerm struct {
+	TermID     uint           `gorm:"primaryKey" json:"term_id"`
+	Field      string         `gorm:"not null" json:"field"`
+	Value      string         `gorm:"not null" json:"value"`
+	DwcValue   *string        `json:"dwc_value"` // Darwin Coreで出すときの値なのだ。nilなら出さないのだ
+	Synonyms   datatypes.JSON `gorm:"type:jsonb" json:"synonyms"`
+	Labels     datatypes.JSON `gorm:"type:jsonb" json:"labels"`
+	SortOrder  int            `json:"sort_order"`
+	Deprecated bool           `json:"deprecated"`
+}
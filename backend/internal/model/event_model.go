@@ -0,0 +1,51 @@
+// internal/model/event_model.go
+package model
+
+import "time"
+
+// 採集のまとまりの段なのだ。上から順に並べてあるのだ
+// sample は1つの罠の一晩や1回のすくい取りのような、いちばん小さい採集の単位なのだ
+const (
+	EventTypeSurvey    = "survey"
+	EventTypeSiteVisit = "site_visit"
+	EventTypeSample    = "sample"
+)
+
+// EventTypes は採集のまとまりの段を上から順に並べたものなのだ
+var EventTypes = []string{EventTypeSurvey, EventTypeSiteVisit, EventTypeSample}
+
+// Event は "events" テーブルに対応するのだ
+// SampleSizeValue と SampleSizeUnit は努力量 (例: 3 trap-nights) で、どちらもあるかどちらもないかなのだ
+type Event struct {
+	EventID          uint       `gorm:"primaryKey" json:"event_id"`
+	ParentEventID    *uint      `json:"parent_event_id"`
+	EventType        string     `json:"event_type"`
+	EventCode        *string    `json:"event_code"`
+	Name             *string    `json:"name"`
+	ProjectID        *uint      `json:"project_id"`
+	PlaceID          *uint      `json:"place_id"`
+	SamplingProtocol *string    `json:"sampling_protocol"`
+	SampleSizeValue  *float64   `gorm:"type:numeric" json:"sample_size_value"`
+	SampleSizeUnit   *string    `json:"sample_size_unit"`
+	SamplingEffort   *string    `json:"sampling_effort"`
+	StartAt          *time.Time `json:"start_at"`
+	EndAt            *time.Time `json:"end_at"`
+	Timezone         int16      `gorm:"not null" json:"timezone"`
+	Note             *string    `json:"note"`
+	CreatedAt        time.Time  `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	Project      *Project           `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	Place        *Place             `gorm:"foreignKey:PlaceID" json:"place,omitempty"`
+	Participants []EventParticipant `gorm:"foreignKey:EventID" json:"participants,omitempty"`
+}
+
+// EventParticipant は "event_participants" テーブルに対応するのだ
+type EventParticipant struct {
+	EventID uint    `gorm:"primaryKey" json:"event_id"`
+	UserID  uint    `gorm:"primaryKey" json:"user_id"`
+	Role    *string `json:"role"`
+
+	// 関連
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
@@ -9,10 +9,12 @@ type Identification struct {
 	UserID           uint      `json:"user_id"`
 	OccurrenceID     uint      `json:"occurrence_id"`
 	SourceInfo       string    `json:"source_info"`
+	ClassificationID *uint     `json:"classification_id"` // この同定で決めた分類なのだ
 	IdentificatedAt  time.Time `gorm:"default:now()" json:"identificated_at"`
 	Timezone         int16     `gorm:"not null" json:"timezone"`
 
 	// 関連
-	User         User         `gorm:"foreignKey:UserID" json:"user"`
-	Occurrence   Occurrence   `gorm:"foreignKey:OccurrenceID" json:"occurrence"`
+	User               User                `gorm:"foreignKey:UserID" json:"user"`
+	Occurrence         Occurrence          `gorm:"foreignKey:OccurrenceID" json:"occurrence"`
+	ClassificationJSON *ClassificationJSON `gorm:"foreignKey:ClassificationID" json:"classification,omitempty"`
 }
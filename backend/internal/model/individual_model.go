@@ -0,0 +1,18 @@
+// internal/model/individual_model.go
+package model
+
+import "time"
+
+// Individual は "individuals" テーブルに対応するのだ
+// 標識再捕獲で同じ個体だと分かる発生情報は、occurrence.individual_ID でこの個体を指すのだ
+type Individual struct {
+	IndividualID uint      `gorm:"primaryKey" json:"individual_id"`
+	ProjectID    *uint     `json:"project_id"`
+	MarkCode     string    `json:"mark_code"`
+	MarkType     *string   `json:"mark_type"`
+	Note         *string   `json:"note"`
+	CreatedAt    time.Time `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	Project *Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
@@ -0,0 +1,49 @@
+// internal/model/loan_model.go
+package model
+
+import "time"
+
+// 標本が館内にあるか貸し出し中かなのだ
+const (
+	SpecimenStatusInHouse = "in_house"
+	SpecimenStatusOnLoan  = "on_loan"
+)
+
+// Loan は "loans" テーブルに対応するのだ
+type Loan struct {
+	LoanID                uint       `gorm:"primaryKey" json:"loan_id"`
+	BorrowerInstitutionID uint       `json:"borrower_institution_id"`
+	ContactName           string     `json:"contact_name"`
+	ContactEmail          *string    `json:"contact_email"`
+	Purpose               *string    `json:"purpose"`
+	LoanDate              time.Time  `json:"loan_date"`
+	DueDate               *time.Time `json:"due_date"`
+	ClosedDate            *time.Time `json:"closed_date"` // 全部返ってきた日なのだ。NULLならまだ貸し出し中なのだ
+	Note                  *string    `json:"note"`
+	UserID                *uint      `json:"user_id"`
+	CreatedAt             time.Time  `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	BorrowerInstitution InstitutionIDCode `gorm:"foreignKey:BorrowerInstitutionID" json:"borrower_institution"`
+	Specimens           []LoanSpecimen    `gorm:"foreignKey:LoanID" json:"specimens,omitempty"`
+	User                *User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (Loan) TableName() string {
+	return "loans"
+}
+
+// LoanSpecimen は "loan_specimens" テーブルに対応するのだ
+type LoanSpecimen struct {
+	LoanID       uint       `gorm:"primaryKey" json:"loan_id"`
+	SpecimenID   uint       `gorm:"primaryKey" json:"specimen_id"`
+	ReturnedDate *time.Time `json:"returned_date"` // NULLならまだ返ってきていないのだ
+	ReturnNote   *string    `json:"return_note"`
+
+	// 関連
+	Specimen *Specimen `gorm:"foreignKey:SpecimenID" json:"specimen,omitempty"`
+}
+
+func (LoanSpecimen) TableName() string {
+	return "loan_specimens"
+}
@@ -0,0 +1,41 @@
+// internal/model/measurement_model.go
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// MeasurementType は "measurement_types" テーブルに対応するのだ
+// Unit はその種類の値をそろえる単位で、数でない形質 (色など) は nil なのだ
+type MeasurementType struct {
+	MeasurementTypeID uint           `gorm:"primaryKey" json:"measurement_type_id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Unit              *string        `json:"unit"`
+	SortOrder         int            `json:"sort_order"`
+	Deprecated        bool           `json:"deprecated"`
+	Labels            datatypes.JSON `gorm:"type:jsonb" json:"labels"`
+}
+
+// Measurement は "measurements" テーブルに対応するのだ
+// Value と Unit は入力されたまま、ValueNumber は種類の単位にそろえた数なのだ
+type Measurement struct {
+	MeasurementID     uint       `gorm:"primaryKey" json:"measurement_id"`
+	OccurrenceID      uint       `json:"occurrence_id"`
+	SpecimenID        *uint      `json:"specimen_id"`
+	MeasurementTypeID uint       `json:"measurement_type_id"`
+	Value             string     `json:"value"`
+	Unit              *string    `json:"unit"`
+	ValueNumber       *float64   `gorm:"type:numeric" json:"value_number"`
+	Accuracy          *string    `json:"accuracy"`
+	Method            *string    `json:"method"`
+	MeasuredByUserID  *uint      `gorm:"column:measured_by_user_id" json:"measured_by_user_id"`
+	MeasuredOn        *time.Time `json:"measured_on"`
+	Remarks           *string    `json:"remarks"`
+	CreatedAt         time.Time  `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	MeasurementType *MeasurementType `gorm:"foreignKey:MeasurementTypeID" json:"measurement_type,omitempty"`
+	MeasuredBy      *User            `gorm:"foreignKey:MeasuredByUserID" json:"measured_by,omitempty"`
+}
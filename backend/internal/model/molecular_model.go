@@ -0,0 +1,60 @@
+// internal/model/molecular_model.go
+package model
+
+import "time"
+
+// 試料の種類なのだ。組織 > DNA抽出液 > PCR産物 の順に取り分けるのだ
+const (
+	SampleTypeTissue     = "tissue"
+	SampleTypeDNAExtract = "dna_extract"
+	SampleTypePCRProduct = "pcr_product"
+)
+
+// SampleTypes は試料の種類を取り分ける順に並べたものなのだ
+var SampleTypes = []string{SampleTypeTissue, SampleTypeDNAExtract, SampleTypePCRProduct}
+
+// 配列の登録先なのだ
+const (
+	AccessionSourceGenBank = "genbank"
+	AccessionSourceBOLD    = "bold"
+)
+
+// MolecularSample は "molecular_samples" テーブルに対応するのだ
+type MolecularSample struct {
+	SampleID             uint       `gorm:"primaryKey" json:"sample_id"`
+	SpecimenID           uint       `json:"specimen_id"`
+	ParentSampleID       *uint      `json:"parent_sample_id"`
+	SampleType           string     `json:"sample_type"`
+	SampleCode           *string    `json:"sample_code"`
+	StorageLocationID    *uint      `json:"storage_location_id"`
+	ConcentrationNgPerUl *float64   `gorm:"column:concentration_ng_per_ul" json:"concentration_ng_per_ul"`
+	VolumeUl             *float64   `gorm:"column:volume_ul" json:"volume_ul"`
+	ExtractionMethod     *string    `json:"extraction_method"`
+	UserID               *uint      `json:"user_id"`
+	PreparedDate         *time.Time `json:"prepared_date"`
+	Note                 *string    `json:"note"`
+	CreatedAt            time.Time  `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	Specimen        *Specimen        `gorm:"foreignKey:SpecimenID" json:"specimen,omitempty"`
+	StorageLocation *StorageLocation `gorm:"foreignKey:StorageLocationID" json:"storage_location,omitempty"`
+	User            *User            `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Sequences       []Sequence       `gorm:"foreignKey:SampleID" json:"sequences,omitempty"`
+}
+
+// Sequence は "sequences" テーブルに対応するのだ
+type Sequence struct {
+	SequenceID      uint      `gorm:"primaryKey" json:"sequence_id"`
+	SampleID        uint      `json:"sample_id"`
+	Marker          string    `json:"marker"`
+	AccessionSource *string   `json:"accession_source"`
+	Accession       *string   `json:"accession"`
+	Definition      *string   `json:"definition"`
+	Sequence        string    `json:"sequence"`
+	SequenceLength  int       `gorm:"->" json:"sequence_length"` // DBで数える列なので書き込まないのだ
+	UserID          *uint     `json:"user_id"`
+	CreatedAt       time.Time `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	Sample *MolecularSample `gorm:"foreignKey:SampleID" json:"sample,omitempty"`
+}
@@ -1,13 +1,20 @@
 // internal/model/observation_models.go
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // ObservationMethod は "observation_methods" テーブルに対応するのだ
 type ObservationMethod struct {
-	ObservationMethodID uint   `gorm:"primaryKey" json:"observation_method_id"`
-	MethodCommonName    string `json:"method_common_name"`
-	PageID              uint   `gorm:"column:pageid" json:"page_id"` // SQLのカラム名が小文字なので合わせる
+	ObservationMethodID uint           `gorm:"primaryKey" json:"observation_method_id"`
+	MethodCommonName    string         `json:"method_common_name"`
+	PageID              uint           `gorm:"column:pageid" json:"page_id"` // SQLのカラム名が小文字なので合わせる
+	SortOrder           int            `json:"sort_order"`
+	Deprecated          bool           `json:"deprecated"`
+	Labels              datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 
 	// 関連
 	WikiPage WikiPage `gorm:"foreignKey:PageID" json:"wiki_page"`
@@ -8,9 +8,12 @@ import (
 
 // Language は "language" テーブルに対応するのだ
 type Language struct {
-	LanguageID      uint   `gorm:"primaryKey" json:"language_id"`
-	LanguageShort   string `json:"language_short"`
-	LanguageCommon  string `json:"language_common"`
+	LanguageID     uint           `gorm:"primaryKey" json:"language_id"`
+	LanguageShort  string         `json:"language_short"`
+	LanguageCommon string         `json:"language_common"`
+	SortOrder      int            `json:"sort_order"`
+	Deprecated     bool           `json:"deprecated"`
+	Labels         datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 }
 
 // ClassificationJSON は "classification_json" テーブルに対応するのだ
@@ -28,7 +31,9 @@ type Occurrence struct {
 	OccurrenceID      uint      `gorm:"primaryKey" json:"occurrence_id"`
 	ProjectID         *uint     `json:"project_id"`
 	UserID            uint      `json:"user_id"`
-	IndividualID      *int      `json:"individual_id"`
+	IndividualID      *uint     `json:"individual_id"`
+	EventID           *uint     `json:"event_id"`
+	IndividualCount   *int      `json:"individual_count"` // 数えた個体の数なのだ。nilは1匹なのだ
 	Lifestage         string    `json:"lifestage"`
 	Sex               string    `json:"sex"`
 	ClassificationID  uint      `json:"classification_id"`
@@ -41,7 +46,16 @@ type Occurrence struct {
 	Timezone          int16     `gorm:"not null" json:"timezone"`
 
 	// 関連
-	Attachments []Attachment `gorm:"many2many:attachment_group;" json:"attachments"` // 多対多
+	Attachments        []Attachment        `gorm:"many2many:attachment_goup;" json:"attachments"` // 多対多
+	User               *User               `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Project            *Project            `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	ClassificationJSON *ClassificationJSON `gorm:"foreignKey:ClassificationID" json:"classification,omitempty"`
+	Place              *Place              `gorm:"foreignKey:PlaceID" json:"place,omitempty"`
+	Individual         *Individual         `gorm:"foreignKey:IndividualID" json:"individual,omitempty"`
+	Specimens          []Specimen          `gorm:"foreignKey:OccurrenceID" json:"specimens,omitempty"`
+	Observations       []Observation       `gorm:"foreignKey:OccurrenceID" json:"observations,omitempty"`
+	Identifications    []Identification    `gorm:"foreignKey:OccurrenceID" json:"identifications,omitempty"`
+	Measurements       []Measurement       `gorm:"foreignKey:OccurrenceID" json:"measurements,omitempty"`
 }
 
 func (Occurrence) TableName() string {
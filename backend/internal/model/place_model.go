@@ -2,6 +2,8 @@
 package model
 
 import (
+	"time"
+
 	"gorm.io/datatypes" // JSON型のためにインポートするのだ
 )
 
@@ -20,6 +22,51 @@ type Place struct {
 	PlaceID       uint    `gorm:"primaryKey" json:"place_id"`
 	Coordinates   *string `gorm:"type:geography(Point,4326)" json:"coordinates"` // PostGIS型はstringで受けて、別途ライブラリで処理するのが一般的なのだ
 	PlaceNameID   uint    `json:"place_name_id"`
-	Accuracy      float64 `gorm:"type:numeric" json:"accuracy"`
+	Accuracy      float64 `gorm:"type:numeric" json:"accuracy"` // 誤差半径(m)なのだ
+
+	// 野帳に書かれたままの座標なのだ (Darwin Core の verbatimCoordinates など)
+	VerbatimCoordinates      string `json:"verbatim_coordinates"`
+	VerbatimCoordinateSystem string `json:"verbatim_coordinate_system"`
+	VerbatimDatum            string `json:"verbatim_datum"`
+
+	// 標高・水深(m)と生息環境なのだ
+	MinimumElevationInMeters *float64 `gorm:"type:numeric" json:"minimum_elevation_in_meters"`
+	MaximumElevationInMeters *float64 `gorm:"type:numeric" json:"maximum_elevation_in_meters"`
+	MinimumDepthInMeters     *float64 `gorm:"type:numeric" json:"minimum_depth_in_meters"`
+	MaximumDepthInMeters     *float64 `gorm:"type:numeric" json:"maximum_depth_in_meters"`
+	Habitat                  string   `json:"habitat"`
+	VerbatimLocality         string   `json:"verbatim_locality"`
+
+	// 誰がどうやって座標を付けたかの記録なのだ
+	GeoreferenceProtocol string     `json:"georeference_protocol"`
+	GeoreferencedBy      string     `json:"georeferenced_by"`
+	GeoreferencedDate    *time.Time `gorm:"type:date" json:"georeferenced_date"`
+
+	// coordinates から取り出した緯度経度なのだ (読み込み専用、SELECTで指定したときだけ入るのだ)
+	Latitude  *float64 `gorm:"->" json:"latitude,omitempty"`
+	Longitude *float64 `gorm:"->" json:"longitude,omitempty"`
+
+	// 関連
+	PlaceName *PlaceNameJSON `gorm:"foreignKey:PlaceNameID" json:"place_name,omitempty"`
 }
 
+// 行政区画のレベルなのだ
+const (
+	AdminLevelCountry      int16 = 0
+	AdminLevelPrefecture   int16 = 1
+	AdminLevelMunicipality int16 = 2
+)
+
+// AdminBoundary は "admin_boundaries" テーブルに対応するのだ
+// geom列(PostGISのMultiPolygon)はリポジトリでSQLを直接書いて扱うので構造体には持たないのだ
+type AdminBoundary struct {
+	BoundaryID uint   `gorm:"primaryKey" json:"boundary_id"`
+	AdminLevel int16  `gorm:"not null" json:"admin_level"`
+	Name       string `gorm:"not null" json:"name"`
+	Code       string `json:"code"`
+	Source     string `json:"source"`
+}
+
+func (AdminBoundary) TableName() string {
+	return "admin_boundaries"
+}
@@ -6,11 +6,14 @@ import "time"
 type Project struct {
 	ProjectID    uint       `gorm:"primaryKey" json:"project_id"`
 	ProjectName  string     `gorm:"not null" json:"project_name"`
-	Description  string     `json:"description"` // SQLのdisscriptionはtypoだと思うので修正
+	Description  string     `json:"description"` // SQLのdisscriptionはtypoだったので V0.0.21 で直したのだ
 	StartDay     *time.Time `json:"start_day"` // NULLを許容する日付はポインタ型にするのだ
 	FinishedDay  *time.Time `json:"finished_day"`
-	UpdatedDay   *time.Time `json:"updated_day"`
+	UpdatedDay   *time.Time `gorm:"->" json:"updated_day"` // DBのトリガーが付けるので書き込まないのだ
 	Note         string     `json:"note"`
+
+	// 関連
+	ProjectMembers []ProjectMember `gorm:"foreignKey:ProjectID" json:"project_members,omitempty"`
 }
 
 type ProjectMember struct {
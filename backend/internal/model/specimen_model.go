@@ -1,12 +1,19 @@
 // internal/model/specimen_models.go
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // InstitutionIDCode は "institution_ID_code" テーブルに対応するのだ
 type InstitutionIDCode struct {
-	InstitutionID   uint   `gorm:"primaryKey" json:"institution_id"`
-	InstitutionCode string `json:"institution_code"`
+	InstitutionID   uint           `gorm:"primaryKey" json:"institution_id"`
+	InstitutionCode string         `json:"institution_code"`
+	SortOrder       int            `json:"sort_order"`
+	Deprecated      bool           `json:"deprecated"`
+	Labels          datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 }
 
 func (InstitutionIDCode) TableName() string {
@@ -17,6 +24,11 @@ func (InstitutionIDCode) TableName() string {
 type CollectionIDCode struct {
 	CollectionID   uint   `gorm:"primaryKey" json:"collection_id"`
 	CollectionCode string `json:"collection_code"`
+	// CatalogNumberPattern は登録番号の形なのだ (例: {institution}-{collection}-{yyyy}-{seq:05})
+	CatalogNumberPattern *string        `json:"catalog_number_pattern"`
+	SortOrder            int            `json:"sort_order"`
+	Deprecated           bool           `json:"deprecated"`
+	Labels               datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 }
 
 func (CollectionIDCode) TableName() string {
@@ -25,9 +37,12 @@ func (CollectionIDCode) TableName() string {
 
 // SpecimenMethod は "specimen_methods" テーブルに対応するのだ
 type SpecimenMethod struct {
-	SpecimenMethodsID  uint   `gorm:"primaryKey" json:"specimen_methods_id"`
-	MethodCommonName string `json:"method_common_name"`
-	PageID             uint   `json:"page_id"`
+	SpecimenMethodsID uint           `gorm:"primaryKey" json:"specimen_methods_id"`
+	MethodCommonName  string         `json:"method_common_name"`
+	PageID            uint           `json:"page_id"`
+	SortOrder         int            `json:"sort_order"`
+	Deprecated        bool           `json:"deprecated"`
+	Labels            datatypes.JSON `gorm:"type:jsonb" json:"labels"`
 
 	// 関連
 	WikiPage WikiPage `gorm:"foreignKey:PageID" json:"wiki_page"`
@@ -40,18 +55,35 @@ type Specimen struct {
 	SpecimenMethodID  *uint `json:"specimen_method_id"`
 	InstitutionID     *uint `json:"institution_id"`
 	CollectionID      *uint `gorm:"column:collection_id" json:"collection_id"` // SQLのカラム名が小文字なので合わせる
+	CatalogNumber     *string `json:"catalog_number"` // 登録番号なのだ。一度付けたら変えないのだ
+	StorageLocationID *uint   `json:"storage_location_id"` // 今ある場所なのだ。動かすときは履歴を残すので直接は書き換えないのだ
+	Status            string  `gorm:"default:in_house" json:"status"` // 館内にあるか貸し出し中かなのだ。貸し出しと返却で書き換えるのだ
+	Part              *string `json:"part"` // 部位なのだ (例: whole organism, genitalia, tissue)
+	ParentSpecimenID  *uint   `json:"parent_specimen_id"` // 取り分ける前の標本なのだ
 
 	// 関連
 	Occurrence       Occurrence        `gorm:"foreignKey:OccurrenceID" json:"occurrence"`
 	SpecimenMethod   SpecimenMethod    `gorm:"foreignKey:SpecimenMethodID" json:"specimen_method"`
 	InstitutionIDCode InstitutionIDCode `gorm:"foreignKey:InstitutionID" json:"institution_id_code"`
 	CollectionIDCode  CollectionIDCode  `gorm:"foreignKey:CollectionID" json:"collection_id_code"`
+	MakeSpecimens     []MakeSpecimen    `gorm:"foreignKey:SpecimenID" json:"make_specimens,omitempty"` // 標本作製の履歴なのだ
+	StorageLocation   *StorageLocation  `gorm:"foreignKey:StorageLocationID" json:"storage_location,omitempty"`
 }
 
 func (Specimen) TableName() string {
 	return "specimen"
 }
 
+// 作製の進み具合なのだ
+const (
+	PreparationStatusPending    = "pending"
+	PreparationStatusInProgress = "in_progress"
+	PreparationStatusDone       = "done"
+)
+
+// PreparationStatuses は作製の進み具合を順に並べたものなのだ
+var PreparationStatuses = []string{PreparationStatusPending, PreparationStatusInProgress, PreparationStatusDone}
+
 // MakeSpecimen は "make_specimen" テーブルに対応するのだ
 type MakeSpecimen struct {
 	MakeSpecimenID   uint      `gorm:"primaryKey" json:"make_specimen_id"`
@@ -62,11 +94,14 @@ type MakeSpecimen struct {
 	SpecimenMethodID *uint      `json:"specimen_method_id"`
 	CreatedAt        time.Time `gorm:"default:now()" json:"created_at"`
 	Timezone         int16     `gorm:"not null" json:"timezone"`
+	Status           string     `gorm:"default:done" json:"status"` // pending・in_progress・done なのだ
+	Note             *string    `json:"note"`
 
 	// 関連
-	Occurrence     Occurrence     `gorm:"foreignKey:OccurrenceID" json:"occurrence"`
+	// 標本の履歴として返すときは同じ標本と発生情報を繰り返さないように、読み込んだときだけ出すのだ
+	Occurrence     *Occurrence    `gorm:"foreignKey:OccurrenceID" json:"occurrence,omitempty"`
 	User           User           `gorm:"foreignKey:UserID" json:"user"`
-	Specimen       Specimen       `gorm:"foreignKey:SpecimenID" json:"specimen"`
+	Specimen       *Specimen      `gorm:"foreignKey:SpecimenID" json:"specimen,omitempty"`
 	SpecimenMethod SpecimenMethod `gorm:"foreignKey:SpecimenMethodID" json:"specimen_method"`
 }
 
@@ -0,0 +1,44 @@
+// internal/model/storage_location_model.go
+package model
+
+import "time"
+
+// 置き場所の段なのだ。上から順に並べてあるのだ
+const (
+	StorageLevelBuilding = "building"
+	StorageLevelRoom     = "room"
+	StorageLevelCabinet  = "cabinet"
+	StorageLevelDrawer   = "drawer"
+	StorageLevelUnitTray = "unit_tray"
+)
+
+// StorageLevels は置き場所の段を上から順に並べたものなのだ
+var StorageLevels = []string{StorageLevelBuilding, StorageLevelRoom, StorageLevelCabinet, StorageLevelDrawer, StorageLevelUnitTray}
+
+// StorageLocation は "storage_locations" テーブルに対応するのだ
+type StorageLocation struct {
+	StorageLocationID uint      `gorm:"primaryKey" json:"storage_location_id"`
+	ParentID          *uint     `json:"parent_id"`
+	Level             string    `json:"level"`
+	Code              string    `json:"code"`
+	Name              *string   `json:"name"`
+	Note              *string   `json:"note"`
+	CreatedAt         time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+// SpecimenMovement は "specimen_movements" テーブルに対応するのだ
+type SpecimenMovement struct {
+	SpecimenMovementID uint      `gorm:"primaryKey" json:"specimen_movement_id"`
+	SpecimenID         uint      `json:"specimen_id"`
+	FromLocationID     *uint     `json:"from_location_id"`
+	ToLocationID       *uint     `json:"to_location_id"`
+	UserID             *uint     `json:"user_id"`
+	MovedAt            time.Time `json:"moved_at"`
+	Timezone           int16     `gorm:"not null" json:"timezone"`
+	Note               *string   `json:"note"`
+
+	// 関連
+	FromLocation *StorageLocation `gorm:"foreignKey:FromLocationID" json:"from_location,omitempty"`
+	ToLocation   *StorageLocation `gorm:"foreignKey:ToLocationID" json:"to_location,omitempty"`
+	User         *User            `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
@@ -5,10 +5,30 @@ import "time"
 
 // WikiPage は "wiki_pages" テーブルに対応するのだ
 type WikiPage struct {
-	PageID      uint      `gorm:"primaryKey" json:"page_id"`
-	Title       string    `json:"title"`
-	UserID      uint      `json:"user_id"`
-	CreatedDate time.Time `gorm:"default:now()" json:"created_date"`
+	PageID      uint       `gorm:"primaryKey" json:"page_id"`
+	Title       string     `json:"title"`
+	UserID      uint       `json:"user_id"`
+	CreatedDate time.Time  `gorm:"default:now()" json:"created_date"`
 	UpdatedDate *time.Time `json:"updated_date"`
-	ContentPath string    `json:"content_path"`
+	ContentPath string     `json:"content_path"` // 本文をDBに持つ前の名残なのだ。新しいページでは使わないのだ
+
+	// 最新の版なのだ。本文はここから読むのだ
+	CurrentRevisionID *uint         `json:"current_revision_id"`
+	CurrentRevision   *WikiRevision `gorm:"foreignKey:CurrentRevisionID" json:"current_revision,omitempty"`
+}
+
+// WikiRevision は "wiki_revisions" テーブルに対応するのだ
+type WikiRevision struct {
+	RevisionID     uint      `gorm:"primaryKey" json:"revision_id"`
+	PageID         uint      `json:"page_id"`
+	RevisionNumber int       `json:"revision_number"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	UserID         *uint     `json:"user_id"`
+	Summary        string    `json:"summary"`
+	RevertedTo     *int      `json:"reverted_to"`
+	CreatedAt      time.Time `gorm:"default:now()" json:"created_at"`
+
+	// 関連
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
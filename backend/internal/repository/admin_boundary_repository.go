@@ -0,0 +1,88 @@
+// backend/internal/repository/admin_boundary_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// AdminBoundaryRepository は行政区画ポリゴン関連のデータ操作の契約書なのだ
+type AdminBoundaryRepository interface {
+	FindContainingPoint(wkt string) ([]model.AdminBoundary, error)
+	FindContainingPlace(placeID uint) ([]model.AdminBoundary, error)
+	FindNamesInText(text string) ([]model.AdminBoundary, error)
+	CreateFromGeoJSON(tx *gorm.DB, boundary *model.AdminBoundary, geometry string) (*model.AdminBoundary, error)
+	DeleteByLevelAndSource(tx *gorm.DB, level int16, source string) error
+}
+
+type adminBoundaryRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminBoundaryRepository は新しいリポジトリを生成するのだ
+func NewAdminBoundaryRepository(db *gorm.DB) AdminBoundaryRepository {
+	return &adminBoundaryRepository{db: db}
+}
+
+// FindContainingPoint は "POINT(lon lat)" 形式の座標を含む行政区画をレベル順に取得するのだ
+func (r *adminBoundaryRepository) FindContainingPoint(wkt string) ([]model.AdminBoundary, error) {
+	var boundaries []model.AdminBoundary
+	err := r.db.Where("ST_Covers(geom, ST_GeomFromText(?, 4326))", wkt).
+		Order("admin_level").
+		Find(&boundaries).Error
+	if err != nil {
+		return nil, err
+	}
+	return boundaries, nil
+}
+
+// FindContainingPlace は places.coordinates を含む行政区画をレベル順に取得するのだ
+func (r *adminBoundaryRepository) FindContainingPlace(placeID uint) ([]model.AdminBoundary, error) {
+	var boundaries []model.AdminBoundary
+	err := r.db.Model(&model.AdminBoundary{}).
+		Select("admin_boundaries.*").
+		Joins("JOIN places ON ST_Covers(admin_boundaries.geom, places.coordinates::geometry)").
+		Where("places.place_id = ?", placeID).
+		Order("admin_boundaries.admin_level").
+		Find(&boundaries).Error
+	if err != nil {
+		return nil, err
+	}
+	return boundaries, nil
+}
+
+// FindNamesInText は入力された地名の文字列に名前が含まれている行政区画を取得するのだ
+// 入力地名と座標の食い違いを見つけるために使うのだ
+func (r *adminBoundaryRepository) FindNamesInText(text string) ([]model.AdminBoundary, error) {
+	var boundaries []model.AdminBoundary
+	err := r.db.Select("boundary_id", "admin_level", "name", "code", "source").
+		Where("strpos(?, name) > 0", text).
+		Order("admin_level").
+		Find(&boundaries).Error
+	if err != nil {
+		return nil, err
+	}
+	return boundaries, nil
+}
+
+// CreateFromGeoJSON はGeoJSONのジオメトリから行政区画を登録するのだ
+// リングだけのMultiLineStringでもST_BuildAreaで穴あきポリゴンに組み立てるのだ
+func (r *adminBoundaryRepository) CreateFromGeoJSON(tx *gorm.DB, boundary *model.AdminBoundary, geometry string) (*model.AdminBoundary, error) {
+	err := tx.Raw(`INSERT INTO admin_boundaries (admin_level, name, code, source, geom)
+		VALUES (?, ?, ?, ?, ST_Multi(ST_BuildArea(ST_SetSRID(ST_GeomFromGeoJSON(?), 4326))))
+		RETURNING boundary_id`,
+		boundary.AdminLevel, boundary.Name, boundary.Code, boundary.Source, geometry).
+		Scan(&boundary.BoundaryID).Error
+	if err != nil {
+		return nil, err
+	}
+	return boundary, nil
+}
+
+// DeleteByLevelAndSource は同じ出典・レベルの行政区画をまとめて削除するのだ(再読み込み用)
+func (r *adminBoundaryRepository) DeleteByLevelAndSource(tx *gorm.DB, level int16, source string) error {
+	if err := tx.Where("admin_level = ? AND source = ?", level, source).Delete(&model.AdminBoundary{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
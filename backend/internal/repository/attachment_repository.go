@@ -0,0 +1,143 @@
+// backend/internal/repository/attachment_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// AttachmentRepository は添付ファイル関連のデータ操作の契約書なのだ
+type AttachmentRepository interface {
+	FindByID(id uint) (*model.Attachment, error)
+	FindByOccurrence(occurrenceID uint) ([]OccurrenceAttachment, error)
+	FindExtensionByText(ext string) (*model.FileExtension, error)
+	Create(tx *gorm.DB, attachment *model.Attachment) (*model.Attachment, error)
+	UpdateMetadata(tx *gorm.DB, attachment *model.Attachment) error
+	Delete(tx *gorm.DB, id uint) error
+	AddToOccurrence(tx *gorm.DB, group *model.AttachmentGroup) error
+	UpdatePriority(tx *gorm.DB, occurrenceID, attachmentID uint, priority *int) error
+	RemoveFromGroups(tx *gorm.DB, attachmentID uint) error
+	CreateThumbnails(tx *gorm.DB, thumbnails []model.AttachmentThumbnail) error
+}
+
+// OccurrenceAttachment は発生情報に紐づいた添付ファイルと、その表示順なのだ
+type OccurrenceAttachment struct {
+	model.Attachment
+	Priority *int `json:"priority"`
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAttachmentRepository は新しいリポジトリを生成するのだ
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+// FindByID はIDで添付ファイルを1件取得するのだ
+func (r *attachmentRepository) FindByID(id uint) (*model.Attachment, error) {
+	var attachment model.Attachment
+	if err := r.db.Preload("Extension").Preload("Thumbnails").First(&attachment, id).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// FindByOccurrence は発生情報の添付ファイルを表示順(priorityが小さい順、未設定は最後)で取得するのだ
+func (r *attachmentRepository) FindByOccurrence(occurrenceID uint) ([]OccurrenceAttachment, error) {
+	var attachments []OccurrenceAttachment
+	err := r.db.Model(&model.Attachment{}).
+		Select("attachments.*, attachment_goup.priority").
+		Joins("JOIN attachment_goup ON attachment_goup.attachment_id = attachments.attachment_id").
+		Where("attachment_goup.occurrence_id = ?", occurrenceID).
+		Order("attachment_goup.priority ASC NULLS LAST, attachments.attachment_id ASC").
+		Scan(&attachments).Error
+	if err != nil || len(attachments) == 0 {
+		return attachments, err
+	}
+
+	// Scanでは関連が読み込まれないので、サムネイルはまとめて取ってきて振り分けるのだ
+	ids := make([]uint, len(attachments))
+	for i, a := range attachments {
+		ids[i] = a.AttachmentID
+	}
+	var thumbnails []model.AttachmentThumbnail
+	if err := r.db.Where("attachment_id IN ?", ids).Order("width ASC").Find(&thumbnails).Error; err != nil {
+		return nil, err
+	}
+	byAttachment := make(map[uint][]model.AttachmentThumbnail)
+	for _, t := range thumbnails {
+		byAttachment[t.AttachmentID] = append(byAttachment[t.AttachmentID], t)
+	}
+	for i := range attachments {
+		attachments[i].Thumbnails = byAttachment[attachments[i].AttachmentID]
+	}
+	return attachments, nil
+}
+
+// FindExtensionByText は拡張子(小文字・ドットなし)から登録済みの拡張子を取得するのだ
+// 使うのをやめたファイルの種類の拡張子は、これからは受け付けないので見つからないことにするのだ
+func (r *attachmentRepository) FindExtensionByText(ext string) (*model.FileExtension, error) {
+	var extension model.FileExtension
+	err := r.db.
+		Joins("JOIN file_types ON file_types.file_type_id = file_extensions.file_type_id AND NOT file_types.deprecated").
+		Where("file_extensions.extension_text = ?", ext).
+		First(&extension).Error
+	if err != nil {
+		return nil, err
+	}
+	return &extension, nil
+}
+
+// Create は新しい添付ファイルを作成するのだ
+func (r *attachmentRepository) Create(tx *gorm.DB, attachment *model.Attachment) (*model.Attachment, error) {
+	if err := tx.Create(attachment).Error; err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// UpdateMetadata は権利情報と説明の列だけを更新するのだ
+func (r *attachmentRepository) UpdateMetadata(tx *gorm.DB, attachment *model.Attachment) error {
+	return tx.Model(attachment).
+		Select("license", "rights_holder", "creator", "caption", "subject_part", "capture_device").
+		Updates(attachment).Error
+}
+
+// Delete は添付ファイルを削除するのだ
+func (r *attachmentRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.Attachment{}, id).Error
+}
+
+// AddToOccurrence は添付ファイルを発生情報に紐づけるのだ
+func (r *attachmentRepository) AddToOccurrence(tx *gorm.DB, group *model.AttachmentGroup) error {
+	return tx.Create(group).Error
+}
+
+// UpdatePriority は発生情報の中での表示順を変更するのだ
+func (r *attachmentRepository) UpdatePriority(tx *gorm.DB, occurrenceID, attachmentID uint, priority *int) error {
+	result := tx.Model(&model.AttachmentGroup{}).
+		Where("occurrence_id = ? AND attachment_id = ?", occurrenceID, attachmentID).
+		Update("priority", priority)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RemoveFromGroups は添付ファイルの紐づけを全部外すのだ
+func (r *attachmentRepository) RemoveFromGroups(tx *gorm.DB, attachmentID uint) error {
+	return tx.Where("attachment_id = ?", attachmentID).Delete(&model.AttachmentGroup{}).Error
+}
+
+// CreateThumbnails はサムネイルをまとめて登録するのだ
+func (r *attachmentRepository) CreateThumbnails(tx *gorm.DB, thumbnails []model.AttachmentThumbnail) error {
+	if len(thumbnails) == 0 {
+		return nil
+	}
+	return tx.Create(&thumbnails).Error
+}
@@ -0,0 +1,169 @@
+// backend/internal/repository/controlled_term_repository.go
+package repository
+
+import (
+	"fmt"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// controlledColumns は語彙で値を決める発生情報の列なのだ。語彙の field と同じ名前なのだ
+var controlledColumns = map[string]bool{"sex": true, "lifestage": true}
+
+// ControlledValueCount は発生情報の列に入っている値と、その数なのだ
+type ControlledValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ControlledTermRepository は性別・成長段階などの語彙のデータ操作の契約書なのだ
+type ControlledTermRepository interface {
+	FindByID(id uint) (*model.ControlledTerm, error)
+	FindForUpdate(tx *gorm.DB, id uint) (*model.ControlledTerm, error)
+	FindByField(field string, includeDeprecated bool) ([]model.ControlledTerm, error)
+	FindAll() ([]model.ControlledTerm, error)
+	NextSortOrder(field string) (int, error)
+	Create(tx *gorm.DB, term *model.ControlledTerm) (*model.ControlledTerm, error)
+	Update(tx *gorm.DB, term *model.ControlledTerm) (*model.ControlledTerm, error)
+	Delete(tx *gorm.DB, id uint) error
+
+	// 発生情報に入っている値なのだ
+	CountUsage(tx *gorm.DB, field, value string) (int64, error)
+	DistinctValues(field string) ([]ControlledValueCount, error)
+	ReplaceValue(tx *gorm.DB, field, from, to string) (int64, error)
+}
+
+type controlledTermRepository struct {
+	db *gorm.DB
+}
+
+// NewControlledTermRepository は新しいリポジトリを生成するのだ
+func NewControlledTermRepository(db *gorm.DB) ControlledTermRepository {
+	return &controlledTermRepository{db: db}
+}
+
+// occurrenceColumnOf は語彙の field に当たる発生情報の列を返すのだ
+func occurrenceColumnOf(field string) (string, error) {
+	if !controlledColumns[field] {
+		return "", fmt.Errorf("unknown controlled field %q", field)
+	}
+	return "occurrence." + field, nil
+}
+
+func (r *controlledTermRepository) FindByID(id uint) (*model.ControlledTerm, error) {
+	var term model.ControlledTerm
+	if err := r.db.First(&term, id).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// FindForUpdate は語彙の値に行ロックをかけて取得するのだ
+func (r *controlledTermRepository) FindForUpdate(tx *gorm.DB, id uint) (*model.ControlledTerm, error) {
+	var term model.ControlledTerm
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&term, id).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// FindByField は項目の語彙を並び順で取得するのだ。includeDeprecated でなければ使うのをやめた値は外すのだ
+func (r *controlledTermRepository) FindByField(field string, includeDeprecated bool) ([]model.ControlledTerm, error) {
+	query := r.db.Where("field = ?", field)
+	if !includeDeprecated {
+		query = query.Where("NOT deprecated")
+	}
+	var terms []model.ControlledTerm
+	if err := query.Order("sort_order, term_id").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// FindAll は全ての項目の語彙を、使うのをやめた値も含めて取得するのだ
+func (r *controlledTermRepository) FindAll() ([]model.ControlledTerm, error) {
+	var terms []model.ControlledTerm
+	if err := r.db.Order("field, sort_order, term_id").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// NextSortOrder は項目の一番後ろに並ぶ並び順を返すのだ
+func (r *controlledTermRepository) NextSortOrder(field string) (int, error) {
+	var next int
+	err := r.db.Model(&model.ControlledTerm{}).
+		Where("field = ?", field).
+		Select("COALESCE(MAX(sort_order), 0) + 1").
+		Scan(&next).Error
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *controlledTermRepository) Create(tx *gorm.DB, term *model.ControlledTerm) (*model.ControlledTerm, error) {
+	if err := tx.Create(term).Error; err != nil {
+		return nil, err
+	}
+	return term, nil
+}
+
+func (r *controlledTermRepository) Update(tx *gorm.DB, term *model.ControlledTerm) (*model.ControlledTerm, error) {
+	err := tx.Model(&model.ControlledTerm{TermID: term.TermID}).
+		Select("value", "dwc_value", "synonyms", "labels", "sort_order", "deprecated").
+		Updates(term).Error
+	if err != nil {
+		return nil, err
+	}
+	return term, nil
+}
+
+func (r *controlledTermRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.ControlledTerm{}, id).Error
+}
+
+// CountUsage はその値の入っている発生情報の数を返すのだ
+func (r *controlledTermRepository) CountUsage(tx *gorm.DB, field, value string) (int64, error) {
+	column, err := occurrenceColumnOf(field)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := tx.Model(&model.Occurrence{}).Where(column+" = ?", value).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DistinctValues は発生情報の列に入っている空でない値を、多い順に数えて返すのだ
+func (r *controlledTermRepository) DistinctValues(field string) ([]ControlledValueCount, error) {
+	column, err := occurrenceColumnOf(field)
+	if err != nil {
+		return nil, err
+	}
+	var values []ControlledValueCount
+	err = r.db.Model(&model.Occurrence{}).
+		Select(column + " AS value, COUNT(*) AS count").
+		Where(column + " <> ''").
+		Group(column).
+		Order("count DESC, value").
+		Scan(&values).Error
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ReplaceValue は発生情報の列の from をそのまま to に書き換えて、書き換えた数を返すのだ
+func (r *controlledTermRepository) ReplaceValue(tx *gorm.DB, field, from, to string) (int64, error) {
+	column, err := occurrenceColumnOf(field)
+	if err != nil {
+		return 0, err
+	}
+	result := tx.Model(&model.Occurrence{}).Where(column+" = ?", from).Update(field, to)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,302 @@
+// backend/internal/repository/event_repository.go
+package repository
+
+import (
+	"fmt"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// eventSubtreeSQL は採集のまとまりと、その下の全てのまとまりのIDを返すのだ。? に一番上のIDを入れるのだ
+const eventSubtreeSQL = `
+	WITH RECURSIVE subtree AS (
+		SELECT event_id FROM events WHERE event_id = ?
+		UNION ALL
+		SELECT e.event_id FROM events e JOIN subtree ON e.parent_event_id = subtree.event_id
+	)
+	SELECT event_id FROM subtree`
+
+// EventSearchParams は採集のまとまりの検索条件なのだ。空の条件は使わないのだ
+type EventSearchParams struct {
+	Code          string // 野帳の番号の前方一致なのだ (大文字小文字は区別しないのだ)
+	EventType     string
+	ParentEventID *uint
+	ProjectID     *uint
+	RootOnly      bool // 親のないまとまり (調査など) だけにするのだ
+}
+
+// TaxonCount は分類群ごとの発生情報と個体の数なのだ
+type TaxonCount struct {
+	Taxon       string `json:"taxon"`
+	Occurrences int64  `json:"occurrences"`
+	Individuals int64  `json:"individuals"`
+}
+
+// EventRepository は採集のまとまり関連のデータ操作の契約書なのだ
+type EventRepository interface {
+	FindByID(id uint) (*model.Event, error)
+	Search(params EventSearchParams) ([]model.Event, error)
+	FindAncestors(id uint) ([]model.Event, error)
+	FindChildren(id uint) ([]model.Event, error)
+	FindSubtree(id uint) ([]model.Event, error)
+	IsInSubtree(rootID, id uint) (bool, error)
+	HasChildren(id uint) (bool, error)
+	CodeTaken(code string, exceptID uint) (bool, error)
+	MissingUsers(ids []uint) ([]uint, error)
+	Create(tx *gorm.DB, event *model.Event) (*model.Event, error)
+	Update(tx *gorm.DB, event *model.Event) (*model.Event, error)
+	ReplaceParticipants(tx *gorm.DB, eventID uint, participants []model.EventParticipant) error
+	Delete(tx *gorm.DB, id uint) error
+
+	// まとまりに付いた発生情報なのだ
+	CountOccurrences(id uint, includeSubevents bool) (int64, error)
+	MissingOccurrences(ids []uint) ([]uint, error)
+	AttachOccurrences(tx *gorm.DB, eventID uint, occurrenceIDs []uint) error
+	DetachOccurrence(tx *gorm.DB, eventID, occurrenceID uint) (int64, error)
+	CountTaxa(id uint, rank string) ([]TaxonCount, error)
+
+	// Darwin Core の Event コアに出すまとまりなのだ
+	FindForExport(ids []uint, projectID *uint) ([]model.Event, error)
+}
+
+type eventRepository struct {
+	db *gorm.DB
+}
+
+// NewEventRepository は新しいリポジトリを生成するのだ
+func NewEventRepository(db *gorm.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+// FindByID はIDで採集のまとまりを1件取得する。場所と参加した人も一緒に取得するのだ
+func (r *eventRepository) FindByID(id uint) (*model.Event, error) {
+	var event model.Event
+	err := r.db.
+		Preload("Project").
+		Preload("Place", withLatLon).
+		Preload("Participants.User").
+		First(&event, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Search は条件に合う採集のまとまりを、始めた日時の順に取得するのだ
+func (r *eventRepository) Search(params EventSearchParams) ([]model.Event, error) {
+	query := r.db.Model(&model.Event{})
+	if params.Code != "" {
+		query = query.Where("lower(event_code) LIKE lower(?) ESCAPE '\\'", escapeLike(params.Code)+"%")
+	}
+	if params.EventType != "" {
+		query = query.Where("event_type = ?", params.EventType)
+	}
+	if params.ParentEventID != nil {
+		query = query.Where("parent_event_id = ?", *params.ParentEventID)
+	}
+	if params.ProjectID != nil {
+		query = query.Where("project_id = ?", *params.ProjectID)
+	}
+	if params.RootOnly {
+		query = query.Where("parent_event_id IS NULL")
+	}
+
+	var events []model.Event
+	if err := query.Order("start_at NULLS LAST").Order("event_id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FindAncestors は採集のまとまりから一番上までたどったまとまりを、上から順に取得するのだ (自分も含むのだ)
+func (r *eventRepository) FindAncestors(id uint) ([]model.Event, error) {
+	var events []model.Event
+	err := r.db.Raw(`
+		WITH RECURSIVE chain AS (
+			SELECT e.*, 0 AS depth FROM events e WHERE e.event_id = ?
+			UNION ALL
+			SELECT e.*, chain.depth + 1 FROM events e JOIN chain ON e.event_id = chain.parent_event_id
+		)
+		SELECT * FROM chain ORDER BY depth DESC`, id).
+		Scan(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FindChildren はすぐ下の採集のまとまりを取得するのだ
+func (r *eventRepository) FindChildren(id uint) ([]model.Event, error) {
+	parentID := id
+	return r.Search(EventSearchParams{ParentEventID: &parentID})
+}
+
+// FindSubtree は採集のまとまりと、その下の全てのまとまりを取得するのだ
+func (r *eventRepository) FindSubtree(id uint) ([]model.Event, error) {
+	var events []model.Event
+	if err := r.db.Where("event_id IN ("+eventSubtreeSQL+")", id).Order("event_id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// IsInSubtree は id が rootID かその下のまとまりか確かめるのだ
+func (r *eventRepository) IsInSubtree(rootID, id uint) (bool, error) {
+	return exists(r.db.Model(&model.Event{}).
+		Where("event_id = ?", id).
+		Where("event_id IN ("+eventSubtreeSQL+")", rootID))
+}
+
+// HasChildren は下にまとまりがあるか確かめるのだ
+func (r *eventRepository) HasChildren(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Event{}).Where("parent_event_id = ?", id))
+}
+
+// CodeTaken は野帳の番号がもう使われているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *eventRepository) CodeTaken(code string, exceptID uint) (bool, error) {
+	return exists(r.db.Model(&model.Event{}).
+		Where("lower(event_code) = lower(?)", code).
+		Where("event_id <> ?", exceptID))
+}
+
+// MissingUsers は ids のうち登録されていないユーザーのIDを返すのだ
+func (r *eventRepository) MissingUsers(ids []uint) ([]uint, error) {
+	return missingIDs(r.db.Model(&model.User{}), "user_id", ids)
+}
+
+// missingIDs は ids のうち、query のテーブルの column にないIDを返すのだ
+func missingIDs(query *gorm.DB, column string, ids []uint) ([]uint, error) {
+	var found []uint
+	if err := query.Where(column+" IN ?", ids).Pluck(column, &found).Error; err != nil {
+		return nil, err
+	}
+	present := make(map[uint]bool, len(found))
+	for _, id := range found {
+		present[id] = true
+	}
+	var missing []uint
+	for _, id := range ids {
+		if !present[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// Create は新しい採集のまとまりを作成するのだ。参加した人は ReplaceParticipants で入れるのだ
+func (r *eventRepository) Create(tx *gorm.DB, event *model.Event) (*model.Event, error) {
+	if err := tx.Omit("Project", "Place", "Participants").Create(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Update は採集のまとまりを更新するのだ。外した項目はnilで書き換えるのだ
+func (r *eventRepository) Update(tx *gorm.DB, event *model.Event) (*model.Event, error) {
+	result := tx.Model(&model.Event{EventID: event.EventID}).
+		Select("parent_event_id", "event_type", "event_code", "name", "project_id", "place_id", "sampling_protocol",
+			"sample_size_value", "sample_size_unit", "sampling_effort", "start_at", "end_at", "timezone", "note").
+		Updates(event)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return event, nil
+}
+
+// ReplaceParticipants は参加した人をまるごと入れ替えるのだ
+func (r *eventRepository) ReplaceParticipants(tx *gorm.DB, eventID uint, participants []model.EventParticipant) error {
+	if err := tx.Where("event_id = ?", eventID).Delete(&model.EventParticipant{}).Error; err != nil {
+		return err
+	}
+	if len(participants) == 0 {
+		return nil
+	}
+	for i := range participants {
+		participants[i].EventID = eventID
+	}
+	return tx.Omit("User").Create(&participants).Error
+}
+
+func (r *eventRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.Event{}, id).Error
+}
+
+// CountOccurrences はまとまりに付いた発生情報を数えるのだ。includeSubevents なら下のまとまりの発生情報も数えるのだ
+func (r *eventRepository) CountOccurrences(id uint, includeSubevents bool) (int64, error) {
+	query := r.db.Model(&model.Occurrence{})
+	if includeSubevents {
+		query = query.Where("event_id IN ("+eventSubtreeSQL+")", id)
+	} else {
+		query = query.Where("event_id = ?", id)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MissingOccurrences は ids のうち登録されていない発生情報のIDを返すのだ
+func (r *eventRepository) MissingOccurrences(ids []uint) ([]uint, error) {
+	return missingIDs(r.db.Model(&model.Occurrence{}), "occurrence_id", ids)
+}
+
+// AttachOccurrences は発生情報をまとまりに付けるのだ。ほかのまとまりに付いていたものは付け替えるのだ
+func (r *eventRepository) AttachOccurrences(tx *gorm.DB, eventID uint, occurrenceIDs []uint) error {
+	return tx.Model(&model.Occurrence{}).Where("occurrence_id IN ?", occurrenceIDs).Update("event_id", eventID).Error
+}
+
+// DetachOccurrence は発生情報をまとまりから外して、外した数を返すのだ
+func (r *eventRepository) DetachOccurrence(tx *gorm.DB, eventID, occurrenceID uint) (int64, error) {
+	result := tx.Model(&model.Occurrence{}).
+		Where("occurrence_id = ? AND event_id = ?", occurrenceID, eventID).
+		Update("event_id", nil)
+	return result.RowsAffected, result.Error
+}
+
+// CountTaxa はまとまりと下のまとまりの発生情報を、分類の rank (species など) の名前ごとに数えるのだ
+// 個体の数は individual_count を足したもので、数えていない発生情報は1匹とするのだ
+func (r *eventRepository) CountTaxa(id uint, rank string) ([]TaxonCount, error) {
+	if !classificationKeys[rank] {
+		return nil, fmt.Errorf("unknown rank %q", rank)
+	}
+	var counts []TaxonCount
+	err := r.db.Table("occurrence o").
+		Select("COALESCE(c.class_classification->>?, '') AS taxon, COUNT(*) AS occurrences, SUM(COALESCE(o.individual_count, 1)) AS individuals", rank).
+		Joins("LEFT JOIN classification_json c ON c.classification_id = o.classification_id").
+		Where("o.event_id IN ("+eventSubtreeSQL+")", id).
+		Group("taxon").
+		Order("individuals DESC, taxon").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// FindForExport は ids のまとまりとプロジェクトの全てのまとまりを、上のまとまりもたどって取得するのだ
+// 場所と参加した人も一緒に取得するのだ
+func (r *eventRepository) FindForExport(ids []uint, projectID *uint) ([]model.Event, error) {
+	var events []model.Event
+	err := r.db.
+		Where(`event_id IN (
+			WITH RECURSIVE chain AS (
+				SELECT event_id, parent_event_id FROM events WHERE event_id IN ? OR project_id = ?
+				UNION
+				SELECT e.event_id, e.parent_event_id FROM events e JOIN chain ON e.event_id = chain.parent_event_id
+			)
+			SELECT event_id FROM chain)`, ids, projectID).
+		Preload("Place", withLatLon).
+		Preload("Place.PlaceName").
+		Preload("Participants.User").
+		Order("event_id").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -1,19 +1,27 @@
-
 // backend/internal/repository/identification_repository.go
 package repository
 
 import (
 	"github.com/saku-730/specimen-web/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // IdentificationRepository は同定情報関連のデータ操作の契約書なのだ
 type IdentificationRepository interface {
 	FindByID(id uint) (*model.Identification, error)
 	FindAll() ([]model.Identification, error)
+	FindByOccurrence(occurrenceID uint) ([]model.Identification, error)
 	Create(tx *gorm.DB, identification *model.Identification) (*model.Identification, error)
 	Update(tx *gorm.DB, identification *model.Identification) (*model.Identification, error)
 	Delete(tx *gorm.DB, id uint) error
+
+	// 発生情報の今の同定なのだ
+	OccurrenceExists(id uint) (bool, error)
+	FindOccurrenceForUpdate(tx *gorm.DB, occurrenceID uint) (*model.Occurrence, error)
+	FindLatestClassified(tx *gorm.DB, occurrenceID uint) (*model.Identification, error)
+	CreateClassification(tx *gorm.DB, classification *model.ClassificationJSON) (*model.ClassificationJSON, error)
+	SetOccurrenceClassification(tx *gorm.DB, occurrenceID, classificationID uint) error
 }
 
 type identificationRepository struct {
@@ -25,10 +33,15 @@ func NewIdentificationRepository(db *gorm.DB) IdentificationRepository {
 	return &identificationRepository{db: db}
 }
 
+// withIdentificationDetails は同定と一緒に返す同定者と分類を読み込むのだ
+func withIdentificationDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("User").Preload("ClassificationJSON")
+}
+
 // FindByID はIDで同定情報を1件取得するのだ
 func (r *identificationRepository) FindByID(id uint) (*model.Identification, error) {
 	var identification model.Identification
-	if err := r.db.First(&identification, id).Error; err != nil {
+	if err := withIdentificationDetails(r.db).First(&identification, id).Error; err != nil {
 		return nil, err
 	}
 	return &identification, nil
@@ -43,17 +56,33 @@ func (r *identificationRepository) FindAll() ([]model.Identification, error) {
 	return identifications, nil
 }
 
+// FindByOccurrence は発生情報の同定の履歴を新しい順に取得するのだ。先頭が今の同定なのだ
+func (r *identificationRepository) FindByOccurrence(occurrenceID uint) ([]model.Identification, error) {
+	var identifications []model.Identification
+	err := withIdentificationDetails(r.db).
+		Where("occurrence_id = ?", occurrenceID).
+		Order("identificated_at DESC, identification_id DESC").
+		Find(&identifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return identifications, nil
+}
+
 // Create は新しい同定情報を作成するのだ
 func (r *identificationRepository) Create(tx *gorm.DB, identification *model.Identification) (*model.Identification, error) {
-	if err := tx.Create(identification).Error; err != nil {
+	if err := tx.Omit("User", "Occurrence", "ClassificationJSON").Create(identification).Error; err != nil {
 		return nil, err
 	}
 	return identification, nil
 }
 
-// Update は同定情報を更新するのだ
+// Update は同定情報を更新するのだ。同定する発生情報は変えないのだ
 func (r *identificationRepository) Update(tx *gorm.DB, identification *model.Identification) (*model.Identification, error) {
-	if err := tx.Save(identification).Error; err != nil {
+	err := tx.Model(&model.Identification{IdentificationID: identification.IdentificationID}).
+		Select("user_id", "source_info", "classification_id", "identificated_at", "timezone").
+		Updates(identification).Error
+	if err != nil {
 		return nil, err
 	}
 	return identification, nil
@@ -66,3 +95,49 @@ func (r *identificationRepository) Delete(tx *gorm.DB, id uint) error {
 	}
 	return nil
 }
+
+// OccurrenceExists は発生情報があるか確かめるのだ
+func (r *identificationRepository) OccurrenceExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Occurrence{}).Where("occurrence_id = ?", id))
+}
+
+// FindOccurrenceForUpdate は同定を書き換える間、発生情報の行をロックして取得するのだ
+// 同じ発生情報に同時に同定を足しても、今の同定が食い違わないようにするのだ
+func (r *identificationRepository) FindOccurrenceForUpdate(tx *gorm.DB, occurrenceID uint) (*model.Occurrence, error) {
+	var occurrence model.Occurrence
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("occurrence_id", "classification_id").
+		First(&occurrence, occurrenceID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &occurrence, nil
+}
+
+// FindLatestClassified は分類の付いた同定のうち一番新しいものを取得するのだ
+func (r *identificationRepository) FindLatestClassified(tx *gorm.DB, occurrenceID uint) (*model.Identification, error) {
+	var identification model.Identification
+	err := tx.Where("occurrence_id = ? AND classification_id IS NOT NULL", occurrenceID).
+		Order("identificated_at DESC, identification_id DESC").
+		First(&identification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identification, nil
+}
+
+// CreateClassification は同定で決めた分類を新しく作るのだ
+// 前の同定の分類は履歴として残すので、書き換えずに作り直すのだ
+func (r *identificationRepository) CreateClassification(tx *gorm.DB, classification *model.ClassificationJSON) (*model.ClassificationJSON, error) {
+	if err := tx.Create(classification).Error; err != nil {
+		return nil, err
+	}
+	return classification, nil
+}
+
+// SetOccurrenceClassification は発生情報の今の分類を付け替えるのだ。分類での検索はこの列を見るのだ
+func (r *identificationRepository) SetOccurrenceClassification(tx *gorm.DB, occurrenceID, classificationID uint) error {
+	return tx.Model(&model.Occurrence{}).
+		Where("occurrence_id = ?", occurrenceID).
+		Update("classification_id", classificationID).Error
+}
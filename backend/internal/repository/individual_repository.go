@@ -0,0 +1,146 @@
+// backend/internal/repository/individual_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// IndividualSearchParams は個体の検索条件なのだ。空の条件は使わないのだ
+type IndividualSearchParams struct {
+	ProjectID *uint
+	MarkCode  string // 標識の番号の前方一致なのだ (大文字小文字は区別しないのだ)
+}
+
+// IndividualRepository は標識した個体関連のデータ操作の契約書なのだ
+type IndividualRepository interface {
+	FindByID(id uint) (*model.Individual, error)
+	Search(params IndividualSearchParams) ([]model.Individual, error)
+	MarkCodeTaken(projectID *uint, code string, exceptID uint) (bool, error)
+	Create(tx *gorm.DB, individual *model.Individual) (*model.Individual, error)
+	Update(tx *gorm.DB, individual *model.Individual) (*model.Individual, error)
+	Delete(tx *gorm.DB, id uint) error
+
+	// 個体の発生情報なのだ
+	CountOccurrences(id uint) (int64, error)
+	FindOccurrences(individualIDs []uint) ([]model.Occurrence, error)
+	MissingOccurrences(ids []uint) ([]uint, error)
+	AttachOccurrences(tx *gorm.DB, individualID uint, occurrenceIDs []uint) error
+	DetachOccurrence(tx *gorm.DB, individualID, occurrenceID uint) (int64, error)
+}
+
+type individualRepository struct {
+	db *gorm.DB
+}
+
+// NewIndividualRepository は新しいリポジトリを生成するのだ
+func NewIndividualRepository(db *gorm.DB) IndividualRepository {
+	return &individualRepository{db: db}
+}
+
+func (r *individualRepository) FindByID(id uint) (*model.Individual, error) {
+	var individual model.Individual
+	if err := r.db.Preload("Project").First(&individual, id).Error; err != nil {
+		return nil, err
+	}
+	return &individual, nil
+}
+
+// Search は条件に合う個体を標識の番号の順に取得するのだ
+func (r *individualRepository) Search(params IndividualSearchParams) ([]model.Individual, error) {
+	query := r.db.Model(&model.Individual{})
+	if params.ProjectID != nil {
+		query = query.Where("project_id = ?", *params.ProjectID)
+	}
+	if params.MarkCode != "" {
+		query = query.Where("lower(mark_code) LIKE lower(?) ESCAPE '\\'", escapeLike(params.MarkCode)+"%")
+	}
+
+	var individuals []model.Individual
+	if err := query.Order("lower(mark_code)").Order("individual_id").Find(&individuals).Error; err != nil {
+		return nil, err
+	}
+	return individuals, nil
+}
+
+// MarkCodeTaken は同じプロジェクトで標識の番号がもう使われているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *individualRepository) MarkCodeTaken(projectID *uint, code string, exceptID uint) (bool, error) {
+	query := r.db.Model(&model.Individual{}).
+		Where("lower(mark_code) = lower(?)", code).
+		Where("individual_id <> ?", exceptID)
+	if projectID != nil {
+		query = query.Where("project_id = ?", *projectID)
+	} else {
+		query = query.Where("project_id IS NULL")
+	}
+	return exists(query)
+}
+
+func (r *individualRepository) Create(tx *gorm.DB, individual *model.Individual) (*model.Individual, error) {
+	if err := tx.Omit("Project").Create(individual).Error; err != nil {
+		return nil, err
+	}
+	return individual, nil
+}
+
+// Update は個体を更新するのだ。外した項目はnilで書き換えるのだ
+func (r *individualRepository) Update(tx *gorm.DB, individual *model.Individual) (*model.Individual, error) {
+	result := tx.Model(&model.Individual{IndividualID: individual.IndividualID}).
+		Select("project_id", "mark_code", "mark_type", "note").
+		Updates(individual)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return individual, nil
+}
+
+func (r *individualRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.Individual{}, id).Error
+}
+
+func (r *individualRepository) CountOccurrences(id uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&model.Occurrence{}).Where("individual_id = ?", id).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindOccurrences は個体の発生情報を、場所・観察・分類と一緒に取得するのだ
+// 日時の順に並べるのは観察の日時を見てからなので、サービスでするのだ
+func (r *individualRepository) FindOccurrences(individualIDs []uint) ([]model.Occurrence, error) {
+	var occurrences []model.Occurrence
+	err := r.db.
+		Where("individual_id IN ?", individualIDs).
+		Preload("Place", withLatLon).
+		Preload("Place.PlaceName").
+		Preload("Observations").
+		Preload("ClassificationJSON").
+		Order("occurrence_id").
+		Find(&occurrences).Error
+	if err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
+
+// MissingOccurrences は ids のうち登録されていない発生情報のIDを返すのだ
+func (r *individualRepository) MissingOccurrences(ids []uint) ([]uint, error) {
+	return missingIDs(r.db.Model(&model.Occurrence{}), "occurrence_id", ids)
+}
+
+// AttachOccurrences は発生情報を個体のものにするのだ。ほかの個体のものだったら付け替えるのだ
+func (r *individualRepository) AttachOccurrences(tx *gorm.DB, individualID uint, occurrenceIDs []uint) error {
+	return tx.Model(&model.Occurrence{}).Where("occurrence_id IN ?", occurrenceIDs).Update("individual_id", individualID).Error
+}
+
+// DetachOccurrence は発生情報を個体から外して、外した数を返すのだ
+func (r *individualRepository) DetachOccurrence(tx *gorm.DB, individualID, occurrenceID uint) (int64, error) {
+	result := tx.Model(&model.Occurrence{}).
+		Where("occurrence_id = ? AND individual_id = ?", occurrenceID, individualID).
+		Update("individual_id", nil)
+	return result.RowsAffected, result.Error
+}
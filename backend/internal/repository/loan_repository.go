@@ -0,0 +1,219 @@
+// backend/internal/repository/loan_repository.go
+package repository
+
+import (
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 貸し出しの状態なのだ。overdue は貸し出し中で返却期限を過ぎたものなのだ
+const (
+	LoanStatusOpen    = "open"
+	LoanStatusClosed  = "closed"
+	LoanStatusOverdue = "overdue"
+)
+
+// LoanListParams は貸し出し一覧の絞り込みとページ送りの条件なのだ
+type LoanListParams struct {
+	Status        string    // 空なら全部なのだ
+	InstitutionID *uint     // 借りている機関なのだ
+	AsOf          time.Time // overdue を決める日なのだ
+	Limit         int
+	Offset        int
+}
+
+// LoanRepository は貸し出し関連のデータ操作の契約書なのだ
+type LoanRepository interface {
+	FindByID(id uint) (*model.Loan, error)
+	FindByIDForUpdate(tx *gorm.DB, id uint) (*model.Loan, error)
+	List(params LoanListParams) ([]model.Loan, int64, error)
+	FindBySpecimen(specimenID uint) ([]model.Loan, error)
+	Create(tx *gorm.DB, loan *model.Loan) (*model.Loan, error)
+	Update(tx *gorm.DB, loan *model.Loan) (*model.Loan, error)
+	SetClosedDate(tx *gorm.DB, id uint, date *time.Time) error
+
+	// 貸し出す標本と返却なのだ
+	FindOutstandingSpecimenIDs(tx *gorm.DB, specimenIDs []uint) ([]uint, error)
+	FindLoanSpecimenIDs(tx *gorm.DB, loanID uint, outstandingOnly bool) ([]uint, error)
+	AddSpecimens(tx *gorm.DB, loanID uint, specimenIDs []uint) error
+	ReturnSpecimens(tx *gorm.DB, loanID uint, specimenIDs []uint, date time.Time, note *string) (int64, error)
+	CountOutstanding(tx *gorm.DB, loanID uint) (int64, error)
+	SetSpecimenStatus(tx *gorm.DB, specimenIDs []uint, status string) error
+}
+
+type loanRepository struct {
+	db *gorm.DB
+}
+
+// NewLoanRepository は新しいリポジトリを生成するのだ
+func NewLoanRepository(db *gorm.DB) LoanRepository {
+	return &loanRepository{db: db}
+}
+
+// withLoanDetails は貸し出しと一緒に返す借り手と標本を読み込むのだ
+func withLoanDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("BorrowerInstitution").
+		Preload("User").
+		Preload("Specimens", func(db *gorm.DB) *gorm.DB {
+			return db.Order("specimen_id")
+		}).
+		Preload("Specimens.Specimen")
+}
+
+// FindByID はIDで貸し出しを1件取得する。貸し出した標本も一緒に取得するのだ
+func (r *loanRepository) FindByID(id uint) (*model.Loan, error) {
+	var loan model.Loan
+	if err := withLoanDetails(r.db).First(&loan, id).Error; err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// FindByIDForUpdate は行ロックをかけて貸し出しを1件取得するのだ。返却を同時に受け付けても閉じ忘れないようにするのだ
+func (r *loanRepository) FindByIDForUpdate(tx *gorm.DB, id uint) (*model.Loan, error) {
+	var loan model.Loan
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// List は条件に合う貸し出しを取得し、全体の件数も返すのだ
+// 返却期限の近いものから並べるので、期限のない貸し出しは最後なのだ
+func (r *loanRepository) List(params LoanListParams) ([]model.Loan, int64, error) {
+	query := r.db.Model(&model.Loan{})
+	switch params.Status {
+	case LoanStatusOpen:
+		query = query.Where("closed_date IS NULL")
+	case LoanStatusClosed:
+		query = query.Where("closed_date IS NOT NULL")
+	case LoanStatusOverdue:
+		query = query.Where("closed_date IS NULL AND due_date < ?", params.AsOf.Format("2006-01-02"))
+	}
+	if params.InstitutionID != nil {
+		query = query.Where("borrower_institution_id = ?", *params.InstitutionID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("due_date NULLS LAST").Order("loan_id DESC")
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		query = query.Offset(params.Offset)
+	}
+
+	var loans []model.Loan
+	if err := withLoanDetails(query).Find(&loans).Error; err != nil {
+		return nil, 0, err
+	}
+	return loans, total, nil
+}
+
+// FindBySpecimen は標本が入った貸し出しを新しい順に取得するのだ
+func (r *loanRepository) FindBySpecimen(specimenID uint) ([]model.Loan, error) {
+	var loans []model.Loan
+	err := withLoanDetails(r.db).
+		Where("loan_id IN (?)", r.db.Model(&model.LoanSpecimen{}).Select("loan_id").Where("specimen_id = ?", specimenID)).
+		Order("loan_date DESC").
+		Order("loan_id DESC").
+		Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// Create は新しい貸し出しを作成するのだ。標本は AddSpecimens で別に追加するのだ
+func (r *loanRepository) Create(tx *gorm.DB, loan *model.Loan) (*model.Loan, error) {
+	if err := tx.Omit("BorrowerInstitution", "Specimens", "User").Create(loan).Error; err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// Update は貸し出しの借り手・連絡先・期限などを更新するのだ。nilでも列を書き換えるのだ
+func (r *loanRepository) Update(tx *gorm.DB, loan *model.Loan) (*model.Loan, error) {
+	err := tx.Model(&model.Loan{LoanID: loan.LoanID}).
+		Select("borrower_institution_id", "contact_name", "contact_email", "purpose", "loan_date", "due_date", "note").
+		Updates(loan).Error
+	if err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// SetClosedDate は貸し出しを閉じるのだ。nilなら開き直すのだ
+func (r *loanRepository) SetClosedDate(tx *gorm.DB, id uint, date *time.Time) error {
+	return tx.Model(&model.Loan{}).Where("loan_id = ?", id).Update("closed_date", date).Error
+}
+
+// FindOutstandingSpecimenIDs はまだ返ってきていない貸し出しに入っている標本のIDを取得するのだ
+func (r *loanRepository) FindOutstandingSpecimenIDs(tx *gorm.DB, specimenIDs []uint) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&model.LoanSpecimen{}).
+		Where("specimen_id IN ? AND returned_date IS NULL", specimenIDs).
+		Order("specimen_id").
+		Pluck("specimen_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindLoanSpecimenIDs は貸し出しに入っている標本のIDを取得するのだ。outstandingOnly ならまだ返ってきていないものだけなのだ
+func (r *loanRepository) FindLoanSpecimenIDs(tx *gorm.DB, loanID uint, outstandingOnly bool) ([]uint, error) {
+	query := tx.Model(&model.LoanSpecimen{}).Where("loan_id = ?", loanID)
+	if outstandingOnly {
+		query = query.Where("returned_date IS NULL")
+	}
+	var ids []uint
+	if err := query.Order("specimen_id").Pluck("specimen_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// AddSpecimens は貸し出しに標本を追加するのだ
+func (r *loanRepository) AddSpecimens(tx *gorm.DB, loanID uint, specimenIDs []uint) error {
+	rows := make([]model.LoanSpecimen, len(specimenIDs))
+	for i, id := range specimenIDs {
+		rows[i] = model.LoanSpecimen{LoanID: loanID, SpecimenID: id}
+	}
+	return tx.Omit("Specimen").Create(&rows).Error
+}
+
+// ReturnSpecimens はまだ返ってきていない標本に返却日を入れて、返却した数を返すのだ
+func (r *loanRepository) ReturnSpecimens(tx *gorm.DB, loanID uint, specimenIDs []uint, date time.Time, note *string) (int64, error) {
+	result := tx.Model(&model.LoanSpecimen{}).
+		Where("loan_id = ? AND specimen_id IN ? AND returned_date IS NULL", loanID, specimenIDs).
+		Updates(map[string]any{"returned_date": date, "return_note": note})
+	return result.RowsAffected, result.Error
+}
+
+// CountOutstanding は貸し出しの中でまだ返ってきていない標本を数えるのだ
+func (r *loanRepository) CountOutstanding(tx *gorm.DB, loanID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&model.LoanSpecimen{}).
+		Where("loan_id = ? AND returned_date IS NULL", loanID).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetSpecimenStatus は標本が館内にあるか貸し出し中かを書き換えるのだ
+func (r *loanRepository) SetSpecimenStatus(tx *gorm.DB, specimenIDs []uint, status string) error {
+	if len(specimenIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&model.Specimen{}).Where("specimen_id IN ?", specimenIDs).Update("status", status).Error
+}
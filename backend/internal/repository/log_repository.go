@@ -0,0 +1,44 @@
+// backend/internal/repository/log_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// LogRepository は変更履歴 (change_logs) のデータ操作の契約書なのだ
+type LogRepository interface {
+	FindByTarget(logType string, changedID uint) ([]model.ChangeLog, error)
+	Create(tx *gorm.DB, log *model.ChangeLog) (*model.ChangeLog, error)
+}
+
+type logRepository struct {
+	db *gorm.DB
+}
+
+// NewLogRepository は新しいリポジトリを生成するのだ
+func NewLogRepository(db *gorm.DB) LogRepository {
+	return &logRepository{db: db}
+}
+
+// FindByTarget は type と changed_ID で変更履歴を新しい順に取得するのだ
+func (r *logRepository) FindByTarget(logType string, changedID uint) ([]model.ChangeLog, error) {
+	var logs []model.ChangeLog
+	err := r.db.
+		Preload("User").
+		Where("type = ? AND changed_id = ?", logType, changedID).
+		Order("date DESC").
+		Order("log_id DESC").
+		Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *logRepository) Create(tx *gorm.DB, log *model.ChangeLog) (*model.ChangeLog, error) {
+	if err := tx.Omit("User").Create(log).Error; err != nil {
+		return nil, err
+	}
+	return log, nil
+}
@@ -0,0 +1,167 @@
+// backend/internal/repository/measurement_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MeasurementRepository は計測値と計測の種類のデータ操作の契約書なのだ
+type MeasurementRepository interface {
+	FindByID(id uint) (*model.Measurement, error)
+	FindByOccurrence(occurrenceID uint) ([]model.Measurement, error)
+	FindBySpecimen(specimenID uint) ([]model.Measurement, error)
+	OccurrenceExists(id uint) (bool, error)
+	SpecimenOccurrenceID(specimenID uint) (uint, error)
+	Create(tx *gorm.DB, measurement *model.Measurement) (*model.Measurement, error)
+	Update(tx *gorm.DB, measurement *model.Measurement) (*model.Measurement, error)
+	Delete(tx *gorm.DB, id uint) error
+
+	// 計測の種類なのだ
+	FindAllTypes() ([]model.MeasurementType, error)
+	FindTypeForShare(tx *gorm.DB, id uint) (*model.MeasurementType, error)
+	FindTypeForUpdate(tx *gorm.DB, id uint) (*model.MeasurementType, error)
+	CountByType(tx *gorm.DB, typeID uint) (int64, error)
+	UpdateTypeUnit(tx *gorm.DB, typeID uint, unit *string, factor float64) error
+}
+
+type measurementRepository struct {
+	db *gorm.DB
+}
+
+// NewMeasurementRepository は新しいリポジトリを生成するのだ
+func NewMeasurementRepository(db *gorm.DB) MeasurementRepository {
+	return &measurementRepository{db: db}
+}
+
+// withMeasurementDetails は計測の種類と測った人を一緒に読み込むのだ
+func withMeasurementDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("MeasurementType").Preload("MeasuredBy")
+}
+
+func (r *measurementRepository) FindByID(id uint) (*model.Measurement, error) {
+	var measurement model.Measurement
+	if err := withMeasurementDetails(r.db).First(&measurement, id).Error; err != nil {
+		return nil, err
+	}
+	return &measurement, nil
+}
+
+// FindByOccurrence は発生情報の計測値を、計測の種類の並び順で取得するのだ
+func (r *measurementRepository) FindByOccurrence(occurrenceID uint) ([]model.Measurement, error) {
+	return r.findOrdered(r.db.Where("measurements.occurrence_id = ?", occurrenceID))
+}
+
+// FindBySpecimen は標本を測った計測値を、計測の種類の並び順で取得するのだ
+func (r *measurementRepository) FindBySpecimen(specimenID uint) ([]model.Measurement, error) {
+	return r.findOrdered(r.db.Where("measurements.specimen_id = ?", specimenID))
+}
+
+func (r *measurementRepository) findOrdered(query *gorm.DB) ([]model.Measurement, error) {
+	var measurements []model.Measurement
+	err := withMeasurementDetails(query).
+		Joins("JOIN measurement_types mt ON mt.measurement_type_id = measurements.measurement_type_id").
+		Order("mt.sort_order, measurements.measurement_id").
+		Find(&measurements).Error
+	if err != nil {
+		return nil, err
+	}
+	return measurements, nil
+}
+
+// OccurrenceExists は発生情報があるか確かめるのだ
+func (r *measurementRepository) OccurrenceExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Occurrence{}).Where("occurrence_id = ?", id))
+}
+
+// SpecimenOccurrenceID は標本の発生情報のIDを返すのだ。発生情報に付いていない標本なら0なのだ
+func (r *measurementRepository) SpecimenOccurrenceID(specimenID uint) (uint, error) {
+	var specimen model.Specimen
+	if err := r.db.Select("specimen_id", "occurrence_id").First(&specimen, specimenID).Error; err != nil {
+		return 0, err
+	}
+	return specimen.OccurrenceID, nil
+}
+
+// Create は新しい計測値を作成するのだ
+func (r *measurementRepository) Create(tx *gorm.DB, measurement *model.Measurement) (*model.Measurement, error) {
+	if err := tx.Omit("MeasurementType", "MeasuredBy").Create(measurement).Error; err != nil {
+		return nil, err
+	}
+	return measurement, nil
+}
+
+// Update は計測値を更新するのだ。外した項目はnilで書き換えるのだ
+// 発生情報は測った相手なので変えないのだ
+func (r *measurementRepository) Update(tx *gorm.DB, measurement *model.Measurement) (*model.Measurement, error) {
+	result := tx.Model(&model.Measurement{MeasurementID: measurement.MeasurementID}).
+		Select("specimen_id", "measurement_type_id", "value", "unit", "value_number", "accuracy",
+			"method", "measured_by_user_id", "measured_on", "remarks").
+		Updates(measurement)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return measurement, nil
+}
+
+func (r *measurementRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.Measurement{}, id).Error
+}
+
+// FindAllTypes は使うのをやめていない計測の種類を並び順で取得するのだ
+func (r *measurementRepository) FindAllTypes() ([]model.MeasurementType, error) {
+	var types []model.MeasurementType
+	if err := r.db.Where("NOT deprecated").Order("sort_order, measurement_type_id").Find(&types).Error; err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// FindTypeForShare は計測の種類に共有ロックをかけて取得するのだ
+// 種類の単位で値をそろえている間に、単位が書き換わらないようにするのだ
+func (r *measurementRepository) FindTypeForShare(tx *gorm.DB, id uint) (*model.MeasurementType, error) {
+	var measurementType model.MeasurementType
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&measurementType, id).Error; err != nil {
+		return nil, err
+	}
+	return &measurementType, nil
+}
+
+// FindTypeForUpdate は計測の種類に行ロックをかけて取得するのだ
+func (r *measurementRepository) FindTypeForUpdate(tx *gorm.DB, id uint) (*model.MeasurementType, error) {
+	var measurementType model.MeasurementType
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&measurementType, id).Error; err != nil {
+		return nil, err
+	}
+	return &measurementType, nil
+}
+
+// CountByType はその種類の計測値の数を返すのだ
+func (r *measurementRepository) CountByType(tx *gorm.DB, typeID uint) (int64, error) {
+	var count int64
+	if err := tx.Model(&model.Measurement{}).Where("measurement_type_id = ?", typeID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateTypeUnit は計測の種類の単位を書き換えて、そろえた値に factor を掛けて新しい単位にするのだ
+func (r *measurementRepository) UpdateTypeUnit(tx *gorm.DB, typeID uint, unit *string, factor float64) error {
+	result := tx.Model(&model.MeasurementType{MeasurementTypeID: typeID}).Select("unit").Updates(&model.MeasurementType{Unit: unit})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if factor == 1 {
+		return nil
+	}
+	return tx.Model(&model.Measurement{}).
+		Where("measurement_type_id = ? AND value_number IS NOT NULL", typeID).
+		Update("value_number", gorm.Expr("value_number * ?", factor)).Error
+}
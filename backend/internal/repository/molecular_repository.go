@@ -0,0 +1,195 @@
+// backend/internal/repository/molecular_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// SampleListParams は試料一覧の絞り込み条件なのだ。nilや空の条件は使わないのだ
+type SampleListParams struct {
+	SpecimenID   *uint
+	OccurrenceID *uint
+	SampleType   string
+}
+
+// SequenceListParams は配列の絞り込み条件なのだ。nilや空の条件は使わないのだ
+type SequenceListParams struct {
+	SequenceIDs  []uint
+	SampleID     *uint
+	SpecimenID   *uint
+	OccurrenceID *uint
+	Marker       string // 大文字小文字は区別しないのだ
+}
+
+// MolecularRepository はDNA用の試料と塩基配列のデータ操作の契約書なのだ
+type MolecularRepository interface {
+	FindSampleByID(id uint) (*model.MolecularSample, error)
+	ListSamples(params SampleListParams) ([]model.MolecularSample, error)
+	SampleCodeTaken(code string, exceptID uint) (bool, error)
+	HasSubsamples(id uint) (bool, error)
+	CreateSample(tx *gorm.DB, sample *model.MolecularSample) (*model.MolecularSample, error)
+	UpdateSample(tx *gorm.DB, sample *model.MolecularSample) (*model.MolecularSample, error)
+	DeleteSample(tx *gorm.DB, id uint) error
+
+	FindSequenceByID(id uint) (*model.Sequence, error)
+	FindSequences(params SequenceListParams) ([]model.Sequence, error)
+	AccessionTaken(source, accession string) (bool, error)
+	CreateSequences(tx *gorm.DB, sequences []model.Sequence) error
+	DeleteSequence(tx *gorm.DB, id uint) error
+}
+
+type molecularRepository struct {
+	db *gorm.DB
+}
+
+// NewMolecularRepository は新しいリポジトリを生成するのだ
+func NewMolecularRepository(db *gorm.DB) MolecularRepository {
+	return &molecularRepository{db: db}
+}
+
+// FindSampleByID はIDで試料を1件取得する。標本と置き場所と配列も一緒に取得するのだ
+func (r *molecularRepository) FindSampleByID(id uint) (*model.MolecularSample, error) {
+	var sample model.MolecularSample
+	err := r.db.
+		Preload("Specimen").
+		Preload("StorageLocation").
+		Preload("User").
+		Preload("Sequences", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sequence_id")
+		}).
+		First(&sample, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sample, nil
+}
+
+// ListSamples は条件に合う試料を古い順に取得するのだ。配列は数が多くなるので読み込まないのだ
+func (r *molecularRepository) ListSamples(params SampleListParams) ([]model.MolecularSample, error) {
+	query := r.db.Model(&model.MolecularSample{})
+	if params.SpecimenID != nil {
+		query = query.Where("molecular_samples.specimen_id = ?", *params.SpecimenID)
+	}
+	if params.OccurrenceID != nil {
+		query = query.Where("molecular_samples.specimen_id IN (?)",
+			r.db.Model(&model.Specimen{}).Select("specimen_id").Where("occurrence_id = ?", *params.OccurrenceID))
+	}
+	if params.SampleType != "" {
+		query = query.Where("molecular_samples.sample_type = ?", params.SampleType)
+	}
+
+	var samples []model.MolecularSample
+	err := query.
+		Preload("StorageLocation").
+		Preload("User").
+		Order("molecular_samples.sample_id").
+		Find(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// SampleCodeTaken は試料の番号がもう使われているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *molecularRepository) SampleCodeTaken(code string, exceptID uint) (bool, error) {
+	return exists(r.db.Model(&model.MolecularSample{}).
+		Where("lower(sample_code) = lower(?)", code).
+		Where("sample_id <> ?", exceptID))
+}
+
+// HasSubsamples は試料から取り分けた試料があるか確かめるのだ
+func (r *molecularRepository) HasSubsamples(id uint) (bool, error) {
+	return exists(r.db.Model(&model.MolecularSample{}).Where("parent_sample_id = ?", id))
+}
+
+// CreateSample は新しい試料を作成するのだ
+func (r *molecularRepository) CreateSample(tx *gorm.DB, sample *model.MolecularSample) (*model.MolecularSample, error) {
+	if err := tx.Omit("Specimen", "StorageLocation", "User", "Sequences").Create(sample).Error; err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// UpdateSample は試料の内容を更新するのだ。外した項目はnilで書き換えるのだ
+// 標本は取り分けた元なので変えないのだ
+func (r *molecularRepository) UpdateSample(tx *gorm.DB, sample *model.MolecularSample) (*model.MolecularSample, error) {
+	err := tx.Model(&model.MolecularSample{SampleID: sample.SampleID}).
+		Select("parent_sample_id", "sample_type", "sample_code", "storage_location_id", "concentration_ng_per_ul",
+			"volume_ul", "extraction_method", "user_id", "prepared_date", "note").
+		Updates(sample).Error
+	if err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// DeleteSample はIDを元に試料を削除するのだ。配列も一緒に消えるのだ
+func (r *molecularRepository) DeleteSample(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.MolecularSample{}, id).Error
+}
+
+// FindSequenceByID はIDで配列を1件取得するのだ
+func (r *molecularRepository) FindSequenceByID(id uint) (*model.Sequence, error) {
+	var sequence model.Sequence
+	if err := r.db.First(&sequence, id).Error; err != nil {
+		return nil, err
+	}
+	return &sequence, nil
+}
+
+// FindSequences は条件に合う配列を、FASTAの見出しに使う標本と学名も読み込んで取得するのだ
+func (r *molecularRepository) FindSequences(params SequenceListParams) ([]model.Sequence, error) {
+	query := r.db.Model(&model.Sequence{})
+	if len(params.SequenceIDs) > 0 {
+		query = query.Where("sequences.sequence_id IN ?", params.SequenceIDs)
+	}
+	if params.SampleID != nil {
+		query = query.Where("sequences.sample_id = ?", *params.SampleID)
+	}
+	if params.SpecimenID != nil || params.OccurrenceID != nil {
+		samples := r.db.Model(&model.MolecularSample{}).Select("sample_id")
+		if params.SpecimenID != nil {
+			samples = samples.Where("specimen_id = ?", *params.SpecimenID)
+		}
+		if params.OccurrenceID != nil {
+			samples = samples.Where("specimen_id IN (?)",
+				r.db.Model(&model.Specimen{}).Select("specimen_id").Where("occurrence_id = ?", *params.OccurrenceID))
+		}
+		query = query.Where("sequences.sample_id IN (?)", samples)
+	}
+	if params.Marker != "" {
+		query = query.Where("lower(sequences.marker) = lower(?)", params.Marker)
+	}
+
+	var sequences []model.Sequence
+	err := query.
+		Preload("Sample").
+		Preload("Sample.Specimen").
+		Preload("Sample.Specimen.Occurrence").
+		Preload("Sample.Specimen.Occurrence.ClassificationJSON").
+		Order("sequences.sequence_id").
+		Find(&sequences).Error
+	if err != nil {
+		return nil, err
+	}
+	return sequences, nil
+}
+
+// AccessionTaken は登録番号がもう使われているか確かめるのだ
+func (r *molecularRepository) AccessionTaken(source, accession string) (bool, error) {
+	return exists(r.db.Model(&model.Sequence{}).Where("accession_source = ? AND accession = ?", source, accession))
+}
+
+// CreateSequences は配列をまとめて追加するのだ
+func (r *molecularRepository) CreateSequences(tx *gorm.DB, sequences []model.Sequence) error {
+	if len(sequences) == 0 {
+		return nil
+	}
+	return tx.Omit("Sample").Create(&sequences).Error
+}
+
+// DeleteSequence はIDを元に配列を削除するのだ
+func (r *molecularRepository) DeleteSequence(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.Sequence{}, id).Error
+}
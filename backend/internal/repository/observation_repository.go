@@ -1,4 +1,3 @@
-
 // backend/internal/repository/observation_repository.go
 package repository
 
@@ -11,9 +10,15 @@ import (
 type ObservationRepository interface {
 	FindByID(id uint) (*model.Observation, error)
 	FindAll() ([]model.Observation, error)
+	FindByOccurrence(occurrenceID uint) ([]model.Observation, error)
 	Create(tx *gorm.DB, observation *model.Observation) (*model.Observation, error)
 	Update(tx *gorm.DB, observation *model.Observation) (*model.Observation, error)
 	Delete(tx *gorm.DB, id uint) error
+
+	// 観察方法と、観察を付ける発生情報なのだ
+	FindAllMethods() ([]model.ObservationMethod, error)
+	MethodExists(id uint) (bool, error)
+	OccurrenceExists(id uint) (bool, error)
 }
 
 type observationRepository struct {
@@ -25,10 +30,15 @@ func NewObservationRepository(db *gorm.DB) ObservationRepository {
 	return &observationRepository{db: db}
 }
 
+// withObservationDetails は観察と一緒に返す観察方法と観察者を読み込むのだ
+func withObservationDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("ObservationMethod").Preload("User")
+}
+
 // FindByID はIDで観察情報を1件取得するのだ
 func (r *observationRepository) FindByID(id uint) (*model.Observation, error) {
 	var observation model.Observation
-	if err := r.db.First(&observation, id).Error; err != nil {
+	if err := withObservationDetails(r.db).First(&observation, id).Error; err != nil {
 		return nil, err
 	}
 	return &observation, nil
@@ -43,17 +53,33 @@ func (r *observationRepository) FindAll() ([]model.Observation, error) {
 	return observations, nil
 }
 
+// FindByOccurrence は発生情報に付いた観察を、観察した順に取得するのだ
+func (r *observationRepository) FindByOccurrence(occurrenceID uint) ([]model.Observation, error) {
+	var observations []model.Observation
+	err := withObservationDetails(r.db).
+		Where("occurrence_id = ?", occurrenceID).
+		Order("observed_at, observations_id").
+		Find(&observations).Error
+	if err != nil {
+		return nil, err
+	}
+	return observations, nil
+}
+
 // Create は新しい観察情報を作成するのだ
 func (r *observationRepository) Create(tx *gorm.DB, observation *model.Observation) (*model.Observation, error) {
-	if err := tx.Create(observation).Error; err != nil {
+	if err := tx.Omit("User", "Occurrence", "ObservationMethod").Create(observation).Error; err != nil {
 		return nil, err
 	}
 	return observation, nil
 }
 
-// Update は観察情報を更新するのだ
+// Update は観察情報を更新するのだ。読み込んだ観察方法や観察者までは書き戻さないのだ
 func (r *observationRepository) Update(tx *gorm.DB, observation *model.Observation) (*model.Observation, error) {
-	if err := tx.Save(observation).Error; err != nil {
+	err := tx.Model(&model.Observation{ObservationsID: observation.ObservationsID}).
+		Select("user_id", "occurrence_id", "observation_method_id", "behavior", "observed_at", "timezone").
+		Updates(observation).Error
+	if err != nil {
 		return nil, err
 	}
 	return observation, nil
@@ -66,3 +92,22 @@ func (r *observationRepository) Delete(tx *gorm.DB, id uint) error {
 	}
 	return nil
 }
+
+// FindAllMethods は使うのをやめていない観察方法を並び順で取得するのだ
+func (r *observationRepository) FindAllMethods() ([]model.ObservationMethod, error) {
+	var methods []model.ObservationMethod
+	if err := r.db.Where("NOT deprecated").Order("sort_order, observation_method_id").Find(&methods).Error; err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// MethodExists は観察方法があるか確かめるのだ
+func (r *observationRepository) MethodExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.ObservationMethod{}).Where("observation_method_id = ?", id))
+}
+
+// OccurrenceExists は発生情報があるか確かめるのだ
+func (r *observationRepository) OccurrenceExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Occurrence{}).Where("occurrence_id = ?", id))
+}
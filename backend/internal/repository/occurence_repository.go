@@ -2,19 +2,79 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/saku-730/specimen-web/backend/internal/model"
 
 	"gorm.io/gorm"
 )
 
-type SearchParams struct{
-	user
+// SearchParams は発生情報の検索条件なのだ。nilの条件は使わないのだ
+type SearchParams struct {
+	UserID            *uint
+	ObservationUserID *uint
+	SpecimenUserID    *uint
+	IdentUserID       *uint
+
+	// 分類 (kingdom, phylum, class, order, family, genus, species) のキーと値なのだ
+	Classification map[string]string
+
+	ObservationMethodID *uint
+	SpecimenMethodID    *uint
+	ProjectID           *uint
+	InstitutionID       *uint
+	CollectionID        *uint
+	SpecimenStatus      *string // 標本が館内にあるか貸し出し中かなのだ
+	EventID             *uint // 下のまとまりの発生情報も含めるのだ
+	IndividualID        *uint
+
+	// 期間は [Start, End) で指定するのだ
+	OccurrenceDateStart     *time.Time
+	OccurrenceDateEnd       *time.Time
+	ObservationDateStart    *time.Time
+	ObservationDateEnd      *time.Time
+	IdentificationDateStart *time.Time
+	IdentificationDateEnd   *time.Time
+	SpecimenDateStart       *time.Time
+	SpecimenDateEnd         *time.Time
+
+	Lifestage  *string
+	Sex        *string
+	Note       *string
+	Behavior   *string
+	SourceInfo *string
+	PlaceName  *string
+
+	// 場所の標高・水深(m)と生息環境なのだ
+	ElevationMin *float64
+	ElevationMax *float64
+	DepthMin     *float64
+	DepthMax     *float64
+	Habitat      *string
+
+	// 計測値の範囲は計測の種類の単位 (measurement_types.unit) で指定するのだ
+	MeasurementTypeID *uint
+	MeasurementMin    *float64
+	MeasurementMax    *float64
+
+	Limit  int
+	Offset int
+}
+
+// 分類JSONで検索に使ってよいキーなのだ
+var classificationKeys = map[string]bool{
+	"kingdom": true, "phylum": true, "class": true, "order": true,
+	"family": true, "genus": true, "species": true,
 }
 
 // OccurrenceRepository は発生情報関連のデータ操作の契約書なのだ
 type OccurrenceRepository interface {
+	FindByID(id uint) (*model.Occurrence, error)
+	IndividualExists(id uint) (bool, error)
+	EventExists(id uint) (bool, error)
 	Create(tx *gorm.DB, occurrence *model.Occurrence) (*model.Occurrence, error)
-	Search(params SearchRepository) ([]handler.SerachResult, error)
+	Search(params SearchParams) ([]model.Occurrence, error)
+	FindForExport(params SearchParams) ([]model.Occurrence, error)
 }
 
 type occurrenceRepository struct {
@@ -26,16 +86,245 @@ func NewOccurrenceRepository(db *gorm.DB) OccurrenceRepository {
 	return &occurrenceRepository{db: db}
 }
 
+// FindByID はIDで発生情報を1件取得するのだ
+func (r *occurrenceRepository) FindByID(id uint) (*model.Occurrence, error) {
+	var occurrence model.Occurrence
+	if err := r.db.First(&occurrence, id).Error; err != nil {
+		return nil, err
+	}
+	return &occurrence, nil
+}
+
+// IndividualExists は個体があるか確かめるのだ
+func (r *occurrenceRepository) IndividualExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Individual{}).Where("individual_id = ?", id))
+}
+
+// EventExists は採集のまとまりがあるか確かめるのだ
+func (r *occurrenceRepository) EventExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Event{}).Where("event_id = ?", id))
+}
+
 func (r *occurrenceRepository) Create(tx *gorm.DB, occurrence *model.Occurrence) (*model.Occurrence, error) {
 	if err := tx.Create(occurrence).Error; err != nil {
 		return nil, err
 	}
-	return user, nil
+	return occurrence, nil
+}
+
+// Search は条件に合う発生情報を、一覧表示に必要な関連情報と一緒に取得するのだ
+func (r *occurrenceRepository) Search(params SearchParams) ([]model.Occurrence, error) {
+	var occurrences []model.Occurrence
+	err := r.filtered(params).
+		Preload("User").
+		Preload("Project").
+		Preload("ClassificationJSON").
+		Preload("Specimens", func(db *gorm.DB) *gorm.DB {
+			return db.Order("specimen_id")
+		}).
+		Find(&occurrences).Error
+	if err != nil {
+		return nil, err
+	}
+	return occurrences, nil
 }
 
-func (r *occurrenceRepository) Search(params SearchParams) ([]handler.SearchResult, error){
+// FindForExport はDarwin Coreの出力に必要な関連情報を全部読み込んで取得するのだ
+func (r *occurrenceRepository) FindForExport(params SearchParams) ([]model.Occurrence, error) {
 	var occurrences []model.Occurrence
+	err := r.filtered(params).
+		Preload("User").
+		Preload("Project").
+		Preload("ClassificationJSON").
+		Preload("Place", withLatLon).
+		Preload("Place.PlaceName").
+		Preload("Individual").
+		Preload("Observations").
+		Preload("Specimens.InstitutionIDCode").
+		Preload("Specimens.CollectionIDCode").
+		Preload("Identifications", func(db *gorm.DB) *gorm.DB {
+			return db.Order("identificated_at DESC")
+		}).
+		Preload("Identifications.User").
+		Preload("Attachments").
+		Preload("Measurements", func(db *gorm.DB) *gorm.DB {
+			return db.Order("measurement_id")
+		}).
+		Preload("Measurements.MeasurementType").
+		Preload("Measurements.MeasuredBy").
+		Find(&occurrences).Error
+	if err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
 
+// withLatLon はgeography型の座標から緯度経度を取り出してSELECTするのだ
+func withLatLon(db *gorm.DB) *gorm.DB {
+	return db.Select("places.*, ST_Y(coordinates::geometry) AS latitude, ST_X(coordinates::geometry) AS longitude")
+}
+
+// filtered は検索条件をWHERE句に組み立てるのだ
+func (r *occurrenceRepository) filtered(p SearchParams) *gorm.DB {
 	query := r.db.Model(&model.Occurrence{})
 
+	if p.UserID != nil {
+		query = query.Where("occurrence.user_id = ?", *p.UserID)
+	}
+	if p.ProjectID != nil {
+		query = query.Where("occurrence.project_id = ?", *p.ProjectID)
+	}
+	if p.OccurrenceDateStart != nil {
+		query = query.Where("occurrence.created_at >= ?", *p.OccurrenceDateStart)
+	}
+	if p.OccurrenceDateEnd != nil {
+		query = query.Where("occurrence.created_at < ?", *p.OccurrenceDateEnd)
+	}
+	if p.Lifestage != nil {
+		query = query.Where("occurrence.lifestage ILIKE ? ESCAPE '\\'", like(*p.Lifestage))
+	}
+	if p.Sex != nil {
+		query = query.Where("occurrence.sex ILIKE ? ESCAPE '\\'", like(*p.Sex))
+	}
+	if p.Note != nil {
+		query = query.Where("occurrence.note ILIKE ? ESCAPE '\\'", like(*p.Note))
+	}
+
+	for key, value := range p.Classification {
+		if !classificationKeys[key] || value == "" {
+			continue
+		}
+		query = query.Where("occurrence.classification_id IN (SELECT classification_id FROM classification_json WHERE class_classification->>? ILIKE ? ESCAPE '\\')", key, like(value))
+	}
+
+	// 観察
+	if p.ObservationUserID != nil || p.ObservationMethodID != nil || p.ObservationDateStart != nil || p.ObservationDateEnd != nil || p.Behavior != nil {
+		sub := r.db.Table("observations").Select("occurrence_id")
+		if p.ObservationUserID != nil {
+			sub = sub.Where("user_id = ?", *p.ObservationUserID)
+		}
+		if p.ObservationMethodID != nil {
+			sub = sub.Where("observation_method_id = ?", *p.ObservationMethodID)
+		}
+		if p.ObservationDateStart != nil {
+			sub = sub.Where("observed_at >= ?", *p.ObservationDateStart)
+		}
+		if p.ObservationDateEnd != nil {
+			sub = sub.Where("observed_at < ?", *p.ObservationDateEnd)
+		}
+		if p.Behavior != nil {
+			sub = sub.Where("behavior ILIKE ? ESCAPE '\\'", like(*p.Behavior))
+		}
+		query = query.Where("occurrence.occurrence_id IN (?)", sub)
+	}
+
+	// 同定
+	if p.IdentUserID != nil || p.IdentificationDateStart != nil || p.IdentificationDateEnd != nil || p.SourceInfo != nil {
+		sub := r.db.Table("identifications").Select("occurrence_id")
+		if p.IdentUserID != nil {
+			sub = sub.Where("user_id = ?", *p.IdentUserID)
+		}
+		if p.IdentificationDateStart != nil {
+			sub = sub.Where("identificated_at >= ?", *p.IdentificationDateStart)
+		}
+		if p.IdentificationDateEnd != nil {
+			sub = sub.Where("identificated_at < ?", *p.IdentificationDateEnd)
+		}
+		if p.SourceInfo != nil {
+			sub = sub.Where("source_info ILIKE ? ESCAPE '\\'", like(*p.SourceInfo))
+		}
+		query = query.Where("occurrence.occurrence_id IN (?)", sub)
+	}
+
+	// 標本
+	if p.SpecimenMethodID != nil || p.InstitutionID != nil || p.CollectionID != nil || p.SpecimenStatus != nil {
+		sub := r.db.Table("specimen").Select("occurrence_id")
+		if p.SpecimenMethodID != nil {
+			sub = sub.Where("specimen_method_id = ?", *p.SpecimenMethodID)
+		}
+		if p.InstitutionID != nil {
+			sub = sub.Where("institution_id = ?", *p.InstitutionID)
+		}
+		if p.CollectionID != nil {
+			sub = sub.Where("collection_id = ?", *p.CollectionID)
+		}
+		if p.SpecimenStatus != nil {
+			sub = sub.Where("status = ?", *p.SpecimenStatus)
+		}
+		query = query.Where("occurrence.occurrence_id IN (?)", sub)
+	}
+
+	// 標本作製
+	if p.SpecimenUserID != nil || p.SpecimenDateStart != nil || p.SpecimenDateEnd != nil {
+		sub := r.db.Table("make_specimen").Select("occurrence_id")
+		if p.SpecimenUserID != nil {
+			sub = sub.Where("user_id = ?", *p.SpecimenUserID)
+		}
+		if p.SpecimenDateStart != nil {
+			sub = sub.Where("date >= ?", *p.SpecimenDateStart)
+		}
+		if p.SpecimenDateEnd != nil {
+			sub = sub.Where("date < ?", *p.SpecimenDateEnd)
+		}
+		query = query.Where("occurrence.occurrence_id IN (?)", sub)
+	}
+
+	// 場所 (標高・水深は範囲が重なるものを探すのだ)
+	if p.PlaceName != nil || p.ElevationMin != nil || p.ElevationMax != nil || p.DepthMin != nil || p.DepthMax != nil || p.Habitat != nil {
+		sub := r.db.Table("places").Select("place_id")
+		if p.PlaceName != nil {
+			sub = sub.Where("(place_name_id IN (SELECT place_name_id FROM place_names_json WHERE class_place_name::text ILIKE ? ESCAPE '\\') OR verbatim_locality ILIKE ? ESCAPE '\\')",
+				like(*p.PlaceName), like(*p.PlaceName))
+		}
+		if p.ElevationMin != nil {
+			sub = sub.Where("COALESCE(maximum_elevation_in_meters, minimum_elevation_in_meters) >= ?", *p.ElevationMin)
+		}
+		if p.ElevationMax != nil {
+			sub = sub.Where("COALESCE(minimum_elevation_in_meters, maximum_elevation_in_meters) <= ?", *p.ElevationMax)
+		}
+		if p.DepthMin != nil {
+			sub = sub.Where("COALESCE(maximum_depth_in_meters, minimum_depth_in_meters) >= ?", *p.DepthMin)
+		}
+		if p.DepthMax != nil {
+			sub = sub.Where("COALESCE(minimum_depth_in_meters, maximum_depth_in_meters) <= ?", *p.DepthMax)
+		}
+		if p.Habitat != nil {
+			sub = sub.Where("habitat ILIKE ? ESCAPE '\\'", like(*p.Habitat))
+		}
+		query = query.Where("occurrence.place_id IN (?)", sub)
+	}
+
+	if p.EventID != nil {
+		query = query.Where("occurrence.event_id IN ("+eventSubtreeSQL+")", *p.EventID)
+	}
+	if p.IndividualID != nil {
+		query = query.Where("occurrence.individual_id = ?", *p.IndividualID)
+	}
+
+	// 計測値 (範囲を指定しなければ、その種類の計測があるものを探すのだ)
+	if p.MeasurementTypeID != nil {
+		sub := r.db.Table("measurements").Select("occurrence_id").Where("measurement_type_id = ?", *p.MeasurementTypeID)
+		if p.MeasurementMin != nil {
+			sub = sub.Where("value_number >= ?", *p.MeasurementMin)
+		}
+		if p.MeasurementMax != nil {
+			sub = sub.Where("value_number <= ?", *p.MeasurementMax)
+		}
+		query = query.Where("occurrence.occurrence_id IN (?)", sub)
+	}
+
+	query = query.Order("occurrence.occurrence_id DESC")
+	if p.Limit > 0 {
+		query = query.Limit(p.Limit)
+	}
+	if p.Offset > 0 {
+		query = query.Offset(p.Offset)
+	}
+	return query
+}
+
+// like は部分一致検索用に % で囲むのだ
+// 入力の % や _ はワイルドカードにならないようにエスケープするのだ
+func like(value string) string {
+	return "%" + escapeLike(value) + "%"
 }
@@ -14,6 +14,9 @@ type PlaceRepository interface {
 	Create(tx *gorm.DB, place *model.Place) (*model.Place, error)
 	Update(tx *gorm.DB, place *model.Place) (*model.Place, error)
 	Delete(tx *gorm.DB, id uint) error
+	FindWithCoordinatesAfter(afterID uint, limit int) ([]model.Place, error)
+	FindPlaceNameByID(id uint) (*model.PlaceNameJSON, error)
+	UpdatePlaceName(tx *gorm.DB, placeName *model.PlaceNameJSON) (*model.PlaceNameJSON, error)
 }
 
 type placeRepository struct {
@@ -66,3 +69,33 @@ func (r *placeRepository) Delete(tx *gorm.DB, id uint) error {
 	}
 	return nil
 }
+
+// FindWithCoordinatesAfter は座標を持つ場所をIDの昇順でlimit件ずつ取得するのだ(バッチ処理用)
+func (r *placeRepository) FindWithCoordinatesAfter(afterID uint, limit int) ([]model.Place, error) {
+	var places []model.Place
+	err := r.db.Where("place_id > ? AND coordinates IS NOT NULL", afterID).
+		Order("place_id").
+		Limit(limit).
+		Find(&places).Error
+	if err != nil {
+		return nil, err
+	}
+	return places, nil
+}
+
+// FindPlaceNameByID はIDで地名JSONを1件取得するのだ
+func (r *placeRepository) FindPlaceNameByID(id uint) (*model.PlaceNameJSON, error) {
+	var placeName model.PlaceNameJSON
+	if err := r.db.First(&placeName, id).Error; err != nil {
+		return nil, err
+	}
+	return &placeName, nil
+}
+
+// UpdatePlaceName は地名JSONを更新するのだ
+func (r *placeRepository) UpdatePlaceName(tx *gorm.DB, placeName *model.PlaceNameJSON) (*model.PlaceNameJSON, error) {
+	if err := tx.Save(placeName).Error; err != nil {
+		return nil, err
+	}
+	return placeName, nil
+}
@@ -2,11 +2,34 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/saku-730/specimen-web/backend/internal/model"
 
 	"gorm.io/gorm"
 )
 
+// topCollectorLimit はプロジェクトのまとめに出す記録の多い人の数なのだ
+const topCollectorLimit = 10
+
+// ProjectCollector はプロジェクトで発生情報を記録した人と、その数なのだ
+type ProjectCollector struct {
+	UserID          uint   `json:"user_id"`
+	UserName        string `json:"user_name"`
+	DisplayName     string `json:"display_name"`
+	OccurrenceCount int64  `json:"occurrence_count"`
+}
+
+// ProjectSummary はプロジェクトの発生情報のまとめなのだ
+// 種の数は分類に種名まである発生情報の学名を数えるのだ。期間は発生情報の日時の最初と最後なのだ
+type ProjectSummary struct {
+	OccurrenceCount int64              `json:"occurrence_count"`
+	SpeciesCount    int64              `json:"species_count"`
+	FirstOccurrence *time.Time         `json:"first_occurrence"`
+	LastOccurrence  *time.Time         `json:"last_occurrence"`
+	TopCollectors   []ProjectCollector `json:"top_collectors"`
+}
+
 // ProjectRepository はプロジェクト関連のデータ操作の契約書なのだ
 type ProjectRepository interface {
 	FindByID(id uint) (*model.Project, error)
@@ -14,7 +37,16 @@ type ProjectRepository interface {
 	Create(tx *gorm.DB, project *model.Project) (*model.Project, error)
 	Update(tx *gorm.DB, project *model.Project) (*model.Project, error)
 	Delete(tx *gorm.DB, id uint) error
+	HasOccurrences(id uint) (bool, error)
+	Summarize(id uint) (*ProjectSummary, error)
+
+	// メンバーなのだ
+	FindMemberByID(id uint) (*model.ProjectMember, error)
+	UserExists(id uint) (bool, error)
+	IsActiveMember(projectID, userID uint) (bool, error)
 	AddMember(tx *gorm.DB, member *model.ProjectMember) (*model.ProjectMember, error)
+	EndMember(tx *gorm.DB, id uint, finishDay time.Time) error
+	RemoveMember(tx *gorm.DB, id uint) error
 }
 
 type projectRepository struct {
@@ -30,7 +62,13 @@ func NewProjectRepository(db *gorm.DB) ProjectRepository {
 func (r *projectRepository) FindByID(id uint) (*model.Project, error) {
 	var project model.Project
 	// Preloadを使うと、関連するProjectMembersと、さらにその中のUser情報も一緒に取得できるのだ
-	if err := r.db.Preload("ProjectMembers.User").First(&project, id).Error; err != nil {
+	err := r.db.
+		Preload("ProjectMembers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("join_day, project_member_id")
+		}).
+		Preload("ProjectMembers.User").
+		First(&project, id).Error
+	if err != nil {
 		return nil, err
 	}
 	return &project, nil
@@ -39,7 +77,7 @@ func (r *projectRepository) FindByID(id uint) (*model.Project, error) {
 // FindAll は全てのプロジェクトを取得するのだ
 func (r *projectRepository) FindAll() ([]model.Project, error) {
 	var projects []model.Project
-	if err := r.db.Find(&projects).Error; err != nil {
+	if err := r.db.Order("project_id").Find(&projects).Error; err != nil {
 		return nil, err
 	}
 	return projects, nil
@@ -47,32 +85,114 @@ func (r *projectRepository) FindAll() ([]model.Project, error) {
 
 // Create は新しいプロジェクトを作成するのだ
 func (r *projectRepository) Create(tx *gorm.DB, project *model.Project) (*model.Project, error) {
-	if err := tx.Create(project).Error; err != nil {
+	if err := tx.Omit("ProjectMembers").Create(project).Error; err != nil {
 		return nil, err
 	}
 	return project, nil
 }
 
-// AddMember はプロジェクトに新しいメンバーを追加するのだ
-func (r *projectRepository) AddMember(tx *gorm.DB, member *model.ProjectMember) (*model.ProjectMember, error) {
-	if err := tx.Create(member).Error; err != nil {
-		return nil, err
-	}
-	return member, nil
-}
-
-// Update はプロジェクト情報を更新するのだ
+// Update はプロジェクト情報を更新するのだ。updated_day はDBのトリガーが付けるのだ
 func (r *projectRepository) Update(tx *gorm.DB, project *model.Project) (*model.Project, error) {
-	if err := tx.Save(project).Error; err != nil {
+	err := tx.Model(&model.Project{ProjectID: project.ProjectID}).
+		Select("project_name", "description", "start_day", "finished_day", "note").
+		Updates(project).Error
+	if err != nil {
 		return nil, err
 	}
 	return project, nil
 }
 
-// Delete はIDを元にプロジェクトを削除するのだ
+// Delete はIDを元にプロジェクトを削除するのだ。メンバーの記録も一緒に消すのだ
 func (r *projectRepository) Delete(tx *gorm.DB, id uint) error {
+	if err := tx.Where("project_id = ?", id).Delete(&model.ProjectMember{}).Error; err != nil {
+		return err
+	}
 	if err := tx.Delete(&model.Project{}, id).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+// HasOccurrences はプロジェクトに発生情報が付いているか確かめるのだ
+func (r *projectRepository) HasOccurrences(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Occurrence{}).Where("project_id = ?", id))
+}
+
+// projectSpeciesSQL は分類JSONから学名を作る式なのだ。種名がなければNULLなので、種の数に入らないのだ
+// 種名が属名から書いてあるときはそのまま使うのだ (サービスの scientificNameOf と同じ当てはめなのだ)
+const projectSpeciesSQL = `CASE
+	WHEN coalesce(c.class_classification->>'species', '') = '' THEN NULL
+	WHEN coalesce(c.class_classification->>'genus', '') = ''
+		OR c.class_classification->>'species' LIKE (c.class_classification->>'genus') || ' %'
+		THEN lower(c.class_classification->>'species')
+	ELSE lower((c.class_classification->>'genus') || ' ' || (c.class_classification->>'species'))
+END`
+
+// Summarize はプロジェクトの発生情報の数・種の数・期間と、記録の多い人を集計するのだ
+func (r *projectRepository) Summarize(id uint) (*ProjectSummary, error) {
+	var summary ProjectSummary
+	err := r.db.Table("occurrence o").
+		Select("count(*) AS occurrence_count, count(DISTINCT "+projectSpeciesSQL+") AS species_count, "+
+			"min(o.created_at) AS first_occurrence, max(o.created_at) AS last_occurrence").
+		Joins("LEFT JOIN classification_json c ON c.classification_id = o.classification_id").
+		Where("o.project_id = ?", id).
+		Scan(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary.TopCollectors = []ProjectCollector{}
+	err = r.db.Table("occurrence o").
+		Select("u.user_id, u.user_name, u.display_name, count(*) AS occurrence_count").
+		Joins("JOIN users u ON u.user_id = o.user_id").
+		Where("o.project_id = ?", id).
+		Group("u.user_id, u.user_name, u.display_name").
+		Order("occurrence_count DESC, u.user_id").
+		Limit(topCollectorLimit).
+		Scan(&summary.TopCollectors).Error
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// FindMemberByID はIDでメンバーの記録を1件取得するのだ
+func (r *projectRepository) FindMemberByID(id uint) (*model.ProjectMember, error) {
+	var member model.ProjectMember
+	if err := r.db.Preload("User").First(&member, id).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// UserExists はユーザーがいるか確かめるのだ
+func (r *projectRepository) UserExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.User{}).Where("user_id = ?", id))
+}
+
+// IsActiveMember はユーザーが今プロジェクトのメンバーか確かめるのだ (抜けた日がないか、まだ来ていないのだ)
+func (r *projectRepository) IsActiveMember(projectID, userID uint) (bool, error) {
+	return exists(r.db.Model(&model.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", projectID, userID).
+		Where("finish_day IS NULL OR finish_day > current_date"))
+}
+
+// AddMember はプロジェクトに新しいメンバーを追加するのだ
+func (r *projectRepository) AddMember(tx *gorm.DB, member *model.ProjectMember) (*model.ProjectMember, error) {
+	if err := tx.Omit("Project", "User").Create(member).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// EndMember はメンバーが抜けた日を記録するのだ。記録は残すので、前のメンバーとして見られるのだ
+func (r *projectRepository) EndMember(tx *gorm.DB, id uint, finishDay time.Time) error {
+	return tx.Model(&model.ProjectMember{}).
+		Where("project_member_id = ?", id).
+		Update("finish_day", finishDay).Error
+}
+
+// RemoveMember はメンバーの記録を消すのだ。間違えて追加したときに使うのだ
+func (r *projectRepository) RemoveMember(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.ProjectMember{}, id).Error
+}
@@ -2,19 +2,78 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/saku-730/specimen-web/backend/internal/model"
 
 	"gorm.io/gorm"
 )
 
+// SpecimenListParams は標本一覧の絞り込みとページ送りの条件なのだ。nilの条件は使わないのだ
+type SpecimenListParams struct {
+	OccurrenceID      *uint
+	InstitutionID     *uint
+	CollectionID      *uint
+	SpecimenMethodID  *uint
+	CatalogNumber     string // 前方一致なのだ
+	StorageLocationID *uint  // 下の置き場所にある標本も含めるのだ
+	Status            string // 館内にあるか貸し出し中かなのだ
+
+	Limit  int
+	Offset int
+}
+
+// PreparationListParams は作製の記録の絞り込み条件なのだ。空の条件は使わないのだ
+type PreparationListParams struct {
+	UserID   *uint
+	Statuses []string
+}
+
+// SpecimenSummary は標本のIDと登録番号だけなのだ
+type SpecimenSummary struct {
+	SpecimenID    uint    `json:"specimen_id"`
+	CatalogNumber *string `json:"catalog_number"`
+}
+
 // SpecimenRepository は標本関連のデータ操作の契約書なのだ
 type SpecimenRepository interface {
 	FindByID(id uint) (*model.Specimen, error)
 	FindAll() ([]model.Specimen, error)
 	FindByConditions(conditions *model.Specimen) ([]model.Specimen, error)
+	FindByCatalogNumber(number string) (*model.Specimen, error)
+	FindForLabels(ids []uint) ([]model.Specimen, error)
+	List(params SpecimenListParams) ([]model.Specimen, int64, error)
+	FindSummaries(params SpecimenListParams) ([]SpecimenSummary, error)
+	FindSummariesByCodes(ids []uint, catalogNumbers []string) ([]SpecimenSummary, error)
 	Create(tx *gorm.DB, specimen *model.Specimen) (*model.Specimen, error)
 	Update(tx *gorm.DB, specimen *model.Specimen) (*model.Specimen, error)
 	Delete(tx *gorm.DB, id uint) error
+
+	// 部位と取り分けなのだ
+	FindByOccurrence(occurrenceID uint) ([]model.Specimen, error)
+	HasParts(id uint) (bool, error)
+
+	// 標本作製の履歴なのだ
+	CreateMakeSpecimen(tx *gorm.DB, makeSpecimen *model.MakeSpecimen) (*model.MakeSpecimen, error)
+	FindMakeSpecimenByID(id uint) (*model.MakeSpecimen, error)
+	UpdateMakeSpecimen(tx *gorm.DB, makeSpecimen *model.MakeSpecimen) (*model.MakeSpecimen, error)
+	FindPreparations(params PreparationListParams) ([]model.MakeSpecimen, error)
+
+	// 作製方法・機関コード・コレクションコードなのだ
+	FindAllMethods() ([]model.SpecimenMethod, error)
+	MethodExists(id uint) (bool, error)
+	InstitutionExists(id uint) (bool, error)
+	CollectionExists(id uint) (bool, error)
+	FindInstitutionByID(id uint) (*model.InstitutionIDCode, error)
+	FindAllInstitutions() ([]model.InstitutionIDCode, error)
+	FindCollectionByID(id uint) (*model.CollectionIDCode, error)
+	FindAllCollections() ([]model.CollectionIDCode, error)
+	UpdateCollectionPattern(tx *gorm.DB, id uint, pattern *string) error
+
+	// 登録番号の採番なのだ
+	CatalogNumberExists(tx *gorm.DB, number string) (bool, error)
+	NextCatalogSequence(tx *gorm.DB, collectionID uint, scope string) (int64, error)
+	SetCatalogNumber(tx *gorm.DB, id uint, number string) error
 }
 
 type specimenRepository struct {
@@ -30,17 +89,30 @@ func NewSpecimenRepository(db *gorm.DB) SpecimenRepository {
 func (r *specimenRepository) FindByID(id uint) (*model.Specimen, error) {
 	var specimen model.Specimen
 	// 標本は多くの情報と紐づいているので、必要なものをPreloadで指定するのだ
-	err := r.db.Preload("Occurrence").
-		Preload("SpecimenMethod").
-		Preload("InstitutionIDCode").
-		Preload("CollectionIDCode").
-		First(&specimen, id).Error
+	err := withSpecimenDetails(r.db).First(&specimen, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &specimen, nil
 }
 
+// withSpecimenDetails は標本と一緒に返す発生情報と作製の履歴を読み込むのだ
+func withSpecimenDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Occurrence").
+		Preload("Occurrence.ClassificationJSON").
+		Preload("Occurrence.Place", withLatLon).
+		Preload("Occurrence.Place.PlaceName").
+		Preload("SpecimenMethod").
+		Preload("InstitutionIDCode").
+		Preload("CollectionIDCode").
+		Preload("StorageLocation").
+		Preload("MakeSpecimens", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date NULLS LAST").Order("created_at").Order("make_specimen_id")
+		}).
+		Preload("MakeSpecimens.User").
+		Preload("MakeSpecimens.SpecimenMethod")
+}
+
 // FindAll は全ての標本を取得するのだ
 func (r *specimenRepository) FindAll() ([]model.Specimen, error) {
 	var specimens []model.Specimen
@@ -59,6 +131,132 @@ func (r *specimenRepository) FindByConditions(conditions *model.Specimen) ([]mod
 	return specimens, nil
 }
 
+// FindByCatalogNumber は登録番号で標本を1件取得するのだ。大文字小文字は区別しないのだ
+func (r *specimenRepository) FindByCatalogNumber(number string) (*model.Specimen, error) {
+	var specimen model.Specimen
+	err := withSpecimenDetails(r.db).
+		Where("lower(catalog_number) = lower(?)", number).
+		First(&specimen).Error
+	if err != nil {
+		return nil, err
+	}
+	return &specimen, nil
+}
+
+// FindForLabels はラベルに載せる情報 (場所・採集者・同定) を全部読み込んで標本を取得するのだ
+func (r *specimenRepository) FindForLabels(ids []uint) ([]model.Specimen, error) {
+	var specimens []model.Specimen
+	err := r.db.Where("specimen_id IN ?", ids).
+		Preload("Occurrence").
+		Preload("Occurrence.User").
+		Preload("Occurrence.ClassificationJSON").
+		Preload("Occurrence.Place", withLatLon).
+		Preload("Occurrence.Place.PlaceName").
+		Preload("Occurrence.Observations").
+		Preload("Occurrence.Identifications", func(db *gorm.DB) *gorm.DB {
+			return db.Order("identificated_at DESC")
+		}).
+		Preload("Occurrence.Identifications.User").
+		Preload("InstitutionIDCode").
+		Preload("CollectionIDCode").
+		Find(&specimens).Error
+	if err != nil {
+		return nil, err
+	}
+	return specimens, nil
+}
+
+// List は条件に合う標本を新しい順に取得し、全体の件数も返すのだ
+func (r *specimenRepository) List(params SpecimenListParams) ([]model.Specimen, int64, error) {
+	query := r.filtered(params)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("specimen.specimen_id DESC")
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		query = query.Offset(params.Offset)
+	}
+
+	var specimens []model.Specimen
+	if err := withSpecimenDetails(query).Find(&specimens).Error; err != nil {
+		return nil, 0, err
+	}
+	return specimens, total, nil
+}
+
+// FindSummaries は条件に合う全ての標本のIDと登録番号だけを取得するのだ (棚卸しで数が多くなるので軽くしてあるのだ)
+func (r *specimenRepository) FindSummaries(params SpecimenListParams) ([]SpecimenSummary, error) {
+	var summaries []SpecimenSummary
+	err := r.filtered(params).
+		Select("specimen.specimen_id", "specimen.catalog_number").
+		Order("specimen.specimen_id").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// FindSummariesByCodes はIDか登録番号 (大文字小文字は区別しないのだ) に当たる標本のIDと登録番号を取得するのだ
+func (r *specimenRepository) FindSummariesByCodes(ids []uint, catalogNumbers []string) ([]SpecimenSummary, error) {
+	if len(ids) == 0 && len(catalogNumbers) == 0 {
+		return nil, nil
+	}
+	lowered := make([]string, len(catalogNumbers))
+	for i, n := range catalogNumbers {
+		lowered[i] = strings.ToLower(n)
+	}
+
+	query := r.db.Model(&model.Specimen{}).Select("specimen_id", "catalog_number")
+	switch {
+	case len(ids) > 0 && len(lowered) > 0:
+		query = query.Where("specimen_id IN ? OR lower(catalog_number) IN ?", ids, lowered)
+	case len(ids) > 0:
+		query = query.Where("specimen_id IN ?", ids)
+	default:
+		query = query.Where("lower(catalog_number) IN ?", lowered)
+	}
+
+	var summaries []SpecimenSummary
+	if err := query.Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// filtered は一覧の絞り込み条件をクエリにするのだ
+func (r *specimenRepository) filtered(params SpecimenListParams) *gorm.DB {
+	query := r.db.Model(&model.Specimen{})
+	if params.OccurrenceID != nil {
+		query = query.Where("specimen.occurrence_id = ?", *params.OccurrenceID)
+	}
+	if params.InstitutionID != nil {
+		query = query.Where("specimen.institution_id = ?", *params.InstitutionID)
+	}
+	if params.CollectionID != nil {
+		query = query.Where("specimen.collection_id = ?", *params.CollectionID)
+	}
+	if params.SpecimenMethodID != nil {
+		query = query.Where("specimen.specimen_method_id = ?", *params.SpecimenMethodID)
+	}
+	if params.CatalogNumber != "" {
+		query = query.Where("lower(specimen.catalog_number) LIKE lower(?) ESCAPE '\\'", escapeLike(params.CatalogNumber)+"%")
+	}
+	if params.Status != "" {
+		query = query.Where("specimen.status = ?", params.Status)
+	}
+	if params.StorageLocationID != nil {
+		query = query.Where("specimen.storage_location_id IN ("+storageSubtreeSQL+")", *params.StorageLocationID)
+	}
+	return query
+}
+
 // Create は新しい標本を作成するのだ
 func (r *specimenRepository) Create(tx *gorm.DB, specimen *model.Specimen) (*model.Specimen, error) {
 	if err := tx.Create(specimen).Error; err != nil {
@@ -68,18 +266,230 @@ func (r *specimenRepository) Create(tx *gorm.DB, specimen *model.Specimen) (*mod
 }
 
 // Update は標本情報を更新するのだ
+// 作製方法・機関・コレクションは外すこともあるので、nilでも列を書き換えるのだ
 func (r *specimenRepository) Update(tx *gorm.DB, specimen *model.Specimen) (*model.Specimen, error) {
-	if err := tx.Model(&model.Specimen{SpecimenID: specimen.SpecimenID}).Updates(specimen).Error; err != nil {
+	err := tx.Model(&model.Specimen{SpecimenID: specimen.SpecimenID}).
+		Select("occurrence_id", "specimen_method_id", "institution_id", "collection_id", "part", "parent_specimen_id").
+		Updates(specimen).Error
+	if err != nil {
 		return nil, err
 	}
 	return specimen, nil
 }
 
-// Delete はIDを元に標本を削除するのだ
+// Delete はIDを元に標本を削除するのだ。作製の履歴も一緒に消すのだ
 func (r *specimenRepository) Delete(tx *gorm.DB, id uint) error {
+	if err := tx.Where("specimen_id = ?", id).Delete(&model.MakeSpecimen{}).Error; err != nil {
+		return err
+	}
 	if err := tx.Delete(&model.Specimen{}, id).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
+// FindByOccurrence は発生情報から作った全ての標本を、作製の記録つきで古い順に取得するのだ
+func (r *specimenRepository) FindByOccurrence(occurrenceID uint) ([]model.Specimen, error) {
+	var specimens []model.Specimen
+	err := r.db.Where("occurrence_id = ?", occurrenceID).
+		Preload("SpecimenMethod").
+		Preload("InstitutionIDCode").
+		Preload("CollectionIDCode").
+		Preload("StorageLocation").
+		Preload("MakeSpecimens", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date NULLS LAST").Order("created_at").Order("make_specimen_id")
+		}).
+		Preload("MakeSpecimens.User").
+		Preload("MakeSpecimens.SpecimenMethod").
+		Order("specimen_id").
+		Find(&specimens).Error
+	if err != nil {
+		return nil, err
+	}
+	return specimens, nil
+}
+
+// HasParts は標本から取り分けた標本があるか確かめるのだ
+func (r *specimenRepository) HasParts(id uint) (bool, error) {
+	return exists(r.db.Model(&model.Specimen{}).Where("parent_specimen_id = ?", id))
+}
+
+// CreateMakeSpecimen は標本作製の履歴を1件追加するのだ
+func (r *specimenRepository) CreateMakeSpecimen(tx *gorm.DB, makeSpecimen *model.MakeSpecimen) (*model.MakeSpecimen, error) {
+	if err := tx.Omit("Occurrence", "User", "Specimen", "SpecimenMethod").Create(makeSpecimen).Error; err != nil {
+		return nil, err
+	}
+	return makeSpecimen, nil
+}
+
+// FindMakeSpecimenByID はIDで作製の記録を1件取得するのだ
+func (r *specimenRepository) FindMakeSpecimenByID(id uint) (*model.MakeSpecimen, error) {
+	var makeSpecimen model.MakeSpecimen
+	if err := r.db.Preload("User").Preload("SpecimenMethod").First(&makeSpecimen, id).Error; err != nil {
+		return nil, err
+	}
+	return &makeSpecimen, nil
+}
+
+// UpdateMakeSpecimen は作製の記録の作製者・日付・方法・進み具合・メモを更新するのだ
+func (r *specimenRepository) UpdateMakeSpecimen(tx *gorm.DB, makeSpecimen *model.MakeSpecimen) (*model.MakeSpecimen, error) {
+	err := tx.Model(&model.MakeSpecimen{MakeSpecimenID: makeSpecimen.MakeSpecimenID}).
+		Select("user_id", "date", "specimen_method_id", "status", "note").
+		Updates(makeSpecimen).Error
+	if err != nil {
+		return nil, err
+	}
+	return makeSpecimen, nil
+}
+
+// FindPreparations は条件に合う作製の記録を、作製者ごとに登録の古い順で取得するのだ
+// 作業待ちの一覧に出すので、どの個体のどの標本かが分かるように標本と学名も読み込むのだ
+func (r *specimenRepository) FindPreparations(params PreparationListParams) ([]model.MakeSpecimen, error) {
+	query := r.db.Model(&model.MakeSpecimen{})
+	if params.UserID != nil {
+		query = query.Where("user_id = ?", *params.UserID)
+	}
+	if len(params.Statuses) > 0 {
+		query = query.Where("status IN ?", params.Statuses)
+	}
+
+	var preparations []model.MakeSpecimen
+	err := query.
+		Preload("User").
+		Preload("SpecimenMethod").
+		Preload("Specimen").
+		Preload("Occurrence").
+		Preload("Occurrence.ClassificationJSON").
+		Order("user_id").
+		Order("created_at").
+		Order("make_specimen_id").
+		Find(&preparations).Error
+	if err != nil {
+		return nil, err
+	}
+	return preparations, nil
+}
+
+// FindAllMethods は使うのをやめていない標本作製方法を並び順で取得するのだ
+func (r *specimenRepository) FindAllMethods() ([]model.SpecimenMethod, error) {
+	var methods []model.SpecimenMethod
+	if err := r.db.Where("NOT deprecated").Order("sort_order, specimen_methods_id").Find(&methods).Error; err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// MethodExists は標本作製方法があるか確かめるのだ
+func (r *specimenRepository) MethodExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.SpecimenMethod{}).Where("specimen_methods_id = ?", id))
+}
+
+// InstitutionExists は機関コードがあるか確かめるのだ
+func (r *specimenRepository) InstitutionExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.InstitutionIDCode{}).Where("institution_id = ?", id))
+}
+
+// CollectionExists はコレクションコードがあるか確かめるのだ
+func (r *specimenRepository) CollectionExists(id uint) (bool, error) {
+	return exists(r.db.Model(&model.CollectionIDCode{}).Where("collection_id = ?", id))
+}
+
+// FindInstitutionByID は機関コードを1件取得するのだ
+func (r *specimenRepository) FindInstitutionByID(id uint) (*model.InstitutionIDCode, error) {
+	var institution model.InstitutionIDCode
+	if err := r.db.First(&institution, id).Error; err != nil {
+		return nil, err
+	}
+	return &institution, nil
+}
+
+// FindAllInstitutions は使うのをやめていない機関コードを並び順で取得するのだ
+func (r *specimenRepository) FindAllInstitutions() ([]model.InstitutionIDCode, error) {
+	var institutions []model.InstitutionIDCode
+	if err := r.db.Where("NOT deprecated").Order("sort_order, institution_id").Find(&institutions).Error; err != nil {
+		return nil, err
+	}
+	return institutions, nil
+}
+
+// FindCollectionByID はコレクションコードを1件取得するのだ
+func (r *specimenRepository) FindCollectionByID(id uint) (*model.CollectionIDCode, error) {
+	var collection model.CollectionIDCode
+	if err := r.db.First(&collection, id).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// FindAllCollections は使うのをやめていないコレクションコードを並び順で取得するのだ
+func (r *specimenRepository) FindAllCollections() ([]model.CollectionIDCode, error) {
+	var collections []model.CollectionIDCode
+	if err := r.db.Where("NOT deprecated").Order("sort_order, collection_id").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// UpdateCollectionPattern はコレクションの登録番号の形を変えるのだ。nilなら既定の形に戻すのだ
+func (r *specimenRepository) UpdateCollectionPattern(tx *gorm.DB, id uint, pattern *string) error {
+	result := tx.Model(&model.CollectionIDCode{}).
+		Where("collection_id = ?", id).
+		Update("catalog_number_pattern", pattern)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CatalogNumberExists は登録番号がもう使われているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *specimenRepository) CatalogNumberExists(tx *gorm.DB, number string) (bool, error) {
+	return exists(tx.Model(&model.Specimen{}).Where("lower(catalog_number) = lower(?)", number))
+}
+
+// NextCatalogSequence は連番を1つ進めて返すのだ
+// 行ロックがかかるので、同時に登録しても同じ番号は出ないのだ。ロックは呼び出し側のトランザクションが終わるまで続くのだ
+func (r *specimenRepository) NextCatalogSequence(tx *gorm.DB, collectionID uint, scope string) (int64, error) {
+	var value int64
+	err := tx.Raw(`
+		INSERT INTO catalog_number_sequences (collection_id, scope, last_value)
+		VALUES (?, ?, 1)
+		ON CONFLICT (collection_id, scope)
+		DO UPDATE SET last_value = catalog_number_sequences.last_value + 1
+		RETURNING last_value`, collectionID, scope).
+		Scan(&value).Error
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// SetCatalogNumber は登録番号のない標本に番号を付けるのだ。もう付いている標本は変えないのだ
+func (r *specimenRepository) SetCatalogNumber(tx *gorm.DB, id uint, number string) error {
+	result := tx.Model(&model.Specimen{}).
+		Where("specimen_id = ? AND catalog_number IS NULL", id).
+		Update("catalog_number", number)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// escapeLike は LIKE の特別な文字 (% _ \) をそのままの文字として扱うようにするのだ
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// exists は条件に合う行が1件でもあるか確かめるのだ
+func exists(query *gorm.DB) (bool, error) {
+	var count int64
+	if err := query.Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,238 @@
+// backend/internal/repository/storage_location_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// storageSubtreeSQL は置き場所とその下の全ての置き場所のIDを返す副問い合わせなのだ。? に一番上の置き場所のIDを入れるのだ
+const storageSubtreeSQL = `
+	WITH RECURSIVE subtree AS (
+		SELECT storage_location_id FROM storage_locations WHERE storage_location_id = ?
+		UNION ALL
+		SELECT l.storage_location_id FROM storage_locations l JOIN subtree ON l.parent_id = subtree.storage_location_id
+	)
+	SELECT storage_location_id FROM subtree`
+
+// StorageLocationSearchParams は置き場所の検索条件なのだ。空の条件は使わないのだ
+type StorageLocationSearchParams struct {
+	Code     string // 記号の前方一致なのだ (大文字小文字は区別しないのだ)
+	Level    string
+	ParentID *uint
+	RootOnly bool // 親のない置き場所 (建物など) だけにするのだ
+}
+
+// StorageLocationRepository は置き場所関連のデータ操作の契約書なのだ
+type StorageLocationRepository interface {
+	FindByID(id uint) (*model.StorageLocation, error)
+	FindAll() ([]model.StorageLocation, error)
+	Search(params StorageLocationSearchParams) ([]model.StorageLocation, error)
+	FindAncestors(id uint) ([]model.StorageLocation, error)
+	FindChildren(id uint) ([]model.StorageLocation, error)
+	IsInSubtree(rootID, id uint) (bool, error)
+	HasChildren(id uint) (bool, error)
+	CodeTaken(parentID *uint, code string, exceptID uint) (bool, error)
+	CountSpecimens(id uint, includeSublocations bool) (int64, error)
+	Create(tx *gorm.DB, location *model.StorageLocation) (*model.StorageLocation, error)
+	Update(tx *gorm.DB, location *model.StorageLocation) (*model.StorageLocation, error)
+	Delete(tx *gorm.DB, id uint) error
+
+	// 標本の移動と履歴なのだ
+	HasMovements(id uint) (bool, error)
+	FindSpecimensForUpdate(tx *gorm.DB, ids []uint) ([]model.Specimen, error)
+	MoveSpecimens(tx *gorm.DB, ids []uint, to *uint) error
+	CreateMovements(tx *gorm.DB, movements []model.SpecimenMovement) error
+	FindMovements(specimenID uint) ([]model.SpecimenMovement, error)
+}
+
+type storageLocationRepository struct {
+	db *gorm.DB
+}
+
+// NewStorageLocationRepository は新しいリポジトリを生成するのだ
+func NewStorageLocationRepository(db *gorm.DB) StorageLocationRepository {
+	return &storageLocationRepository{db: db}
+}
+
+// FindByID はIDで置き場所を1件取得するのだ
+func (r *storageLocationRepository) FindByID(id uint) (*model.StorageLocation, error) {
+	var location model.StorageLocation
+	if err := r.db.First(&location, id).Error; err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// FindAll は全ての置き場所を記号の順に取得するのだ
+func (r *storageLocationRepository) FindAll() ([]model.StorageLocation, error) {
+	var locations []model.StorageLocation
+	if err := r.db.Order("lower(code)").Order("storage_location_id").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// Search は条件に合う置き場所を記号の順に取得するのだ
+func (r *storageLocationRepository) Search(params StorageLocationSearchParams) ([]model.StorageLocation, error) {
+	query := r.db.Model(&model.StorageLocation{})
+	if params.Code != "" {
+		query = query.Where("lower(code) LIKE lower(?) ESCAPE '\\'", escapeLike(params.Code)+"%")
+	}
+	if params.Level != "" {
+		query = query.Where("level = ?", params.Level)
+	}
+	if params.ParentID != nil {
+		query = query.Where("parent_id = ?", *params.ParentID)
+	}
+	if params.RootOnly {
+		query = query.Where("parent_id IS NULL")
+	}
+
+	var locations []model.StorageLocation
+	if err := query.Order("lower(code)").Order("storage_location_id").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// FindAncestors は置き場所から一番上までたどった置き場所を、上から順に取得するのだ (自分も含むのだ)
+func (r *storageLocationRepository) FindAncestors(id uint) ([]model.StorageLocation, error) {
+	var locations []model.StorageLocation
+	err := r.db.Raw(`
+		WITH RECURSIVE chain AS (
+			SELECT l.*, 0 AS depth FROM storage_locations l WHERE l.storage_location_id = ?
+			UNION ALL
+			SELECT l.*, chain.depth + 1 FROM storage_locations l JOIN chain ON l.storage_location_id = chain.parent_id
+		)
+		SELECT storage_location_id, parent_id, level, code, name, note, created_at
+		FROM chain ORDER BY depth DESC`, id).
+		Scan(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// FindChildren はすぐ下の置き場所を記号の順に取得するのだ
+func (r *storageLocationRepository) FindChildren(id uint) ([]model.StorageLocation, error) {
+	parentID := id
+	return r.Search(StorageLocationSearchParams{ParentID: &parentID})
+}
+
+// IsInSubtree は id が rootID かその下の置き場所か確かめるのだ
+func (r *storageLocationRepository) IsInSubtree(rootID, id uint) (bool, error) {
+	return exists(r.db.Model(&model.StorageLocation{}).
+		Where("storage_location_id = ?", id).
+		Where("storage_location_id IN ("+storageSubtreeSQL+")", rootID))
+}
+
+// HasChildren は下に置き場所があるか確かめるのだ
+func (r *storageLocationRepository) HasChildren(id uint) (bool, error) {
+	return exists(r.db.Model(&model.StorageLocation{}).Where("parent_id = ?", id))
+}
+
+// CodeTaken は同じ親の下に同じ記号の置き場所があるか確かめるのだ (大文字小文字は区別しないのだ)
+// exceptID は記号を変えるときの自分なのだ
+func (r *storageLocationRepository) CodeTaken(parentID *uint, code string, exceptID uint) (bool, error) {
+	query := r.db.Model(&model.StorageLocation{}).
+		Where("lower(code) = lower(?)", code).
+		Where("storage_location_id <> ?", exceptID)
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	return exists(query)
+}
+
+// CountSpecimens は置き場所にある標本を数えるのだ。includeSublocations なら下の置き場所の標本も数えるのだ
+func (r *storageLocationRepository) CountSpecimens(id uint, includeSublocations bool) (int64, error) {
+	query := r.db.Model(&model.Specimen{})
+	if includeSublocations {
+		query = query.Where("storage_location_id IN ("+storageSubtreeSQL+")", id)
+	} else {
+		query = query.Where("storage_location_id = ?", id)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Create は新しい置き場所を作成するのだ
+func (r *storageLocationRepository) Create(tx *gorm.DB, location *model.StorageLocation) (*model.StorageLocation, error) {
+	if err := tx.Create(location).Error; err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// Update は置き場所を更新するのだ。親を外して一番上にすることもあるので、nilでも列を書き換えるのだ
+func (r *storageLocationRepository) Update(tx *gorm.DB, location *model.StorageLocation) (*model.StorageLocation, error) {
+	err := tx.Model(&model.StorageLocation{StorageLocationID: location.StorageLocationID}).
+		Select("parent_id", "level", "code", "name", "note").
+		Updates(location).Error
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// Delete はIDを元に置き場所を削除するのだ
+func (r *storageLocationRepository) Delete(tx *gorm.DB, id uint) error {
+	return tx.Delete(&model.StorageLocation{}, id).Error
+}
+
+// HasMovements は置き場所が標本の移動の履歴に出てくるか確かめるのだ
+func (r *storageLocationRepository) HasMovements(id uint) (bool, error) {
+	return exists(r.db.Model(&model.SpecimenMovement{}).Where("from_location_id = ? OR to_location_id = ?", id, id))
+}
+
+// FindSpecimensForUpdate は動かす標本のIDと今の置き場所を、行ロックをかけて取得するのだ
+// 同時に同じ標本を動かしても、履歴の from と to がつながるようにするのだ
+func (r *storageLocationRepository) FindSpecimensForUpdate(tx *gorm.DB, ids []uint) ([]model.Specimen, error) {
+	var specimens []model.Specimen
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("specimen_id", "storage_location_id").
+		Where("specimen_id IN ?", ids).
+		Order("specimen_id").
+		Find(&specimens).Error
+	if err != nil {
+		return nil, err
+	}
+	return specimens, nil
+}
+
+// MoveSpecimens は標本の置き場所を書き換えるのだ。to がnilなら棚から出すのだ
+func (r *storageLocationRepository) MoveSpecimens(tx *gorm.DB, ids []uint, to *uint) error {
+	return tx.Model(&model.Specimen{}).
+		Where("specimen_id IN ?", ids).
+		Update("storage_location_id", to).Error
+}
+
+// CreateMovements は標本の移動の履歴をまとめて追加するのだ
+func (r *storageLocationRepository) CreateMovements(tx *gorm.DB, movements []model.SpecimenMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	return tx.Omit("FromLocation", "ToLocation", "User").Create(&movements).Error
+}
+
+// FindMovements は標本の移動の履歴を古い順に取得するのだ
+func (r *storageLocationRepository) FindMovements(specimenID uint) ([]model.SpecimenMovement, error) {
+	var movements []model.SpecimenMovement
+	err := r.db.Where("specimen_id = ?", specimenID).
+		Preload("FromLocation").
+		Preload("ToLocation").
+		Preload("User").
+		Order("moved_at").
+		Order("specimen_movement_id").
+		Find(&movements).Error
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
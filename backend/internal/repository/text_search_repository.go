@@ -0,0 +1,126 @@
+// backend/internal/repository/text_search_repository.go
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 全文検索で当たったものの種類なのだ
+const (
+	TextHitWikiPage       = "wiki_page"
+	TextHitOccurrence     = "occurrence"
+	TextHitObservation    = "observation"
+	TextHitIdentification = "identification"
+)
+
+// textSearchSources は種類ごとの検索対象の列なのだ
+// どれも type・id・page_id・occurrence_id・title・body・rank を同じ並びと名前で返すのだ
+// UNION ALL の列名は最初に来たものになるので、どの種類が先頭になっても同じ名前になるよう全部に AS を付けるのだ
+// ? は検索語から作った tsquery で置き換わるのだ
+var textSearchSources = map[string]string{
+	TextHitWikiPage: `
+		SELECT 'wiki_page' AS type, p.page_id AS id, p.page_id AS page_id, NULL::int AS occurrence_id,
+			r.title AS title, r.content AS body, ts_rank_cd(r.search_vector, q.query) AS rank
+		FROM wiki_pages p
+		JOIN wiki_revisions r ON r.revision_id = p.current_revision_id, q
+		WHERE r.search_vector @@ q.query`,
+	TextHitOccurrence: `
+		SELECT 'occurrence' AS type, o.occurrence_id AS id, NULL::int AS page_id, o.occurrence_id AS occurrence_id,
+			NULL::text AS title, o.note AS body, ts_rank_cd(o.note_search, q.query) AS rank
+		FROM occurrence o, q
+		WHERE o.note_search @@ q.query`,
+	TextHitObservation: `
+		SELECT 'observation' AS type, ob.observations_id AS id, NULL::int AS page_id, ob.occurrence_id AS occurrence_id,
+			NULL::text AS title, ob.behavior AS body, ts_rank_cd(ob.behavior_search, q.query) AS rank
+		FROM observations ob, q
+		WHERE ob.behavior_search @@ q.query`,
+	TextHitIdentification: `
+		SELECT 'identification' AS type, i.identification_id AS id, NULL::int AS page_id, i.occurrence_id AS occurrence_id,
+			NULL::text AS title, i.source_info AS body, ts_rank_cd(i.source_info_search, q.query) AS rank
+		FROM identifications i, q
+		WHERE i.source_info_search @@ q.query`,
+}
+
+// TextSearchTypes は検索できる種類を並び順どおりに返すのだ
+func TextSearchTypes() []string {
+	return []string{TextHitWikiPage, TextHitOccurrence, TextHitObservation, TextHitIdentification}
+}
+
+// TextSearchParams は全文検索の条件なのだ
+type TextSearchParams struct {
+	Query string
+	Types []string // 空なら全ての種類なのだ
+
+	// HeadlineOptions は ts_headline に渡す設定なのだ (当たった語の印などなのだ)
+	HeadlineOptions string
+
+	Limit  int
+	Offset int
+}
+
+// TextSearchRow は全文検索で当たった1件なのだ
+type TextSearchRow struct {
+	Type         string
+	ID           uint
+	PageID       *uint
+	OccurrenceID *uint
+	Title        *string
+	Body         string
+	Headline     string
+	Rank         float64
+	Total        int64
+}
+
+// TextSearchRepository は全文検索の契約書なのだ
+type TextSearchRepository interface {
+	Search(params TextSearchParams) ([]TextSearchRow, error)
+}
+
+type textSearchRepository struct {
+	db *gorm.DB
+}
+
+func NewTextSearchRepository(db *gorm.DB) TextSearchRepository {
+	return &textSearchRepository{db: db}
+}
+
+// Search は種類ごとの検索結果をまとめ、関連度の高い順に並べるのだ
+// 抜粋 (ts_headline) は重いので、返すページの分だけ作るのだ
+func (r *textSearchRepository) Search(params TextSearchParams) ([]TextSearchRow, error) {
+	types := params.Types
+	if len(types) == 0 {
+		types = TextSearchTypes()
+	}
+	sources := make([]string, 0, len(types))
+	for _, t := range types {
+		if source, ok := textSearchSources[t]; ok {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	sql := `
+		WITH q AS (SELECT text_search_query(?) AS query),
+		hits AS (` + strings.Join(sources, "\n\t\tUNION ALL") + `
+		),
+		ranked AS (
+			SELECT hits.*, count(*) OVER () AS total
+			FROM hits
+			ORDER BY rank DESC, type, id
+			LIMIT ? OFFSET ?
+		)
+		SELECT ranked.*, ts_headline('english', coalesce(ranked.body, ''), q.query, ?) AS headline
+		FROM ranked, q
+		ORDER BY rank DESC, type, id`
+
+	var rows []TextSearchRow
+	err := r.db.Raw(sql, params.Query, params.Limit, params.Offset, params.HeadlineOptions).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -10,6 +10,7 @@ import (
 // 1. UserRepository は、ユーザーデータに関する操作の「契約書」(インターフェース)なのだ
 type UserRepository interface {
 	FindAll() ([]model.User, error)
+	FindByID(id uint) (*model.User, error)
 	Create(tx *gorm.DB, user *model.User) (*model.User, error)
 	Update(tx *gorm.DB, user *model.User) (*model.User, error)
 	Delete(tx *gorm.DB, id uint) error
@@ -35,6 +36,15 @@ func (r *userRepository) FindAll() ([]model.User, error) {
 	return users, nil
 }
 
+// FindByID はIDでユーザーを1件取得するのだ
+func (r *userRepository) FindByID(id uint) (*model.User, error) {
+	var user model.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Create(tx *gorm.DB, user *model.User) (*model.User, error) {
 	// GORMのCreateメソッドを使って、レコードをINSERTするのだ
 	if err := tx.Create(user).Error; err != nil {
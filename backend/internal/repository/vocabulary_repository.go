@@ -0,0 +1,288 @@
+// backend/internal/repository/vocabulary_repository.go
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VocabularyItem は語彙の1つの値と、それを使っている行の数なのだ
+// どの語彙でも同じ形で返すので、IDと名前は語彙ごとの列から読み替えるのだ
+type VocabularyItem struct {
+	ID         uint           `json:"id"`
+	Code       *string        `json:"code,omitempty"` // 言語の短い名前 (en, jp) なのだ。ほかの語彙にはないのだ
+	Name       string         `json:"name"`
+	Label      string         `gorm:"-" json:"label"` // 頼まれた言語の表示名なのだ。なければ名前なのだ
+	SortOrder  int            `json:"sort_order"`
+	Deprecated bool           `json:"deprecated"`
+	Labels     datatypes.JSON `json:"labels"`
+	UsageCount int64          `json:"usage_count"`
+}
+
+// vocabularyUsage は語彙の値を参照している列なのだ
+type vocabularyUsage struct {
+	table  string
+	column string
+}
+
+// vocabularyTable は語彙のテーブルと、その値を使っている列の対応なのだ
+type vocabularyTable struct {
+	table      string
+	idColumn   string
+	nameColumn string
+	codeColumn string
+	usages     []vocabularyUsage
+}
+
+// vocabularyTables はAPIの語彙の名前からテーブルを引く表なのだ
+// 登録番号の連番 (catalog_number_sequences) はコレクションと一緒に消えるものなので、使っている数には入れないのだ
+var vocabularyTables = map[string]vocabularyTable{
+	"specimen-methods": {
+		table: "specimen_methods", idColumn: "specimen_methods_id", nameColumn: "method_common_name",
+		usages: []vocabularyUsage{{"specimen", "specimen_method_id"}, {"make_specimen", "specimen_method_id"}},
+	},
+	"institution-codes": {
+		table: "institution_id_code", idColumn: "institution_id", nameColumn: "institution_code",
+		usages: []vocabularyUsage{{"specimen", "institution_id"}, {"loans", "borrower_institution_id"}},
+	},
+	"collection-codes": {
+		table: "collection_id_code", idColumn: "collection_id", nameColumn: "collection_code",
+		usages: []vocabularyUsage{{"specimen", "collection_id"}},
+	},
+	"observation-methods": {
+		table: "observation_methods", idColumn: "observation_method_id", nameColumn: "method_common_name",
+		usages: []vocabularyUsage{{"observations", "observation_method_id"}},
+	},
+	"file-types": {
+		table: "file_types", idColumn: "file_type_id", nameColumn: "type_name",
+		usages: []vocabularyUsage{{"file_extensions", "file_type_id"}},
+	},
+	"languages": {
+		table: "languages", idColumn: "language_id", nameColumn: "language_common", codeColumn: "language_short",
+		usages: []vocabularyUsage{{"occurrence", "language_id"}},
+	},
+	"measurement-types": {
+		table: "measurement_types", idColumn: "measurement_type_id", nameColumn: "name",
+		usages: []vocabularyUsage{{"measurements", "measurement_type_id"}},
+	},
+}
+
+// selectColumns は VocabularyItem の形に読み替える列なのだ。テーブルは t という名前で読むのだ
+func (v vocabularyTable) selectColumns() string {
+	code := "NULL"
+	if v.codeColumn != "" {
+		code = "t." + v.codeColumn
+	}
+	usages := make([]string, len(v.usages))
+	for i, u := range v.usages {
+		usages[i] = fmt.Sprintf("(SELECT COUNT(*) FROM %s u WHERE u.%s = t.%s)", u.table, u.column, v.idColumn)
+	}
+	return fmt.Sprintf("t.%s AS id, %s AS code, t.%s AS name, t.sort_order, t.deprecated, t.labels, %s AS usage_count",
+		v.idColumn, code, v.nameColumn, strings.Join(usages, " + "))
+}
+
+// VocabularyRepository は語彙 (作製方法・機関コードなどの選択肢のテーブル) のデータ操作の契約書なのだ
+// kind はAPIの語彙の名前 (specimen-methods など) なのだ
+type VocabularyRepository interface {
+	Kinds() []string
+	HasKind(kind string) bool
+	HasCode(kind string) bool
+	FindAll(kind string, includeDeprecated bool) ([]VocabularyItem, error)
+	FindByID(kind string, id uint) (*VocabularyItem, error)
+	FindForUpdate(tx *gorm.DB, kind string, id uint) (*VocabularyItem, error)
+	NameTaken(kind, name string, exceptID uint) (bool, error)
+	CodeTaken(kind, code string, exceptID uint) (bool, error)
+	NextSortOrder(kind string) (int, error)
+	LanguageCodes() ([]string, error)
+	Create(tx *gorm.DB, kind string, item *VocabularyItem) (*VocabularyItem, error)
+	Update(tx *gorm.DB, kind string, item *VocabularyItem) error
+	Delete(tx *gorm.DB, kind string, id uint) error
+}
+
+type vocabularyRepository struct {
+	db *gorm.DB
+}
+
+// NewVocabularyRepository は新しいリポジトリを生成するのだ
+func NewVocabularyRepository(db *gorm.DB) VocabularyRepository {
+	return &vocabularyRepository{db: db}
+}
+
+// tableOf は語彙のテーブルを引くのだ。知らない語彙はサービスで弾いているはずなので、ここではただのエラーにするのだ
+func tableOf(kind string) (vocabularyTable, error) {
+	v, ok := vocabularyTables[kind]
+	if !ok {
+		return vocabularyTable{}, fmt.Errorf("unknown vocabulary %q", kind)
+	}
+	return v, nil
+}
+
+// Kinds は管理できる語彙の名前を名前順で返すのだ
+func (r *vocabularyRepository) Kinds() []string {
+	kinds := make([]string, 0, len(vocabularyTables))
+	for kind := range vocabularyTables {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (r *vocabularyRepository) HasKind(kind string) bool {
+	_, ok := vocabularyTables[kind]
+	return ok
+}
+
+// HasCode は語彙が名前のほかに短いコードを持つか確かめるのだ (今は言語だけなのだ)
+func (r *vocabularyRepository) HasCode(kind string) bool {
+	return vocabularyTables[kind].codeColumn != ""
+}
+
+// FindAll は語彙の値を並び順で返すのだ。includeDeprecated でなければ使うのをやめた値は外すのだ
+func (r *vocabularyRepository) FindAll(kind string, includeDeprecated bool) ([]VocabularyItem, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	query := r.db.Table(v.table + " AS t").Select(v.selectColumns())
+	if !includeDeprecated {
+		query = query.Where("NOT t.deprecated")
+	}
+	var items []VocabularyItem
+	if err := query.Order("t.sort_order, t." + v.idColumn).Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FindByID は語彙の値を1件、使っている数つきで取得するのだ
+func (r *vocabularyRepository) FindByID(kind string, id uint) (*VocabularyItem, error) {
+	return findVocabularyItem(r.db, kind, id)
+}
+
+// FindForUpdate は語彙の値に行ロックをかけて取得するのだ
+// 参照する行を足すときもこの行にロックがかかるので、数えてから消すまでの間に使われることはないのだ
+func (r *vocabularyRepository) FindForUpdate(tx *gorm.DB, kind string, id uint) (*VocabularyItem, error) {
+	return findVocabularyItem(tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "t"}}), kind, id)
+}
+
+func findVocabularyItem(db *gorm.DB, kind string, id uint) (*VocabularyItem, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	var item VocabularyItem
+	err = db.Table(v.table+" AS t").
+		Select(v.selectColumns()).
+		Where("t."+v.idColumn+" = ?", id).
+		Take(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// NameTaken はほかの値がもうその名前を使っているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *vocabularyRepository) NameTaken(kind, name string, exceptID uint) (bool, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return false, err
+	}
+	return exists(r.db.Table(v.table).
+		Where("lower("+v.nameColumn+") = lower(?) AND "+v.idColumn+" <> ?", name, exceptID))
+}
+
+// CodeTaken はほかの値がもうそのコードを使っているか確かめるのだ (大文字小文字は区別しないのだ)
+func (r *vocabularyRepository) CodeTaken(kind, code string, exceptID uint) (bool, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return false, err
+	}
+	if v.codeColumn == "" {
+		return false, nil
+	}
+	return exists(r.db.Table(v.table).
+		Where("lower("+v.codeColumn+") = lower(?) AND "+v.idColumn+" <> ?", code, exceptID))
+}
+
+// NextSortOrder は一番後ろに並ぶ並び順を返すのだ
+func (r *vocabularyRepository) NextSortOrder(kind string) (int, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return 0, err
+	}
+	var next int
+	if err := r.db.Table(v.table).Select("COALESCE(MAX(sort_order), 0) + 1").Scan(&next).Error; err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// LanguageCodes は表示名のキーに使える言語の短い名前を返すのだ
+func (r *vocabularyRepository) LanguageCodes() ([]string, error) {
+	var codes []string
+	if err := r.db.Table("languages").Where("language_short IS NOT NULL").Pluck("language_short", &codes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Create は語彙に値を追加するのだ
+func (r *vocabularyRepository) Create(tx *gorm.DB, kind string, item *VocabularyItem) (*VocabularyItem, error) {
+	v, err := tableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{v.nameColumn, "sort_order", "deprecated", "labels"}
+	values := []any{item.Name, item.SortOrder, item.Deprecated, item.Labels}
+	if v.codeColumn != "" {
+		columns = append(columns, v.codeColumn)
+		values = append(values, item.Code)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	err = tx.Raw(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		v.table, strings.Join(columns, ", "), placeholders, v.idColumn), values...).
+		Scan(&item.ID).Error
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Update は語彙の値の名前・並び順・表示名・使うのをやめたかを書き換えるのだ
+func (r *vocabularyRepository) Update(tx *gorm.DB, kind string, item *VocabularyItem) error {
+	v, err := tableOf(kind)
+	if err != nil {
+		return err
+	}
+	values := map[string]any{
+		v.nameColumn: item.Name,
+		"sort_order": item.SortOrder,
+		"deprecated": item.Deprecated,
+		"labels":     item.Labels,
+	}
+	if v.codeColumn != "" {
+		values[v.codeColumn] = item.Code
+	}
+	result := tx.Table(v.table).Where(v.idColumn+" = ?", item.ID).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete は語彙の値を削除するのだ。使われていないことは呼び出し側で確かめるのだ
+func (r *vocabularyRepository) Delete(tx *gorm.DB, kind string, id uint) error {
+	v, err := tableOf(kind)
+	if err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM "+v.table+" WHERE "+v.idColumn+" = ?", id).Error
+}
@@ -0,0 +1,230 @@
+// backend/internal/repository/wiki_repository.go
+package repository
+
+import (
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WikiListParams はWikiページ一覧の絞り込みとページ送りの条件なのだ
+type WikiListParams struct {
+	Title  string // タイトルの部分一致なのだ
+	Limit  int
+	Offset int
+}
+
+// 手法の種類なのだ
+const (
+	MethodKindObservation = "observation"
+	MethodKindSpecimen    = "specimen"
+)
+
+// LinkedMethod はWikiページを手順書として参照している観察手法・標本作製手法なのだ
+type LinkedMethod struct {
+	Kind             string `json:"kind"`
+	MethodID         uint   `json:"method_id"`
+	MethodCommonName string `json:"method_common_name"`
+}
+
+// WikiRepository はWikiページ関連のデータ操作の契約書なのだ
+type WikiRepository interface {
+	FindByID(id uint) (*model.WikiPage, error)
+	FindByIDForUpdate(tx *gorm.DB, id uint) (*model.WikiPage, error)
+	FindAll() ([]model.WikiPage, error)
+	List(params WikiListParams) ([]model.WikiPage, int64, error)
+	FindLinkedMethods(pageID uint) ([]LinkedMethod, error)
+	CountLinkedMethods(tx *gorm.DB, pageID uint) (int64, error)
+	FindLegacyPages() ([]model.WikiPage, error)
+	Create(tx *gorm.DB, page *model.WikiPage) (*model.WikiPage, error)
+	Update(tx *gorm.DB, page *model.WikiPage) (*model.WikiPage, error)
+	Delete(tx *gorm.DB, id uint) error
+	FindRevisions(pageID uint) ([]model.WikiRevision, error)
+	FindRevision(pageID uint, number int) (*model.WikiRevision, error)
+	FindLatestRevision(tx *gorm.DB, pageID uint) (*model.WikiRevision, error)
+	CreateRevision(tx *gorm.DB, revision *model.WikiRevision) (*model.WikiRevision, error)
+}
+
+type wikiRepository struct {
+	db *gorm.DB
+}
+
+// NewWikiRepository は新しいリポジトリを生成するのだ
+func NewWikiRepository(db *gorm.DB) WikiRepository {
+	return &wikiRepository{db: db}
+}
+
+// FindByID はIDでWikiページを1件取得する。最新の版も一緒に読み込むのだ
+func (r *wikiRepository) FindByID(id uint) (*model.WikiPage, error) {
+	var page model.WikiPage
+	if err := r.db.Preload("CurrentRevision.User").First(&page, id).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FindByIDForUpdate は同時に編集されないように、行をロックしてWikiページを取得するのだ
+func (r *wikiRepository) FindByIDForUpdate(tx *gorm.DB, id uint) (*model.WikiPage, error) {
+	var page model.WikiPage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&page, id).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FindAll は全てのWikiページを取得するのだ
+func (r *wikiRepository) FindAll() ([]model.WikiPage, error) {
+	var pages []model.WikiPage
+	if err := r.db.Order("page_id").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// List は条件に合うWikiページを更新の新しい順に取得し、全体の件数も返すのだ
+func (r *wikiRepository) List(params WikiListParams) ([]model.WikiPage, int64, error) {
+	query := r.db.Model(&model.WikiPage{})
+	if params.Title != "" {
+		query = query.Where("title ILIKE ? ESCAPE '\\'", like(params.Title))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var pages []model.WikiPage
+	query = query.Order("COALESCE(updated_date, created_date) DESC").Order("page_id DESC")
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		query = query.Offset(params.Offset)
+	}
+	if err := query.Find(&pages).Error; err != nil {
+		return nil, 0, err
+	}
+	return pages, total, nil
+}
+
+// FindLinkedMethods はページを参照している観察手法と標本作製手法を取得するのだ
+func (r *wikiRepository) FindLinkedMethods(pageID uint) ([]LinkedMethod, error) {
+	var methods []LinkedMethod
+	err := r.db.Raw(`
+		SELECT ? AS kind, observation_method_id AS method_id, method_common_name
+		FROM observation_methods WHERE pageid = ?
+		UNION ALL
+		SELECT ? AS kind, specimen_methods_id AS method_id, method_common_name
+		FROM specimen_methods WHERE page_id = ?
+		ORDER BY kind, method_id`,
+		MethodKindObservation, pageID, MethodKindSpecimen, pageID).
+		Scan(&methods).Error
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// CountLinkedMethods はページを参照している手法の数を、トランザクションの中で数えるのだ
+func (r *wikiRepository) CountLinkedMethods(tx *gorm.DB, pageID uint) (int64, error) {
+	var count int64
+	err := tx.Raw(`
+		SELECT (SELECT COUNT(*) FROM observation_methods WHERE pageid = ?)
+		     + (SELECT COUNT(*) FROM specimen_methods WHERE page_id = ?)`,
+		pageID, pageID).
+		Scan(&count).Error
+	return count, err
+}
+
+// FindLegacyPages は本文がまだ content_path のファイルにあり、版を持たないページを取得するのだ
+func (r *wikiRepository) FindLegacyPages() ([]model.WikiPage, error) {
+	var pages []model.WikiPage
+	err := r.db.Where("current_revision_id IS NULL AND content_path IS NOT NULL AND content_path <> ''").
+		Order("page_id").
+		Find(&pages).Error
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Create は新しいWikiページを作成するのだ
+func (r *wikiRepository) Create(tx *gorm.DB, page *model.WikiPage) (*model.WikiPage, error) {
+	if err := tx.Omit("CurrentRevision").Create(page).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Update はWikiページを更新するのだ
+func (r *wikiRepository) Update(tx *gorm.DB, page *model.WikiPage) (*model.WikiPage, error) {
+	if err := tx.Omit("CurrentRevision").Save(page).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Delete はWikiページを削除するのだ。版は ON DELETE CASCADE で一緒に消えるのだ
+func (r *wikiRepository) Delete(tx *gorm.DB, id uint) error {
+	// 最新の版への参照を先に外さないと、版の削除が外部キーに引っかかるのだ
+	if err := tx.Model(&model.WikiPage{}).Where("page_id = ?", id).Update("current_revision_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&model.WikiPage{}, id).Error
+}
+
+// FindRevisions はページの版の一覧を新しい順に取得するのだ。本文は重いので読み込まないのだ
+func (r *wikiRepository) FindRevisions(pageID uint) ([]model.WikiRevision, error) {
+	var revisions []model.WikiRevision
+	err := r.db.Omit("content").
+		Preload("User").
+		Where("page_id = ?", pageID).
+		Order("revision_number DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// FindRevision はページの版を番号で1件取得するのだ
+func (r *wikiRepository) FindRevision(pageID uint, number int) (*model.WikiRevision, error) {
+	var revision model.WikiRevision
+	err := r.db.Preload("User").
+		Where("page_id = ? AND revision_number = ?", pageID, number).
+		First(&revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// FindLatestRevision はページの最新の版を取得するのだ。まだ版がなければnilなのだ
+func (r *wikiRepository) FindLatestRevision(tx *gorm.DB, pageID uint) (*model.WikiRevision, error) {
+	var revisions []model.WikiRevision
+	err := tx.Where("page_id = ?", pageID).
+		Order("revision_number DESC").
+		Limit(1).
+		Find(&revisions).Error
+	if err != nil || len(revisions) == 0 {
+		return nil, err
+	}
+	return &revisions[0], nil
+}
+
+// CreateRevision は次の版番号を付けて新しい版を作成するのだ
+// 呼び出す前に FindByIDForUpdate でページをロックしておくのだ
+func (r *wikiRepository) CreateRevision(tx *gorm.DB, revision *model.WikiRevision) (*model.WikiRevision, error) {
+	var last int
+	if err := tx.Model(&model.WikiRevision{}).
+		Where("page_id = ?", revision.PageID).
+		Select("COALESCE(MAX(revision_number), 0)").
+		Scan(&last).Error; err != nil {
+		return nil, err
+	}
+	revision.RevisionNumber = last + 1
+	if err := tx.Omit("User").Create(revision).Error; err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
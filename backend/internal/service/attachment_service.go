@@ -0,0 +1,442 @@
+// backend/internal/service/attachment_service.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+	"github.com/saku-730/specimen-web/backend/internal/infrastructure/storage"
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrUnsupportedFileType は file_extensions に登録されていない種類のファイルのときのエラーなのだ
+var ErrUnsupportedFileType = errors.New("unsupported file type")
+
+// sniffLength はMIMEの判定に読む先頭のバイト数なのだ
+const sniffLength = 3072
+
+// maxThumbnailPixels はサムネイルを作る画像の画素数の上限なのだ
+// 展開するとRGBAで1画素4バイトになるので、小さなファイルでもメモリを使い切らないように抑えるのだ
+const maxThumbnailPixels = 50_000_000
+
+// UploadAttachmentRequest は添付ファイルのアップロード内容なのだ
+type UploadAttachmentRequest struct {
+	OccurrenceID uint
+	UserID       uint
+	Priority     *int
+	FileName     string
+	Size         int64
+	Content      io.Reader
+
+	// 公開のための権利情報と説明なのだ。撮影機材が空なら写真のEXIFから埋めるのだ
+	License       string
+	RightsHolder  string
+	Creator       string
+	Caption       string
+	SubjectPart   string
+	CaptureDevice string
+}
+
+// UpdatePriorityRequest は表示順の変更リクエストなのだ
+type UpdatePriorityRequest struct {
+	Priority *int `json:"priority"`
+}
+
+// UpdateAttachmentMetadataRequest は権利情報と説明の更新リクエストなのだ。nilの項目は変更しないのだ
+// license に空文字を送るとライセンスなし(非公開)に戻るのだ
+type UpdateAttachmentMetadataRequest struct {
+	License       *string `json:"license"`
+	RightsHolder  *string `json:"rights_holder"`
+	Creator       *string `json:"creator"`
+	Caption       *string `json:"caption"`
+	SubjectPart   *string `json:"subject_part"`
+	CaptureDevice *string `json:"capture_device"`
+}
+
+// UploadAttachmentResult はアップロードの結果なのだ
+// 写真なら、EXIFから作った入力フォームの初期値も付けて返すのだ
+type UploadAttachmentResult struct {
+	Attachment *model.Attachment `json:"attachment"`
+	Suggestion *PhotoSuggestion  `json:"suggestion,omitempty"`
+}
+
+// AttachmentContent はダウンロード用に開いた添付ファイルなのだ。使い終わったらCloseするのだ
+// サムネイルを開いたときは Thumbnail も入るのだ
+type AttachmentContent struct {
+	Attachment *model.Attachment
+	Thumbnail  *model.AttachmentThumbnail
+	Object     storage.Object
+}
+
+// AttachmentService は添付ファイル関連のビジネスロジックのインターフェースなのだ
+type AttachmentService interface {
+	Upload(ctx context.Context, req UploadAttachmentRequest) (*UploadAttachmentResult, error)
+	Inspect(content io.Reader) (*PhotoSuggestion, error)
+	ListByOccurrence(occurrenceID uint) ([]repository.OccurrenceAttachment, error)
+	GetAttachment(id uint) (*model.Attachment, error)
+	UpdateMetadata(id uint, req UpdateAttachmentMetadataRequest) (*model.Attachment, error)
+	GetLicenses() []MediaLicense
+	GetSuggestion(id uint) (*PhotoSuggestion, error)
+	Open(ctx context.Context, id uint) (*AttachmentContent, error)
+	OpenThumbnail(ctx context.Context, id uint, size string) (*AttachmentContent, error)
+	UpdatePriority(occurrenceID, attachmentID uint, req UpdatePriorityRequest) error
+	DeleteAttachment(ctx context.Context, id uint) error
+}
+
+type attachmentService struct {
+	db             *gorm.DB
+	repo           repository.AttachmentRepository
+	occurrenceRepo repository.OccurrenceRepository
+	storage        storage.Storage
+}
+
+// NewAttachmentService は新しいサービスを生成するのだ
+func NewAttachmentService(db *gorm.DB, repo repository.AttachmentRepository, occurrenceRepo repository.OccurrenceRepository, store storage.Storage) AttachmentService {
+	return &attachmentService{db: db, repo: repo, occurrenceRepo: occurrenceRepo, storage: store}
+}
+
+// Upload はファイルの中身からMIMEを判定して保存し、発生情報に紐づけるのだ
+// ファイル名の拡張子は信用せず、file_extensions に登録された種類だけ受け付けるのだ
+// 画像ならEXIFの撮影情報を読み取り、サムネイルも作るのだ
+func (s *attachmentService) Upload(ctx context.Context, req UploadAttachmentRequest) (*UploadAttachmentResult, error) {
+	if _, err := s.occurrenceRepo.FindByID(req.OccurrenceID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	license, err := normalizeLicense(req.License)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先頭だけ読んでMIMEを判定し、読んだ分は後で本体の前につなぎ直すのだ
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(req.Content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	mtype := mimetype.Detect(head)
+	extText := strings.TrimPrefix(mtype.Extension(), ".")
+	if extText == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, mtype.String())
+	}
+	extension, err := s.repo.FindExtensionByText(extText)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, mtype.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &model.Attachment{
+		ExtensionID:  extension.ExtensionID,
+		UserID:       req.UserID,
+		OriginalName: path.Base(strings.ReplaceAll(req.FileName, "\\", "/")),
+		ContentType:  mtype.String(),
+		SizeBytes:    req.Size,
+
+		License:       license,
+		RightsHolder:  req.RightsHolder,
+		Creator:       req.Creator,
+		Caption:       req.Caption,
+		SubjectPart:   req.SubjectPart,
+		CaptureDevice: req.CaptureDevice,
+	}
+	result := &UploadAttachmentResult{Attachment: attachment}
+
+	body := io.MultiReader(bytes.NewReader(head), req.Content)
+	var imageData []byte
+	if strings.HasPrefix(mtype.String(), "image/") {
+		// 画像はEXIFとサムネイルのために中身を全部使うので、メモリに読み込んでおくのだ
+		if imageData, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(imageData)
+		result.Suggestion = s.readImageMetadata(attachment, imageData)
+	}
+
+	key := fmt.Sprintf("occurrences/%d/%s.%s", req.OccurrenceID, uuid.NewString(), extText)
+	hash := sha256.New()
+	if err := s.storage.Put(ctx, key, io.TeeReader(body, hash), req.Size, mtype.String()); err != nil {
+		return nil, fmt.Errorf("ファイルの保存に失敗しました: %w", err)
+	}
+	attachment.FilePath = key
+	attachment.ChecksumSHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.repo.Create(tx, attachment); err != nil {
+			return err
+		}
+		return s.repo.AddToOccurrence(tx, &model.AttachmentGroup{
+			OccurrenceID: req.OccurrenceID,
+			AttachmentID: attachment.AttachmentID,
+			Priority:     req.Priority,
+		})
+	})
+	if err != nil {
+		// DBに登録できなかったファイルは置いておいても誰も参照できないので消すのだ
+		if delErr := s.storage.Delete(ctx, key); delErr != nil {
+			log.Printf("保存済みファイルの削除に失敗しました (%s): %v", key, delErr)
+		}
+		return nil, err
+	}
+	attachment.Extension = extension
+
+	// サムネイルが作れなくても元のファイルは登録できているので、ログに残すだけにするのだ
+	if imageData != nil {
+		thumbnails, err := s.createThumbnails(ctx, attachment, imageData)
+		if err != nil {
+			log.Printf("サムネイルの作成に失敗しました (attachment %d): %v", attachment.AttachmentID, err)
+		}
+		attachment.Thumbnails = thumbnails
+	}
+	return result, nil
+}
+
+// readImageMetadata は画像の大きさとEXIFを読み取って添付ファイルに入れ、フォームの初期値を返すのだ
+func (s *attachmentService) readImageMetadata(attachment *model.Attachment, data []byte) *PhotoSuggestion {
+	meta, err := readPhotoMetadata(data)
+	if err != nil {
+		meta = &PhotoMetadata{}
+	}
+	applyPhotoMetadata(attachment, meta)
+	if attachment.CaptureDevice == "" {
+		attachment.CaptureDevice = captureDeviceOf(meta.CameraMake, meta.CameraModel)
+	}
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		w, h := orientedSize(config.Width, config.Height, meta.Orientation)
+		attachment.Width, attachment.Height = &w, &h
+	}
+	return suggestionFromMetadata(meta)
+}
+
+// createThumbnails はサムネイルを作って保存し、登録するのだ
+func (s *attachmentService) createThumbnails(ctx context.Context, attachment *model.Attachment, data []byte) ([]model.AttachmentThumbnail, error) {
+	// 大きさはreadImageMetadataでDecodeConfigから読んであるので、展開する前に画素数を確かめるのだ
+	if attachment.Width == nil || attachment.Height == nil {
+		return nil, errors.New("画像の大きさを読み取れませんでした")
+	}
+	if pixels := int64(*attachment.Width) * int64(*attachment.Height); pixels > maxThumbnailPixels {
+		return nil, fmt.Errorf("画素数が多すぎるのでサムネイルを作りません (%d×%d)", *attachment.Width, *attachment.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	orientation := 1
+	if meta, err := readPhotoMetadata(data); err == nil {
+		orientation = meta.Orientation
+	}
+	images, err := makeThumbnails(src, orientation)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(attachment.FilePath, path.Ext(attachment.FilePath))
+	thumbnails := make([]model.AttachmentThumbnail, 0, len(images))
+	for _, img := range images {
+		key := fmt.Sprintf("%s_%s.jpg", base, img.Size.Name)
+		if err := s.storage.Put(ctx, key, bytes.NewReader(img.Data), int64(len(img.Data)), "image/jpeg"); err != nil {
+			s.deleteThumbnailFiles(ctx, thumbnails)
+			return nil, err
+		}
+		thumbnails = append(thumbnails, model.AttachmentThumbnail{
+			AttachmentID: attachment.AttachmentID,
+			SizeName:     img.Size.Name,
+			FilePath:     key,
+			Width:        img.Width,
+			Height:       img.Height,
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.CreateThumbnails(tx, thumbnails)
+	})
+	if err != nil {
+		s.deleteThumbnailFiles(ctx, thumbnails)
+		return nil, err
+	}
+	return thumbnails, nil
+}
+
+func (s *attachmentService) deleteThumbnailFiles(ctx context.Context, thumbnails []model.AttachmentThumbnail) {
+	for _, t := range thumbnails {
+		if err := s.storage.Delete(ctx, t.FilePath); err != nil {
+			log.Printf("サムネイルの削除に失敗しました (%s): %v", t.FilePath, err)
+		}
+	}
+}
+
+// Inspect は保存せずに写真のEXIFだけを読んで入力フォームの初期値を返すのだ
+// 発生情報を登録する前に、選んだ写真から場所や日時を埋めるために使うのだ
+func (s *attachmentService) Inspect(content io.Reader) (*PhotoSuggestion, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := readPhotoMetadata(data)
+	if errors.Is(err, errNoExif) {
+		return &PhotoSuggestion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return suggestionFromMetadata(meta), nil
+}
+
+// ListByOccurrence は発生情報の添付ファイルを表示順で取得するのだ
+func (s *attachmentService) ListByOccurrence(occurrenceID uint) ([]repository.OccurrenceAttachment, error) {
+	if _, err := s.occurrenceRepo.FindByID(occurrenceID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.repo.FindByOccurrence(occurrenceID)
+}
+
+// GetAttachment はIDで添付ファイルの情報を1件取得するのだ
+func (s *attachmentService) GetAttachment(id uint) (*model.Attachment, error) {
+	attachment, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return attachment, nil
+}
+
+// UpdateMetadata は権利情報と説明を更新するのだ
+func (s *attachmentService) UpdateMetadata(id uint, req UpdateAttachmentMetadataRequest) (*model.Attachment, error) {
+	attachment, err := s.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.License != nil {
+		license, err := normalizeLicense(*req.License)
+		if err != nil {
+			return nil, err
+		}
+		attachment.License = license
+	}
+	if req.RightsHolder != nil {
+		attachment.RightsHolder = *req.RightsHolder
+	}
+	if req.Creator != nil {
+		attachment.Creator = *req.Creator
+	}
+	if req.Caption != nil {
+		attachment.Caption = *req.Caption
+	}
+	if req.SubjectPart != nil {
+		attachment.SubjectPart = *req.SubjectPart
+	}
+	if req.CaptureDevice != nil {
+		attachment.CaptureDevice = *req.CaptureDevice
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.UpdateMetadata(tx, attachment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// GetLicenses は添付ファイルに付けられるライセンスの一覧を返すのだ
+func (s *attachmentService) GetLicenses() []MediaLicense {
+	return mediaLicenses
+}
+
+// GetSuggestion は保存済みの写真の撮影情報から入力フォームの初期値を返すのだ
+func (s *attachmentService) GetSuggestion(id uint) (*PhotoSuggestion, error) {
+	attachment, err := s.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	return suggestionFromAttachment(attachment), nil
+}
+
+// Open はダウンロードのために保存先のファイルを開くのだ
+func (s *attachmentService) Open(ctx context.Context, id uint) (*AttachmentContent, error) {
+	attachment, err := s.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.storage.Open(ctx, attachment.FilePath)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AttachmentContent{Attachment: attachment, Object: object}, nil
+}
+
+// OpenThumbnail は指定した大きさのサムネイルを開くのだ
+func (s *attachmentService) OpenThumbnail(ctx context.Context, id uint, size string) (*AttachmentContent, error) {
+	attachment, err := s.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachment.Thumbnails {
+		thumbnail := &attachment.Thumbnails[i]
+		if thumbnail.SizeName != size {
+			continue
+		}
+		object, err := s.storage.Open(ctx, thumbnail.FilePath)
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &AttachmentContent{Attachment: attachment, Thumbnail: thumbnail, Object: object}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// UpdatePriority は発生情報の中での表示順を変更するのだ
+func (s *attachmentService) UpdatePriority(occurrenceID, attachmentID uint, req UpdatePriorityRequest) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.UpdatePriority(tx, occurrenceID, attachmentID, req.Priority)
+	})
+	return notFoundOr(err)
+}
+
+// DeleteAttachment は紐づけとレコードを消してから、保存先のファイルを消すのだ
+func (s *attachmentService) DeleteAttachment(ctx context.Context, id uint) error {
+	attachment, err := s.GetAttachment(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.RemoveFromGroups(tx, id); err != nil {
+			return err
+		}
+		return s.repo.Delete(tx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	// レコードはもう消えているので、ファイルの削除に失敗してもログに残すだけにするのだ
+	// サムネイルのレコードは ON DELETE CASCADE で一緒に消えるのだ
+	if err := s.storage.Delete(ctx, attachment.FilePath); err != nil {
+		log.Printf("添付ファイルの削除に失敗しました (%s): %v", attachment.FilePath, err)
+	}
+	s.deleteThumbnailFiles(ctx, attachment.Thumbnails)
+	return nil
+}
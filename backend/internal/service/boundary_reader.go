@@ -0,0 +1,253 @@
+// backend/internal/service/boundary_reader.go
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// BoundaryFeature はファイルから読み込んだ行政区画1件分なのだ
+// Geometry はGeoJSONのジオメトリ(Polygon/MultiPolygon、またはリングのMultiLineString)なのだ
+type BoundaryFeature struct {
+	Properties map[string]string
+	Geometry   json.RawMessage
+}
+
+// readBoundaryFile は拡張子を見てGeoJSONかShapefileを読み込むのだ
+func readBoundaryFile(path, encoding string) ([]BoundaryFeature, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".geojson", ".json":
+		return readGeoJSONBoundaries(path)
+	case ".shp":
+		return readShapefileBoundaries(path, encoding)
+	default:
+		return nil, fmt.Errorf("対応していないファイル形式です: %s", path)
+	}
+}
+
+// --- GeoJSON ---
+
+func readGeoJSONBoundaries(path string) ([]BoundaryFeature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   json.RawMessage        `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("GeoJSONの解析に失敗しました: %w", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.New("GeoJSONはFeatureCollectionである必要があります")
+	}
+
+	features := make([]BoundaryFeature, 0, len(collection.Features))
+	for _, f := range collection.Features {
+		if len(f.Geometry) == 0 || string(f.Geometry) == "null" {
+			continue
+		}
+		props := make(map[string]string, len(f.Properties))
+		for k, v := range f.Properties {
+			if v != nil {
+				props[k] = fmt.Sprint(v)
+			}
+		}
+		features = append(features, BoundaryFeature{Properties: props, Geometry: f.Geometry})
+	}
+	return features, nil
+}
+
+// --- Shapefile (.shp + .dbf) ---
+
+const (
+	shapeNull     = 0
+	shapePolygon  = 5
+	shapePolygonZ = 15
+	shapePolygonM = 25
+)
+
+// readShapefileBoundaries はポリゴンのShapefileと属性の.dbfを読み込むのだ
+// リングはMultiLineStringとして渡し、外周と穴の組み立てはPostGISのST_BuildAreaに任せるのだ
+func readShapefileBoundaries(path, encoding string) ([]BoundaryFeature, error) {
+	shp, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	records, err := readDBF(base+".dbf", detectShapefileEncoding(base, encoding))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shp) < 100 || binary.BigEndian.Uint32(shp[0:4]) != 9994 {
+		return nil, errors.New("Shapefileのヘッダが不正です")
+	}
+
+	var features []BoundaryFeature
+	offset := 100
+	for index := 0; offset+8 <= len(shp); index++ {
+		contentLength := int(binary.BigEndian.Uint32(shp[offset+4:offset+8])) * 2
+		offset += 8
+		if offset+contentLength > len(shp) {
+			return nil, errors.New("Shapefileのレコードが途中で切れています")
+		}
+		content := shp[offset : offset+contentLength]
+		offset += contentLength
+
+		if len(content) < 4 {
+			continue
+		}
+		shapeType := binary.LittleEndian.Uint32(content[0:4])
+		if shapeType == shapeNull {
+			continue
+		}
+		if shapeType != shapePolygon && shapeType != shapePolygonZ && shapeType != shapePolygonM {
+			return nil, fmt.Errorf("ポリゴン以外のShapefileには対応していません (shape type %d)", shapeType)
+		}
+		rings, err := parseShapePolygon(content)
+		if err != nil {
+			return nil, err
+		}
+		geometry, err := json.Marshal(map[string]interface{}{
+			"type":        "MultiLineString",
+			"coordinates": rings,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		props := map[string]string{}
+		if index < len(records) {
+			props = records[index]
+		}
+		features = append(features, BoundaryFeature{Properties: props, Geometry: geometry})
+	}
+	return features, nil
+}
+
+func parseShapePolygon(content []byte) ([][][2]float64, error) {
+	if len(content) < 44 {
+		return nil, errors.New("ポリゴンレコードが短すぎます")
+	}
+	numParts := int(binary.LittleEndian.Uint32(content[36:40]))
+	numPoints := int(binary.LittleEndian.Uint32(content[40:44]))
+	partsStart := 44
+	pointsStart := partsStart + numParts*4
+	if pointsStart+numPoints*16 > len(content) {
+		return nil, errors.New("ポリゴンレコードの点の数が不正です")
+	}
+
+	parts := make([]int, numParts+1)
+	for i := 0; i < numParts; i++ {
+		parts[i] = int(binary.LittleEndian.Uint32(content[partsStart+i*4:]))
+	}
+	parts[numParts] = numPoints
+
+	rings := make([][][2]float64, 0, numParts)
+	for i := 0; i < numParts; i++ {
+		if parts[i] > parts[i+1] || parts[i+1] > numPoints {
+			return nil, errors.New("ポリゴンレコードのパート位置が不正です")
+		}
+		ring := make([][2]float64, 0, parts[i+1]-parts[i])
+		for p := parts[i]; p < parts[i+1]; p++ {
+			at := pointsStart + p*16
+			x := math.Float64frombits(binary.LittleEndian.Uint64(content[at:]))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(content[at+8:]))
+			ring = append(ring, [2]float64{x, y})
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
+
+// detectShapefileEncoding は指定がなければ .cpg ファイルから文字コードを判定するのだ
+func detectShapefileEncoding(base, encoding string) string {
+	if encoding != "" {
+		return encoding
+	}
+	cpg, err := os.ReadFile(base + ".cpg")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(cpg))
+}
+
+// readDBF はdBase形式の属性ファイルを読み込むのだ
+func readDBF(path, encoding string) ([]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 32 {
+		return nil, errors.New(".dbfのヘッダが不正です")
+	}
+	recordCount := int(binary.LittleEndian.Uint32(data[4:8]))
+	headerLength := int(binary.LittleEndian.Uint16(data[8:10]))
+	recordLength := int(binary.LittleEndian.Uint16(data[10:12]))
+
+	type dbfField struct {
+		name   string
+		length int
+	}
+	var fields []dbfField
+	for at := 32; at+32 <= headerLength && data[at] != 0x0D; at += 32 {
+		name := string(bytes.TrimRight(data[at:at+11], "\x00"))
+		fields = append(fields, dbfField{name: name, length: int(data[at+16])})
+	}
+
+	records := make([]map[string]string, 0, recordCount)
+	for i := 0; i < recordCount; i++ {
+		start := headerLength + i*recordLength
+		if start+recordLength > len(data) {
+			break
+		}
+		row := data[start : start+recordLength]
+		record := make(map[string]string, len(fields))
+		at := 1 // 先頭1バイトは削除フラグなのだ
+		for _, f := range fields {
+			if at+f.length > len(row) {
+				break
+			}
+			value, err := decodeDBFText(row[at:at+f.length], encoding)
+			if err != nil {
+				return nil, err
+			}
+			record[f.name] = value
+			at += f.length
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// decodeDBFText は国土数値情報などで使われるShift_JISをUTF-8に変換するのだ
+func decodeDBFText(raw []byte, encoding string) (string, error) {
+	raw = bytes.TrimSpace(bytes.TrimRight(raw, "\x00"))
+	switch strings.ToUpper(strings.ReplaceAll(encoding, "-", "_")) {
+	case "SHIFT_JIS", "SJIS", "CP932", "932", "WINDOWS_31J":
+		decoded, err := io.ReadAll(transform.NewReader(bytes.NewReader(raw), japanese.ShiftJIS.NewDecoder()))
+		if err != nil {
+			return "", err
+		}
+		return string(decoded), nil
+	default:
+		return string(raw), nil
+	}
+}
@@ -0,0 +1,117 @@
+// backend/internal/service/catalog_number.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCatalogPattern は登録番号の形が正しくないときのエラーなのだ
+var ErrInvalidCatalogPattern = errors.New("invalid catalog number pattern")
+
+// defaultCatalogPattern はコレクションに形が決められていないときの登録番号の形なのだ
+const defaultCatalogPattern = "{institution}-{collection}-{seq:06}"
+
+// maxSeqWidth は {seq:0N} の桁数の上限なのだ
+const maxSeqWidth = 12
+
+// catalogForbiddenChars は登録番号に使えない文字なのだ
+// 番号は GET /specimens/catalog/:number のパスやラベルのURIにも入れるので、スラッシュは使えないのだ
+const catalogForbiddenChars = "/"
+
+// catalogToken は {name} または {name:幅} なのだ
+var catalogToken = regexp.MustCompile(`\{([a-z]+)(?::(0?\d+))?\}`)
+
+// catalogValues は登録番号に埋め込む値なのだ
+type catalogValues struct {
+	Institution string
+	Collection  string
+	Date        time.Time
+}
+
+// validateCatalogPattern は形を確かめるのだ
+// 使える部品は {institution} {collection} {yyyy} {yy} {mm} {seq} {seq:0N} で、{seq} はちょうど1つ要るのだ
+func validateCatalogPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("%w: pattern is empty", ErrInvalidCatalogPattern)
+	}
+	if strings.ContainsAny(pattern, catalogForbiddenChars) {
+		return fmt.Errorf("%w: pattern must not contain '/'", ErrInvalidCatalogPattern)
+	}
+	seqs := 0
+	for _, m := range catalogToken.FindAllStringSubmatch(pattern, -1) {
+		switch m[1] {
+		case "institution", "collection", "yyyy", "yy", "mm":
+			if m[2] != "" {
+				return fmt.Errorf("%w: {%s} does not take a width", ErrInvalidCatalogPattern, m[1])
+			}
+		case "seq":
+			seqs++
+			if m[2] != "" {
+				width, _ := strconv.Atoi(m[2])
+				if width < 1 || width > maxSeqWidth {
+					return fmt.Errorf("%w: seq width must be 1-%d", ErrInvalidCatalogPattern, maxSeqWidth)
+				}
+			}
+		default:
+			return fmt.Errorf("%w: unknown token {%s}", ErrInvalidCatalogPattern, m[1])
+		}
+	}
+	if seqs != 1 {
+		return fmt.Errorf("%w: pattern needs exactly one {seq}", ErrInvalidCatalogPattern)
+	}
+	// 部品にならなかった波かっこは書き間違いなのだ
+	if strings.ContainsAny(catalogToken.ReplaceAllString(pattern, ""), "{}") {
+		return fmt.Errorf("%w: unmatched brace", ErrInvalidCatalogPattern)
+	}
+	return nil
+}
+
+// validateCatalogNumber は手で入れた登録番号に、採番の形と同じ文字の決まりを当てはめるのだ
+func validateCatalogNumber(number string) error {
+	if strings.ContainsAny(number, catalogForbiddenChars) {
+		return fmt.Errorf("%w: catalog_number must not contain '/'", ErrInvalidSpecimen)
+	}
+	return nil
+}
+
+// catalogScope は連番を数える範囲なのだ。連番以外を展開したものなので、{yyyy} があれば年ごとに数え直すのだ
+func catalogScope(pattern string, values catalogValues) string {
+	return expandCatalogPattern(pattern, values, func(string) string { return "{seq}" })
+}
+
+// renderCatalogNumber は連番を入れて登録番号にするのだ
+func renderCatalogNumber(pattern string, values catalogValues, seq int64) string {
+	return expandCatalogPattern(pattern, values, func(width string) string {
+		if width == "" {
+			return strconv.FormatInt(seq, 10)
+		}
+		n, _ := strconv.Atoi(width)
+		return fmt.Sprintf("%0*d", n, seq)
+	})
+}
+
+func expandCatalogPattern(pattern string, values catalogValues, seq func(width string) string) string {
+	return catalogToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		m := catalogToken.FindStringSubmatch(token)
+		switch m[1] {
+		case "institution":
+			return values.Institution
+		case "collection":
+			return values.Collection
+		case "yyyy":
+			return values.Date.Format("2006")
+		case "yy":
+			return values.Date.Format("06")
+		case "mm":
+			return values.Date.Format("01")
+		case "seq":
+			return seq(m[2])
+		}
+		return token
+	})
+}
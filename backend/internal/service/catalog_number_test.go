@@ -0,0 +1,85 @@
+// backend/internal/service/catalog_number_test.go
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateCatalogPattern(t *testing.T) {
+	valid := []string{
+		defaultCatalogPattern,
+		"{collection}{yy}-{seq}",
+		"{institution}:{yyyy}{mm}-{seq:4}",
+		"ZOO-{seq:012}",
+	}
+	for _, pattern := range valid {
+		if err := validateCatalogPattern(pattern); err != nil {
+			t.Errorf("validateCatalogPattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+
+	invalid := []struct {
+		name    string
+		pattern string
+	}{
+		{"empty", "  "},
+		{"slash", "{institution}/{seq}"},
+		{"no seq", "{institution}-{collection}"},
+		{"two seqs", "{seq}-{seq:03}"},
+		{"zero width", "{seq:00}"},
+		{"too wide", "{seq:013}"},
+		{"unknown token", "{genus}-{seq}"},
+		{"width on non seq", "{yyyy:04}-{seq}"},
+		{"unmatched brace", "{institution-{seq}"},
+		{"uppercase token", "{SEQ}"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCatalogPattern(tt.pattern); !errors.Is(err, ErrInvalidCatalogPattern) {
+				t.Errorf("validateCatalogPattern(%q) = %v, want ErrInvalidCatalogPattern", tt.pattern, err)
+			}
+		})
+	}
+}
+
+func TestRenderCatalogNumber(t *testing.T) {
+	values := catalogValues{
+		Institution: "NSMT",
+		Collection:  "I",
+		Date:        time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		pattern string
+		seq     int64
+		want    string
+	}{
+		{defaultCatalogPattern, 42, "NSMT-I-000042"},
+		{"{collection}{yy}-{seq}", 7, "I24-7"},
+		{"{yyyy}{mm}-{seq:3}", 12, "202403-012"},
+		// 桁数を超えた連番は切り捨てずにそのまま伸ばすのだ
+		{"{seq:02}", 1234, "1234"},
+	}
+	for _, tt := range tests {
+		if got := renderCatalogNumber(tt.pattern, values, tt.seq); got != tt.want {
+			t.Errorf("renderCatalogNumber(%q, %d) = %q, want %q", tt.pattern, tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogScope(t *testing.T) {
+	values := catalogValues{Institution: "NSMT", Collection: "I", Date: time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{defaultCatalogPattern, "NSMT-I-{seq}"},
+		{"{institution}-{yyyy}-{seq:04}", "NSMT-2024-{seq}"},
+	}
+	for _, tt := range tests {
+		if got := catalogScope(tt.pattern, values); got != tt.want {
+			t.Errorf("catalogScope(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
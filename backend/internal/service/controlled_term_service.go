@@ -0,0 +1,440 @@
+// backend/internal/service/controlled_term_service.go
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTerm は語彙にない値を入力したときや、語彙の内容が正しくないときのエラーなのだ
+var ErrInvalidTerm = errors.New("invalid controlled term")
+
+// ErrTermConflict は同じ項目のほかの語彙と値や別名がかぶったときのエラーなのだ
+var ErrTermConflict = errors.New("controlled term conflict")
+
+// controlledTermFields は語彙で値を決める発生情報の項目と、Darwin Coreのtermの名前の対応なのだ
+var controlledTermFields = map[string]string{
+	"sex":       "sex",
+	"lifestage": "lifeStage",
+}
+
+// ListControlledTermsRequest は語彙の一覧のクエリパラメータなのだ
+type ListControlledTermsRequest struct {
+	Field             string `form:"field" binding:"required"`
+	IncludeDeprecated bool   `form:"include_deprecated"`
+	Lang              string `form:"lang"`
+}
+
+// ControlledTermRequest は語彙の作成・更新のリクエストボディなのだ
+// Synonyms は前に自由に書かれていた書き方で、入力と既存データの読み替えに使うのだ
+// SortOrder を省略すると、作成では一番後ろに、更新では今の順番のままにするのだ
+// Labels を省略すると、作成では空に、更新では今のままにするのだ
+type ControlledTermRequest struct {
+	Field      string         `json:"field" binding:"required"`
+	Value      string         `json:"value" binding:"required"`
+	DwcValue   *string        `json:"dwc_value"`
+	Synonyms   []string       `json:"synonyms"`
+	Labels     datatypes.JSON `json:"labels"`
+	SortOrder  *int           `json:"sort_order"`
+	Deprecated bool           `json:"deprecated"`
+}
+
+// ControlledTermItem は語彙と、頼まれた言語の表示名、その値の入っている発生情報の数なのだ
+type ControlledTermItem struct {
+	model.ControlledTerm
+	Label      string `json:"label"`
+	UsageCount int64  `json:"usage_count"`
+}
+
+// NormalizeTermsRequest は既存の発生情報の値を語彙に読み替えるジョブの指定なのだ
+// DryRun なら書き換えずに、どう読み替えるかだけ返すのだ
+type NormalizeTermsRequest struct {
+	Field  string
+	DryRun bool
+}
+
+// NormalizedValue は読み替えた値と、その数なのだ
+type NormalizedValue struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int64  `json:"count"`
+}
+
+// NormalizeTermsResult は読み替えの結果なのだ。Unmatched は語彙のどれにも当たらなかった値なのだ
+type NormalizeTermsResult struct {
+	Field     string                            `json:"field"`
+	Replaced  []NormalizedValue                 `json:"replaced"`
+	Unmatched []repository.ControlledValueCount `json:"unmatched"`
+}
+
+// DarwinCoreTermValues はDarwin Coreのtermごとに、発生情報の値から推奨の値を引く表なのだ
+type DarwinCoreTermValues map[string]map[string]string
+
+// ControlledTermService は性別・成長段階などの語彙のビジネスロジックのインターフェースなのだ
+type ControlledTermService interface {
+	GetTerms(req ListControlledTermsRequest) ([]ControlledTermItem, error)
+	GetTerm(id uint) (*ControlledTermItem, error)
+	CreateTerm(req ControlledTermRequest) (*ControlledTermItem, error)
+	UpdateTerm(id uint, req ControlledTermRequest) (*ControlledTermItem, error)
+	DeleteTerm(id uint) (*ControlledTermItem, error)
+
+	Canonicalize(field, value string) (string, error)
+	NormalizeOccurrences(req NormalizeTermsRequest) (*NormalizeTermsResult, error)
+	DarwinCoreValues() (DarwinCoreTermValues, error)
+}
+
+type controlledTermService struct {
+	db             *gorm.DB
+	repo           repository.ControlledTermRepository
+	vocabularyRepo repository.VocabularyRepository
+}
+
+// NewControlledTermService は新しいサービスを生成するのだ
+func NewControlledTermService(db *gorm.DB, repo repository.ControlledTermRepository, vocabularyRepo repository.VocabularyRepository) ControlledTermService {
+	return &controlledTermService{db: db, repo: repo, vocabularyRepo: vocabularyRepo}
+}
+
+// GetTerms は項目の語彙を並び順で、表示名と使っている数つきで返すのだ
+func (s *controlledTermService) GetTerms(req ListControlledTermsRequest) ([]ControlledTermItem, error) {
+	if err := checkControlledField(req.Field); err != nil {
+		return nil, err
+	}
+	terms, err := s.repo.FindByField(req.Field, req.IncludeDeprecated)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.repo.DistinctValues(req.Field)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]int64, len(values))
+	for _, v := range values {
+		usage[v.Value] = v.Count
+	}
+	items := make([]ControlledTermItem, len(terms))
+	for i, t := range terms {
+		items[i] = ControlledTermItem{ControlledTerm: t, Label: labelOf(t.Labels, t.Value, req.Lang), UsageCount: usage[t.Value]}
+	}
+	return items, nil
+}
+
+func (s *controlledTermService) GetTerm(id uint) (*ControlledTermItem, error) {
+	term, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	count, err := s.repo.CountUsage(s.db, term.Field, term.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &ControlledTermItem{ControlledTerm: *term, Label: term.Value, UsageCount: count}, nil
+}
+
+// CreateTerm は項目に語彙を追加するのだ
+func (s *controlledTermService) CreateTerm(req ControlledTermRequest) (*ControlledTermItem, error) {
+	if err := checkControlledField(req.Field); err != nil {
+		return nil, err
+	}
+	term := &model.ControlledTerm{Field: req.Field, Deprecated: req.Deprecated, Labels: datatypes.JSON("{}")}
+	if req.SortOrder != nil {
+		term.SortOrder = *req.SortOrder
+	} else {
+		next, err := s.repo.NextSortOrder(req.Field)
+		if err != nil {
+			return nil, err
+		}
+		term.SortOrder = next
+	}
+	if err := s.applyTermRequest(term, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Create(tx, term)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTerm(term.TermID)
+}
+
+// UpdateTerm は語彙を書き換えるのだ。値を変えたときは、その値の入った発生情報も新しい値に書き換えるのだ
+func (s *controlledTermService) UpdateTerm(id uint, req ControlledTermRequest) (*ControlledTermItem, error) {
+	current, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	if req.Field != current.Field {
+		return nil, fmt.Errorf("%w: field cannot be changed", ErrInvalidTerm)
+	}
+	term := *current
+	term.Deprecated = req.Deprecated
+	if req.SortOrder != nil {
+		term.SortOrder = *req.SortOrder
+	}
+	if err := s.applyTermRequest(&term, req); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		locked, err := s.repo.FindForUpdate(tx, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if _, err := s.repo.Update(tx, &term); err != nil {
+			return err
+		}
+		if locked.Value != term.Value {
+			_, err := s.repo.ReplaceValue(tx, term.Field, locked.Value, term.Value)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTerm(id)
+}
+
+// DeleteTerm は発生情報に使われていない語彙を削除するのだ
+// 使われている語彙は消さずに使うのをやめた印を付けて、その語彙を返すのだ。削除したときは nil を返すのだ
+func (s *controlledTermService) DeleteTerm(id uint) (*ControlledTermItem, error) {
+	var deprecated *ControlledTermItem
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		term, err := s.repo.FindForUpdate(tx, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		count, err := s.repo.CountUsage(tx, term.Field, term.Value)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return s.repo.Delete(tx, id)
+		}
+		term.Deprecated = true
+		if _, err := s.repo.Update(tx, term); err != nil {
+			return err
+		}
+		deprecated = &ControlledTermItem{ControlledTerm: *term, Label: term.Value, UsageCount: count}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deprecated, nil
+}
+
+// Canonicalize は入力された値を語彙の値にそろえるのだ
+// 値・別名・表示名のどれかに当たればその語彙の値を返し、どれにも当たらなければ ErrInvalidTerm なのだ。空はそのまま空なのだ
+func (s *controlledTermService) Canonicalize(field, value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	terms, err := s.repo.FindByField(field, false)
+	if err != nil {
+		return "", err
+	}
+	term, ok := newTermMatcher(terms).match(value)
+	if !ok {
+		return "", fmt.Errorf("%w: %s %q is not in the vocabulary", ErrInvalidTerm, field, value)
+	}
+	return term.Value, nil
+}
+
+// NormalizeOccurrences は発生情報に自由に書かれていた値を、語彙の値に書き換えるのだ
+// 語彙のどれにも当たらない値は書き換えずに Unmatched で返すので、語彙の別名を足してからもう一度流すのだ
+func (s *controlledTermService) NormalizeOccurrences(req NormalizeTermsRequest) (*NormalizeTermsResult, error) {
+	if err := checkControlledField(req.Field); err != nil {
+		return nil, err
+	}
+	terms, err := s.repo.FindByField(req.Field, false)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.repo.DistinctValues(req.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := newTermMatcher(terms)
+	result := &NormalizeTermsResult{Field: req.Field, Replaced: []NormalizedValue{}, Unmatched: []repository.ControlledValueCount{}}
+	for _, v := range values {
+		term, ok := matcher.match(v.Value)
+		if !ok {
+			result.Unmatched = append(result.Unmatched, v)
+			continue
+		}
+		if term.Value != v.Value {
+			result.Replaced = append(result.Replaced, NormalizedValue{From: v.Value, To: term.Value, Count: v.Count})
+		}
+	}
+	if req.DryRun {
+		return result, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, r := range result.Replaced {
+			count, err := s.repo.ReplaceValue(tx, req.Field, r.From, r.To)
+			if err != nil {
+				return err
+			}
+			result.Replaced[i].Count = count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DarwinCoreValues はDarwin Coreで出すときの値の表を返すのだ
+// 使うのをやめた語彙も古いデータに残っているので表に入れるのだ
+func (s *controlledTermService) DarwinCoreValues() (DarwinCoreTermValues, error) {
+	terms, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	values := DarwinCoreTermValues{}
+	for _, t := range terms {
+		dwcTerm, ok := controlledTermFields[t.Field]
+		if !ok {
+			continue
+		}
+		if values[dwcTerm] == nil {
+			values[dwcTerm] = map[string]string{}
+		}
+		dwcValue := ""
+		if t.DwcValue != nil {
+			dwcValue = *t.DwcValue
+		}
+		values[dwcTerm][termKey(t.Value)] = dwcValue
+	}
+	return values, nil
+}
+
+// apply はレコードの値を推奨の値に置き換えるのだ。語彙にない値はそのまま出すのだ
+func (v DarwinCoreTermValues) apply(record DarwinCoreRecord) DarwinCoreRecord {
+	for term, values := range v {
+		if dwcValue, ok := values[termKey(record[term])]; ok {
+			record[term] = dwcValue
+		}
+	}
+	return record
+}
+
+// applyTermRequest はリクエストの値・別名・表示名を確かめて語彙に入れるのだ
+// 値・別名・表示名は、同じ項目のほかの語彙のどれとも読み替えがかぶってはいけないのだ
+func (s *controlledTermService) applyTermRequest(term *model.ControlledTerm, req ControlledTermRequest) error {
+	value := strings.TrimSpace(req.Value)
+	if value == "" {
+		return fmt.Errorf("%w: value is required", ErrInvalidTerm)
+	}
+	term.Value = value
+	term.DwcValue = trimmedOrNil(req.DwcValue)
+
+	synonyms := []string{}
+	for _, synonym := range req.Synonyms {
+		if synonym = strings.TrimSpace(synonym); synonym != "" {
+			synonyms = append(synonyms, synonym)
+		}
+	}
+	data, err := json.Marshal(synonyms)
+	if err != nil {
+		return err
+	}
+	term.Synonyms = datatypes.JSON(data)
+
+	if len(req.Labels) > 0 {
+		codes, err := s.vocabularyRepo.LanguageCodes()
+		if err != nil {
+			return err
+		}
+		labels, err := normalizeLabels(req.Labels, codes)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTerm, err)
+		}
+		term.Labels = labels
+	}
+
+	others, err := s.repo.FindByField(term.Field, true)
+	if err != nil {
+		return err
+	}
+	others = slices.DeleteFunc(others, func(t model.ControlledTerm) bool { return t.TermID == term.TermID })
+	matcher := newTermMatcher(others)
+	for _, key := range termKeys(term) {
+		if other, ok := matcher.match(key); ok {
+			return fmt.Errorf("%w: %q is already used by %q", ErrTermConflict, key, other.Value)
+		}
+	}
+	return nil
+}
+
+// termMatcher は入力の書き方から語彙を引く表なのだ
+type termMatcher map[string]*model.ControlledTerm
+
+// newTermMatcher は語彙の値・別名・表示名から表を作るのだ。かぶったときは並び順が先の語彙にするのだ
+func newTermMatcher(terms []model.ControlledTerm) termMatcher {
+	m := termMatcher{}
+	for i := range terms {
+		for _, key := range termKeys(&terms[i]) {
+			if _, ok := m[termKey(key)]; !ok {
+				m[termKey(key)] = &terms[i]
+			}
+		}
+	}
+	return m
+}
+
+func (m termMatcher) match(value string) (*model.ControlledTerm, bool) {
+	key := termKey(value)
+	if key == "" {
+		return nil, false
+	}
+	term, ok := m[key]
+	return term, ok
+}
+
+// termKeys は語彙に当たる書き方 (値・別名・表示名) を並べるのだ
+func termKeys(term *model.ControlledTerm) []string {
+	keys := []string{term.Value}
+	var synonyms []string
+	_ = json.Unmarshal(term.Synonyms, &synonyms)
+	keys = append(keys, synonyms...)
+	var labels map[string]string
+	_ = json.Unmarshal(term.Labels, &labels)
+	langs := make([]string, 0, len(labels))
+	for lang := range labels {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		keys = append(keys, labels[lang])
+	}
+	return keys
+}
+
+// termKey は書き方の違い (全角・半角、大文字・小文字、前後の空白) をそろえるのだ
+func termKey(value string) string {
+	return strings.ToLower(strings.TrimSpace(norm.NFKC.String(value)))
+}
+
+func checkControlledField(field string) error {
+	if _, ok := controlledTermFields[field]; !ok {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidTerm, field)
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+// backend/internal/service/coordinate_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 座標の表記法なのだ (places.verbatim_coordinate_system に入るのだ)
+const (
+	NotationDecimal = "decimal degrees"
+	NotationDMS     = "degrees minutes seconds"
+	NotationUTM     = "UTM"
+	NotationMGRS    = "MGRS"
+	NotationJISMesh = "JIS X 0410 mesh"
+)
+
+// 測地系なのだ。変換後は常にWGS84で places.coordinates に入れるのだ
+const (
+	DatumWGS84   = "WGS84"
+	DatumJGD2000 = "JGD2000"
+	DatumJGD2011 = "JGD2011"
+	DatumTokyo   = "Tokyo"
+)
+
+// ParsedCoordinate は色々な表記の座標をWGS84に変換した結果なのだ
+type ParsedCoordinate struct {
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Verbatim    string  `json:"verbatim_coordinates"`
+	Notation    string  `json:"verbatim_coordinate_system"`
+	Datum       string  `json:"verbatim_datum"`
+	Uncertainty float64 `json:"uncertainty_meters"` // 表記の精度やメッシュの大きさから求めた誤差半径なのだ
+}
+
+// WKT は places.coordinates に入れる "POINT(lon lat)" 形式を返すのだ
+func (p *ParsedCoordinate) WKT() string {
+	return fmt.Sprintf("POINT(%s %s)",
+		strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(p.Latitude, 'f', -1, 64))
+}
+
+// ErrInvalidCoordinates は座標の文字列を解釈できなかったときのエラーなのだ
+var ErrInvalidCoordinates = errors.New("invalid coordinates")
+
+// CoordinateService は野帳に書かれた座標の文字列を解釈するサービスのインターフェースなのだ
+// 10進数、度分秒、UTM、MGRS、地域メッシュコードに対応するのだ
+type CoordinateService interface {
+	Parse(verbatim, datum string) (*ParsedCoordinate, error)
+}
+
+type coordinateService struct{}
+
+// NewCoordinateService は新しいサービスを生成するのだ
+func NewCoordinateService() CoordinateService {
+	return &coordinateService{}
+}
+
+var (
+	meshPattern = regexp.MustCompile(`^(\d{4})(?:-?(\d{2})(?:-?(\d{2})(?:-?([1-4]{1,3}))?)?)?$`)
+	mgrsPattern = regexp.MustCompile(`^(\d{1,2})([C-HJ-NP-X])\s*([A-HJ-NP-Z])([A-HJ-NP-V])\s*(\d*)\s*(\d*)$`)
+	utmPattern  = regexp.MustCompile(`^(\d{1,2})\s*([C-HJ-NP-X])\s+(\d+(?:\.\d+)?)\s*M?\s*E?\s*[,\s]\s*(\d+(?:\.\d+)?)\s*M?\s*N?$`)
+)
+
+// Parse は座標の文字列を表記法を自動判別して解釈し、WGS84に変換するのだ
+// datum が空なら、メッシュコードはJGD2000、それ以外はWGS84とみなすのだ
+func (s *coordinateService) Parse(verbatim, datum string) (*ParsedCoordinate, error) {
+	text := strings.TrimSpace(verbatim)
+	if text == "" {
+		return nil, errors.New("座標が空です")
+	}
+	upper := strings.ToUpper(text)
+
+	var (
+		result *ParsedCoordinate
+		err    error
+	)
+	switch {
+	case meshPattern.MatchString(upper):
+		if datum == "" {
+			datum = DatumJGD2000
+		}
+		result, err = parseJISMesh(upper)
+	case mgrsPattern.MatchString(upper):
+		result, err = parseMGRS(upper, datum)
+	case utmPattern.MatchString(upper):
+		result, err = parseUTM(upper, datum)
+	default:
+		result, err = parseDegrees(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeDatum(datum)
+	if err != nil {
+		return nil, err
+	}
+	if normalized == DatumTokyo {
+		result.Latitude, result.Longitude = tokyoToWGS84(result.Latitude, result.Longitude)
+	}
+	result.Verbatim = text
+	result.Datum = normalized
+	return result, nil
+}
+
+// normalizeDatum は色々な書き方の測地系名をそろえるのだ
+func normalizeDatum(datum string) (string, error) {
+	switch strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(datum)) {
+	case "", "WGS84", "EPSG:4326":
+		return DatumWGS84, nil
+	case "JGD2000", "EPSG:4612", "世界測地系":
+		return DatumJGD2000, nil
+	case "JGD2011", "EPSG:6668":
+		return DatumJGD2011, nil
+	case "TOKYO", "TD", "TOKYODATUM", "EPSG:4301", "日本測地系", "旧日本測地系":
+		return DatumTokyo, nil
+	default:
+		return "", fmt.Errorf("対応していない測地系です: %s", datum)
+	}
+}
+
+// tokyoToWGS84 は日本測地系の緯度経度を世界測地系に近似変換するのだ (誤差は数m程度)
+func tokyoToWGS84(lat, lon float64) (float64, float64) {
+	wLat := lat - 0.00010695*lat + 0.000017464*lon + 0.0046017
+	wLon := lon - 0.000046038*lat - 0.000083043*lon + 0.010040
+	return wLat, wLon
+}
+
+// --- 10進数・度分秒 ---
+
+var degreeReplacer = strings.NewReplacer(
+	"北緯", " N ", "南緯", " S ", "東経", " E ", "西経", " W ",
+	"度", "°", "分", "'", "秒", "\"",
+	"′", "'", "’", "'", "″", "\"", "”", "\"", "，", ",", "、", ",",
+)
+
+var degreeTokenPattern = regexp.MustCompile(`[NSEW]|-?\d+(?:\.\d+)?|[,;/]`)
+
+type degreeGroup struct {
+	hemisphere string
+	parts      []string
+}
+
+// parseDegrees は "35.6581, 139.7414" や `35°39'29.1"N 139°44'28.8"E`、"北緯35度39分29秒" などを解釈するのだ
+func parseDegrees(text string) (*ParsedCoordinate, error) {
+	normalized := strings.ToUpper(degreeReplacer.Replace(text))
+	tokens := degreeTokenPattern.FindAllString(normalized, -1)
+
+	var groups []*degreeGroup
+	current := &degreeGroup{}
+	closeGroup := func() {
+		if len(current.parts) > 0 {
+			groups = append(groups, current)
+		}
+		current = &degreeGroup{}
+	}
+	for _, token := range tokens {
+		switch token {
+		case "N", "S", "E", "W":
+			if len(current.parts) == 0 {
+				current.hemisphere = token
+			} else if current.hemisphere == "" {
+				current.hemisphere = token
+				closeGroup()
+			} else {
+				closeGroup()
+				current.hemisphere = token
+			}
+		case ",", ";", "/":
+			closeGroup()
+		default:
+			current.parts = append(current.parts, token)
+		}
+	}
+	closeGroup()
+
+	// 区切りがない "35 39 29 139 44 28" は半分に分けるのだ
+	if len(groups) == 1 && groups[0].hemisphere == "" && len(groups[0].parts)%2 == 0 {
+		half := len(groups[0].parts) / 2
+		groups = []*degreeGroup{{parts: groups[0].parts[:half]}, {parts: groups[0].parts[half:]}}
+	}
+	if len(groups) != 2 {
+		return nil, fmt.Errorf("座標として解釈できません: %s", text)
+	}
+
+	latGroup, lonGroup := groups[0], groups[1]
+	if latGroup.hemisphere == "E" || latGroup.hemisphere == "W" || lonGroup.hemisphere == "N" || lonGroup.hemisphere == "S" {
+		latGroup, lonGroup = lonGroup, latGroup
+	}
+	if latGroup.hemisphere == "E" || latGroup.hemisphere == "W" || lonGroup.hemisphere == "N" || lonGroup.hemisphere == "S" {
+		return nil, fmt.Errorf("緯度と経度の指定が不正です: %s", text)
+	}
+
+	lat, latRes, err := latGroup.value()
+	if err != nil {
+		return nil, err
+	}
+	lon, lonRes, err := lonGroup.value()
+	if err != nil {
+		return nil, err
+	}
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, fmt.Errorf("緯度経度の範囲外です: %s", text)
+	}
+
+	notation := NotationDMS
+	if len(latGroup.parts) == 1 && len(lonGroup.parts) == 1 {
+		notation = NotationDecimal
+	}
+	// 記録された桁までしか分からないので、精度のセルの対角線を誤差とするのだ
+	dy := latRes * metersPerDegreeLat
+	dx := lonRes * metersPerDegreeLat * math.Cos(lat*math.Pi/180)
+	return &ParsedCoordinate{
+		Latitude:    lat,
+		Longitude:   lon,
+		Notation:    notation,
+		Uncertainty: math.Hypot(dx, dy),
+	}, nil
+}
+
+// value は度・分・秒を10進数の度に直し、最後の桁から精度(度)も返すのだ
+func (g *degreeGroup) value() (float64, float64, error) {
+	if len(g.parts) > 3 {
+		return 0, 0, fmt.Errorf("度分秒の数が多すぎます: %s", strings.Join(g.parts, " "))
+	}
+	negative := strings.HasPrefix(g.parts[0], "-")
+	divisors := []float64{1, 60, 3600}
+	total, resolution := 0.0, 0.0
+	for i, part := range g.parts {
+		v, err := strconv.ParseFloat(strings.TrimPrefix(part, "-"), 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if i > 0 && (v >= 60 || strings.HasPrefix(part, "-")) {
+			return 0, 0, fmt.Errorf("分・秒は0以上60未満である必要があります: %s", part)
+		}
+		total += v / divisors[i]
+		resolution = math.Pow(10, -float64(decimalPlaces(part))) / divisors[i]
+	}
+	if negative || g.hemisphere == "S" || g.hemisphere == "W" {
+		total = -total
+	}
+	return total, resolution, nil
+}
+
+func decimalPlaces(number string) int {
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		return len(number) - i - 1
+	}
+	return 0
+}
+
+// --- 地域メッシュコード (JIS X 0410) ---
+
+// parseJISMesh は1次〜3次メッシュと、2分の1・4分の1・8分の1メッシュを解釈するのだ
+// 座標はメッシュの中心、誤差はメッシュの対角線の半分なのだ
+func parseJISMesh(code string) (*ParsedCoordinate, error) {
+	m := meshPattern.FindStringSubmatch(code)
+	first := m[1]
+	lat := float64(atoiDigit(first[0:2])) / 1.5
+	lon := float64(atoiDigit(first[2:4])) + 100
+	dLat, dLon := 40.0/60, 1.0
+
+	if second := m[2]; second != "" {
+		q, v := atoiDigit(second[0:1]), atoiDigit(second[1:2])
+		if q > 7 || v > 7 {
+			return nil, fmt.Errorf("2次メッシュの番号が不正です: %s", code)
+		}
+		dLat, dLon = 5.0/60, 7.5/60
+		lat += float64(q) * dLat
+		lon += float64(v) * dLon
+	}
+	if third := m[3]; third != "" {
+		dLat, dLon = 30.0/3600, 45.0/3600
+		lat += float64(atoiDigit(third[0:1])) * dLat
+		lon += float64(atoiDigit(third[1:2])) * dLon
+	}
+	for _, quadrant := range m[4] {
+		dLat, dLon = dLat/2, dLon/2
+		if quadrant == '3' || quadrant == '4' {
+			lat += dLat
+		}
+		if quadrant == '2' || quadrant == '4' {
+			lon += dLon
+		}
+	}
+
+	centerLat := lat + dLat/2
+	dy := dLat * metersPerDegreeLat
+	dx := dLon * metersPerDegreeLat * math.Cos(centerLat*math.Pi/180)
+	return &ParsedCoordinate{
+		Latitude:    centerLat,
+		Longitude:   lon + dLon/2,
+		Notation:    NotationJISMesh,
+		Uncertainty: math.Hypot(dx, dy) / 2,
+	}, nil
+}
+
+func atoiDigit(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// --- UTM / MGRS ---
+
+// parseUTM は "54S 382000 3950000" のようなUTM座標を解釈するのだ
+// 半球は緯度帯の文字 (N以降が北半球) で判定するのだ
+func parseUTM(text, datum string) (*ParsedCoordinate, error) {
+	m := utmPattern.FindStringSubmatch(text)
+	zone := atoiDigit(m[1])
+	if zone < 1 || zone > 60 {
+		return nil, fmt.Errorf("UTMのゾーン番号が不正です: %d", zone)
+	}
+	easting, _ := strconv.ParseFloat(m[3], 64)
+	northing, _ := strconv.ParseFloat(m[4], 64)
+
+	lat, lon := utmToLatLon(zone, m[2][0] >= 'N', easting, northing, ellipsoidFor(datum))
+	resolution := math.Pow(10, -float64(max(decimalPlaces(m[3]), decimalPlaces(m[4]))))
+	return &ParsedCoordinate{
+		Latitude:    lat,
+		Longitude:   lon,
+		Notation:    NotationUTM,
+		Uncertainty: math.Hypot(resolution, resolution),
+	}, nil
+}
+
+// MGRSの緯度帯ごとの最小northing(100km単位に丸めたもの)なのだ
+var mgrsMinNorthing = map[byte]float64{
+	'C': 1100000, 'D': 2000000, 'E': 2800000, 'F': 3700000, 'G': 4600000,
+	'H': 5500000, 'J': 6400000, 'K': 7300000, 'L': 8200000, 'M': 9100000,
+	'N': 0, 'P': 800000, 'Q': 1700000, 'R': 2600000, 'S': 3500000,
+	'T': 4400000, 'U': 5300000, 'V': 6200000, 'W': 7000000, 'X': 7900000,
+}
+
+var (
+	mgrsColumnLetters = []string{"ABCDEFGH", "JKLMNPQR", "STUVWXYZ"}
+	mgrsRowLetters    = "ABCDEFGHJKLMNPQRSTUV"
+)
+
+// parseMGRS は "54SUE8200050000" や "54S UE 820 500" のようなMGRS座標を解釈するのだ
+// 座標は桁数で決まる正方形の中心、誤差はその対角線の半分なのだ
+func parseMGRS(text, datum string) (*ParsedCoordinate, error) {
+	m := mgrsPattern.FindStringSubmatch(text)
+	zone := atoiDigit(m[1])
+	if zone < 1 || zone > 60 {
+		return nil, fmt.Errorf("MGRSのゾーン番号が不正です: %d", zone)
+	}
+	band := m[2][0]
+	digits := m[5] + m[6]
+	if len(digits)%2 != 0 || len(digits) > 10 {
+		return nil, fmt.Errorf("MGRSの数字の桁数が不正です: %s", text)
+	}
+
+	column := strings.IndexByte(mgrsColumnLetters[(zone-1)%3], m[3][0])
+	if column < 0 {
+		return nil, fmt.Errorf("MGRSの100km区画の列文字が不正です: %s", m[3])
+	}
+	row := strings.IndexByte(mgrsRowLetters, m[4][0])
+	if zone%2 == 0 {
+		row = (row + 15) % 20 // 偶数ゾーンは F が0から始まるのだ
+	}
+
+	precision := len(digits) / 2
+	size := math.Pow(10, float64(5-precision))
+	var eastingIn, northingIn float64
+	if precision > 0 {
+		eastingIn = float64(atoiDigit(digits[:precision])) * size
+		northingIn = float64(atoiDigit(digits[precision:])) * size
+	}
+
+	easting := float64(column+1)*100000 + eastingIn + size/2
+	northing100k := float64(row) * 100000
+	for northing100k < mgrsMinNorthing[band] {
+		northing100k += 2000000
+	}
+	northing := northing100k + northingIn + size/2
+
+	lat, lon := utmToLatLon(zone, band >= 'N', easting, northing, ellipsoidFor(datum))
+	return &ParsedCoordinate{
+		Latitude:    lat,
+		Longitude:   lon,
+		Notation:    NotationMGRS,
+		Uncertainty: math.Hypot(size, size) / 2,
+	}, nil
+}
+
+const metersPerDegreeLat = 111320.0
+
+type ellipsoid struct {
+	a, f float64
+}
+
+var (
+	ellipsoidWGS84  = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	ellipsoidBessel = ellipsoid{a: 6377397.155, f: 1 / 299.152813}
+)
+
+// ellipsoidFor は日本測地系ならベッセル楕円体、それ以外はWGS84(GRS80とほぼ同じ)を返すのだ
+func ellipsoidFor(datum string) ellipsoid {
+	if normalized, _ := normalizeDatum(datum); normalized == DatumTokyo {
+		return ellipsoidBessel
+	}
+	return ellipsoidWGS84
+}
+
+// utmToLatLon はUTMの座標を緯度経度に逆変換するのだ (Snyder, Map Projections の式)
+func utmToLatLon(zone int, north bool, easting, northing float64, el ellipsoid) (float64, float64) {
+	const k0 = 0.9996
+	e2 := el.f * (2 - el.f)
+	ep2 := e2 / (1 - e2)
+	x := easting - 500000
+	y := northing
+	if !north {
+		y -= 10000000
+	}
+
+	mu := y / k0 / (el.a * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu +
+		(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := ep2 * cos1 * cos1
+	t1 := tan1 * tan1
+	n1 := el.a / math.Sqrt(1-e2*sin1*sin1)
+	r1 := el.a * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := x / (n1 * k0)
+
+	lat := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos1
+
+	centralMeridian := float64(zone*6 - 183)
+	return lat * 180 / math.Pi, centralMeridian + lon*180/math.Pi
+}
@@ -0,0 +1,127 @@
+// backend/internal/service/coordinate_service_test.go
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoordinateServiceParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		verbatim  string
+		datum     string
+		lat, lon  float64
+		notation  string
+		wantDatum string
+	}{
+		{"decimal", "35.6812, 139.7671", "", 35.6812, 139.7671, NotationDecimal, DatumWGS84},
+		{"decimal negative", "-33.8688 151.2093", "WGS84", -33.8688, 151.2093, NotationDecimal, DatumWGS84},
+		{"dms", `35°40'52.3"N 139°46'1.6"E`, "", 35 + 40.0/60 + 52.3/3600, 139 + 46.0/60 + 1.6/3600, NotationDMS, DatumWGS84},
+		{"dms japanese", "北緯35度40分52秒 東経139度46分2秒", "", 35 + 40.0/60 + 52.0/3600, 139 + 46.0/60 + 2.0/3600, NotationDMS, DatumWGS84},
+		{"dms longitude first", `139°46'E 35°40'N`, "", 35 + 40.0/60, 139 + 46.0/60, NotationDMS, DatumWGS84},
+		{"dms southern and western", `33°52'S 70°40'W`, "", -(33 + 52.0/60), -(70 + 40.0/60), NotationDMS, DatumWGS84},
+		{"dms without separator", "35 40 52 139 46 2", "", 35 + 40.0/60 + 52.0/3600, 139 + 46.0/60 + 2.0/3600, NotationDMS, DatumWGS84},
+		{"first mesh", "5339", "", 35 + 40.0/60, 139.5, NotationJISMesh, DatumJGD2000},
+		{"third mesh", "53394611", "", 35.675 + 15.0/3600, 139.7625 + 22.5/3600, NotationJISMesh, DatumJGD2000},
+		{"third mesh with hyphens", "5339-46-11", "", 35.675 + 15.0/3600, 139.7625 + 22.5/3600, NotationJISMesh, DatumJGD2000},
+		{"quarter mesh", "5339461144", "", 35.675 + 26.25/3600, 139.7625 + 39.375/3600, NotationJISMesh, DatumJGD2000},
+		{"utm", "54S 388000 3950000", "", 35.68755090517764, 139.76221299735735, NotationUTM, DatumWGS84},
+		{"utm on central meridian", "54N 500000 0", "", 0, 141, NotationUTM, DatumWGS84},
+		{"utm southern hemisphere", "33H 500000 6000000", "", -36.144718100871245, 15, NotationUTM, DatumWGS84},
+		{"mgrs 100km square", "54SUE", "", 35.68250146830274, 139.34238700227206, NotationMGRS, DatumWGS84},
+		{"mgrs 1m", "54SUE8200050000", "", 35.68685549003306, 139.6959234202503, NotationMGRS, DatumWGS84},
+		{"mgrs with spaces", "54S UE 82000 50000", "", 35.68685549003306, 139.6959234202503, NotationMGRS, DatumWGS84},
+		{"tokyo datum", "35.0, 135.0", "日本測地系", 35.00321609, 134.99721786, NotationDecimal, DatumTokyo},
+		{"epsg datum", "35.0, 135.0", "EPSG:6668", 35, 135, NotationDecimal, DatumJGD2011},
+	}
+
+	s := NewCoordinateService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Parse(tt.verbatim, tt.datum)
+			if err != nil {
+				t.Fatalf("Parse(%q, %q) error: %v", tt.verbatim, tt.datum, err)
+			}
+			// 1e-6度は赤道で約11cmなのだ
+			if math.Abs(got.Latitude-tt.lat) > 1e-6 || math.Abs(got.Longitude-tt.lon) > 1e-6 {
+				t.Errorf("Parse(%q) = (%v, %v), want (%v, %v)", tt.verbatim, got.Latitude, got.Longitude, tt.lat, tt.lon)
+			}
+			if got.Notation != tt.notation {
+				t.Errorf("Notation = %q, want %q", got.Notation, tt.notation)
+			}
+			if got.Datum != tt.wantDatum {
+				t.Errorf("Datum = %q, want %q", got.Datum, tt.wantDatum)
+			}
+			if got.Verbatim == "" || got.Uncertainty <= 0 {
+				t.Errorf("Verbatim = %q, Uncertainty = %v, want both set", got.Verbatim, got.Uncertainty)
+			}
+		})
+	}
+}
+
+func TestCoordinateServiceParseUncertainty(t *testing.T) {
+	tests := []struct {
+		name     string
+		verbatim string
+		min, max float64
+	}{
+		// 小数4桁は約11m四方なのだ
+		{"decimal 4 places", "35.6812, 139.7671", 10, 20},
+		{"dms whole seconds", `35°40'52"N 139°46'2"E`, 30, 45},
+		// 1次メッシュは約80km四方なのだ
+		{"first mesh", "5339", 50000, 70000},
+		{"third mesh", "53394611", 500, 800},
+		{"mgrs 1m", "54SUE8200050000", 0.5, 1},
+		{"mgrs 100km", "54SUE", 70000, 71000},
+	}
+
+	s := NewCoordinateService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Parse(tt.verbatim, "")
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.verbatim, err)
+			}
+			if got.Uncertainty < tt.min || got.Uncertainty > tt.max {
+				t.Errorf("Uncertainty = %v, want between %v and %v", got.Uncertainty, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestCoordinateServiceParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		verbatim string
+		datum    string
+	}{
+		{"empty", "  ", ""},
+		{"text", "somewhere near the river", ""},
+		{"single number", "35.6812", ""},
+		{"latitude out of range", "95.0, 139.0", ""},
+		{"two latitudes", `35°N 36°N`, ""},
+		{"minutes over 60", `35°61'N 139°46'E`, ""},
+		{"second mesh out of range", "533980", ""},
+		{"utm zone out of range", "61S 500000 3950000", ""},
+		{"mgrs odd digits", "54SUE123", ""},
+		{"mgrs bad column letter", "54SAE", ""},
+		{"unknown datum", "35.0, 135.0", "NAD27"},
+	}
+
+	s := NewCoordinateService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := s.Parse(tt.verbatim, tt.datum); err == nil {
+				t.Errorf("Parse(%q, %q) = %+v, want error", tt.verbatim, tt.datum, got)
+			}
+		})
+	}
+}
+
+func TestParsedCoordinateWKT(t *testing.T) {
+	p := &ParsedCoordinate{Latitude: 35.5, Longitude: 139.25}
+	if got, want := p.WKT(), "POINT(139.25 35.5)"; got != want {
+		t.Errorf("WKT() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,698 @@
+// backend/internal/service/darwin_core_service.go
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// DarwinCoreRecord は1行分のDarwin Core (term名 → 値) なのだ
+type DarwinCoreRecord map[string]string
+
+// Occurrenceコアで出力するtermの順番なのだ
+var occurrenceCoreTerms = []string{
+	"occurrenceID", "eventID", "basisOfRecord", "eventDate", "recordedBy",
+	"individualCount", "organismID", "organismName", "sex", "lifeStage", "occurrenceRemarks",
+	"institutionCode", "collectionCode",
+	"scientificName", "kingdom", "phylum", "class", "order", "family", "genus",
+	"identifiedBy", "dateIdentified",
+	"decimalLatitude", "decimalLongitude", "geodeticDatum", "coordinateUncertaintyInMeters",
+	"verbatimCoordinates", "verbatimCoordinateSystem", "verbatimSRS",
+	"country", "stateProvince", "municipality", "locality", "verbatimLocality",
+	"minimumElevationInMeters", "maximumElevationInMeters",
+	"minimumDepthInMeters", "maximumDepthInMeters", "habitat",
+	"georeferencedBy", "georeferencedDate", "georeferenceProtocol",
+}
+
+// Eventコアで出力するtermの順番なのだ
+var eventCoreTerms = []string{
+	"eventID", "parentEventID", "eventType", "fieldNumber", "eventDate",
+	"samplingProtocol", "sampleSizeValue", "sampleSizeUnit", "samplingEffort",
+	"recordedBy", "eventRemarks",
+	"decimalLatitude", "decimalLongitude", "geodeticDatum", "coordinateUncertaintyInMeters",
+	"verbatimCoordinates", "verbatimCoordinateSystem", "verbatimSRS",
+	"country", "stateProvince", "municipality", "locality", "verbatimLocality",
+	"minimumElevationInMeters", "maximumElevationInMeters",
+	"minimumDepthInMeters", "maximumDepthInMeters", "habitat",
+	"georeferencedBy", "georeferencedDate", "georeferenceProtocol",
+}
+
+// Audubon Core のMultimedia拡張で出力するtermの順番なのだ
+var multimediaTerms = []string{
+	"coreid", "dcterms:identifier", "dcterms:type", "dc:format", "ac:accessURI",
+	"ac:caption", "ac:subjectPart", "ac:captureDevice", "dc:creator", "dcterms:created",
+	"dcterms:rights", "xmpRights:WebStatement", "xmpRights:UsageTerms", "xmpRights:Owner",
+	"exif:PixelXDimension", "exif:PixelYDimension", "ac:hashFunction", "ac:hashValue",
+}
+
+// MeasurementOrFact拡張で出力するtermの順番なのだ
+var measurementTerms = []string{
+	"coreid", "measurementID", "measurementType", "measurementValue", "measurementAccuracy",
+	"measurementUnit", "measurementDeterminedBy", "measurementDeterminedDate",
+	"measurementMethod", "measurementRemarks",
+}
+
+// termの接頭辞と名前空間なのだ。接頭辞のないtermはDarwin Coreなのだ
+var termNamespaces = map[string]string{
+	"dwc":       "http://rs.tdwg.org/dwc/terms/",
+	"dcterms":   "http://purl.org/dc/terms/",
+	"dc":        "http://purl.org/dc/elements/1.1/",
+	"ac":        "http://rs.tdwg.org/ac/terms/",
+	"xmp":       "http://ns.adobe.com/xap/1.0/",
+	"xmpRights": "http://ns.adobe.com/xap/1.0/rights/",
+	"exif":      "http://ns.adobe.com/exif/1.0/",
+}
+
+// DarwinCoreService はDarwin Core形式での出力のインターフェースなのだ
+type DarwinCoreService interface {
+	ExportOccurrences(req SearchRequest) ([]DarwinCoreRecord, error)
+	ExportMultimedia(req SearchRequest) ([]DarwinCoreRecord, error)
+	ExportMeasurements(req SearchRequest) ([]DarwinCoreRecord, error)
+	WriteArchive(w io.Writer, req SearchRequest) error
+	WriteEventArchive(w io.Writer, req SearchRequest) error
+}
+
+type darwinCoreService struct {
+	db             *gorm.DB
+	occurrenceRepo repository.OccurrenceRepository
+	eventRepo      repository.EventRepository
+	terms          ControlledTermService
+	publicBaseURL  string
+}
+
+// NewDarwinCoreService は新しいサービスを生成するのだ
+// publicBaseURL は画像のURLを組み立てるための、外から見たAPIのURLなのだ
+func NewDarwinCoreService(db *gorm.DB, occurrenceRepo repository.OccurrenceRepository, eventRepo repository.EventRepository, terms ControlledTermService, publicBaseURL string) DarwinCoreService {
+	return &darwinCoreService{db: db, occurrenceRepo: occurrenceRepo, eventRepo: eventRepo, terms: terms, publicBaseURL: strings.TrimRight(publicBaseURL, "/")}
+}
+
+// ExportOccurrences は検索条件に合う発生情報をDarwin CoreのOccurrenceとして返すのだ
+func (s *darwinCoreService) ExportOccurrences(req SearchRequest) ([]DarwinCoreRecord, error) {
+	occurrences, err := s.findForExport(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.occurrenceRecords(occurrences)
+}
+
+// ExportMultimedia は検索条件に合う発生情報の添付ファイルを、Audubon CoreのMultimediaとして返すのだ
+// ライセンスのない添付ファイルは公開できないので出力しないのだ
+func (s *darwinCoreService) ExportMultimedia(req SearchRequest) ([]DarwinCoreRecord, error) {
+	occurrences, err := s.findForExport(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.multimediaRecords(occurrences), nil
+}
+
+// ExportMeasurements は検索条件に合う発生情報の計測値を、MeasurementOrFactとして返すのだ
+func (s *darwinCoreService) ExportMeasurements(req SearchRequest) ([]DarwinCoreRecord, error) {
+	occurrences, err := s.findForExport(req)
+	if err != nil {
+		return nil, err
+	}
+	return measurementRecords(occurrences), nil
+}
+
+// WriteArchive はDarwin Core Archive (meta.xml + occurrence.txt + multimedia.txt + measurementorfact.txt のzip) を書き出すのだ
+func (s *darwinCoreService) WriteArchive(w io.Writer, req SearchRequest) error {
+	occurrences, err := s.findForExport(req)
+	if err != nil {
+		return err
+	}
+	records, err := s.occurrenceRecords(occurrences)
+	if err != nil {
+		return err
+	}
+	core := dwcaTable{
+		RowType:  "http://rs.tdwg.org/dwc/terms/Occurrence",
+		FileName: "occurrence.txt",
+		Terms:    occurrenceCoreTerms,
+		Rows:     records,
+	}
+
+	var extensions []dwcaTable
+	if media := s.multimediaRecords(occurrences); len(media) > 0 {
+		extensions = append(extensions, dwcaTable{
+			RowType:  "http://rs.tdwg.org/ac/terms/Multimedia",
+			FileName: "multimedia.txt",
+			Terms:    multimediaTerms[1:],
+			IDTerm:   "coreid",
+			Rows:     media,
+		})
+	}
+	if measurements := measurementRecords(occurrences); len(measurements) > 0 {
+		extensions = append(extensions, dwcaTable{
+			RowType:  "http://rs.tdwg.org/dwc/terms/MeasurementOrFact",
+			FileName: "measurementorfact.txt",
+			Terms:    measurementTerms[1:],
+			IDTerm:   "coreid",
+			Rows:     measurements,
+		})
+	}
+	return writeDarwinCoreArchive(w, core, extensions)
+}
+
+// WriteEventArchive は採集のまとまりをコアにしたDarwin Core Archive (meta.xml + event.txt + occurrence.txt のzip) を書き出すのだ
+// 検索条件に合う発生情報が付いているまとまりと、その上のまとまりを出力するのだ。プロジェクトを指定したらそのまとまりも全部出すのだ
+// 発生情報は拡張にするので、まとまりに付いていない発生情報は出力しないのだ
+func (s *darwinCoreService) WriteEventArchive(w io.Writer, req SearchRequest) error {
+	occurrences, err := s.findForExport(req)
+	if err != nil {
+		return err
+	}
+	var attached []model.Occurrence
+	var eventIDs []uint
+	for _, o := range occurrences {
+		if o.EventID != nil {
+			attached = append(attached, o)
+			eventIDs = append(eventIDs, *o.EventID)
+		}
+	}
+	events, err := s.eventRepo.FindForExport(eventIDs, req.ProjectID)
+	if err != nil {
+		return err
+	}
+	records, err := s.occurrenceRecords(attached)
+	if err != nil {
+		return err
+	}
+
+	core := dwcaTable{
+		RowType:  "http://rs.tdwg.org/dwc/terms/Event",
+		FileName: "event.txt",
+		Terms:    eventCoreTerms,
+		Rows:     eventRecords(events),
+	}
+	extensions := []dwcaTable{{
+		RowType:  "http://rs.tdwg.org/dwc/terms/Occurrence",
+		FileName: "occurrence.txt",
+		Terms:    occurrenceCoreTerms,
+		IDTerm:   "eventID",
+		Rows:     records,
+	}}
+	return writeDarwinCoreArchive(w, core, extensions)
+}
+
+// occurrenceRecords は発生情報をOccurrenceのレコードにするのだ
+// 性別・成長段階は語彙の推奨の値 (male、adult など) に置き換えるのだ
+func (s *darwinCoreService) occurrenceRecords(occurrences []model.Occurrence) ([]DarwinCoreRecord, error) {
+	termValues, err := s.terms.DarwinCoreValues()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]DarwinCoreRecord, 0, len(occurrences))
+	for i := range occurrences {
+		records = append(records, termValues.apply(toDarwinCoreOccurrence(&occurrences[i])))
+	}
+	return records, nil
+}
+
+func (s *darwinCoreService) multimediaRecords(occurrences []model.Occurrence) []DarwinCoreRecord {
+	var records []DarwinCoreRecord
+	for i := range occurrences {
+		for j := range occurrences[i].Attachments {
+			a := &occurrences[i].Attachments[j]
+			if r, ok := s.toAudubonMultimedia(&occurrences[i], a); ok {
+				records = append(records, r)
+			}
+		}
+	}
+	return records
+}
+
+// toAudubonMultimedia は添付ファイルをAudubon Coreのtermに当てはめるのだ
+// ライセンスが付いていない(または知らないライセンスの)ときは false を返すのだ
+func (s *darwinCoreService) toAudubonMultimedia(o *model.Occurrence, a *model.Attachment) (DarwinCoreRecord, bool) {
+	if a.License == nil {
+		return nil, false
+	}
+	license, ok := findMediaLicense(*a.License)
+	if !ok {
+		return nil, false
+	}
+
+	accessURI := fmt.Sprintf("%s/attachments/%d/download", s.publicBaseURL, a.AttachmentID)
+	r := DarwinCoreRecord{
+		"coreid":                 strconv.FormatUint(uint64(o.OccurrenceID), 10),
+		"dcterms:identifier":     accessURI,
+		"dcterms:type":           dcmiTypeOf(a.ContentType),
+		"dc:format":              a.ContentType,
+		"ac:accessURI":           accessURI,
+		"ac:caption":             a.Caption,
+		"ac:subjectPart":         a.SubjectPart,
+		"ac:captureDevice":       a.CaptureDevice,
+		"dc:creator":             a.Creator,
+		"dcterms:rights":         license.URI,
+		"xmpRights:WebStatement": license.URI,
+		"xmpRights:UsageTerms":   license.Code,
+		"xmpRights:Owner":        a.RightsHolder,
+	}
+	if a.TakenAt != nil {
+		if a.TakenAtOffsetMinutes != nil {
+			r["dcterms:created"] = a.TakenAt.In(time.FixedZone("", int(*a.TakenAtOffsetMinutes)*60)).Format(time.RFC3339)
+		} else {
+			// 時差が分からないときは、タイムゾーンを付けずに現地の時刻だけ書くのだ
+			r["dcterms:created"] = a.TakenAt.UTC().Format("2006-01-02T15:04:05")
+		}
+	}
+	if a.Width != nil && a.Height != nil {
+		r["exif:PixelXDimension"] = strconv.Itoa(*a.Width)
+		r["exif:PixelYDimension"] = strconv.Itoa(*a.Height)
+	}
+	if a.ChecksumSHA256 != "" {
+		r["ac:hashFunction"] = "SHA-256"
+		r["ac:hashValue"] = a.ChecksumSHA256
+	}
+	return r, true
+}
+
+func measurementRecords(occurrences []model.Occurrence) []DarwinCoreRecord {
+	var records []DarwinCoreRecord
+	for i := range occurrences {
+		for j := range occurrences[i].Measurements {
+			records = append(records, toMeasurementOrFact(&occurrences[i].Measurements[j]))
+		}
+	}
+	return records
+}
+
+// toMeasurementOrFact は計測値をMeasurementOrFactのtermに当てはめるのだ
+// 単位のある種類は、種類の単位にそろえた値で出すのだ。数でない形質は入力のままなのだ
+func toMeasurementOrFact(m *model.Measurement) DarwinCoreRecord {
+	r := DarwinCoreRecord{
+		"coreid":              strconv.FormatUint(uint64(m.OccurrenceID), 10),
+		"measurementID":       strconv.FormatUint(uint64(m.MeasurementID), 10),
+		"measurementValue":    m.Value,
+		"measurementUnit":     stringOrEmpty(m.Unit),
+		"measurementAccuracy": stringOrEmpty(m.Accuracy),
+		"measurementMethod":   stringOrEmpty(m.Method),
+		"measurementRemarks":  stringOrEmpty(m.Remarks),
+	}
+	if t := m.MeasurementType; t != nil {
+		r["measurementType"] = t.Name
+		if t.Unit != nil && m.ValueNumber != nil {
+			r["measurementValue"] = formatFloat(m.ValueNumber)
+			r["measurementUnit"] = *t.Unit
+		}
+	}
+	if m.MeasuredBy != nil {
+		r["measurementDeterminedBy"] = displayNameOf(m.MeasuredBy)
+	}
+	if m.MeasuredOn != nil {
+		r["measurementDeterminedDate"] = m.MeasuredOn.Format("2006-01-02")
+	}
+	return r
+}
+
+// dcmiTypeOf はMIMEタイプからDCMIの種類を決めるのだ
+func dcmiTypeOf(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "http://purl.org/dc/dcmitype/StillImage"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "http://purl.org/dc/dcmitype/Sound"
+	case strings.HasPrefix(contentType, "video/"):
+		return "http://purl.org/dc/dcmitype/MovingImage"
+	case strings.HasPrefix(contentType, "text/"), contentType == "application/pdf":
+		return "http://purl.org/dc/dcmitype/Text"
+	}
+	return "http://purl.org/dc/dcmitype/Dataset"
+}
+
+func (s *darwinCoreService) findForExport(req SearchRequest) ([]model.Occurrence, error) {
+	params, err := toSearchParams(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.occurrenceRepo.FindForExport(*params)
+}
+
+// toDarwinCoreOccurrence は発生情報と関連データをDarwin Coreのtermに当てはめるのだ
+func toDarwinCoreOccurrence(o *model.Occurrence) DarwinCoreRecord {
+	r := DarwinCoreRecord{
+		"occurrenceID":      strconv.FormatUint(uint64(o.OccurrenceID), 10),
+		"basisOfRecord":     "HumanObservation",
+		"eventDate":         formatEventTime(eventTimeOf(o)),
+		"sex":               o.Sex,
+		"lifeStage":         o.Lifestage,
+		"occurrenceRemarks": o.Note,
+	}
+	if o.EventID != nil {
+		r["eventID"] = strconv.FormatUint(uint64(*o.EventID), 10)
+	}
+	if o.IndividualCount != nil {
+		r["individualCount"] = strconv.Itoa(*o.IndividualCount)
+	}
+	// 標識の番号はプロジェクトの中でしか区別できないので、organismID には個体のIDを出して、番号は organismName にするのだ
+	if o.IndividualID != nil {
+		r["organismID"] = strconv.FormatUint(uint64(*o.IndividualID), 10)
+	}
+	if o.Individual != nil {
+		r["organismName"] = o.Individual.MarkCode
+	}
+	if o.User != nil {
+		r["recordedBy"] = displayNameOf(o.User)
+	}
+
+	if len(o.Specimens) > 0 {
+		r["basisOfRecord"] = "PreservedSpecimen"
+		r["institutionCode"] = o.Specimens[0].InstitutionIDCode.InstitutionCode
+		r["collectionCode"] = o.Specimens[0].CollectionIDCode.CollectionCode
+	}
+
+	classification := decodeClassification(o.ClassificationJSON)
+	r["kingdom"] = classification.Kingdom
+	r["phylum"] = classification.Phylum
+	r["class"] = classification.Class
+	r["order"] = classification.Order
+	r["family"] = classification.Family
+	r["genus"] = classification.Genus
+	r["scientificName"] = scientificNameOf(classification)
+
+	// Identificationsは新しい順にPreloadしているので、先頭が現在の同定なのだ
+	if len(o.Identifications) > 0 {
+		latest := o.Identifications[0]
+		r["identifiedBy"] = displayNameOf(&latest.User)
+		r["dateIdentified"] = formatEventTime(latest.IdentificatedAt, latest.Timezone)
+	}
+
+	setPlaceTerms(r, o.Place)
+	return r
+}
+
+// eventRecords は採集のまとまりをEventのレコードにするのだ
+// 場所や日時のないまとまりは、いちばん近い上のまとまりのものを使うのだ (罠の場所は地点の場所と同じ、など)
+func eventRecords(events []model.Event) []DarwinCoreRecord {
+	byID := make(map[uint]*model.Event, len(events))
+	for i := range events {
+		byID[events[i].EventID] = &events[i]
+	}
+	records := make([]DarwinCoreRecord, 0, len(events))
+	for i := range events {
+		e := &events[i]
+		r := DarwinCoreRecord{
+			"eventID":          strconv.FormatUint(uint64(e.EventID), 10),
+			"eventType":        e.EventType,
+			"fieldNumber":      stringOrEmpty(e.EventCode),
+			"samplingProtocol": stringOrEmpty(e.SamplingProtocol),
+			"sampleSizeValue":  formatFloat(e.SampleSizeValue),
+			"sampleSizeUnit":   stringOrEmpty(e.SampleSizeUnit),
+			"samplingEffort":   stringOrEmpty(e.SamplingEffort),
+			"eventRemarks":     stringOrEmpty(e.Note),
+		}
+		if e.ParentEventID != nil {
+			r["parentEventID"] = strconv.FormatUint(uint64(*e.ParentEventID), 10)
+		}
+
+		var names []string
+		for _, p := range e.Participants {
+			if p.User != nil {
+				names = append(names, displayNameOf(p.User))
+			}
+		}
+		r["recordedBy"] = strings.Join(names, " | ")
+
+		dated, placed := e, e
+		for dated != nil && dated.StartAt == nil {
+			dated = parentEventOf(dated, byID)
+		}
+		for placed != nil && placed.Place == nil {
+			placed = parentEventOf(placed, byID)
+		}
+		if dated != nil {
+			r["eventDate"] = formatEventTime(*dated.StartAt, dated.Timezone)
+			if dated.EndAt != nil {
+				r["eventDate"] += "/" + formatEventTime(*dated.EndAt, dated.Timezone)
+			}
+		}
+		if placed != nil {
+			setPlaceTerms(r, placed.Place)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func parentEventOf(e *model.Event, byID map[uint]*model.Event) *model.Event {
+	if e.ParentEventID == nil {
+		return nil
+	}
+	return byID[*e.ParentEventID]
+}
+
+// setPlaceTerms は場所を座標・地名などのtermに当てはめるのだ。発生情報と採集のまとまりで使うのだ
+func setPlaceTerms(r DarwinCoreRecord, p *model.Place) {
+	if p == nil {
+		return
+	}
+	if p.Latitude != nil && p.Longitude != nil {
+		r["decimalLatitude"] = formatFloat(p.Latitude)
+		r["decimalLongitude"] = formatFloat(p.Longitude)
+		r["geodeticDatum"] = "WGS84"
+	}
+	if p.Accuracy > 0 {
+		r["coordinateUncertaintyInMeters"] = formatFloat(&p.Accuracy)
+	}
+	r["verbatimCoordinates"] = p.VerbatimCoordinates
+	r["verbatimCoordinateSystem"] = p.VerbatimCoordinateSystem
+	r["verbatimSRS"] = p.VerbatimDatum
+	r["verbatimLocality"] = p.VerbatimLocality
+	r["minimumElevationInMeters"] = formatFloat(p.MinimumElevationInMeters)
+	r["maximumElevationInMeters"] = formatFloat(p.MaximumElevationInMeters)
+	r["minimumDepthInMeters"] = formatFloat(p.MinimumDepthInMeters)
+	r["maximumDepthInMeters"] = formatFloat(p.MaximumDepthInMeters)
+	r["habitat"] = p.Habitat
+	r["georeferencedBy"] = p.GeoreferencedBy
+	r["georeferenceProtocol"] = p.GeoreferenceProtocol
+	if p.GeoreferencedDate != nil {
+		r["georeferencedDate"] = p.GeoreferencedDate.Format("2006-01-02")
+	}
+	if p.PlaceName != nil && len(p.PlaceName.ClassPlaceName) > 0 {
+		var names map[string]interface{}
+		if err := json.Unmarshal(p.PlaceName.ClassPlaceName, &names); err == nil {
+			r["country"] = stringOf(names["country"])
+			r["stateProvince"] = stringOf(names["prefecture"])
+			r["municipality"] = stringOf(names["municipality"])
+			r["locality"] = stringOf(names["name"])
+		}
+	}
+}
+
+// eventTimeOf は最初の観察日時を採集日時とし、なければ発生情報の日時を使うのだ
+func eventTimeOf(o *model.Occurrence) (time.Time, int16) {
+	eventTime, timezone := o.CreatedAt, o.Timezone
+	for _, obs := range o.Observations {
+		if obs.ObservedAt.Before(eventTime) {
+			eventTime, timezone = obs.ObservedAt, obs.Timezone
+		}
+	}
+	return eventTime, timezone
+}
+
+// formatEventTime は記録したタイムゾーン(時間単位)の時刻としてISO 8601で書き出すのだ
+func formatEventTime(t time.Time, timezone int16) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.FixedZone("", int(timezone)*3600)).Format(time.RFC3339)
+}
+
+func scientificNameOf(c ClassificationJSONB) string {
+	if c.Species == "" {
+		return c.Genus
+	}
+	if c.Genus == "" || strings.HasPrefix(c.Species, c.Genus+" ") {
+		return c.Species
+	}
+	return c.Genus + " " + c.Species
+}
+
+func displayNameOf(u *model.User) string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.UserName
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func stringOrEmpty(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func stringOf(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// --- Darwin Core Archive ---
+
+// dwcaTable はアーカイブ内の1ファイル(コアまたは拡張)なのだ。各行の1列目はコアのIDなのだ
+type dwcaTable struct {
+	RowType  string
+	FileName string
+	Terms    []string
+	IDTerm   string // 1列目に入れるキーなのだ (コアでは省略するとTermsの先頭、拡張ではコアのIDを入れたキー)
+	Rows     []DarwinCoreRecord
+}
+
+type dwcaMeta struct {
+	XMLName    xml.Name       `xml:"archive"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Core       dwcaMetaFile   `xml:"core"`
+	Extensions []dwcaMetaFile `xml:"extension"`
+}
+
+type dwcaMetaFile struct {
+	Encoding           string          `xml:"encoding,attr"`
+	FieldsTerminatedBy string          `xml:"fieldsTerminatedBy,attr"`
+	LinesTerminatedBy  string          `xml:"linesTerminatedBy,attr"`
+	FieldsEnclosedBy   string          `xml:"fieldsEnclosedBy,attr"`
+	IgnoreHeaderLines  int             `xml:"ignoreHeaderLines,attr"`
+	RowType            string          `xml:"rowType,attr"`
+	Location           string          `xml:"files>location"`
+	ID                 *dwcaMetaIndex  `xml:"id,omitempty"`
+	CoreID             *dwcaMetaIndex  `xml:"coreid,omitempty"`
+	Fields             []dwcaMetaField `xml:"field"`
+}
+
+type dwcaMetaIndex struct {
+	Index int `xml:"index,attr"`
+}
+
+type dwcaMetaField struct {
+	Index int    `xml:"index,attr"`
+	Term  string `xml:"term,attr"`
+}
+
+// writeDarwinCoreArchive はコアと拡張のタブ区切りファイルとmeta.xmlをzipに書き出すのだ
+func writeDarwinCoreArchive(w io.Writer, core dwcaTable, extensions []dwcaTable) error {
+	archive := zip.NewWriter(w)
+
+	if core.IDTerm == "" {
+		core.IDTerm = core.Terms[0]
+	}
+	meta := dwcaMeta{Xmlns: "http://rs.tdwg.org/dwc/text/"}
+	coreFile, err := writeDwcaTable(archive, core)
+	if err != nil {
+		return err
+	}
+	coreFile.ID = &dwcaMetaIndex{Index: 0}
+	meta.Core = coreFile
+
+	for _, ext := range extensions {
+		extFile, err := writeDwcaTable(archive, ext)
+		if err != nil {
+			return err
+		}
+		extFile.CoreID = &dwcaMetaIndex{Index: 0}
+		meta.Extensions = append(meta.Extensions, extFile)
+	}
+
+	metaWriter, err := archive.Create("meta.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(metaWriter, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(metaWriter)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(meta); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeDwcaTable(archive *zip.Writer, table dwcaTable) (dwcaMetaFile, error) {
+	file := dwcaMetaFile{
+		Encoding:           "UTF-8",
+		FieldsTerminatedBy: `\t`,
+		LinesTerminatedBy:  `\n`,
+		FieldsEnclosedBy:   "",
+		IgnoreHeaderLines:  1,
+		RowType:            table.RowType,
+		Location:           table.FileName,
+	}
+	// 1列目はIDなので、termの一覧からは外して2列目以降に並べるのだ
+	columns := []string{table.IDTerm}
+	for _, term := range table.Terms {
+		if term != table.IDTerm {
+			columns = append(columns, term)
+		}
+	}
+	declared := map[string]bool{}
+	for _, term := range table.Terms {
+		declared[term] = true
+	}
+	for i, term := range columns {
+		if !declared[term] {
+			continue // 拡張のcoreid列はtermとしては宣言しないのだ
+		}
+		file.Fields = append(file.Fields, dwcaMetaField{Index: i, Term: termURI(term)})
+	}
+
+	out, err := archive.Create(table.FileName)
+	if err != nil {
+		return file, err
+	}
+	header := make([]string, len(columns))
+	for i, term := range columns {
+		header[i] = localTermName(term)
+	}
+	if _, err := fmt.Fprintln(out, strings.Join(header, "\t")); err != nil {
+		return file, err
+	}
+	for _, row := range table.Rows {
+		values := make([]string, len(columns))
+		for i, term := range columns {
+			values[i] = escapeDwcaValue(row[term])
+		}
+		if _, err := fmt.Fprintln(out, strings.Join(values, "\t")); err != nil {
+			return file, err
+		}
+	}
+	return file, nil
+}
+
+func termURI(term string) string {
+	if prefix, name, ok := strings.Cut(term, ":"); ok {
+		if ns, known := termNamespaces[prefix]; known {
+			return ns + name
+		}
+	}
+	return termNamespaces["dwc"] + term
+}
+
+func localTermName(term string) string {
+	if _, name, ok := strings.Cut(term, ":"); ok {
+		return name
+	}
+	return term
+}
+
+// escapeDwcaValue はタブ区切りを壊さないように、タブと改行を空白に置き換えるのだ
+func escapeDwcaValue(value string) string {
+	return strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
@@ -0,0 +1,30 @@
+// backend/internal/service/errors.go
+package service
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound は対象のデータが見つからないときのエラーなのだ。ハンドラで404にするのだ
+var ErrNotFound = errors.New("not found")
+
+// uniqueViolation は PostgreSQL の一意制約違反のエラーコードなのだ
+const uniqueViolation = "23505"
+
+// notFoundOr はレコードがないエラーをErrNotFoundに置き換えるのだ
+func notFoundOr(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isUniqueViolation は constraint の一意制約に引っかかったエラーか確かめるのだ
+// 先に確かめていても同時に登録されると制約で弾かれるので、そのときに使うのだ
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == constraint
+}
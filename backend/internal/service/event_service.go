@@ -0,0 +1,504 @@
+// backend/internal/service/event_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidEvent は採集のまとまりの内容が正しくないときのエラーなのだ (段の順が逆、終わりが始めより前など)
+var ErrInvalidEvent = errors.New("invalid event")
+
+// ErrEventCodeTaken は野帳の番号がもう使われているときのエラーなのだ
+var ErrEventCodeTaken = errors.New("event code already in use")
+
+// ErrEventInUse は下のまとまりや発生情報があるまとまりを消そうとしたときのエラーなのだ
+var ErrEventInUse = errors.New("event is in use")
+
+// maxAttachOccurrences は一度にまとまりに付けられる発生情報の数の上限なのだ
+const maxAttachOccurrences = 5000
+
+// ListEventsRequest は採集のまとまりの検索のクエリパラメータなのだ
+// 条件がなければ一番上のまとまり (調査など) を返すのだ
+type ListEventsRequest struct {
+	Code          string `form:"code"` // 前方一致なのだ
+	EventType     string `form:"event_type"`
+	ParentEventID *uint  `form:"parent_event_id"`
+	ProjectID     *uint  `form:"project_id"`
+}
+
+// EventRequest は採集のまとまりの作成・更新のリクエストボディなのだ
+// StartAt と EndAt は始めと終わりの日時 (2006-01-02T15:04、timezone の現地時刻) なのだ
+// SampleSizeValue と SampleSizeUnit は努力量 (例: 3 trap-nights) で、両方送るか両方省略するのだ
+// Participants は参加した人をまるごと入れ替えるのだ
+type EventRequest struct {
+	ParentEventID    *uint                     `json:"parent_event_id"`
+	EventType        string                    `json:"event_type" binding:"required"`
+	EventCode        *string                   `json:"event_code"`
+	Name             *string                   `json:"name"`
+	ProjectID        *uint                     `json:"project_id"`
+	PlaceID          *uint                     `json:"place_id"`
+	SamplingProtocol *string                   `json:"sampling_protocol"`
+	SampleSizeValue  *float64                  `json:"sample_size_value"`
+	SampleSizeUnit   *string                   `json:"sample_size_unit"`
+	SamplingEffort   *string                   `json:"sampling_effort"`
+	StartAt          string                    `json:"start_at"`
+	EndAt            string                    `json:"end_at"`
+	Timezone         int16                     `json:"timezone"`
+	Note             *string                   `json:"note"`
+	Participants     []EventParticipantRequest `json:"participants"`
+}
+
+// EventParticipantRequest は参加した人1人なのだ
+type EventParticipantRequest struct {
+	UserID uint    `json:"user_id"`
+	Role   *string `json:"role"`
+}
+
+// EventOccurrencesRequest はまとまりに発生情報を付けるリクエストボディなのだ
+type EventOccurrencesRequest struct {
+	OccurrenceIDs []uint `json:"occurrence_ids"`
+}
+
+// AbundanceRequest は個体数の集計のクエリパラメータなのだ
+// Rank は分類の段 (species、genus など) で、省略すると species なのだ
+// ByChildren なら、すぐ下のまとまりごとの集計も付けるのだ (地点や罠どうしを比べるのに使うのだ)
+type AbundanceRequest struct {
+	Rank       string `form:"rank"`
+	ByChildren bool   `form:"by_children"`
+}
+
+// EventDetail は採集のまとまりと、上のまとまり・すぐ下のまとまり・発生情報の数なのだ
+// Path は一番上から自分までで、画面に 調査 > 地点 > 罠 と出すのに使うのだ
+type EventDetail struct {
+	*model.Event
+	Path                 []model.Event `json:"path"`
+	Children             []model.Event `json:"children"`
+	OccurrenceCount      int64         `json:"occurrence_count"`
+	TotalOccurrenceCount int64         `json:"total_occurrence_count"` // 下のまとまりの発生情報も含めた数なのだ
+}
+
+// TaxonAbundance は分類群ごとの数と、努力量あたりの個体数なのだ
+type TaxonAbundance struct {
+	repository.TaxonCount
+	PerUnitEffort *float64 `json:"per_unit_effort"` // 努力量が分からないときは nil なのだ
+}
+
+// EventAbundance はまとまりと下のまとまりで採れた個体数の集計なのだ
+// 努力量は、まとまりに書いてあればそれを、なければ下のまとまりの努力量を足したものを使うのだ
+// 努力量の分からない下のまとまりが一つでもあると、その個体数を割る努力量がないので努力量は nil なのだ
+type EventAbundance struct {
+	EventID         uint             `json:"event_id"`
+	Rank            string           `json:"rank"`
+	SampleSizeValue *float64         `json:"sample_size_value"`
+	SampleSizeUnit  *string          `json:"sample_size_unit"`
+	Taxa            []TaxonAbundance `json:"taxa"`
+	Children        []EventAbundance `json:"children,omitempty"`
+}
+
+// EventService は採集のまとまり (Darwin Core の Event) のビジネスロジックのインターフェースなのだ
+type EventService interface {
+	ListEvents(req ListEventsRequest) ([]model.Event, error)
+	GetEvent(id uint) (*EventDetail, error)
+	CreateEvent(req EventRequest) (*EventDetail, error)
+	UpdateEvent(id uint, req EventRequest) (*EventDetail, error)
+	DeleteEvent(id uint) error
+
+	AttachOccurrences(id uint, req EventOccurrencesRequest) (*EventDetail, error)
+	DetachOccurrence(id, occurrenceID uint) error
+	GetAbundance(id uint, req AbundanceRequest) (*EventAbundance, error)
+}
+
+type eventService struct {
+	db   *gorm.DB
+	repo repository.EventRepository
+}
+
+// NewEventService は新しいサービスを生成するのだ
+func NewEventService(db *gorm.DB, repo repository.EventRepository) EventService {
+	return &eventService{db: db, repo: repo}
+}
+
+func (s *eventService) ListEvents(req ListEventsRequest) ([]model.Event, error) {
+	params := repository.EventSearchParams{
+		Code:          strings.TrimSpace(req.Code),
+		EventType:     strings.TrimSpace(req.EventType),
+		ParentEventID: req.ParentEventID,
+		ProjectID:     req.ProjectID,
+	}
+	if params.EventType != "" && !slices.Contains(model.EventTypes, params.EventType) {
+		return nil, fmt.Errorf("%w: unknown event_type %q", ErrInvalidEvent, params.EventType)
+	}
+	params.RootOnly = params.Code == "" && params.EventType == "" && params.ParentEventID == nil && params.ProjectID == nil
+	return s.repo.Search(params)
+}
+
+func (s *eventService) GetEvent(id uint) (*EventDetail, error) {
+	event, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	path, err := s.repo.FindAncestors(id)
+	if err != nil {
+		return nil, err
+	}
+	children, err := s.repo.FindChildren(id)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountOccurrences(id, false)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.repo.CountOccurrences(id, true)
+	if err != nil {
+		return nil, err
+	}
+	return &EventDetail{
+		Event:                event,
+		Path:                 path,
+		Children:             children,
+		OccurrenceCount:      count,
+		TotalOccurrenceCount: total,
+	}, nil
+}
+
+func (s *eventService) CreateEvent(req EventRequest) (*EventDetail, error) {
+	event, participants, err := s.newEvent(0, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.repo.Create(tx, event); err != nil {
+			return err
+		}
+		return s.repo.ReplaceParticipants(tx, event.EventID, participants)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetEvent(event.EventID)
+}
+
+// UpdateEvent は採集のまとまりを書き換えるのだ。親を変えると下のまとまりと発生情報もまとめて動くのだ
+func (s *eventService) UpdateEvent(id uint, req EventRequest) (*EventDetail, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	event, participants, err := s.newEvent(id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 段を変えたときに、下のまとまりより下の段にならないようにするのだ
+	children, err := s.repo.FindChildren(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if eventTypeRank(child.EventType) <= eventTypeRank(event.EventType) {
+			return nil, fmt.Errorf("%w: %s cannot contain %s %d", ErrInvalidEvent, event.EventType, child.EventType, child.EventID)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.repo.Update(tx, event); err != nil {
+			return err
+		}
+		return s.repo.ReplaceParticipants(tx, id, participants)
+	})
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.GetEvent(id)
+}
+
+// DeleteEvent は下のまとまりも発生情報もないまとまりを削除するのだ
+func (s *eventService) DeleteEvent(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	hasChildren, err := s.repo.HasChildren(id)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("%w: it still contains other events", ErrEventInUse)
+	}
+	count, err := s.repo.CountOccurrences(id, false)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d occurrences are attached", ErrEventInUse, count)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Delete(tx, id)
+	})
+}
+
+// AttachOccurrences は発生情報をまとまりに付けるのだ。ほかのまとまりに付いていた発生情報は付け替えるのだ
+func (s *eventService) AttachOccurrences(id uint, req EventOccurrencesRequest) (*EventDetail, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(req.OccurrenceIDs)))
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: occurrence_ids is empty", ErrInvalidEvent)
+	}
+	if len(ids) > maxAttachOccurrences {
+		return nil, fmt.Errorf("%w: at most %d occurrences at once", ErrInvalidEvent, maxAttachOccurrences)
+	}
+	missing, err := s.repo.MissingOccurrences(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: occurrences not found: %s", ErrInvalidEvent, joinIDs(missing))
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.AttachOccurrences(tx, id, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetEvent(id)
+}
+
+// DetachOccurrence は発生情報をまとまりから外すのだ
+func (s *eventService) DetachOccurrence(id, occurrenceID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		detached, err := s.repo.DetachOccurrence(tx, id, occurrenceID)
+		if err != nil {
+			return err
+		}
+		if detached == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetAbundance はまとまりと下のまとまりで採れた個体数を分類群ごとに数えて、努力量あたりの数にするのだ
+func (s *eventService) GetAbundance(id uint, req AbundanceRequest) (*EventAbundance, error) {
+	rank := strings.TrimSpace(req.Rank)
+	if rank == "" {
+		rank = "species"
+	}
+	if !slices.Contains([]string{"kingdom", "phylum", "class", "order", "family", "genus", "species"}, rank) {
+		return nil, fmt.Errorf("%w: unknown rank %q", ErrInvalidEvent, rank)
+	}
+	events, err := s.repo.FindSubtree(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+	tree := newEventEffortTree(events)
+
+	abundance, err := s.abundanceOf(id, rank, tree)
+	if err != nil {
+		return nil, err
+	}
+	if req.ByChildren {
+		abundance.Children = []EventAbundance{}
+		for _, childID := range tree.children[id] {
+			child, err := s.abundanceOf(childID, rank, tree)
+			if err != nil {
+				return nil, err
+			}
+			abundance.Children = append(abundance.Children, *child)
+		}
+	}
+	return abundance, nil
+}
+
+func (s *eventService) abundanceOf(id uint, rank string, tree *eventEffortTree) (*EventAbundance, error) {
+	value, unit, err := tree.effortOf(id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.CountTaxa(id, rank)
+	if err != nil {
+		return nil, err
+	}
+	abundance := &EventAbundance{EventID: id, Rank: rank, SampleSizeValue: value, SampleSizeUnit: unit, Taxa: []TaxonAbundance{}}
+	for _, c := range counts {
+		taxon := TaxonAbundance{TaxonCount: c}
+		if value != nil {
+			perUnit := float64(c.Individuals) / *value
+			taxon.PerUnitEffort = &perUnit
+		}
+		abundance.Taxa = append(abundance.Taxa, taxon)
+	}
+	return abundance, nil
+}
+
+// eventEffortTree は努力量を足し上げるための、まとまりの木なのだ
+type eventEffortTree struct {
+	events   map[uint]*model.Event
+	children map[uint][]uint
+}
+
+func newEventEffortTree(events []model.Event) *eventEffortTree {
+	tree := &eventEffortTree{events: map[uint]*model.Event{}, children: map[uint][]uint{}}
+	for i := range events {
+		e := &events[i]
+		tree.events[e.EventID] = e
+		if e.ParentEventID != nil {
+			tree.children[*e.ParentEventID] = append(tree.children[*e.ParentEventID], e.EventID)
+		}
+	}
+	return tree
+}
+
+// effortOf はまとまりの努力量を返すのだ。書いてなければ下のまとまりの努力量を足すのだ
+// 努力量の分からない下のまとまりが一つでもあれば、個体数だけ数えて努力量を足さないことになるので nil を返すのだ
+// 単位がそろっていないと足せないのでエラーなのだ
+func (t *eventEffortTree) effortOf(id uint) (*float64, *string, error) {
+	e := t.events[id]
+	if e.SampleSizeValue != nil && e.SampleSizeUnit != nil {
+		return e.SampleSizeValue, e.SampleSizeUnit, nil
+	}
+	var total *float64
+	var unit *string
+	for _, childID := range t.children[id] {
+		value, childUnit, err := t.effortOf(childID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if value == nil {
+			return nil, nil, nil
+		}
+		if unit != nil && *unit != *childUnit {
+			return nil, nil, fmt.Errorf("%w: sample sizes under event %d mix units %q and %q", ErrInvalidEvent, id, *unit, *childUnit)
+		}
+		sum := *value
+		if total != nil {
+			sum += *total
+		}
+		total, unit = &sum, childUnit
+	}
+	return total, unit, nil
+}
+
+// newEvent はリクエストから採集のまとまりと参加した人を作るのだ。段・親・日時・努力量を確かめるのだ
+// id は更新するときの自分で、作成のときは0なのだ
+func (s *eventService) newEvent(id uint, req EventRequest) (*model.Event, []model.EventParticipant, error) {
+	event := &model.Event{
+		EventID:          id,
+		ParentEventID:    req.ParentEventID,
+		EventType:        strings.TrimSpace(req.EventType),
+		EventCode:        trimmedOrNil(req.EventCode),
+		Name:             trimmedOrNil(req.Name),
+		ProjectID:        req.ProjectID,
+		PlaceID:          req.PlaceID,
+		SamplingProtocol: trimmedOrNil(req.SamplingProtocol),
+		SampleSizeValue:  req.SampleSizeValue,
+		SampleSizeUnit:   trimmedOrNil(req.SampleSizeUnit),
+		SamplingEffort:   trimmedOrNil(req.SamplingEffort),
+		Timezone:         req.Timezone,
+		Note:             req.Note,
+	}
+	if !slices.Contains(model.EventTypes, event.EventType) {
+		return nil, nil, fmt.Errorf("%w: event_type must be one of %s", ErrInvalidEvent, strings.Join(model.EventTypes, ", "))
+	}
+	if (event.SampleSizeValue == nil) != (event.SampleSizeUnit == nil) {
+		return nil, nil, fmt.Errorf("%w: sample_size_value and sample_size_unit go together", ErrInvalidEvent)
+	}
+	if v := event.SampleSizeValue; v != nil && *v <= 0 {
+		return nil, nil, fmt.Errorf("%w: sample_size_value must be positive", ErrInvalidEvent)
+	}
+
+	location := time.FixedZone("", int(event.Timezone)*3600)
+	for _, d := range []struct {
+		name   string
+		value  string
+		target **time.Time
+	}{
+		{"start_at", req.StartAt, &event.StartAt},
+		{"end_at", req.EndAt, &event.EndAt},
+	} {
+		if strings.TrimSpace(d.value) == "" {
+			continue
+		}
+		t, err := time.ParseInLocation(formDateTimeLayout, strings.TrimSpace(d.value), location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s must be YYYY-MM-DDThh:mm", ErrInvalidEvent, d.name)
+		}
+		*d.target = &t
+	}
+	if event.StartAt != nil && event.EndAt != nil && event.EndAt.Before(*event.StartAt) {
+		return nil, nil, fmt.Errorf("%w: end_at is before start_at", ErrInvalidEvent)
+	}
+
+	if event.ParentEventID != nil {
+		parent, err := s.repo.FindByID(*event.ParentEventID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, fmt.Errorf("%w: parent_event_id %d does not exist", ErrInvalidEvent, *event.ParentEventID)
+			}
+			return nil, nil, err
+		}
+		if eventTypeRank(parent.EventType) >= eventTypeRank(event.EventType) {
+			return nil, nil, fmt.Errorf("%w: %s cannot be placed in %s", ErrInvalidEvent, event.EventType, parent.EventType)
+		}
+		// 自分の下に自分を入れると輪になってしまうのだ
+		if id != 0 {
+			inside, err := s.repo.IsInSubtree(id, parent.EventID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if inside {
+				return nil, nil, fmt.Errorf("%w: cannot move an event into itself", ErrInvalidEvent)
+			}
+		}
+	}
+
+	if event.EventCode != nil {
+		taken, err := s.repo.CodeTaken(*event.EventCode, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if taken {
+			return nil, nil, fmt.Errorf("%w: %s", ErrEventCodeTaken, *event.EventCode)
+		}
+	}
+
+	participants := make([]model.EventParticipant, 0, len(req.Participants))
+	userIDs := make([]uint, 0, len(req.Participants))
+	for _, p := range req.Participants {
+		if p.UserID == 0 {
+			return nil, nil, fmt.Errorf("%w: participants need user_id", ErrInvalidEvent)
+		}
+		if slices.Contains(userIDs, p.UserID) {
+			return nil, nil, fmt.Errorf("%w: user %d is listed twice in participants", ErrInvalidEvent, p.UserID)
+		}
+		userIDs = append(userIDs, p.UserID)
+		participants = append(participants, model.EventParticipant{UserID: p.UserID, Role: trimmedOrNil(p.Role)})
+	}
+	if len(userIDs) > 0 {
+		missing, err := s.repo.MissingUsers(userIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(missing) > 0 {
+			return nil, nil, fmt.Errorf("%w: users not found: %s", ErrInvalidEvent, joinIDs(missing))
+		}
+	}
+	return event, participants, nil
+}
+
+// eventTypeRank はまとまりの段の深さなのだ。調査が0で、下の段ほど大きいのだ
+func eventTypeRank(eventType string) int {
+	return slices.Index(model.EventTypes, eventType)
+}
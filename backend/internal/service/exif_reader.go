@@ -0,0 +1,338 @@
+// backend/internal/service/exif_reader.go
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// PhotoMetadata は写真のEXIFから取り出した撮影情報なのだ
+// JPEGとTIFFのEXIFに対応しているのだ (HEICなどは読めないので空になるのだ)
+type PhotoMetadata struct {
+	CameraMake  string
+	CameraModel string
+	Orientation int
+
+	// TakenAt は撮影時刻なのだ。OffsetMinutes がnilのときは時差が分からないので、壁時計の時刻をUTCとして入れてあるのだ
+	TakenAt       *time.Time
+	OffsetMinutes *int
+	OffsetSource  string
+
+	Latitude  *float64
+	Longitude *float64
+	Altitude  *float64
+	Accuracy  *float64
+}
+
+// 時差をどこから求めたかなのだ
+const (
+	OffsetSourceExif    = "exif_offset"
+	OffsetSourceGPSTime = "gps_time"
+)
+
+// EXIFのタグ番号なのだ
+const (
+	tagMake              = 0x010F
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+	tagOffsetTimeOrig    = 0x9011
+
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+	tagGPSAltitudeRef     = 0x0005
+	tagGPSAltitude        = 0x0006
+	tagGPSTimeStamp       = 0x0007
+	tagGPSDateStamp       = 0x001D
+	tagGPSHPositioningErr = 0x001F
+)
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var errNoExif = errors.New("EXIFが見つかりません")
+
+// readPhotoMetadata はJPEGかTIFFのバイト列からEXIFを読むのだ。EXIFがなければ errNoExif なのだ
+func readPhotoMetadata(data []byte) (*PhotoMetadata, error) {
+	tiff, err := findTIFFData(data)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newTIFFReader(tiff)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &PhotoMetadata{}
+	ifd0 := r.readIFD(r.firstIFD)
+	meta.CameraMake = r.ascii(ifd0, tagMake)
+	meta.CameraModel = r.ascii(ifd0, tagModel)
+	if v, ok := r.integer(ifd0, tagOrientation); ok {
+		meta.Orientation = int(v)
+	}
+
+	var exifIFD, gpsIFD map[uint16]tiffEntry
+	if offset, ok := r.integer(ifd0, tagExifIFD); ok {
+		exifIFD = r.readIFD(offset)
+	}
+	if offset, ok := r.integer(ifd0, tagGPSIFD); ok {
+		gpsIFD = r.readIFD(offset)
+	}
+
+	// 撮影時刻は DateTimeOriginal を優先するのだ
+	dateText := r.ascii(exifIFD, tagDateTimeOriginal)
+	if dateText == "" {
+		dateText = r.ascii(exifIFD, tagDateTimeDigitized)
+	}
+	if dateText == "" {
+		dateText = r.ascii(ifd0, tagDateTime)
+	}
+	if local, err := time.Parse(exifDateLayout, dateText); err == nil {
+		meta.TakenAt = &local
+	}
+
+	if gpsIFD != nil {
+		meta.Latitude = r.gpsCoordinate(gpsIFD, tagGPSLatitude, tagGPSLatitudeRef, "S", 90)
+		meta.Longitude = r.gpsCoordinate(gpsIFD, tagGPSLongitude, tagGPSLongitudeRef, "W", 180)
+		if meta.Latitude == nil || meta.Longitude == nil {
+			meta.Latitude, meta.Longitude = nil, nil
+		}
+		if alt, ok := r.rationals(gpsIFD, tagGPSAltitude); ok && len(alt) == 1 {
+			v := alt[0]
+			if ref, ok := r.integer(gpsIFD, tagGPSAltitudeRef); ok && ref == 1 {
+				v = -v // 1 は海面下なのだ
+			}
+			meta.Altitude = &v
+		}
+		if acc, ok := r.rationals(gpsIFD, tagGPSHPositioningErr); ok && len(acc) == 1 {
+			meta.Accuracy = &acc[0]
+		}
+	}
+
+	// 時差は OffsetTimeOriginal を使い、なければGPSのUTC時刻との差から推定するのだ
+	if meta.TakenAt != nil {
+		if minutes, ok := parseExifOffset(r.ascii(exifIFD, tagOffsetTimeOrig)); ok {
+			meta.OffsetMinutes, meta.OffsetSource = &minutes, OffsetSourceExif
+		} else if utc, ok := r.gpsTime(gpsIFD); ok {
+			if minutes, ok := offsetFromGPSTime(*meta.TakenAt, utc); ok {
+				meta.OffsetMinutes, meta.OffsetSource = &minutes, OffsetSourceGPSTime
+			}
+		}
+		if meta.OffsetMinutes != nil {
+			taken := meta.TakenAt.Add(-time.Duration(*meta.OffsetMinutes) * time.Minute)
+			meta.TakenAt = &taken
+		}
+	}
+	return meta, nil
+}
+
+// findTIFFData はJPEGならAPP1セグメントの中から、TIFFならそのままEXIFのTIFF構造を返すのだ
+func findTIFFData(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return data, nil
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errNoExif
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, errNoExif
+		}
+		marker := data[pos+1]
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break // 画像データが始まったらEXIFはもうないのだ
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, errNoExif
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+		pos += 2 + length
+	}
+	return nil, errNoExif
+}
+
+// --- TIFF構造の読み取り ---
+
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+type tiffReader struct {
+	data     []byte
+	order    binary.ByteOrder
+	firstIFD uint32
+}
+
+func newTIFFReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, errNoExif
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return nil, errNoExif
+	}
+	r.firstIFD = r.order.Uint32(data[4:])
+	return r, nil
+}
+
+// tiffTypeSize は型ごとの1要素のバイト数なのだ
+var tiffTypeSize = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// readIFD は1つのIFDのエントリを読むのだ。壊れた部分は読み飛ばすのだ
+func (r *tiffReader) readIFD(offset uint32) map[uint16]tiffEntry {
+	entries := map[uint16]tiffEntry{}
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return entries
+	}
+	n := uint32(r.order.Uint16(r.data[offset:]))
+	for i := uint32(0); i < n; i++ {
+		pos := uint64(offset) + 2 + uint64(i)*12
+		if pos+12 > uint64(len(r.data)) {
+			break
+		}
+		raw := r.data[pos : pos+12]
+		tag := r.order.Uint16(raw)
+		typ := r.order.Uint16(raw[2:])
+		count := r.order.Uint32(raw[4:])
+		size, ok := tiffTypeSize[typ]
+		if !ok {
+			continue
+		}
+		total := uint64(size) * uint64(count)
+		var value []byte
+		if total <= 4 {
+			value = raw[8 : 8+total]
+		} else {
+			start := uint64(r.order.Uint32(raw[8:]))
+			if start+total > uint64(len(r.data)) {
+				continue
+			}
+			value = r.data[start : start+total]
+		}
+		entries[tag] = tiffEntry{typ: typ, count: count, value: value}
+	}
+	return entries
+}
+
+func (r *tiffReader) ascii(ifd map[uint16]tiffEntry, tag uint16) string {
+	e, ok := ifd[tag]
+	if !ok || e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+func (r *tiffReader) integer(ifd map[uint16]tiffEntry, tag uint16) (uint32, bool) {
+	e, ok := ifd[tag]
+	if !ok || e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case 1, 7:
+		return uint32(e.value[0]), true
+	case 3:
+		return uint32(r.order.Uint16(e.value)), true
+	case 4, 9:
+		return r.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+func (r *tiffReader) rationals(ifd map[uint16]tiffEntry, tag uint16) ([]float64, bool) {
+	e, ok := ifd[tag]
+	if !ok || (e.typ != 5 && e.typ != 10) {
+		return nil, false
+	}
+	values := make([]float64, 0, e.count)
+	for i := uint32(0); i < e.count; i++ {
+		num, den := r.order.Uint32(e.value[i*8:]), r.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return nil, false
+		}
+		if e.typ == 10 {
+			values = append(values, float64(int32(num))/float64(int32(den)))
+		} else {
+			values = append(values, float64(num)/float64(den))
+		}
+	}
+	return values, true
+}
+
+// gpsCoordinate は度・分・秒の3つの有理数と N/S, E/W から10進の度を作るのだ
+func (r *tiffReader) gpsCoordinate(ifd map[uint16]tiffEntry, tag, refTag uint16, negativeRef string, limit float64) *float64 {
+	dms, ok := r.rationals(ifd, tag)
+	if !ok || len(dms) != 3 {
+		return nil
+	}
+	v := dms[0] + dms[1]/60 + dms[2]/3600
+	if strings.EqualFold(r.ascii(ifd, refTag), negativeRef) {
+		v = -v
+	}
+	if math.IsNaN(v) || math.Abs(v) > limit {
+		return nil
+	}
+	return &v
+}
+
+// gpsTime は GPSDateStamp と GPSTimeStamp からUTCの時刻を作るのだ
+func (r *tiffReader) gpsTime(ifd map[uint16]tiffEntry) (time.Time, bool) {
+	date, err := time.Parse("2006:01:02", r.ascii(ifd, tagGPSDateStamp))
+	if err != nil {
+		return time.Time{}, false
+	}
+	hms, ok := r.rationals(ifd, tagGPSTimeStamp)
+	if !ok || len(hms) != 3 {
+		return time.Time{}, false
+	}
+	seconds := hms[0]*3600 + hms[1]*60 + hms[2]
+	return date.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
+// parseExifOffset は "+09:00" のような時差を分に直すのだ
+func parseExifOffset(text string) (int, bool) {
+	t, err := time.Parse("-07:00", text)
+	if err != nil {
+		return 0, false
+	}
+	_, seconds := t.Zone()
+	return seconds / 60, true
+}
+
+// offsetFromGPSTime は撮影時刻(現地)とGPSのUTC時刻の差を15分単位に丸めて時差にするのだ
+// カメラの時計のずれがあるので、丸めた結果がありえない時差なら使わないのだ
+func offsetFromGPSTime(local, utc time.Time) (int, bool) {
+	minutes := int(math.Round(local.Sub(utc).Minutes()/15) * 15)
+	if minutes < -12*60 || minutes > 14*60 {
+		return 0, false
+	}
+	return minutes, true
+}
@@ -0,0 +1,96 @@
+// backend/internal/service/fasta.go
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// fastaLineWidth はFASTAを書き出すときの1行の文字数なのだ (GenBankと同じにしてあるのだ)
+const fastaLineWidth = 70
+
+// fastaAlphabet は配列に使ってよい文字なのだ。IUPACの塩基記号と、ギャップの - と不明の ? なのだ
+const fastaAlphabet = "ACGTURYSWKMBDHVN-?"
+
+// fastaRecord はFASTAの1件分なのだ。Header は先頭の > を除いた見出しなのだ
+type fastaRecord struct {
+	Header   string
+	Sequence string
+}
+
+// parseFASTA はFASTAを読むのだ。配列は大文字にして、改行や空白を取り除くのだ
+// 見出しのない配列や、塩基記号でない文字があるときはエラーにするのだ
+func parseFASTA(r io.Reader) ([]fastaRecord, error) {
+	var records []fastaRecord
+	var current *fastaRecord
+	var seq strings.Builder
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		if seq.Len() == 0 {
+			return fmt.Errorf("%w: record %q has no sequence", ErrInvalidSample, current.Header)
+		}
+		current.Sequence = seq.String()
+		records = append(records, *current)
+		seq.Reset()
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // 1行に全部書いた長い配列も読めるようにするのだ
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if header, ok := strings.CutPrefix(line, ">"); ok {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			current = &fastaRecord{Header: strings.TrimSpace(header)}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%w: line %d: sequence before the first > header", ErrInvalidSample, lineNo)
+		}
+		for _, r := range strings.ToUpper(line) {
+			if r == ' ' || r == '\t' {
+				continue
+			}
+			if !strings.ContainsRune(fastaAlphabet, r) {
+				return nil, fmt.Errorf("%w: line %d: %q is not a nucleotide code", ErrInvalidSample, lineNo, r)
+			}
+			seq.WriteRune(r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSample, err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// writeFASTA は1件を書き出すのだ。配列は fastaLineWidth 文字ごとに折り返すのだ
+func writeFASTA(w io.Writer, record fastaRecord) error {
+	if _, err := fmt.Fprintf(w, ">%s\n", record.Header); err != nil {
+		return err
+	}
+	for seq := record.Sequence; len(seq) > 0; {
+		n := min(len(seq), fastaLineWidth)
+		if _, err := fmt.Fprintf(w, "%s\n", seq[:n]); err != nil {
+			return err
+		}
+		seq = seq[n:]
+	}
+	return nil
+}
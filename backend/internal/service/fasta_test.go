@@ -0,0 +1,97 @@
+// backend/internal/service/fasta_test.go
+package service
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFASTA(t *testing.T) {
+	input := "\ufeff; コメント行は読み飛ばすのだ\n" +
+		">  NSMT-I-000042 COI  \n" +
+		"acgt nnry\n" +
+		"\n" +
+		"ACG-?\n" +
+		">second\r\n" +
+		"TTTT\r\n"
+	got, err := parseFASTA(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseFASTA error: %v", err)
+	}
+	want := []fastaRecord{
+		{Header: "NSMT-I-000042 COI", Sequence: "ACGTNNRYACG-?"},
+		{Header: "second", Sequence: "TTTT"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseFASTA returned %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseFASTAInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"sequence before header", "ACGT\n>a\nACGT\n"},
+		{"not a nucleotide code", ">a\nACGX\n"},
+		{"header without sequence", ">a\n>b\nACGT\n"},
+		{"last header without sequence", ">a\nACGT\n>b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseFASTA(strings.NewReader(tt.input)); !errors.Is(err, ErrInvalidSample) {
+				t.Errorf("parseFASTA error = %v, want ErrInvalidSample", err)
+			}
+		})
+	}
+}
+
+func TestParseFASTAEmpty(t *testing.T) {
+	got, err := parseFASTA(strings.NewReader("\n; only a comment\n"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("parseFASTA = %+v, %v, want no records", got, err)
+	}
+}
+
+func TestWriteFASTA(t *testing.T) {
+	seq := strings.Repeat("ACGTACGTAC", 15) // 150文字なのだ
+	var buf bytes.Buffer
+	if err := writeFASTA(&buf, fastaRecord{Header: "s1 COI", Sequence: seq}); err != nil {
+		t.Fatalf("writeFASTA error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 || lines[0] != ">s1 COI" {
+		t.Fatalf("writeFASTA wrote %q", buf.String())
+	}
+	for i, want := range []int{fastaLineWidth, fastaLineWidth, 150 - 2*fastaLineWidth} {
+		if len(lines[i+1]) != want {
+			t.Errorf("line %d has %d characters, want %d", i+1, len(lines[i+1]), want)
+		}
+	}
+
+	// 書き出したものを読み直すと同じ配列になるのだ
+	records, err := parseFASTA(&buf)
+	if err != nil {
+		t.Fatalf("parseFASTA error: %v", err)
+	}
+	if len(records) != 1 || records[0].Sequence != seq || records[0].Header != "s1 COI" {
+		t.Errorf("round trip = %+v", records)
+	}
+}
+
+func TestWriteFASTAExactWidth(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFASTA(&buf, fastaRecord{Header: "s", Sequence: strings.Repeat("N", fastaLineWidth)}); err != nil {
+		t.Fatalf("writeFASTA error: %v", err)
+	}
+	if want := ">s\n" + strings.Repeat("N", fastaLineWidth) + "\n"; buf.String() != want {
+		t.Errorf("writeFASTA wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,314 @@
+// backend/internal/service/geocoding_service.go
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var adminLevels = []int16{model.AdminLevelCountry, model.AdminLevelPrefecture, model.AdminLevelMunicipality}
+
+// place_names_json.class_place_name に書き込むキーなのだ
+var adminLevelKeys = map[int16]string{
+	model.AdminLevelCountry:      "country",
+	model.AdminLevelPrefecture:   "prefecture",
+	model.AdminLevelMunicipality: "municipality",
+}
+
+var adminLevelLabels = map[int16]string{
+	model.AdminLevelCountry:      "国",
+	model.AdminLevelPrefecture:   "都道府県",
+	model.AdminLevelMunicipality: "市区町村",
+}
+
+// AdminArea は座標から求めた行政区画なのだ
+type AdminArea struct {
+	Country      string `json:"country"`
+	Prefecture   string `json:"prefecture"`
+	Municipality string `json:"municipality"`
+}
+
+// PlaceNameWarning は入力された地名と座標が食い違っているときの警告なのだ
+type PlaceNameWarning struct {
+	Field    string `json:"field"`
+	Typed    string `json:"typed"`
+	Resolved string `json:"resolved"`
+	Message  string `json:"message"`
+}
+
+// ImportBoundariesRequest は行政区画ファイル読み込みの設定を表すのだ
+type ImportBoundariesRequest struct {
+	FilePath   string `json:"file_path"`
+	AdminLevel int16  `json:"admin_level"`
+	NameField  string `json:"name_field"` // GADMなら NAME_1 など
+	CodeField  string `json:"code_field"` // GADMなら GID_1 など
+	Source     string `json:"source"`
+	Encoding   string `json:"encoding"` // Shapefileの.dbfの文字コード (空なら.cpgから判定)
+	Replace    bool   `json:"replace"`  // 同じ出典・レベルの既存データを置き換えるか
+}
+
+// BackfillRequest は既存の場所データへの一括付与の設定を表すのだ
+type BackfillRequest struct {
+	BatchSize int `json:"batch_size"`
+}
+
+// BackfillResult は一括付与の結果なのだ
+type BackfillResult struct {
+	Processed int                         `json:"processed"`
+	Annotated int                         `json:"annotated"`
+	Warnings  map[uint][]PlaceNameWarning `json:"warnings"` // place_id ごとの警告
+}
+
+// GeocodingService は座標から行政区画を求めるオフライン逆ジオコーディングのインターフェースなのだ
+type GeocodingService interface {
+	ReverseGeocode(wkt string) (*AdminArea, error)
+	AnnotatePlaceName(wkt string, classPlaceName datatypes.JSON) (datatypes.JSON, []PlaceNameWarning, error)
+	BackfillPlaceNames(req BackfillRequest) (*BackfillResult, error)
+	ImportBoundaries(req ImportBoundariesRequest) (int, error)
+}
+
+type geocodingService struct {
+	db           *gorm.DB
+	boundaryRepo repository.AdminBoundaryRepository
+	placeRepo    repository.PlaceRepository
+}
+
+// NewGeocodingService は新しいサービスを生成するのだ
+func NewGeocodingService(db *gorm.DB, boundaryRepo repository.AdminBoundaryRepository, placeRepo repository.PlaceRepository) GeocodingService {
+	return &geocodingService{db: db, boundaryRepo: boundaryRepo, placeRepo: placeRepo}
+}
+
+// ReverseGeocode は "POINT(lon lat)" 形式の座標から国・都道府県・市区町村を求めるのだ
+func (s *geocodingService) ReverseGeocode(wkt string) (*AdminArea, error) {
+	boundaries, err := s.boundaryRepo.FindContainingPoint(wkt)
+	if err != nil {
+		return nil, err
+	}
+	return toAdminArea(boundaries), nil
+}
+
+// AnnotatePlaceName は座標から求めた行政区画を class_place_name に書き足すのだ
+// 入力済みの値は上書きせず、食い違っていれば警告を返すのだ
+func (s *geocodingService) AnnotatePlaceName(wkt string, classPlaceName datatypes.JSON) (datatypes.JSON, []PlaceNameWarning, error) {
+	boundaries, err := s.boundaryRepo.FindContainingPoint(wkt)
+	if err != nil {
+		return nil, nil, err
+	}
+	annotated, warnings, _, err := s.annotate(boundaries, classPlaceName)
+	return annotated, warnings, err
+}
+
+// BackfillPlaceNames は座標を持つ既存の場所に行政区画をまとめて付与するのだ
+func (s *geocodingService) BackfillPlaceNames(req BackfillRequest) (*BackfillResult, error) {
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	result := &BackfillResult{Warnings: map[uint][]PlaceNameWarning{}}
+	var lastID uint
+	for {
+		places, err := s.placeRepo.FindWithCoordinatesAfter(lastID, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(places) == 0 {
+			break
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			for _, place := range places {
+				result.Processed++
+				boundaries, err := s.boundaryRepo.FindContainingPlace(place.PlaceID)
+				if err != nil {
+					return err
+				}
+				if len(boundaries) == 0 {
+					continue
+				}
+
+				placeName := &model.PlaceNameJSON{}
+				if place.PlaceNameID != 0 {
+					placeName, err = s.placeRepo.FindPlaceNameByID(place.PlaceNameID)
+					if err != nil {
+						return err
+					}
+				}
+
+				annotated, warnings, changed, err := s.annotate(boundaries, placeName.ClassPlaceName)
+				if err != nil {
+					return err
+				}
+				if len(warnings) > 0 {
+					result.Warnings[place.PlaceID] = warnings
+				}
+				if !changed {
+					continue
+				}
+
+				placeName.ClassPlaceName = annotated
+				if _, err := s.placeRepo.UpdatePlaceName(tx, placeName); err != nil {
+					return err
+				}
+				if place.PlaceNameID == 0 {
+					place.PlaceNameID = placeName.PlaceNameID
+					if _, err := s.placeRepo.Update(tx, &place); err != nil {
+						return err
+					}
+				}
+				result.Annotated++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		lastID = places[len(places)-1].PlaceID
+	}
+	return result, nil
+}
+
+// ImportBoundaries はGeoJSONやShapefileから行政区画ポリゴンを読み込んでPostGISに登録するのだ
+func (s *geocodingService) ImportBoundaries(req ImportBoundariesRequest) (int, error) {
+	if req.FilePath == "" || req.NameField == "" {
+		return 0, errors.New("ファイルパスと名前の属性名は必須です")
+	}
+	if _, ok := adminLevelKeys[req.AdminLevel]; !ok {
+		return 0, fmt.Errorf("不正な行政区画レベルです: %d", req.AdminLevel)
+	}
+
+	features, err := readBoundaryFile(req.FilePath, req.Encoding)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if req.Replace {
+			if err := s.boundaryRepo.DeleteByLevelAndSource(tx, req.AdminLevel, req.Source); err != nil {
+				return err
+			}
+		}
+		for i, f := range features {
+			name := f.Properties[req.NameField]
+			if name == "" {
+				return fmt.Errorf("%d件目に属性 %s がありません", i+1, req.NameField)
+			}
+			boundary := &model.AdminBoundary{
+				AdminLevel: req.AdminLevel,
+				Name:       name,
+				Code:       f.Properties[req.CodeField],
+				Source:     req.Source,
+			}
+			if _, err := s.boundaryRepo.CreateFromGeoJSON(tx, boundary, string(f.Geometry)); err != nil {
+				return fmt.Errorf("%s の登録に失敗しました: %w", name, err)
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// annotate は行政区画を地名JSONに書き足し、入力値との食い違いを調べるのだ
+func (s *geocodingService) annotate(boundaries []model.AdminBoundary, classPlaceName datatypes.JSON) (datatypes.JSON, []PlaceNameWarning, bool, error) {
+	names := map[string]interface{}{}
+	if len(classPlaceName) > 0 && string(classPlaceName) != "null" {
+		if err := json.Unmarshal(classPlaceName, &names); err != nil {
+			return nil, nil, false, fmt.Errorf("地名JSONの解析に失敗しました: %w", err)
+		}
+	}
+
+	resolved := map[int16]string{}
+	for _, b := range boundaries {
+		if _, ok := resolved[b.AdminLevel]; !ok {
+			resolved[b.AdminLevel] = b.Name
+		}
+	}
+
+	var warnings []PlaceNameWarning
+	warned := map[int16]bool{}
+	changed := false
+	for _, level := range adminLevels {
+		key := adminLevelKeys[level]
+		name, ok := resolved[level]
+		if !ok {
+			continue
+		}
+		typed, _ := names[key].(string)
+		if typed == "" {
+			names[key] = name
+			changed = true
+			continue
+		}
+		if typed != name {
+			warnings = append(warnings, newPlaceNameWarning(level, typed, name))
+			warned[level] = true
+		}
+	}
+
+	// 自由入力の地名に、座標とは別の行政区画の名前が書かれていないか調べるのだ
+	if typedName, _ := names["name"].(string); typedName != "" {
+		mentioned, err := s.boundaryRepo.FindNamesInText(typedName)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for _, m := range mentioned {
+			name, ok := resolved[m.AdminLevel]
+			if !ok || warned[m.AdminLevel] || strings.Contains(name, m.Name) {
+				continue
+			}
+			warnings = append(warnings, newPlaceNameWarning(m.AdminLevel, typedName, name))
+			warned[m.AdminLevel] = true
+		}
+	}
+
+	if !changed {
+		return classPlaceName, warnings, false, nil
+	}
+	annotated, err := json.Marshal(names)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return datatypes.JSON(annotated), warnings, true, nil
+}
+
+func newPlaceNameWarning(level int16, typed, resolved string) PlaceNameWarning {
+	return PlaceNameWarning{
+		Field:    adminLevelKeys[level],
+		Typed:    typed,
+		Resolved: resolved,
+		Message:  fmt.Sprintf("入力された地名「%s」と座標から求めた%s「%s」が一致しません", typed, adminLevelLabels[level], resolved),
+	}
+}
+
+func toAdminArea(boundaries []model.AdminBoundary) *AdminArea {
+	area := &AdminArea{}
+	for _, b := range boundaries {
+		switch b.AdminLevel {
+		case model.AdminLevelCountry:
+			if area.Country == "" {
+				area.Country = b.Name
+			}
+		case model.AdminLevelPrefecture:
+			if area.Prefecture == "" {
+				area.Prefecture = b.Name
+			}
+		case model.AdminLevelMunicipality:
+			if area.Municipality == "" {
+				area.Municipality = b.Name
+			}
+		}
+	}
+	return area
+}
@@ -0,0 +1,221 @@
+// backend/internal/service/identification_service.go
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidIdentification は同定の内容が正しくないときのエラーなのだ (分類がJSONのオブジェクトでない、日時の形が違うなど)
+var ErrInvalidIdentification = errors.New("invalid identification")
+
+// IdentificationRequest は同定の作成・更新のリクエストボディなのだ
+// Classification はこの同定で決めた分類で、省略すると作成では発生情報の今の分類を、更新では前の分類をそのまま使うのだ
+// IdentificatedAt は同定した日時 (2006-01-02T15:04、timezone の現地時刻) で、作成で省略すると今なのだ
+type IdentificationRequest struct {
+	UserID          uint                   `json:"user_id" binding:"required"`
+	SourceInfo      string                 `json:"source_info"`
+	Classification  *ClassificationPayload `json:"classification"`
+	IdentificatedAt string                 `json:"identificated_at"`
+	Timezone        int16                  `json:"timezone"`
+}
+
+// IdentificationService は同定情報関連のビジネスロジックのインターフェースなのだ
+// 発生情報の分類はいつも一番新しい同定の分類に合わせるのだ
+type IdentificationService interface {
+	GetIdentifications(occurrenceID uint) ([]model.Identification, error)
+	GetIdentification(occurrenceID, id uint) (*model.Identification, error)
+	CreateIdentification(occurrenceID uint, req IdentificationRequest) (*model.Identification, error)
+	UpdateIdentification(occurrenceID, id uint, req IdentificationRequest) (*model.Identification, error)
+	DeleteIdentification(occurrenceID, id uint) error
+}
+
+type identificationService struct {
+	db   *gorm.DB
+	repo repository.IdentificationRepository
+}
+
+// NewIdentificationService は新しいサービスを生成するのだ
+func NewIdentificationService(db *gorm.DB, repo repository.IdentificationRepository) IdentificationService {
+	return &identificationService{db: db, repo: repo}
+}
+
+// GetIdentifications は発生情報の同定の履歴を新しい順に返すのだ
+func (s *identificationService) GetIdentifications(occurrenceID uint) ([]model.Identification, error) {
+	ok, err := s.repo.OccurrenceExists(occurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.repo.FindByOccurrence(occurrenceID)
+}
+
+// GetIdentification は発生情報に付いた同定を1件返すのだ。ほかの発生情報の同定は見つからないことにするのだ
+func (s *identificationService) GetIdentification(occurrenceID, id uint) (*model.Identification, error) {
+	identification, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	if identification.OccurrenceID != occurrenceID {
+		return nil, ErrNotFound
+	}
+	return identification, nil
+}
+
+func (s *identificationService) CreateIdentification(occurrenceID uint, req IdentificationRequest) (*model.Identification, error) {
+	identification := &model.Identification{
+		UserID:          req.UserID,
+		OccurrenceID:    occurrenceID,
+		SourceInfo:      req.SourceInfo,
+		IdentificatedAt: time.Now(),
+		Timezone:        req.Timezone,
+	}
+	if err := applyIdentificatedAt(identification, req.IdentificatedAt); err != nil {
+		return nil, err
+	}
+	classification, err := newClassification(req.Classification)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		occurrence, err := s.repo.FindOccurrenceForUpdate(tx, occurrenceID)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if classification != nil {
+			if _, err := s.repo.CreateClassification(tx, classification); err != nil {
+				return err
+			}
+			identification.ClassificationID = &classification.ClassificationID
+		} else {
+			identification.ClassificationID = &occurrence.ClassificationID
+		}
+		if _, err := s.repo.Create(tx, identification); err != nil {
+			return err
+		}
+		return s.syncCurrentDetermination(tx, occurrence)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(identification.IdentificationID)
+}
+
+// UpdateIdentification は同定を書き換えるのだ。日時を変えて一番新しい同定が入れ替わったときも発生情報の分類を合わせるのだ
+func (s *identificationService) UpdateIdentification(occurrenceID, id uint, req IdentificationRequest) (*model.Identification, error) {
+	current, err := s.GetIdentification(occurrenceID, id)
+	if err != nil {
+		return nil, err
+	}
+	// 読み込んだ同定者や分類まで書き戻さないように、列だけの同定を作るのだ
+	identification := &model.Identification{
+		IdentificationID: id,
+		UserID:           req.UserID,
+		OccurrenceID:     occurrenceID,
+		SourceInfo:       req.SourceInfo,
+		ClassificationID: current.ClassificationID,
+		IdentificatedAt:  current.IdentificatedAt,
+		Timezone:         req.Timezone,
+	}
+	if err := applyIdentificatedAt(identification, req.IdentificatedAt); err != nil {
+		return nil, err
+	}
+	classification, err := newClassification(req.Classification)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		occurrence, err := s.repo.FindOccurrenceForUpdate(tx, occurrenceID)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if classification != nil {
+			if _, err := s.repo.CreateClassification(tx, classification); err != nil {
+				return err
+			}
+			identification.ClassificationID = &classification.ClassificationID
+		}
+		if _, err := s.repo.Update(tx, identification); err != nil {
+			return err
+		}
+		return s.syncCurrentDetermination(tx, occurrence)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(id)
+}
+
+// DeleteIdentification は同定を削除するのだ。今の同定を消したときは、1つ前の同定の分類に戻すのだ
+func (s *identificationService) DeleteIdentification(occurrenceID, id uint) error {
+	if _, err := s.GetIdentification(occurrenceID, id); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		occurrence, err := s.repo.FindOccurrenceForUpdate(tx, occurrenceID)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if err := s.repo.Delete(tx, id); err != nil {
+			return err
+		}
+		return s.syncCurrentDetermination(tx, occurrence)
+	})
+}
+
+// syncCurrentDetermination は発生情報の分類を一番新しい同定の分類に合わせるのだ
+// 分類での検索と検索結果の学名は発生情報の分類を見るので、これで同定が検索にも出るのだ
+// 分類の付いた同定が1つもなくなったときは、発生情報の分類はそのままにするのだ
+func (s *identificationService) syncCurrentDetermination(tx *gorm.DB, occurrence *model.Occurrence) error {
+	latest, err := s.repo.FindLatestClassified(tx, occurrence.OccurrenceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if *latest.ClassificationID == occurrence.ClassificationID {
+		return nil
+	}
+	return s.repo.SetOccurrenceClassification(tx, occurrence.OccurrenceID, *latest.ClassificationID)
+}
+
+// applyIdentificatedAt は同定した日時を現地時刻として読むのだ。空なら今の値のままにするのだ
+func applyIdentificatedAt(identification *model.Identification, identificatedAt string) error {
+	if identificatedAt == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation(formDateTimeLayout, identificatedAt, time.FixedZone("", int(identification.Timezone)*3600))
+	if err != nil {
+		return fmt.Errorf("%w: identificated_at must be YYYY-MM-DDThh:mm", ErrInvalidIdentification)
+	}
+	identification.IdentificatedAt = t
+	return nil
+}
+
+// newClassification はリクエストの分類を確かめるのだ。分類のキーに1つは値のあるJSONのオブジェクトでないといけないのだ
+func newClassification(payload *ClassificationPayload) (*model.ClassificationJSON, error) {
+	if payload == nil || len(payload.ClassClassification) == 0 {
+		return nil, nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal(payload.ClassClassification, &data); err != nil || data == nil {
+		return nil, fmt.Errorf("%w: class_classification must be a JSON object", ErrInvalidIdentification)
+	}
+	var c ClassificationJSONB
+	_ = json.Unmarshal(payload.ClassClassification, &c)
+	if c == (ClassificationJSONB{}) {
+		return nil, fmt.Errorf("%w: class_classification has no taxon", ErrInvalidIdentification)
+	}
+	return &model.ClassificationJSON{ClassClassification: payload.ClassClassification}, nil
+}
@@ -0,0 +1,553 @@
+// backend/internal/service/individual_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidIndividual は個体の内容が正しくないときのエラーなのだ
+var ErrInvalidIndividual = errors.New("invalid individual")
+
+// ErrMarkCodeTaken は同じプロジェクトで標識の番号がもう使われているときのエラーなのだ
+var ErrMarkCodeTaken = errors.New("mark code already in use")
+
+// ErrIndividualInUse は発生情報のある個体を消そうとしたときのエラーなのだ
+var ErrIndividualInUse = errors.New("individual is in use")
+
+// defaultMaxDailyDistanceM は同じ日に同じ個体がこれより離れた場所で記録されたら、おかしいとする距離(m)なのだ
+// 歩いて移動する甲虫を考えた値なので、よく飛ぶ虫では max_distance_m で広げるのだ
+const defaultMaxDailyDistanceM = 1000
+
+// 個体の記録のおかしなところの種類なのだ
+const (
+	ConflictDistantSameDay = "distant_same_day" // 同じ日に離れた場所で記録されているのだ
+	ConflictSexMismatch    = "sex_mismatch"     // 記録によって性別が違うのだ
+	ConflictTaxonMismatch  = "taxon_mismatch"   // 記録によって種が違うのだ
+)
+
+// ListIndividualsRequest は個体の検索のクエリパラメータなのだ
+type ListIndividualsRequest struct {
+	ProjectID *uint  `form:"project_id"`
+	MarkCode  string `form:"mark_code"` // 前方一致なのだ
+}
+
+// IndividualRequest は個体の作成・更新のリクエストボディなのだ
+// MarkCode は個体に付けた標識の番号で、同じプロジェクトの中で重ならないようにするのだ
+type IndividualRequest struct {
+	ProjectID *uint   `json:"project_id"`
+	MarkCode  string  `json:"mark_code" binding:"required"`
+	MarkType  *string `json:"mark_type"` // 標識の付け方 (例: paint, tag, notch) なのだ
+	Note      *string `json:"note"`
+}
+
+// IndividualOccurrencesRequest は個体に発生情報を付けるリクエストボディなのだ
+type IndividualOccurrencesRequest struct {
+	OccurrenceIDs []uint `json:"occurrence_ids"`
+}
+
+// IndividualConflictsRequest は個体の記録のおかしなところを探すクエリパラメータなのだ
+// MaxDistanceM は同じ日に記録された場所がこれより離れていたらおかしいとする距離(m)で、省略すると1000mなのだ
+type IndividualConflictsRequest struct {
+	ProjectID    *uint    `form:"project_id"`
+	MarkCode     string   `form:"mark_code"`
+	MaxDistanceM *float64 `form:"max_distance_m"`
+}
+
+// IndividualDetail は個体と、最初と最後に記録された日時なのだ
+type IndividualDetail struct {
+	*model.Individual
+	OccurrenceCount int64  `json:"occurrence_count"`
+	FirstSeenAt     string `json:"first_seen_at,omitempty"`
+	LastSeenAt      string `json:"last_seen_at,omitempty"`
+}
+
+// IndividualTimelineEntry は個体の1回の記録なのだ
+// 日時は最初の観察の日時で、観察がなければ発生情報の日時なのだ (Darwin Core の eventDate と同じなのだ)
+type IndividualTimelineEntry struct {
+	OccurrenceID          uint     `json:"occurrence_id"`
+	SeenAt                string   `json:"seen_at"`
+	Timezone              int16    `json:"timezone"`
+	EventID               *uint    `json:"event_id"`
+	PlaceID               *uint    `json:"place_id"`
+	Latitude              *float64 `json:"latitude"`
+	Longitude             *float64 `json:"longitude"`
+	Locality              string   `json:"locality"`
+	Sex                   string   `json:"sex"`
+	Lifestage             string   `json:"lifestage"`
+	ScientificName        string   `json:"scientific_name"`
+	DistanceFromPreviousM *float64 `json:"distance_from_previous_m"` // 座標のある1つ前の記録からの距離なのだ
+	DaysSincePrevious     *float64 `json:"days_since_previous"`
+
+	seenAt time.Time
+}
+
+// IndividualTimeline は個体の記録を日時の順に並べたものなのだ
+type IndividualTimeline struct {
+	IndividualID uint                      `json:"individual_id"`
+	MarkCode     string                    `json:"mark_code"`
+	Entries      []IndividualTimelineEntry `json:"entries"`
+}
+
+// IndividualConflict は個体の記録のおかしなところ1つなのだ
+type IndividualConflict struct {
+	IndividualID  uint     `json:"individual_id"`
+	MarkCode      string   `json:"mark_code"`
+	Kind          string   `json:"kind"`
+	OccurrenceIDs []uint   `json:"occurrence_ids"`
+	DistanceM     *float64 `json:"distance_m,omitempty"`
+	Detail        string   `json:"detail"`
+}
+
+// GeoJSONFeatureCollection は GeoJSON の FeatureCollection なのだ
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature は GeoJSON の Feature なのだ。点が2つ未満で線にならないときは Geometry が null なのだ
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *GeoJSONLineString     `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONLineString は GeoJSON の LineString で、座標は [経度, 緯度] の順なのだ
+type GeoJSONLineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+// IndividualService は標識再捕獲で追いかける個体のビジネスロジックのインターフェースなのだ
+type IndividualService interface {
+	ListIndividuals(req ListIndividualsRequest) ([]model.Individual, error)
+	GetIndividual(id uint) (*IndividualDetail, error)
+	CreateIndividual(req IndividualRequest) (*IndividualDetail, error)
+	UpdateIndividual(id uint, req IndividualRequest) (*IndividualDetail, error)
+	DeleteIndividual(id uint) error
+
+	AttachOccurrences(id uint, req IndividualOccurrencesRequest) (*IndividualDetail, error)
+	DetachOccurrence(id, occurrenceID uint) error
+
+	GetTimeline(id uint) (*IndividualTimeline, error)
+	GetTrack(id uint) (*GeoJSONFeature, error)
+	GetTracks(req ListIndividualsRequest) (*GeoJSONFeatureCollection, error)
+	GetConflicts(id uint, req IndividualConflictsRequest) ([]IndividualConflict, error)
+	FindConflicts(req IndividualConflictsRequest) ([]IndividualConflict, error)
+}
+
+type individualService struct {
+	db   *gorm.DB
+	repo repository.IndividualRepository
+}
+
+// NewIndividualService は新しいサービスを生成するのだ
+func NewIndividualService(db *gorm.DB, repo repository.IndividualRepository) IndividualService {
+	return &individualService{db: db, repo: repo}
+}
+
+func (s *individualService) ListIndividuals(req ListIndividualsRequest) ([]model.Individual, error) {
+	return s.repo.Search(repository.IndividualSearchParams{
+		ProjectID: req.ProjectID,
+		MarkCode:  strings.TrimSpace(req.MarkCode),
+	})
+}
+
+func (s *individualService) GetIndividual(id uint) (*IndividualDetail, error) {
+	individual, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	occurrences, err := s.repo.FindOccurrences([]uint{id})
+	if err != nil {
+		return nil, err
+	}
+	detail := &IndividualDetail{Individual: individual, OccurrenceCount: int64(len(occurrences))}
+	if entries := timelineOf(occurrences); len(entries) > 0 {
+		detail.FirstSeenAt = entries[0].SeenAt
+		detail.LastSeenAt = entries[len(entries)-1].SeenAt
+	}
+	return detail, nil
+}
+
+func (s *individualService) CreateIndividual(req IndividualRequest) (*IndividualDetail, error) {
+	individual, err := s.newIndividual(0, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Create(tx, individual)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetIndividual(individual.IndividualID)
+}
+
+func (s *individualService) UpdateIndividual(id uint, req IndividualRequest) (*IndividualDetail, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	individual, err := s.newIndividual(id, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, individual)
+		return err
+	})
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.GetIndividual(id)
+}
+
+// DeleteIndividual は発生情報のない個体を削除するのだ
+func (s *individualService) DeleteIndividual(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	count, err := s.repo.CountOccurrences(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d occurrences are attached", ErrIndividualInUse, count)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Delete(tx, id)
+	})
+}
+
+// AttachOccurrences は再捕獲した発生情報を個体に付けるのだ。ほかの個体に付いていたものは付け替えるのだ
+func (s *individualService) AttachOccurrences(id uint, req IndividualOccurrencesRequest) (*IndividualDetail, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(req.OccurrenceIDs)))
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: occurrence_ids is empty", ErrInvalidIndividual)
+	}
+	missing, err := s.repo.MissingOccurrences(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: occurrences not found: %s", ErrInvalidIndividual, joinIDs(missing))
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.AttachOccurrences(tx, id, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetIndividual(id)
+}
+
+// DetachOccurrence は発生情報を個体から外すのだ
+func (s *individualService) DetachOccurrence(id, occurrenceID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		detached, err := s.repo.DetachOccurrence(tx, id, occurrenceID)
+		if err != nil {
+			return err
+		}
+		if detached == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetTimeline は個体の記録を日時の順に、場所と前の記録からの移動と一緒に返すのだ
+func (s *individualService) GetTimeline(id uint) (*IndividualTimeline, error) {
+	individual, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	occurrences, err := s.repo.FindOccurrences([]uint{id})
+	if err != nil {
+		return nil, err
+	}
+	return &IndividualTimeline{IndividualID: id, MarkCode: individual.MarkCode, Entries: timelineOf(occurrences)}, nil
+}
+
+// GetTrack は個体の動いた跡を GeoJSON の LineString の Feature で返すのだ
+func (s *individualService) GetTrack(id uint) (*GeoJSONFeature, error) {
+	individual, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	occurrences, err := s.repo.FindOccurrences([]uint{id})
+	if err != nil {
+		return nil, err
+	}
+	track := trackOf(individual, timelineOf(occurrences))
+	return &track, nil
+}
+
+// GetTracks は条件に合う個体の動いた跡を GeoJSON の FeatureCollection で返すのだ
+// 地図に線を引くためのものなので、座標のある記録が2つ以上ある個体だけにするのだ
+func (s *individualService) GetTracks(req ListIndividualsRequest) (*GeoJSONFeatureCollection, error) {
+	individuals, byIndividual, err := s.findWithOccurrences(req.ProjectID, req.MarkCode)
+	if err != nil {
+		return nil, err
+	}
+	collection := &GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for i := range individuals {
+		track := trackOf(&individuals[i], timelineOf(byIndividual[individuals[i].IndividualID]))
+		if track.Geometry != nil {
+			collection.Features = append(collection.Features, track)
+		}
+	}
+	return collection, nil
+}
+
+// GetConflicts は個体の記録のおかしなところを返すのだ
+func (s *individualService) GetConflicts(id uint, req IndividualConflictsRequest) ([]IndividualConflict, error) {
+	maxDistance, err := maxDailyDistanceOf(req)
+	if err != nil {
+		return nil, err
+	}
+	individual, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	occurrences, err := s.repo.FindOccurrences([]uint{id})
+	if err != nil {
+		return nil, err
+	}
+	return conflictsOf(individual, occurrences, maxDistance), nil
+}
+
+// FindConflicts は条件に合う全ての個体の記録のおかしなところを返すのだ
+func (s *individualService) FindConflicts(req IndividualConflictsRequest) ([]IndividualConflict, error) {
+	maxDistance, err := maxDailyDistanceOf(req)
+	if err != nil {
+		return nil, err
+	}
+	individuals, byIndividual, err := s.findWithOccurrences(req.ProjectID, req.MarkCode)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []IndividualConflict{}
+	for i := range individuals {
+		conflicts = append(conflicts, conflictsOf(&individuals[i], byIndividual[individuals[i].IndividualID], maxDistance)...)
+	}
+	return conflicts, nil
+}
+
+// findWithOccurrences は条件に合う個体と、個体ごとの発生情報を取得するのだ
+func (s *individualService) findWithOccurrences(projectID *uint, markCode string) ([]model.Individual, map[uint][]model.Occurrence, error) {
+	individuals, err := s.repo.Search(repository.IndividualSearchParams{
+		ProjectID: projectID,
+		MarkCode:  strings.TrimSpace(markCode),
+	})
+	if err != nil || len(individuals) == 0 {
+		return individuals, nil, err
+	}
+	ids := make([]uint, len(individuals))
+	for i, individual := range individuals {
+		ids[i] = individual.IndividualID
+	}
+	occurrences, err := s.repo.FindOccurrences(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	byIndividual := map[uint][]model.Occurrence{}
+	for _, o := range occurrences {
+		byIndividual[*o.IndividualID] = append(byIndividual[*o.IndividualID], o)
+	}
+	return individuals, byIndividual, nil
+}
+
+func (s *individualService) newIndividual(id uint, req IndividualRequest) (*model.Individual, error) {
+	individual := &model.Individual{
+		IndividualID: id,
+		ProjectID:    req.ProjectID,
+		MarkCode:     strings.TrimSpace(req.MarkCode),
+		MarkType:     trimmedOrNil(req.MarkType),
+		Note:         req.Note,
+	}
+	if individual.MarkCode == "" {
+		return nil, fmt.Errorf("%w: mark_code is empty", ErrInvalidIndividual)
+	}
+	taken, err := s.repo.MarkCodeTaken(individual.ProjectID, individual.MarkCode, id)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: %s", ErrMarkCodeTaken, individual.MarkCode)
+	}
+	return individual, nil
+}
+
+func maxDailyDistanceOf(req IndividualConflictsRequest) (float64, error) {
+	if req.MaxDistanceM == nil {
+		return defaultMaxDailyDistanceM, nil
+	}
+	if *req.MaxDistanceM <= 0 {
+		return 0, fmt.Errorf("%w: max_distance_m must be positive", ErrInvalidIndividual)
+	}
+	return *req.MaxDistanceM, nil
+}
+
+// timelineOf は発生情報を日時の順に並べて、座標のある1つ前の記録からの距離と日数を入れるのだ
+func timelineOf(occurrences []model.Occurrence) []IndividualTimelineEntry {
+	entries := make([]IndividualTimelineEntry, 0, len(occurrences))
+	for i := range occurrences {
+		o := &occurrences[i]
+		seenAt, timezone := eventTimeOf(o)
+		entry := IndividualTimelineEntry{
+			OccurrenceID:   o.OccurrenceID,
+			SeenAt:         formatEventTime(seenAt, timezone),
+			Timezone:       timezone,
+			EventID:        o.EventID,
+			PlaceID:        o.PlaceID,
+			Sex:            o.Sex,
+			Lifestage:      o.Lifestage,
+			ScientificName: scientificNameOf(decodeClassification(o.ClassificationJSON)),
+			seenAt:         seenAt,
+		}
+		if p := o.Place; p != nil {
+			entry.Latitude, entry.Longitude = p.Latitude, p.Longitude
+			locality := DarwinCoreRecord{}
+			setPlaceTerms(locality, p)
+			entry.Locality = locality["locality"]
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortStableFunc(entries, func(a, b IndividualTimelineEntry) int {
+		return a.seenAt.Compare(b.seenAt)
+	})
+
+	var previous *IndividualTimelineEntry
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.located() {
+			continue
+		}
+		if previous != nil {
+			distance := distanceMeters(previous, entry)
+			days := entry.seenAt.Sub(previous.seenAt).Hours() / 24
+			entry.DistanceFromPreviousM, entry.DaysSincePrevious = &distance, &days
+		}
+		previous = entry
+	}
+	return entries
+}
+
+func (e *IndividualTimelineEntry) located() bool {
+	return e.Latitude != nil && e.Longitude != nil
+}
+
+// localDate は記録したタイムゾーンでの日付なのだ。同じ日かどうかは現地の日付で比べるのだ
+func (e *IndividualTimelineEntry) localDate() string {
+	return e.seenAt.In(time.FixedZone("", int(e.Timezone)*3600)).Format("2006-01-02")
+}
+
+// trackOf は座標のある記録を日時の順につないだ線にするのだ
+func trackOf(individual *model.Individual, entries []IndividualTimelineEntry) GeoJSONFeature {
+	coordinates := [][2]float64{}
+	occurrenceIDs := []uint{}
+	times := []string{}
+	length := 0.0
+	for _, entry := range entries {
+		if !entry.located() {
+			continue
+		}
+		coordinates = append(coordinates, [2]float64{*entry.Longitude, *entry.Latitude})
+		occurrenceIDs = append(occurrenceIDs, entry.OccurrenceID)
+		times = append(times, entry.SeenAt)
+		if entry.DistanceFromPreviousM != nil {
+			length += *entry.DistanceFromPreviousM
+		}
+	}
+	feature := GeoJSONFeature{
+		Type: "Feature",
+		Properties: map[string]interface{}{
+			"individual_id":  individual.IndividualID,
+			"mark_code":      individual.MarkCode,
+			"occurrence_ids": occurrenceIDs,
+			"times":          times,
+			"length_m":       math.Round(length),
+		},
+	}
+	if len(coordinates) >= 2 {
+		feature.Geometry = &GeoJSONLineString{Type: "LineString", Coordinates: coordinates}
+	}
+	return feature
+}
+
+// conflictsOf は1つの個体の記録の食い違いを探すのだ
+// 同じ日に maxDistance より離れた2つの記録、性別の違う記録、種の違う記録を見つけるのだ
+func conflictsOf(individual *model.Individual, occurrences []model.Occurrence, maxDistance float64) []IndividualConflict {
+	entries := timelineOf(occurrences)
+	conflicts := []IndividualConflict{}
+	newConflict := func(kind, detail string, ids ...uint) IndividualConflict {
+		return IndividualConflict{IndividualID: individual.IndividualID, MarkCode: individual.MarkCode, Kind: kind, OccurrenceIDs: ids, Detail: detail}
+	}
+
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := &entries[i], &entries[j]
+			if !a.located() || !b.located() || a.localDate() != b.localDate() {
+				continue
+			}
+			distance := distanceMeters(a, b)
+			if distance <= maxDistance {
+				continue
+			}
+			conflict := newConflict(ConflictDistantSameDay,
+				fmt.Sprintf("recorded %.0f m apart on %s", distance, a.localDate()), a.OccurrenceID, b.OccurrenceID)
+			rounded := math.Round(distance)
+			conflict.DistanceM = &rounded
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	// 空の値は「記録していない」なので比べないのだ
+	for _, check := range []struct {
+		kind  string
+		name  string
+		value func(e *IndividualTimelineEntry) string
+	}{
+		{ConflictSexMismatch, "sex", func(e *IndividualTimelineEntry) string { return e.Sex }},
+		{ConflictTaxonMismatch, "scientific name", func(e *IndividualTimelineEntry) string { return e.ScientificName }},
+	} {
+		var values []string
+		var ids []uint
+		for i := range entries {
+			if v := strings.TrimSpace(check.value(&entries[i])); v != "" {
+				ids = append(ids, entries[i].OccurrenceID)
+				if !slices.Contains(values, v) {
+					values = append(values, v)
+				}
+			}
+		}
+		if len(values) > 1 {
+			conflicts = append(conflicts, newConflict(check.kind,
+				fmt.Sprintf("recorded with different %s: %s", check.name, strings.Join(values, ", ")), ids...))
+		}
+	}
+	return conflicts
+}
+
+// distanceMeters は2つの記録の座標の間の距離(m)を大円距離で求めるのだ
+func distanceMeters(a, b *IndividualTimelineEntry) float64 {
+	const earthRadiusM = 6371008.8
+	lat1, lat2 := *a.Latitude*math.Pi/180, *b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (*b.Longitude - *a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
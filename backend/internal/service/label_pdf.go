@@ -0,0 +1,296 @@
+// backend/internal/service/label_pdf.go
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+)
+
+// LabelPaper はラベルを並べる用紙の大きさ(mm)なのだ
+type LabelPaper struct {
+	Name   string  `json:"name"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// labelPapers はよく使うラベル用紙なのだ。先頭が既定なのだ
+var labelPapers = []LabelPaper{
+	{Name: "a4", Width: 210, Height: 297},
+	{Name: "letter", Width: 215.9, Height: 279.4},
+	{Name: "b5", Width: 182, Height: 257},
+	{Name: "a5", Width: 148, Height: 210},
+}
+
+// labelLine はラベルの1行分の文字と、基本の大きさに対する倍率なのだ
+type labelLine struct {
+	Text  string
+	Scale float64
+}
+
+// labelTemplate はラベルの種類ごとの大きさ(mm)・文字の大きさ(pt)・載せる行なのだ
+type labelTemplate struct {
+	Name     string
+	Width    float64
+	Height   float64
+	FontSize float64
+	Lines    func(d labelData) []labelLine
+}
+
+// labelTemplates は印刷できるラベルの種類なのだ。先頭が既定なのだ
+// 昆虫針に刺す小さなラベルを基準にしているので、文字は入りきるまで縮めるのだ
+var labelTemplates = []labelTemplate{
+	{
+		// 採集ラベル: 場所・座標・日付・採集者
+		Name: "locality", Width: 40, Height: 16, FontSize: 5,
+		Lines: func(d labelData) []labelLine {
+			return []labelLine{
+				{Text: d.Locality, Scale: 1},
+				{Text: joinNonEmpty("  ", d.Coordinates, d.Elevation), Scale: 1},
+				{Text: d.Date, Scale: 1},
+				{Text: d.Collector, Scale: 1},
+			}
+		},
+	},
+	{
+		// 同定ラベル: 学名と同定者
+		Name: "determination", Width: 40, Height: 12, FontSize: 5.5,
+		Lines: func(d labelData) []labelLine {
+			return []labelLine{
+				{Text: d.ScientificName, Scale: 1.2},
+				{Text: d.Determiner, Scale: 1},
+			}
+		},
+	},
+	{
+		// 登録番号ラベル: 番号と機関・コレクション
+		Name: "catalogue", Width: 32, Height: 12, FontSize: 6,
+		Lines: func(d labelData) []labelLine {
+			return []labelLine{
+				{Text: d.CatalogNumber, Scale: 1.3},
+				{Text: d.Collection, Scale: 1},
+			}
+		},
+	},
+}
+
+// ラベルの並べ方(mm)と文字の詰め方なのだ
+const (
+	labelPageMargin  = 8.0
+	labelGap         = 1.0
+	labelPadding     = 0.8
+	labelMinFontSize = 3.0
+	labelLineSpacing = 1.15
+	ptToMM           = 25.4 / 72
+	labelCodeModule  = 8 // コード1マスあたりの画素数なのだ
+)
+
+// labelSheet はテンプレートと用紙を決めたラベルの印刷なのだ
+type labelSheet struct {
+	template labelTemplate
+	paper    LabelPaper
+	code     string
+	fontPath string
+}
+
+// render はラベルを用紙に左上から詰めて並べ、PDFで書き出すのだ。ラベルの周りには切り取り用の細い線を引くのだ
+func (s labelSheet) render(w io.Writer, labels []labelData) error {
+	t, p := s.template, s.paper
+	cols := int((p.Width - 2*labelPageMargin + labelGap) / (t.Width + labelGap))
+	rows := int((p.Height - 2*labelPageMargin + labelGap) / (t.Height + labelGap))
+	if cols < 1 || rows < 1 {
+		return fmt.Errorf("%w: %s labels do not fit on %s paper", ErrInvalidLabelRequest, t.Name, p.Name)
+	}
+
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: p.Width, Ht: p.Height},
+	})
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetCellMargin(0)
+	pdf.SetCreator("specimen-web", true)
+
+	// 日本語の地名などを印刷するにはTrueTypeのフォントが要るのだ。なければ英数字だけのHelveticaで印刷するのだ
+	family := "Helvetica"
+	encode := func(text string) string { return text }
+	if s.fontPath != "" {
+		font, err := os.ReadFile(s.fontPath)
+		if err != nil {
+			return fmt.Errorf("failed to read label font: %w", err)
+		}
+		family = "label"
+		pdf.AddUTF8FontFromBytes(family, "", font)
+	} else {
+		tr := pdf.UnicodeTranslatorFromDescriptor("")
+		encode = func(text string) string { return tr(latinOnly(text)) }
+	}
+	pdf.SetFont(family, "", t.FontSize)
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("failed to set up label font: %w", err)
+	}
+
+	codes := map[string]string{} // 同じ中身のコードは1回だけ埋め込むのだ
+	for i, label := range labels {
+		if i%(cols*rows) == 0 {
+			pdf.AddPage()
+		}
+		cell := i % (cols * rows)
+		x := labelPageMargin + float64(cell%cols)*(t.Width+labelGap)
+		y := labelPageMargin + float64(cell/cols)*(t.Height+labelGap)
+
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.SetLineWidth(0.1)
+		pdf.Rect(x, y, t.Width, t.Height, "D")
+
+		codeSize := t.Height - 2*labelPadding
+		if label.Code != "" {
+			name, ok := codes[label.Code]
+			if !ok {
+				name = fmt.Sprintf("code%d", len(codes))
+				if err := registerLabelCode(pdf, name, s.code, label.Code); err != nil {
+					return err
+				}
+				codes[label.Code] = name
+			}
+			pdf.ImageOptions(name, x+t.Width-labelPadding-codeSize, y+labelPadding, codeSize, codeSize,
+				false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		}
+
+		textWidth := t.Width - 3*labelPadding - codeSize
+		drawLabelText(pdf, encode, t, t.Lines(label), x+labelPadding, y+labelPadding, textWidth, t.Height-2*labelPadding)
+	}
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// drawLabelText は枠に入りきる大きさまで文字を縮めて、行を折り返しながら書くのだ
+// 一番小さくしても入りきらないときは、はみ出した行を落とすのだ
+func drawLabelText(pdf *fpdf.Fpdf, encode func(string) string, t labelTemplate, lines []labelLine, x, y, width, height float64) {
+	type wrapped struct {
+		texts []string
+		size  float64
+	}
+	layout := func(base float64) ([]wrapped, float64) {
+		var result []wrapped
+		total := 0.0
+		for _, line := range lines {
+			if strings.TrimSpace(line.Text) == "" {
+				continue
+			}
+			size := base * line.Scale
+			pdf.SetFontSize(size)
+			texts := wrapLabelText(func(s string) float64 { return pdf.GetStringWidth(encode(s)) }, line.Text, width)
+			result = append(result, wrapped{texts: texts, size: size})
+			total += float64(len(texts)) * size * ptToMM * labelLineSpacing
+		}
+		return result, total
+	}
+
+	size := t.FontSize
+	blocks, total := layout(size)
+	for total > height && size > labelMinFontSize {
+		size = max(labelMinFontSize, size-0.25)
+		blocks, total = layout(size)
+	}
+
+	cursor := y
+	for _, block := range blocks {
+		pdf.SetFontSize(block.size)
+		lineHeight := block.size * ptToMM * labelLineSpacing
+		for _, text := range block.texts {
+			if cursor+lineHeight > y+height+0.01 {
+				return
+			}
+			// ベースラインは行の高さの8割くらいの位置なのだ
+			pdf.Text(x, cursor+lineHeight*0.8, encode(text))
+			cursor += lineHeight
+		}
+	}
+}
+
+// wrapLabelText は幅に収まるように行を折り返すのだ
+// 英語は空白で、日本語は文字の間で折り返すのだ
+func wrapLabelText(measure func(string) float64, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		runes := []rune(strings.TrimSpace(paragraph))
+		start := 0
+		lastSpace := -1
+		for i := 0; i < len(runes); i++ {
+			if unicode.IsSpace(runes[i]) {
+				lastSpace = i
+			}
+			if i > start && measure(string(runes[start:i+1])) > width {
+				end := i
+				if lastSpace > start {
+					end = lastSpace
+				}
+				lines = append(lines, strings.TrimSpace(string(runes[start:end])))
+				start = end
+				for start < len(runes) && unicode.IsSpace(runes[start]) {
+					start++
+				}
+				lastSpace = -1
+				i = start - 1
+			}
+		}
+		if start < len(runes) {
+			lines = append(lines, string(runes[start:]))
+		}
+	}
+	return lines
+}
+
+// registerLabelCode はQRコードかDataMatrixをPNGにしてPDFに登録するのだ
+func registerLabelCode(pdf *fpdf.Fpdf, name, kind, content string) error {
+	var code barcode.Barcode
+	var err error
+	switch kind {
+	case LabelCodeDataMatrix:
+		code, err = datamatrix.Encode(content)
+	default:
+		code, err = qr.Encode(content, qr.M, qr.Auto)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: cannot encode %q: %v", ErrInvalidLabelRequest, content, err)
+	}
+
+	// 1マスを整数の画素にして、印刷でにじまないようにするのだ
+	b := code.Bounds()
+	scaled, err := barcode.Scale(code, b.Dx()*labelCodeModule, b.Dy()*labelCodeModule)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return err
+	}
+	pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &buf)
+	return pdf.Error()
+}
+
+// latinOnly はHelveticaで印刷できない文字を ? にするのだ
+func latinOnly(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x100 || r == '–' {
+			return r
+		}
+		return '?'
+	}, text)
+}
@@ -0,0 +1,312 @@
+// backend/internal/service/label_service.go
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidLabelRequest はラベルの印刷条件が正しくないときのエラーなのだ
+var ErrInvalidLabelRequest = errors.New("invalid label request")
+
+// ラベルに印刷するコードの種類と中身なのだ
+const (
+	LabelCodeQR         = "qr"
+	LabelCodeDataMatrix = "datamatrix"
+
+	LabelContentURI           = "uri"
+	LabelContentCatalogNumber = "catalog_number"
+)
+
+const (
+	maxLabelSpecimens = 500
+	maxLabelCopies    = 10
+)
+
+// LabelRequest はラベル印刷のリクエストボディなのだ
+// 省略したときは 採集ラベル・A4・1枚ずつ・QRコード・URI なのだ
+type LabelRequest struct {
+	SpecimenIDs []uint `json:"specimen_ids" binding:"required"`
+	Template    string `json:"template"`
+	Paper       string `json:"paper"`
+	Copies      int    `json:"copies"`
+	Code        string `json:"code"`
+	CodeContent string `json:"code_content"`
+}
+
+// LabelOptions は選べるテンプレートと用紙なのだ
+type LabelOptions struct {
+	Templates    []LabelTemplateInfo `json:"templates"`
+	Papers       []LabelPaper        `json:"papers"`
+	Codes        []string            `json:"codes"`
+	CodeContents []string            `json:"code_contents"`
+}
+
+// LabelTemplateInfo はテンプレートの名前と大きさ(mm)なのだ
+type LabelTemplateInfo struct {
+	Name   string  `json:"name"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// labelData はラベル1枚に載せる文字とコードなのだ
+type labelData struct {
+	Locality       string
+	Coordinates    string
+	Elevation      string
+	Date           string
+	Collector      string
+	ScientificName string
+	Determiner     string
+	CatalogNumber  string
+	Collection     string
+	Code           string
+}
+
+// LabelService は標本ラベルのPDFを作るインターフェースなのだ
+type LabelService interface {
+	RenderLabels(w io.Writer, req LabelRequest) error
+	GetLabelOptions() LabelOptions
+}
+
+type labelService struct {
+	db            *gorm.DB
+	specimenRepo  repository.SpecimenRepository
+	publicBaseURL string
+	fontPath      string
+}
+
+// NewLabelService は新しいサービスを生成するのだ。fontPath が空なら英数字だけのフォントで印刷するのだ
+func NewLabelService(db *gorm.DB, specimenRepo repository.SpecimenRepository, publicBaseURL, fontPath string) LabelService {
+	return &labelService{
+		db:            db,
+		specimenRepo:  specimenRepo,
+		publicBaseURL: strings.TrimRight(publicBaseURL, "/"),
+		fontPath:      fontPath,
+	}
+}
+
+func (s *labelService) GetLabelOptions() LabelOptions {
+	options := LabelOptions{
+		Papers:       labelPapers,
+		Codes:        []string{LabelCodeQR, LabelCodeDataMatrix},
+		CodeContents: []string{LabelContentURI, LabelContentCatalogNumber},
+	}
+	for _, t := range labelTemplates {
+		options.Templates = append(options.Templates, LabelTemplateInfo{Name: t.Name, Width: t.Width, Height: t.Height})
+	}
+	return options
+}
+
+// RenderLabels は選んだ標本のラベルをPDFで書き出すのだ
+// 途中で失敗したときに壊れたPDFを返さないように、全部作ってから書き出すのだ
+func (s *labelService) RenderLabels(w io.Writer, req LabelRequest) error {
+	template, paper, err := s.resolveLabelRequest(&req)
+	if err != nil {
+		return err
+	}
+
+	specimens, err := s.specimenRepo.FindForLabels(req.SpecimenIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*model.Specimen, len(specimens))
+	for i := range specimens {
+		byID[specimens[i].SpecimenID] = &specimens[i]
+	}
+
+	// 頼まれた順に並べるのだ (引き出しの並びで印刷したいことが多いのだ)
+	labels := make([]labelData, 0, len(req.SpecimenIDs)*req.Copies)
+	var missing []string
+	for _, id := range req.SpecimenIDs {
+		specimen, ok := byID[id]
+		if !ok {
+			missing = append(missing, fmt.Sprint(id))
+			continue
+		}
+		data := s.labelDataOf(specimen, req.CodeContent)
+		for range req.Copies {
+			labels = append(labels, data)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: specimens not found: %s", ErrInvalidLabelRequest, strings.Join(missing, ", "))
+	}
+
+	var buf bytes.Buffer
+	sheet := labelSheet{template: template, paper: paper, code: req.Code, fontPath: s.fontPath}
+	if err := sheet.render(&buf, labels); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// resolveLabelRequest は省略された項目を埋めて、テンプレートと用紙を決めるのだ
+func (s *labelService) resolveLabelRequest(req *LabelRequest) (labelTemplate, LabelPaper, error) {
+	if len(req.SpecimenIDs) == 0 {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: specimen_ids is empty", ErrInvalidLabelRequest)
+	}
+	if len(req.SpecimenIDs) > maxLabelSpecimens {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: at most %d specimens at once", ErrInvalidLabelRequest, maxLabelSpecimens)
+	}
+
+	if req.Copies == 0 {
+		req.Copies = 1
+	}
+	if req.Copies < 1 || req.Copies > maxLabelCopies {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: copies must be 1-%d", ErrInvalidLabelRequest, maxLabelCopies)
+	}
+
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	if req.Code == "" {
+		req.Code = LabelCodeQR
+	}
+	if req.Code != LabelCodeQR && req.Code != LabelCodeDataMatrix {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: unknown code %q", ErrInvalidLabelRequest, req.Code)
+	}
+
+	req.CodeContent = strings.ToLower(strings.TrimSpace(req.CodeContent))
+	if req.CodeContent == "" {
+		req.CodeContent = LabelContentURI
+	}
+	if req.CodeContent != LabelContentURI && req.CodeContent != LabelContentCatalogNumber {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: unknown code_content %q", ErrInvalidLabelRequest, req.CodeContent)
+	}
+
+	templateName := strings.ToLower(strings.TrimSpace(req.Template))
+	if templateName == "" {
+		templateName = labelTemplates[0].Name
+	}
+	i := slices.IndexFunc(labelTemplates, func(t labelTemplate) bool { return t.Name == templateName })
+	if i < 0 {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: unknown template %q", ErrInvalidLabelRequest, req.Template)
+	}
+	template := labelTemplates[i]
+
+	paperName := strings.ToLower(strings.TrimSpace(req.Paper))
+	if paperName == "" {
+		paperName = labelPapers[0].Name
+	}
+	j := slices.IndexFunc(labelPapers, func(p LabelPaper) bool { return p.Name == paperName })
+	if j < 0 {
+		return labelTemplate{}, LabelPaper{}, fmt.Errorf("%w: unknown paper %q", ErrInvalidLabelRequest, req.Paper)
+	}
+	return template, labelPapers[j], nil
+}
+
+// labelDataOf は標本からラベルに載せる文字を作るのだ。Darwin Core の書き出しと同じ当てはめを使うのだ
+func (s *labelService) labelDataOf(specimen *model.Specimen, codeContent string) labelData {
+	o := &specimen.Occurrence
+	record := toDarwinCoreOccurrence(o)
+
+	data := labelData{
+		Locality:       localityOf(record),
+		ScientificName: record["scientificName"],
+		Collector:      prefixed("leg. ", record["recordedBy"]),
+		Collection:     joinNonEmpty("-", specimen.InstitutionIDCode.InstitutionCode, specimen.CollectionIDCode.CollectionCode),
+	}
+	if specimen.CatalogNumber != nil {
+		data.CatalogNumber = *specimen.CatalogNumber
+	}
+
+	if p := o.Place; p != nil {
+		if p.Latitude != nil && p.Longitude != nil {
+			data.Coordinates = formatLabelCoordinates(*p.Latitude, *p.Longitude)
+		}
+		data.Elevation = formatLabelElevation(p.MinimumElevationInMeters, p.MaximumElevationInMeters)
+	}
+
+	if t, tz := eventTimeOf(o); !t.IsZero() {
+		data.Date = formatLabelDate(t.In(time.FixedZone("", int(tz)*3600)))
+	}
+
+	// Identifications は新しい順に読み込んでいるので、先頭が今の同定なのだ
+	if len(o.Identifications) > 0 {
+		latest := o.Identifications[0]
+		year := latest.IdentificatedAt.In(time.FixedZone("", int(latest.Timezone)*3600)).Format("2006")
+		data.Determiner = prefixed("det. ", joinNonEmpty(" ", displayNameOf(&latest.User), year))
+	}
+
+	data.Code = s.stableURI(specimen)
+	if codeContent == LabelContentCatalogNumber && data.CatalogNumber != "" {
+		data.Code = data.CatalogNumber
+	}
+	return data
+}
+
+// stableURI は標本を指す変わらないURLなのだ。登録番号があれば番号で、なければIDで引くのだ
+func (s *labelService) stableURI(specimen *model.Specimen) string {
+	if specimen.CatalogNumber != nil && *specimen.CatalogNumber != "" {
+		return fmt.Sprintf("%s/specimens/catalog/%s", s.publicBaseURL, url.PathEscape(*specimen.CatalogNumber))
+	}
+	return fmt.Sprintf("%s/specimens/%d", s.publicBaseURL, specimen.SpecimenID)
+}
+
+// localityOf は国・都道府県・市町村・地名を大きい順につなぐのだ。地名がなければ野帳の記載を使うのだ
+func localityOf(record DarwinCoreRecord) string {
+	locality := joinNonEmpty(", ", record["country"], record["stateProvince"], record["municipality"], record["locality"])
+	if record["locality"] == "" && record["verbatimLocality"] != "" {
+		locality = joinNonEmpty(", ", locality, record["verbatimLocality"])
+	}
+	return locality
+}
+
+// formatLabelCoordinates は 35.6580°N 139.7413°E の形にするのだ
+func formatLabelCoordinates(lat, lon float64) string {
+	ns, ew := "N", "E"
+	if lat < 0 {
+		ns, lat = "S", -lat
+	}
+	if lon < 0 {
+		ew, lon = "W", -lon
+	}
+	return fmt.Sprintf("%.4f°%s %.4f°%s", lat, ns, lon, ew)
+}
+
+// formatLabelElevation は標高を 120 m や 120–180 m の形にするのだ
+func formatLabelElevation(minimum, maximum *float64) string {
+	switch {
+	case minimum != nil && maximum != nil && *minimum != *maximum:
+		return fmt.Sprintf("%.0f–%.0f m", *minimum, *maximum)
+	case minimum != nil:
+		return fmt.Sprintf("%.0f m", *minimum)
+	case maximum != nil:
+		return fmt.Sprintf("%.0f m", *maximum)
+	}
+	return ""
+}
+
+// labelMonths は月のローマ数字なのだ。日と月を取り違えないように、ラベルでは 1.III.2026 の形で書くのだ
+var labelMonths = [...]string{"I", "II", "III", "IV", "V", "VI", "VII", "VIII", "IX", "X", "XI", "XII"}
+
+func formatLabelDate(t time.Time) string {
+	return fmt.Sprintf("%d.%s.%d", t.Day(), labelMonths[t.Month()-1], t.Year())
+}
+
+func prefixed(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
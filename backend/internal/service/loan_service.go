@@ -0,0 +1,506 @@
+// backend/internal/service/loan_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidLoan は貸し出しの内容が正しくないときのエラーなのだ (存在しない標本や機関、日付の形式など)
+var ErrInvalidLoan = errors.New("invalid loan")
+
+// ErrLoanClosed は閉じた貸し出しに標本を足したり返したりしようとしたときのエラーなのだ
+var ErrLoanClosed = errors.New("loan is already closed")
+
+// loanSpecimensOutstandingKey は同じ標本を二重に貸し出さないための一意インデックスの名前なのだ
+const loanSpecimensOutstandingKey = "loan_specimens_outstanding_key"
+
+const (
+	defaultLoanLimit = 50
+	maxLoanLimit     = 200
+
+	// maxLoanSpecimens は1回の貸し出しに入れられる標本の数の上限なのだ
+	maxLoanSpecimens = 5000
+)
+
+// ListLoansRequest は貸し出し一覧のクエリパラメータなのだ
+// status は open・closed・overdue で、as_of (2006-01-02) を省略すると今日で期限切れを決めるのだ
+type ListLoansRequest struct {
+	Status        string `form:"status"`
+	InstitutionID *uint  `form:"institution_id"`
+	AsOf          string `form:"as_of"`
+	Limit         int    `form:"limit"`
+	Offset        int    `form:"offset"`
+}
+
+// OverdueLoansRequest は期限切れの報告のクエリパラメータなのだ
+type OverdueLoansRequest struct {
+	AsOf string `form:"as_of"`
+}
+
+// CreateLoanRequest は貸し出し作成時のリクエストボディなのだ
+// 日付は 2006-01-02 の形で、loan_date を省略すると今日なのだ
+type CreateLoanRequest struct {
+	BorrowerInstitutionID uint    `json:"borrower_institution_id" binding:"required"`
+	ContactName           string  `json:"contact_name" binding:"required"`
+	ContactEmail          *string `json:"contact_email"`
+	Purpose               *string `json:"purpose"`
+	LoanDate              string  `json:"loan_date"`
+	DueDate               string  `json:"due_date"`
+	Note                  *string `json:"note"`
+	UserID                *uint   `json:"user_id"`
+	SpecimenIDs           []uint  `json:"specimen_ids" binding:"required"`
+}
+
+// UpdateLoanRequest は貸し出し更新時のリクエストボディなのだ (期限の延長など)。省略した項目は外すのだ
+type UpdateLoanRequest struct {
+	BorrowerInstitutionID uint    `json:"borrower_institution_id" binding:"required"`
+	ContactName           string  `json:"contact_name" binding:"required"`
+	ContactEmail          *string `json:"contact_email"`
+	Purpose               *string `json:"purpose"`
+	LoanDate              string  `json:"loan_date" binding:"required"`
+	DueDate               string  `json:"due_date"`
+	Note                  *string `json:"note"`
+}
+
+// AddLoanSpecimensRequest は貸し出し中の貸し出しに標本を足すリクエストボディなのだ
+type AddLoanSpecimensRequest struct {
+	SpecimenIDs []uint `json:"specimen_ids" binding:"required"`
+}
+
+// ReturnLoanSpecimensRequest は返却のリクエストボディなのだ
+// specimen_ids を省略すると、まだ返ってきていない標本を全部返すのだ。returned_date を省略すると今日なのだ
+type ReturnLoanSpecimensRequest struct {
+	SpecimenIDs  []uint  `json:"specimen_ids"`
+	ReturnedDate string  `json:"returned_date"`
+	Note         *string `json:"note"`
+}
+
+// LoanDetail は貸し出しと、その状態・標本の数なのだ
+type LoanDetail struct {
+	*model.Loan
+	Status           string `json:"status"` // open・closed・overdue なのだ
+	SpecimenCount    int    `json:"specimen_count"`
+	OutstandingCount int    `json:"outstanding_count"` // まだ返ってきていない標本の数なのだ
+	DaysOverdue      int    `json:"days_overdue,omitempty"`
+}
+
+// LoanList は貸し出し一覧の1ページ分なのだ
+type LoanList struct {
+	Loans  []LoanDetail `json:"loans"`
+	Total  int64        `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
+// OverdueReport は返却期限を過ぎた貸し出しの一覧なのだ。期限の古い順なのだ
+type OverdueReport struct {
+	AsOf                 string       `json:"as_of"`
+	Loans                []LoanDetail `json:"loans"`
+	OutstandingSpecimens int          `json:"outstanding_specimens"`
+}
+
+// LoanService は貸し出し関連のビジネスロジックのインターフェースなのだ
+type LoanService interface {
+	ListLoans(req ListLoansRequest) (*LoanList, error)
+	GetOverdueLoans(req OverdueLoansRequest) (*OverdueReport, error)
+	GetLoan(id uint) (*LoanDetail, error)
+	CreateLoan(req CreateLoanRequest) (*LoanDetail, error)
+	UpdateLoan(id uint, req UpdateLoanRequest) (*LoanDetail, error)
+	AddLoanSpecimens(id uint, req AddLoanSpecimensRequest) (*LoanDetail, error)
+	ReturnLoanSpecimens(id uint, req ReturnLoanSpecimensRequest) (*LoanDetail, error)
+	GetSpecimenLoans(specimenID uint) ([]LoanDetail, error)
+}
+
+type loanService struct {
+	db           *gorm.DB
+	repo         repository.LoanRepository
+	specimenRepo repository.SpecimenRepository
+}
+
+// NewLoanService は新しいサービスを生成するのだ
+func NewLoanService(db *gorm.DB, repo repository.LoanRepository, specimenRepo repository.SpecimenRepository) LoanService {
+	return &loanService{db: db, repo: repo, specimenRepo: specimenRepo}
+}
+
+func (s *loanService) ListLoans(req ListLoansRequest) (*LoanList, error) {
+	status := strings.TrimSpace(req.Status)
+	if status != "" && status != repository.LoanStatusOpen && status != repository.LoanStatusClosed && status != repository.LoanStatusOverdue {
+		return nil, fmt.Errorf("%w: status must be open, closed or overdue", ErrInvalidLoan)
+	}
+	asOf, err := parseLoanDate("as_of", req.AsOf, today())
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLoanLimit
+	}
+	limit = min(limit, maxLoanLimit)
+	offset := max(req.Offset, 0)
+
+	loans, total, err := s.repo.List(repository.LoanListParams{
+		Status:        status,
+		InstitutionID: req.InstitutionID,
+		AsOf:          asOf,
+		Limit:         limit,
+		Offset:        offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	details := make([]LoanDetail, len(loans))
+	for i := range loans {
+		details[i] = loanDetailOf(&loans[i], asOf)
+	}
+	return &LoanList{Loans: details, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// GetOverdueLoans は as_of の時点で返却期限を過ぎている貸し出しを全部返すのだ (督促の一覧なのだ)
+func (s *loanService) GetOverdueLoans(req OverdueLoansRequest) (*OverdueReport, error) {
+	asOf, err := parseLoanDate("as_of", req.AsOf, today())
+	if err != nil {
+		return nil, err
+	}
+	loans, _, err := s.repo.List(repository.LoanListParams{Status: repository.LoanStatusOverdue, AsOf: asOf})
+	if err != nil {
+		return nil, err
+	}
+	report := &OverdueReport{AsOf: asOf.Format("2006-01-02"), Loans: make([]LoanDetail, len(loans))}
+	for i := range loans {
+		report.Loans[i] = loanDetailOf(&loans[i], asOf)
+		report.OutstandingSpecimens += report.Loans[i].OutstandingCount
+	}
+	return report, nil
+}
+
+func (s *loanService) GetLoan(id uint) (*LoanDetail, error) {
+	loan, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	detail := loanDetailOf(loan, today())
+	return &detail, nil
+}
+
+// CreateLoan は貸し出しを作って、標本を貸し出し中にするのだ
+func (s *loanService) CreateLoan(req CreateLoanRequest) (*LoanDetail, error) {
+	loan := &model.Loan{UserID: req.UserID}
+	if err := s.applyLoanFields(loan, req.BorrowerInstitutionID, req.ContactName, req.ContactEmail, req.Purpose, req.LoanDate, req.DueDate, req.Note); err != nil {
+		return nil, err
+	}
+	ids, err := s.checkSpecimens(req.SpecimenIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkNotOnLoan(tx, ids); err != nil {
+			return err
+		}
+		if _, err := s.repo.Create(tx, loan); err != nil {
+			return err
+		}
+		if err := s.repo.AddSpecimens(tx, loan.LoanID, ids); err != nil {
+			return err
+		}
+		return s.repo.SetSpecimenStatus(tx, ids, model.SpecimenStatusOnLoan)
+	})
+	if err != nil {
+		return nil, onLoanOr(err)
+	}
+	return s.GetLoan(loan.LoanID)
+}
+
+// UpdateLoan は借り手・連絡先・日付などを書き換えるのだ。標本の出し入れは AddLoanSpecimens と ReturnLoanSpecimens でするのだ
+func (s *loanService) UpdateLoan(id uint, req UpdateLoanRequest) (*LoanDetail, error) {
+	current, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	loan := &model.Loan{LoanID: id}
+	if err := s.applyLoanFields(loan, req.BorrowerInstitutionID, req.ContactName, req.ContactEmail, req.Purpose, req.LoanDate, req.DueDate, req.Note); err != nil {
+		return nil, err
+	}
+	// 貸した日より前に返ってきたことにならないようにするのだ
+	for _, item := range current.Specimens {
+		if item.ReturnedDate != nil && item.ReturnedDate.Before(loan.LoanDate) {
+			return nil, fmt.Errorf("%w: loan_date is after the return of specimen %d", ErrInvalidLoan, item.SpecimenID)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, loan)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetLoan(id)
+}
+
+// AddLoanSpecimens は貸し出し中の貸し出しに標本を足すのだ
+func (s *loanService) AddLoanSpecimens(id uint, req AddLoanSpecimensRequest) (*LoanDetail, error) {
+	ids, err := s.checkSpecimens(req.SpecimenIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		loan, err := s.repo.FindByIDForUpdate(tx, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if loan.ClosedDate != nil {
+			return ErrLoanClosed
+		}
+		// 一度返ってきた標本をもう一度同じ貸し出しに入れるときは、新しい貸し出しにするのだ
+		existing, err := s.repo.FindLoanSpecimenIDs(tx, id, false)
+		if err != nil {
+			return err
+		}
+		if dup := intersectIDs(ids, existing); len(dup) > 0 {
+			return fmt.Errorf("%w: specimens already in this loan: %s", ErrInvalidLoan, joinIDs(dup))
+		}
+		if err := s.checkNotOnLoan(tx, ids); err != nil {
+			return err
+		}
+		if err := s.repo.AddSpecimens(tx, id, ids); err != nil {
+			return err
+		}
+		return s.repo.SetSpecimenStatus(tx, ids, model.SpecimenStatusOnLoan)
+	})
+	if err != nil {
+		return nil, onLoanOr(err)
+	}
+	return s.GetLoan(id)
+}
+
+// ReturnLoanSpecimens は標本の返却を受け付けるのだ。一部だけの返却もできて、全部返ってきたら貸し出しを閉じるのだ
+func (s *loanService) ReturnLoanSpecimens(id uint, req ReturnLoanSpecimensRequest) (*LoanDetail, error) {
+	returnedDate, err := parseLoanDate("returned_date", req.ReturnedDate, today())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		loan, err := s.repo.FindByIDForUpdate(tx, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if loan.ClosedDate != nil {
+			return ErrLoanClosed
+		}
+		if returnedDate.Before(loan.LoanDate) {
+			return fmt.Errorf("%w: returned_date is before loan_date", ErrInvalidLoan)
+		}
+
+		outstanding, err := s.repo.FindLoanSpecimenIDs(tx, id, true)
+		if err != nil {
+			return err
+		}
+		ids := outstanding
+		if len(req.SpecimenIDs) > 0 {
+			ids = slices.Compact(slices.Sorted(slices.Values(req.SpecimenIDs)))
+			if rest := subtractIDs(ids, outstanding); len(rest) > 0 {
+				return fmt.Errorf("%w: specimens not outstanding in this loan: %s", ErrInvalidLoan, joinIDs(rest))
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if _, err := s.repo.ReturnSpecimens(tx, id, ids, returnedDate, req.Note); err != nil {
+			return err
+		}
+		if err := s.repo.SetSpecimenStatus(tx, ids, model.SpecimenStatusInHouse); err != nil {
+			return err
+		}
+		left, err := s.repo.CountOutstanding(tx, id)
+		if err != nil {
+			return err
+		}
+		if left == 0 {
+			return s.repo.SetClosedDate(tx, id, &returnedDate)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetLoan(id)
+}
+
+// GetSpecimenLoans は標本が入った貸し出しを新しい順に返すのだ
+func (s *loanService) GetSpecimenLoans(specimenID uint) ([]LoanDetail, error) {
+	if _, err := s.specimenRepo.FindByID(specimenID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	loans, err := s.repo.FindBySpecimen(specimenID)
+	if err != nil {
+		return nil, err
+	}
+	asOf := today()
+	details := make([]LoanDetail, len(loans))
+	for i := range loans {
+		details[i] = loanDetailOf(&loans[i], asOf)
+	}
+	return details, nil
+}
+
+// applyLoanFields はリクエストの項目を確かめて貸し出しに入れるのだ
+func (s *loanService) applyLoanFields(loan *model.Loan, institutionID uint, contactName string, contactEmail, purpose *string, loanDate, dueDate string, note *string) error {
+	ok, err := s.specimenRepo.InstitutionExists(institutionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: borrower_institution_id %d does not exist", ErrInvalidLoan, institutionID)
+	}
+	loan.BorrowerInstitutionID = institutionID
+
+	loan.ContactName = strings.TrimSpace(contactName)
+	if loan.ContactName == "" {
+		return fmt.Errorf("%w: contact_name is empty", ErrInvalidLoan)
+	}
+	loan.ContactEmail = contactEmail
+	loan.Purpose = purpose
+	loan.Note = note
+
+	loan.LoanDate, err = parseLoanDate("loan_date", loanDate, today())
+	if err != nil {
+		return err
+	}
+	loan.DueDate = nil
+	if dueDate != "" {
+		due, err := parseLoanDate("due_date", dueDate, time.Time{})
+		if err != nil {
+			return err
+		}
+		if due.Before(loan.LoanDate) {
+			return fmt.Errorf("%w: due_date is before loan_date", ErrInvalidLoan)
+		}
+		loan.DueDate = &due
+	}
+	return nil
+}
+
+// checkSpecimens は貸し出す標本があるか確かめて、重なりを除いて並べたIDを返すのだ
+func (s *loanService) checkSpecimens(specimenIDs []uint) ([]uint, error) {
+	ids := slices.Compact(slices.Sorted(slices.Values(specimenIDs)))
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: specimen_ids is empty", ErrInvalidLoan)
+	}
+	if len(ids) > maxLoanSpecimens {
+		return nil, fmt.Errorf("%w: at most %d specimens at once", ErrInvalidLoan, maxLoanSpecimens)
+	}
+	found, err := s.specimenRepo.FindSummariesByCodes(ids, nil)
+	if err != nil {
+		return nil, err
+	}
+	foundIDs := make([]uint, len(found))
+	for i, f := range found {
+		foundIDs[i] = f.SpecimenID
+	}
+	slices.Sort(foundIDs)
+	if missing := subtractIDs(ids, foundIDs); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: specimens not found: %s", ErrInvalidLoan, joinIDs(missing))
+	}
+	return ids, nil
+}
+
+// checkNotOnLoan はほかの貸し出しでまだ返ってきていない標本がないか確かめるのだ
+func (s *loanService) checkNotOnLoan(tx *gorm.DB, ids []uint) error {
+	onLoan, err := s.repo.FindOutstandingSpecimenIDs(tx, ids)
+	if err != nil {
+		return err
+	}
+	if len(onLoan) > 0 {
+		return fmt.Errorf("%w: %s", ErrSpecimenOnLoan, joinIDs(onLoan))
+	}
+	return nil
+}
+
+// onLoanOr は貸し出し中の標本の一意インデックスに引っかかったエラーをErrSpecimenOnLoanに置き換えるのだ
+// checkNotOnLoan で確かめた後に、同じ標本がほかの貸し出しに同時に入れられたときに起きるのだ
+func onLoanOr(err error) error {
+	if isUniqueViolation(err, loanSpecimensOutstandingKey) {
+		return fmt.Errorf("%w: specimens were lent by another loan at the same time", ErrSpecimenOnLoan)
+	}
+	return err
+}
+
+// loanDetailOf は貸し出しの状態と標本の数を数えるのだ
+func loanDetailOf(loan *model.Loan, asOf time.Time) LoanDetail {
+	detail := LoanDetail{Loan: loan, Status: repository.LoanStatusOpen, SpecimenCount: len(loan.Specimens)}
+	for _, item := range loan.Specimens {
+		if item.ReturnedDate == nil {
+			detail.OutstandingCount++
+		}
+	}
+	switch {
+	case loan.ClosedDate != nil:
+		detail.Status = repository.LoanStatusClosed
+	case loan.DueDate != nil && loan.DueDate.Before(asOf):
+		detail.Status = repository.LoanStatusOverdue
+		detail.DaysOverdue = int(asOf.Sub(*loan.DueDate).Hours() / 24)
+	}
+	return detail
+}
+
+// parseLoanDate は 2006-01-02 の形の日付を読むのだ。空なら def を返すのだ
+func parseLoanDate(field, value string, def time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return def, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be YYYY-MM-DD", ErrInvalidLoan, field)
+	}
+	return date, nil
+}
+
+// today は今日の日付なのだ。DATE の列と比べるので、時刻を落としてUTCにするのだ
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// subtractIDs は a にあって b にないIDなのだ。どちらも小さい順に並んでいるのだ
+func subtractIDs(a, b []uint) []uint {
+	var rest []uint
+	for _, id := range a {
+		if _, ok := slices.BinarySearch(b, id); !ok {
+			rest = append(rest, id)
+		}
+	}
+	return rest
+}
+
+// intersectIDs は a と b の両方にあるIDなのだ。b は小さい順に並んでいるのだ
+func intersectIDs(a, b []uint) []uint {
+	var both []uint
+	for _, id := range a {
+		if _, ok := slices.BinarySearch(b, id); ok {
+			both = append(both, id)
+		}
+	}
+	return both
+}
+
+func joinIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ", ")
+}
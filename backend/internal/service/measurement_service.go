@@ -0,0 +1,342 @@
+// backend/internal/service/measurement_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidMeasurement は計測値の内容が正しくないときのエラーなのだ (数でない値、次元の違う単位、ほかの発生情報の標本など)
+var ErrInvalidMeasurement = errors.New("invalid measurement")
+
+// ErrMeasurementUnitInUse は計測値がある種類の単位を、読み替えられない単位に変えようとしたときのエラーなのだ
+var ErrMeasurementUnitInUse = errors.New("measurement type unit is in use")
+
+// MeasurementRequest は計測値の作成・更新のリクエストボディなのだ
+// Value は数なら文字列で送るのだ (例: "12.5")。Unit を省略すると計測の種類の単位で測ったことにするのだ
+// MeasuredOn は測った日 (2006-01-02) なのだ
+type MeasurementRequest struct {
+	SpecimenID        *uint   `json:"specimen_id"`
+	MeasurementTypeID uint    `json:"measurement_type_id" binding:"required"`
+	Value             string  `json:"value" binding:"required"`
+	Unit              *string `json:"unit"`
+	Accuracy          *string `json:"accuracy"`
+	Method            *string `json:"method"`
+	MeasuredByUserID  *uint   `json:"measured_by_user_id"`
+	MeasuredOn        string  `json:"measured_on"`
+	Remarks           *string `json:"remarks"`
+}
+
+// MeasurementTypeUnitRequest は計測の種類の単位を変えるリクエストボディなのだ。nilにすると数でない形質の種類になるのだ
+type MeasurementTypeUnitRequest struct {
+	Unit *string `json:"unit"`
+}
+
+// MeasurementService は計測値 (Darwin Core の MeasurementOrFact) のビジネスロジックのインターフェースなのだ
+// 計測の種類の名前・並び順・表示名は語彙の管理 (VocabularyService) で直すのだ
+type MeasurementService interface {
+	GetMeasurementTypes() ([]model.MeasurementType, error)
+	GetMeasurementUnits() []MeasurementUnit
+	UpdateTypeUnit(typeID uint, req MeasurementTypeUnitRequest) (*model.MeasurementType, error)
+
+	GetMeasurement(id uint) (*model.Measurement, error)
+	GetMeasurementsByOccurrence(occurrenceID uint) ([]model.Measurement, error)
+	GetMeasurementsBySpecimen(specimenID uint) ([]model.Measurement, error)
+	CreateMeasurement(occurrenceID uint, req MeasurementRequest) (*model.Measurement, error)
+	UpdateMeasurement(id uint, req MeasurementRequest) (*model.Measurement, error)
+	DeleteMeasurement(id uint) error
+}
+
+type measurementService struct {
+	db   *gorm.DB
+	repo repository.MeasurementRepository
+}
+
+// NewMeasurementService は新しいサービスを生成するのだ
+func NewMeasurementService(db *gorm.DB, repo repository.MeasurementRepository) MeasurementService {
+	return &measurementService{db: db, repo: repo}
+}
+
+// GetMeasurementTypes は登録フォームに出す計測の種類を返すのだ
+func (s *measurementService) GetMeasurementTypes() ([]model.MeasurementType, error) {
+	return s.repo.FindAllTypes()
+}
+
+// GetMeasurementUnits は読み替えられる単位の一覧を返すのだ
+func (s *measurementService) GetMeasurementUnits() []MeasurementUnit {
+	return measurementUnits
+}
+
+// UpdateTypeUnit は計測の種類の単位を変えて、そろえてある値も新しい単位に読み替えるのだ
+// 計測値があるときは、同じ次元の単位 (mm から cm など) にしか変えられないのだ
+func (s *measurementService) UpdateTypeUnit(typeID uint, req MeasurementTypeUnitRequest) (*model.MeasurementType, error) {
+	var unit *MeasurementUnit
+	if symbol := trimmedOrNil(req.Unit); symbol != nil {
+		u, ok := findMeasurementUnit(*symbol)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown unit %q", ErrInvalidMeasurement, *symbol)
+		}
+		unit = &u
+	}
+
+	var updated *model.MeasurementType
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		current, err := s.repo.FindTypeForUpdate(tx, typeID)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		factor := 1.0
+		if current.Unit != nil || unit != nil {
+			count, err := s.repo.CountByType(tx, typeID)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				factor, err = unitChangeFactor(current.Unit, unit)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		var symbol *string
+		if unit != nil {
+			symbol = &unit.Symbol
+		}
+		if err := s.repo.UpdateTypeUnit(tx, typeID, symbol, factor); err != nil {
+			return err
+		}
+		current.Unit = symbol
+		updated = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// unitChangeFactor は計測値のある種類の単位を from から to に変えるとき、そろえた値に掛ける数を返すのだ
+func unitChangeFactor(from *string, to *MeasurementUnit) (float64, error) {
+	if from == nil || to == nil {
+		return 0, fmt.Errorf("%w: cannot add or remove the unit of a type with measurements", ErrMeasurementUnitInUse)
+	}
+	current, ok := findMeasurementUnit(*from)
+	if !ok {
+		return 0, fmt.Errorf("%w: current unit %q cannot be converted", ErrMeasurementUnitInUse, *from)
+	}
+	factor, ok := convertUnit(1, current, *to)
+	if !ok {
+		return 0, fmt.Errorf("%w: cannot convert %s to %s", ErrMeasurementUnitInUse, current.Symbol, to.Symbol)
+	}
+	return factor, nil
+}
+
+func (s *measurementService) GetMeasurement(id uint) (*model.Measurement, error) {
+	measurement, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return measurement, nil
+}
+
+// GetMeasurementsByOccurrence は発生情報の計測値を、計測の種類の並び順で返すのだ
+func (s *measurementService) GetMeasurementsByOccurrence(occurrenceID uint) ([]model.Measurement, error) {
+	ok, err := s.repo.OccurrenceExists(occurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.repo.FindByOccurrence(occurrenceID)
+}
+
+// GetMeasurementsBySpecimen は標本を測った計測値を返すのだ
+func (s *measurementService) GetMeasurementsBySpecimen(specimenID uint) ([]model.Measurement, error) {
+	if _, err := s.repo.SpecimenOccurrenceID(specimenID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.repo.FindBySpecimen(specimenID)
+}
+
+// CreateMeasurement は発生情報に計測値を追加するのだ。標本を指定するときは、その発生情報の標本でないといけないのだ
+func (s *measurementService) CreateMeasurement(occurrenceID uint, req MeasurementRequest) (*model.Measurement, error) {
+	ok, err := s.repo.OccurrenceExists(occurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	measurement := &model.Measurement{OccurrenceID: occurrenceID}
+	if err := s.applyMeasurementRequest(measurement, req); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.normalizeValue(tx, measurement, true); err != nil {
+			return err
+		}
+		_, err := s.repo.Create(tx, measurement)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMeasurement(measurement.MeasurementID)
+}
+
+// UpdateMeasurement は計測値を書き換えるのだ。測った発生情報は変えられないのだ
+func (s *measurementService) UpdateMeasurement(id uint, req MeasurementRequest) (*model.Measurement, error) {
+	measurement, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	typeChanged := measurement.MeasurementTypeID != req.MeasurementTypeID
+	measurement.MeasurementType, measurement.MeasuredBy = nil, nil
+	if err := s.applyMeasurementRequest(measurement, req); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.normalizeValue(tx, measurement, typeChanged); err != nil {
+			return err
+		}
+		_, err := s.repo.Update(tx, measurement)
+		return err
+	})
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.GetMeasurement(id)
+}
+
+func (s *measurementService) DeleteMeasurement(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Delete(tx, id)
+	})
+}
+
+// applyMeasurementRequest はリクエストの内容を確かめて計測値に入れるのだ。値を単位でそろえるのは normalizeValue なのだ
+func (s *measurementService) applyMeasurementRequest(measurement *model.Measurement, req MeasurementRequest) error {
+	value := strings.TrimSpace(req.Value)
+	if value == "" {
+		return fmt.Errorf("%w: value is required", ErrInvalidMeasurement)
+	}
+	if req.SpecimenID != nil {
+		occurrenceID, err := s.repo.SpecimenOccurrenceID(*req.SpecimenID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: specimen %d not found", ErrInvalidMeasurement, *req.SpecimenID)
+		}
+		if err != nil {
+			return err
+		}
+		if occurrenceID != measurement.OccurrenceID {
+			return fmt.Errorf("%w: specimen %d is not from occurrence %d", ErrInvalidMeasurement, *req.SpecimenID, measurement.OccurrenceID)
+		}
+	}
+
+	measurement.SpecimenID = req.SpecimenID
+	measurement.MeasurementTypeID = req.MeasurementTypeID
+	measurement.Value = value
+	measurement.Unit = trimmedOrNil(req.Unit)
+	measurement.Accuracy = trimmedOrNil(req.Accuracy)
+	measurement.Method = trimmedOrNil(req.Method)
+	measurement.MeasuredByUserID = req.MeasuredByUserID
+	measurement.Remarks = trimmedOrNil(req.Remarks)
+	measurement.MeasuredOn = nil
+	if date := strings.TrimSpace(req.MeasuredOn); date != "" {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return fmt.Errorf("%w: measured_on must be YYYY-MM-DD", ErrInvalidMeasurement)
+		}
+		measurement.MeasuredOn = &t
+	}
+	return nil
+}
+
+// decimalPattern は計測値として受け付ける10進数の書き方なのだ (例: 12、-0.5、.5、1.2e3)
+// strconv.ParseFloat だけだと NaN・Inf・16進数 (0x1p4) も数になってしまうので、先に形を確かめるのだ
+var decimalPattern = regexp.MustCompile(`^[+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?$`)
+
+// parseDecimal は10進数の有限な数のときだけ数として読むのだ
+func parseDecimal(value string) (float64, bool) {
+	if !decimalPattern.MatchString(value) {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, false
+	}
+	return number, true
+}
+
+// normalizeValue は計測値を計測の種類の単位にそろえて value_number に入れるのだ
+// 種類に共有ロックをかけるので、そろえている間に種類の単位が変わることはないのだ
+// newType のときは、使うのをやめた種類を選べないようにするのだ
+func (s *measurementService) normalizeValue(tx *gorm.DB, measurement *model.Measurement, newType bool) error {
+	measurementType, err := s.repo.FindTypeForShare(tx, measurement.MeasurementTypeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: measurement type %d not found", ErrInvalidMeasurement, measurement.MeasurementTypeID)
+	}
+	if err != nil {
+		return err
+	}
+	if newType && measurementType.Deprecated {
+		return fmt.Errorf("%w: measurement type %q is deprecated", ErrInvalidMeasurement, measurementType.Name)
+	}
+
+	number, isNumber := parseDecimal(measurement.Value)
+	measurement.ValueNumber = nil
+
+	// 数でない形質の種類なのだ。数なら入力のまま、知っている単位なら書き方だけそろえるのだ
+	if measurementType.Unit == nil {
+		if measurement.Unit != nil {
+			if u, ok := findMeasurementUnit(*measurement.Unit); ok {
+				measurement.Unit = &u.Symbol
+			}
+		}
+		if isNumber {
+			measurement.ValueNumber = &number
+		}
+		return nil
+	}
+
+	if !isNumber {
+		return fmt.Errorf("%w: value of %q must be a number", ErrInvalidMeasurement, measurementType.Name)
+	}
+	if measurement.Unit == nil {
+		measurement.Unit = measurementType.Unit
+		measurement.ValueNumber = &number
+		return nil
+	}
+	typeUnit, typeKnown := findMeasurementUnit(*measurementType.Unit)
+	unit, known := findMeasurementUnit(*measurement.Unit)
+	if !typeKnown || !known {
+		// 読み替えられない単位は、種類と同じ書き方のときだけ受け付けるのだ
+		if !strings.EqualFold(*measurement.Unit, *measurementType.Unit) {
+			return fmt.Errorf("%w: unit %q cannot be converted to %s", ErrInvalidMeasurement, *measurement.Unit, *measurementType.Unit)
+		}
+		measurement.ValueNumber = &number
+		return nil
+	}
+	converted, ok := convertUnit(number, unit, typeUnit)
+	if !ok {
+		return fmt.Errorf("%w: unit %s cannot be converted to %s", ErrInvalidMeasurement, unit.Symbol, typeUnit.Symbol)
+	}
+	measurement.Unit = &unit.Symbol
+	measurement.ValueNumber = &converted
+	return nil
+}
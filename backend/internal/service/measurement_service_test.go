@@ -0,0 +1,35 @@
+// backend/internal/service/measurement_service_test.go
+package service
+
+import "testing"
+
+func TestParseDecimal(t *testing.T) {
+	valid := []struct {
+		input string
+		want  float64
+	}{
+		{"12", 12},
+		{"-3.5", -3.5},
+		{"+.5", 0.5},
+		{"1.", 1},
+		{"1e3", 1000},
+		{"2.5E-2", 0.025},
+	}
+	for _, tt := range valid {
+		got, ok := parseDecimal(tt.input)
+		if !ok || got != tt.want {
+			t.Errorf("parseDecimal(%q) = %v, %v, want %v", tt.input, got, ok, tt.want)
+		}
+	}
+
+	// ParseFloat が読めても、10進数の有限な数でないものは形質の値として扱うのだ
+	invalid := []string{
+		"", ".", " 12", "12 mm", "1,5", "0x10", "0x1p-2", "1_000",
+		"Inf", "-infinity", "NaN", "1e400", "١٢",
+	}
+	for _, input := range invalid {
+		if got, ok := parseDecimal(input); ok {
+			t.Errorf("parseDecimal(%q) = %v, want not a number", input, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// backend/internal/service/measurement_unit.go
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MeasurementUnit は計測値に使える単位なのだ
+// 同じ Dimension の単位どうしは Factor (基本の単位にしたときの倍率) で読み替えられるのだ
+type MeasurementUnit struct {
+	Symbol    string  `json:"symbol"`
+	Dimension string  `json:"dimension"`
+	Factor    float64 `json:"factor"`
+}
+
+// measurementUnits は読み替えられる単位の一覧なのだ。基本の単位は m・g・L・s なのだ
+var measurementUnits = []MeasurementUnit{
+	{Symbol: "μm", Dimension: "length", Factor: 1e-6},
+	{Symbol: "mm", Dimension: "length", Factor: 1e-3},
+	{Symbol: "cm", Dimension: "length", Factor: 1e-2},
+	{Symbol: "m", Dimension: "length", Factor: 1},
+	{Symbol: "km", Dimension: "length", Factor: 1e3},
+	{Symbol: "μg", Dimension: "mass", Factor: 1e-6},
+	{Symbol: "mg", Dimension: "mass", Factor: 1e-3},
+	{Symbol: "g", Dimension: "mass", Factor: 1},
+	{Symbol: "kg", Dimension: "mass", Factor: 1e3},
+	{Symbol: "μL", Dimension: "volume", Factor: 1e-6},
+	{Symbol: "mL", Dimension: "volume", Factor: 1e-3},
+	{Symbol: "L", Dimension: "volume", Factor: 1},
+	{Symbol: "s", Dimension: "time", Factor: 1},
+	{Symbol: "min", Dimension: "time", Factor: 60},
+	{Symbol: "h", Dimension: "time", Factor: 3600},
+	{Symbol: "d", Dimension: "time", Factor: 86400},
+}
+
+// unitAliases はキーボードで打ちやすい書き方から単位への読み替えなのだ
+var unitAliases = map[string]string{"um": "μm", "ug": "μg", "ul": "μL", "sec": "s", "hr": "h", "day": "d"}
+
+// findMeasurementUnit は入力された単位を一覧から探すのだ
+// 全角やマイクロ記号 (µ) はNFKCでそろえて、大文字小文字は区別しないのだ
+func findMeasurementUnit(symbol string) (MeasurementUnit, bool) {
+	symbol = strings.TrimSpace(norm.NFKC.String(symbol))
+	if alias, ok := unitAliases[strings.ToLower(symbol)]; ok {
+		symbol = alias
+	}
+	for _, u := range measurementUnits {
+		if strings.EqualFold(u.Symbol, symbol) {
+			return u, true
+		}
+	}
+	return MeasurementUnit{}, false
+}
+
+// convertUnit は from の単位の値を to の単位に読み替えるのだ。次元が違えば読み替えられないのだ
+func convertUnit(value float64, from, to MeasurementUnit) (float64, bool) {
+	if from.Dimension != to.Dimension {
+		return 0, false
+	}
+	if from.Symbol == to.Symbol {
+		return value, true
+	}
+	// 倍率の掛け算で出る 12.000000000000002 のような端数は、有効数字12桁で丸めて落とすのだ
+	converted, _ := strconv.ParseFloat(strconv.FormatFloat(value*from.Factor/to.Factor, 'g', 12, 64), 64)
+	return converted, true
+}
@@ -0,0 +1,71 @@
+// backend/internal/service/measurement_unit_test.go
+package service
+
+import "testing"
+
+func TestFindMeasurementUnit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"mm", "mm"},
+		{" MM ", "mm"},
+		{"ｍｍ", "mm"}, // 全角なのだ
+		{"µm", "μm"}, // マイクロ記号 (U+00B5) なのだ
+		{"μm", "μm"}, // ギリシャ文字のミュー (U+03BC) なのだ
+		{"um", "μm"},
+		{"UL", "μL"},
+		{"ml", "mL"},
+		{"sec", "s"},
+		{"hr", "h"},
+		{"day", "d"},
+		{"min", "min"},
+		{"kg", "kg"},
+	}
+	for _, tt := range tests {
+		got, ok := findMeasurementUnit(tt.input)
+		if !ok || got.Symbol != tt.want {
+			t.Errorf("findMeasurementUnit(%q) = %q, %v, want %q", tt.input, got.Symbol, ok, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "inch", "mmm", "°C"} {
+		if got, ok := findMeasurementUnit(input); ok {
+			t.Errorf("findMeasurementUnit(%q) = %q, want not found", input, got.Symbol)
+		}
+	}
+}
+
+func TestConvertUnit(t *testing.T) {
+	unit := func(symbol string) MeasurementUnit {
+		u, ok := findMeasurementUnit(symbol)
+		if !ok {
+			t.Fatalf("unit %q not found", symbol)
+		}
+		return u
+	}
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{12, "mm", "cm", 1.2},
+		{1.2, "cm", "mm", 12}, // 掛け算の端数 (12.000000000000002) が残らないのだ
+		{1.5, "kg", "g", 1500},
+		{250, "μL", "mL", 0.25},
+		{90, "min", "h", 1.5},
+		{2, "d", "h", 48},
+		{0.1, "m", "μm", 100000},
+		{3.3, "mm", "mm", 3.3},
+	}
+	for _, tt := range tests {
+		got, ok := convertUnit(tt.value, unit(tt.from), unit(tt.to))
+		if !ok || got != tt.want {
+			t.Errorf("convertUnit(%v, %s, %s) = %v, %v, want %v", tt.value, tt.from, tt.to, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := convertUnit(3, unit("mm"), unit("mg")); ok {
+		t.Error("convertUnit(mm, mg) should fail for different dimensions")
+	}
+}
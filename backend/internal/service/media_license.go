@@ -0,0 +1,63 @@
+// backend/internal/service/media_license.go
+package service
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidLicense は知らないライセンスが指定されたときのエラーなのだ
+var ErrInvalidLicense = errors.New("invalid license")
+
+// MediaLicense は添付ファイルに付けられるライセンスなのだ
+type MediaLicense struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+}
+
+// mediaLicenses は公開に使えるライセンスの一覧なのだ。GBIFが受け付けるクリエイティブ・コモンズに合わせてあるのだ
+var mediaLicenses = []MediaLicense{
+	{Code: "CC0-1.0", Name: "CC0 1.0 (パブリックドメイン)", URI: "http://creativecommons.org/publicdomain/zero/1.0/"},
+	{Code: "CC-BY-4.0", Name: "CC BY 4.0 (表示)", URI: "http://creativecommons.org/licenses/by/4.0/"},
+	{Code: "CC-BY-SA-4.0", Name: "CC BY-SA 4.0 (表示-継承)", URI: "http://creativecommons.org/licenses/by-sa/4.0/"},
+	{Code: "CC-BY-NC-4.0", Name: "CC BY-NC 4.0 (表示-非営利)", URI: "http://creativecommons.org/licenses/by-nc/4.0/"},
+	{Code: "CC-BY-NC-SA-4.0", Name: "CC BY-NC-SA 4.0 (表示-非営利-継承)", URI: "http://creativecommons.org/licenses/by-nc-sa/4.0/"},
+	{Code: "CC-BY-ND-4.0", Name: "CC BY-ND 4.0 (表示-改変禁止)", URI: "http://creativecommons.org/licenses/by-nd/4.0/"},
+	{Code: "CC-BY-NC-ND-4.0", Name: "CC BY-NC-ND 4.0 (表示-非営利-改変禁止)", URI: "http://creativecommons.org/licenses/by-nc-nd/4.0/"},
+}
+
+// findMediaLicense はコードからライセンスを探すのだ (大文字小文字は区別しないのだ)
+func findMediaLicense(code string) (MediaLicense, bool) {
+	for _, l := range mediaLicenses {
+		if strings.EqualFold(l.Code, strings.TrimSpace(code)) {
+			return l, true
+		}
+	}
+	return MediaLicense{}, false
+}
+
+// normalizeLicense は入力されたライセンスを正しいコードにそろえるのだ。空ならnil(ライセンスなし)なのだ
+func normalizeLicense(code string) (*string, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, nil
+	}
+	l, ok := findMediaLicense(code)
+	if !ok {
+		return nil, ErrInvalidLicense
+	}
+	return &l.Code, nil
+}
+
+// captureDeviceOf はEXIFのメーカーと機種から撮影機材の名前を作るのだ
+// 機種名にメーカー名が含まれていることが多いので、そのときは重ねないのだ
+func captureDeviceOf(maker, model string) string {
+	maker, model = strings.TrimSpace(maker), strings.TrimSpace(model)
+	if maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		return model
+	}
+	if model == "" {
+		return maker
+	}
+	return maker + " " + model
+}
@@ -0,0 +1,493 @@
+// backend/internal/service/molecular_service.go
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSample は試料や配列の内容が正しくないときのエラーなのだ (取り分けの順が逆、塩基記号でない文字など)
+var ErrInvalidSample = errors.New("invalid molecular sample")
+
+// ErrSampleInUse は取り分けた試料や配列がある試料を消そうとしたときのエラーなのだ
+var ErrSampleInUse = errors.New("molecular sample is in use")
+
+// ErrSampleCodeTaken は試料の番号や配列の登録番号がもう使われているときのエラーなのだ
+var ErrSampleCodeTaken = errors.New("sample code or accession already in use")
+
+// maxFASTARecords は一度に読み込める配列の数の上限なのだ
+const maxFASTARecords = 1000
+
+// ListSamplesRequest は試料の一覧のクエリパラメータなのだ
+type ListSamplesRequest struct {
+	SpecimenID   *uint  `form:"specimen_id"`
+	OccurrenceID *uint  `form:"occurrence_id"`
+	SampleType   string `form:"sample_type"`
+}
+
+// SampleRequest は試料の作成・更新のリクエストボディなのだ
+// 更新では specimen_id は変えられないので、送っても使わないのだ
+// PreparedDate は作った日 (2006-01-02) なのだ
+type SampleRequest struct {
+	SpecimenID           uint     `json:"specimen_id"`
+	ParentSampleID       *uint    `json:"parent_sample_id"`
+	SampleType           string   `json:"sample_type" binding:"required"`
+	SampleCode           *string  `json:"sample_code"`
+	StorageLocationID    *uint    `json:"storage_location_id"`
+	ConcentrationNgPerUl *float64 `json:"concentration_ng_per_ul"`
+	VolumeUl             *float64 `json:"volume_ul"`
+	ExtractionMethod     *string  `json:"extraction_method"`
+	UserID               *uint    `json:"user_id"`
+	PreparedDate         string   `json:"prepared_date"`
+	Note                 *string  `json:"note"`
+}
+
+// SequenceRequest は配列を1件追加するリクエストボディなのだ
+// Sequence は塩基だけでも、1件分のFASTAでもよいのだ。FASTAの見出しは definition が空なら definition にするのだ
+type SequenceRequest struct {
+	Marker          string  `json:"marker" binding:"required"`
+	AccessionSource *string `json:"accession_source"`
+	Accession       *string `json:"accession"`
+	Definition      *string `json:"definition"`
+	Sequence        string  `json:"sequence" binding:"required"`
+	UserID          *uint   `json:"user_id"`
+}
+
+// ImportFASTARequest はFASTAファイルから配列を読み込むときの条件なのだ
+// AccessionSource を指定すると、見出しの最初の語 (>MN123456.1 Carabus ... の MN123456.1) を登録番号にするのだ
+type ImportFASTARequest struct {
+	Marker          string
+	AccessionSource string
+	UserID          *uint
+}
+
+// ExportFASTARequest はFASTAの書き出しのクエリパラメータなのだ。条件を組み合わせて絞り込むのだ
+type ExportFASTARequest struct {
+	SequenceIDs  []uint `form:"sequence_id"`
+	SampleID     *uint  `form:"sample_id"`
+	SpecimenID   *uint  `form:"specimen_id"`
+	OccurrenceID *uint  `form:"occurrence_id"`
+	Marker       string `form:"marker"`
+}
+
+// MolecularService はDNA用の試料と塩基配列のビジネスロジックのインターフェースなのだ
+type MolecularService interface {
+	ListSamples(req ListSamplesRequest) ([]model.MolecularSample, error)
+	GetSample(id uint) (*model.MolecularSample, error)
+	CreateSample(req SampleRequest) (*model.MolecularSample, error)
+	UpdateSample(id uint, req SampleRequest) (*model.MolecularSample, error)
+	DeleteSample(id uint) error
+
+	GetSequence(id uint) (*model.Sequence, error)
+	AddSequence(sampleID uint, req SequenceRequest) (*model.Sequence, error)
+	ImportFASTA(sampleID uint, r io.Reader, req ImportFASTARequest) ([]model.Sequence, error)
+	ExportFASTA(w io.Writer, req ExportFASTARequest) error
+	DeleteSequence(id uint) error
+}
+
+type molecularService struct {
+	db                  *gorm.DB
+	repo                repository.MolecularRepository
+	specimenRepo        repository.SpecimenRepository
+	storageLocationRepo repository.StorageLocationRepository
+}
+
+// NewMolecularService は新しいサービスを生成するのだ
+func NewMolecularService(db *gorm.DB, repo repository.MolecularRepository, specimenRepo repository.SpecimenRepository, storageLocationRepo repository.StorageLocationRepository) MolecularService {
+	return &molecularService{db: db, repo: repo, specimenRepo: specimenRepo, storageLocationRepo: storageLocationRepo}
+}
+
+func (s *molecularService) ListSamples(req ListSamplesRequest) ([]model.MolecularSample, error) {
+	sampleType := strings.TrimSpace(req.SampleType)
+	if sampleType != "" && !slices.Contains(model.SampleTypes, sampleType) {
+		return nil, fmt.Errorf("%w: unknown sample_type %q", ErrInvalidSample, sampleType)
+	}
+	return s.repo.ListSamples(repository.SampleListParams{
+		SpecimenID:   req.SpecimenID,
+		OccurrenceID: req.OccurrenceID,
+		SampleType:   sampleType,
+	})
+}
+
+func (s *molecularService) GetSample(id uint) (*model.MolecularSample, error) {
+	sample, err := s.repo.FindSampleByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return sample, nil
+}
+
+func (s *molecularService) CreateSample(req SampleRequest) (*model.MolecularSample, error) {
+	if req.SpecimenID == 0 {
+		return nil, fmt.Errorf("%w: specimen_id is required", ErrInvalidSample)
+	}
+	if _, err := s.specimenRepo.FindByID(req.SpecimenID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: specimen %d not found", ErrInvalidSample, req.SpecimenID)
+		}
+		return nil, err
+	}
+	sample, err := s.newSample(0, req.SpecimenID, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.CreateSample(tx, sample)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSample(sample.SampleID)
+}
+
+// UpdateSample は試料を書き換えるのだ。取り分けた試料がある試料は、その試料より後の種類にはできないのだ
+func (s *molecularService) UpdateSample(id uint, req SampleRequest) (*model.MolecularSample, error) {
+	current, err := s.repo.FindSampleByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	sample, err := s.newSample(id, current.SpecimenID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.repo.ListSamples(repository.SampleListParams{SpecimenID: &current.SpecimenID})
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if child.ParentSampleID != nil && *child.ParentSampleID == id && sampleTypeRank(child.SampleType) < sampleTypeRank(sample.SampleType) {
+			return nil, fmt.Errorf("%w: %s cannot be taken from %s", ErrInvalidSample, child.SampleType, sample.SampleType)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.UpdateSample(tx, sample)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSample(id)
+}
+
+// DeleteSample は試料を削除するのだ
+// 配列は試料と一緒に消えてしまうので、配列や取り分けた試料が残っている試料は消さないのだ
+func (s *molecularService) DeleteSample(id uint) error {
+	sample, err := s.repo.FindSampleByID(id)
+	if err != nil {
+		return notFoundOr(err)
+	}
+	if len(sample.Sequences) > 0 {
+		return fmt.Errorf("%w: it still has %d sequences", ErrSampleInUse, len(sample.Sequences))
+	}
+	hasSubsamples, err := s.repo.HasSubsamples(id)
+	if err != nil {
+		return err
+	}
+	if hasSubsamples {
+		return fmt.Errorf("%w: other samples were taken from it", ErrSampleInUse)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.DeleteSample(tx, id)
+	})
+}
+
+// newSample はリクエストを確かめて試料を作るのだ。id が0なら新しい試料なのだ
+func (s *molecularService) newSample(id, specimenID uint, req SampleRequest) (*model.MolecularSample, error) {
+	sample := &model.MolecularSample{
+		SampleID:             id,
+		SpecimenID:           specimenID,
+		ParentSampleID:       req.ParentSampleID,
+		SampleType:           strings.TrimSpace(req.SampleType),
+		SampleCode:           trimmedOrNil(req.SampleCode),
+		StorageLocationID:    req.StorageLocationID,
+		ConcentrationNgPerUl: req.ConcentrationNgPerUl,
+		VolumeUl:             req.VolumeUl,
+		ExtractionMethod:     trimmedOrNil(req.ExtractionMethod),
+		UserID:               req.UserID,
+		Note:                 trimmedOrNil(req.Note),
+	}
+	if !slices.Contains(model.SampleTypes, sample.SampleType) {
+		return nil, fmt.Errorf("%w: sample_type must be one of %s", ErrInvalidSample, strings.Join(model.SampleTypes, ", "))
+	}
+	if v := sample.ConcentrationNgPerUl; v != nil && *v < 0 {
+		return nil, fmt.Errorf("%w: concentration_ng_per_ul must not be negative", ErrInvalidSample)
+	}
+	if v := sample.VolumeUl; v != nil && *v < 0 {
+		return nil, fmt.Errorf("%w: volume_ul must not be negative", ErrInvalidSample)
+	}
+	if date := strings.TrimSpace(req.PreparedDate); date != "" {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: prepared_date must be YYYY-MM-DD", ErrInvalidSample)
+		}
+		sample.PreparedDate = &t
+	}
+
+	if sample.SampleCode != nil {
+		taken, err := s.repo.SampleCodeTaken(*sample.SampleCode, id)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, fmt.Errorf("%w: sample_code %s", ErrSampleCodeTaken, *sample.SampleCode)
+		}
+	}
+	if sample.StorageLocationID != nil {
+		if _, err := s.storageLocationRepo.FindByID(*sample.StorageLocationID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: storage location %d not found", ErrInvalidSample, *sample.StorageLocationID)
+			}
+			return nil, err
+		}
+	}
+	if sample.ParentSampleID != nil {
+		if err := s.validateParent(sample); err != nil {
+			return nil, err
+		}
+	}
+	return sample, nil
+}
+
+// validateParent は取り分けた元の試料を確かめるのだ
+// 元は同じ標本の試料で、組織 > DNA抽出液 > PCR産物 の順を逆にたどらず、自分の下の試料でもないことを見るのだ
+func (s *molecularService) validateParent(sample *model.MolecularSample) error {
+	parentID := *sample.ParentSampleID
+	parent, err := s.repo.FindSampleByID(parentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: parent sample %d not found", ErrInvalidSample, parentID)
+		}
+		return err
+	}
+	if parent.SpecimenID != sample.SpecimenID {
+		return fmt.Errorf("%w: parent sample %d belongs to another specimen", ErrInvalidSample, parentID)
+	}
+	if sampleTypeRank(parent.SampleType) > sampleTypeRank(sample.SampleType) {
+		return fmt.Errorf("%w: %s cannot be taken from %s", ErrInvalidSample, sample.SampleType, parent.SampleType)
+	}
+	if sample.SampleID == 0 {
+		return nil
+	}
+	for ancestor := parent; ; {
+		if ancestor.SampleID == sample.SampleID {
+			return fmt.Errorf("%w: parent sample %d is taken from this sample", ErrInvalidSample, parentID)
+		}
+		if ancestor.ParentSampleID == nil {
+			return nil
+		}
+		if ancestor, err = s.repo.FindSampleByID(*ancestor.ParentSampleID); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *molecularService) GetSequence(id uint) (*model.Sequence, error) {
+	sequence, err := s.repo.FindSequenceByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return sequence, nil
+}
+
+// AddSequence は試料に配列を1件追加するのだ
+func (s *molecularService) AddSequence(sampleID uint, req SequenceRequest) (*model.Sequence, error) {
+	if _, err := s.repo.FindSampleByID(sampleID); err != nil {
+		return nil, notFoundOr(err)
+	}
+
+	text := strings.TrimSpace(req.Sequence)
+	if !strings.HasPrefix(text, ">") {
+		text = ">\n" + text
+	}
+	records, err := parseFASTA(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != 1 {
+		return nil, fmt.Errorf("%w: sequence must contain exactly one record", ErrInvalidSample)
+	}
+
+	definition := trimmedOrNil(req.Definition)
+	if definition == nil && records[0].Header != "" {
+		definition = &records[0].Header
+	}
+	sequence := model.Sequence{
+		SampleID:        sampleID,
+		Marker:          strings.TrimSpace(req.Marker),
+		AccessionSource: trimmedOrNil(req.AccessionSource),
+		Accession:       trimmedOrNil(req.Accession),
+		Definition:      definition,
+		Sequence:        records[0].Sequence,
+		UserID:          req.UserID,
+	}
+	created := []model.Sequence{sequence}
+	if err := s.createSequences(created); err != nil {
+		return nil, err
+	}
+	return s.GetSequence(created[0].SequenceID)
+}
+
+// ImportFASTA はFASTAファイルの配列をまとめて試料に追加するのだ。1件でも正しくなければ何も追加しないのだ
+func (s *molecularService) ImportFASTA(sampleID uint, r io.Reader, req ImportFASTARequest) ([]model.Sequence, error) {
+	if _, err := s.repo.FindSampleByID(sampleID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	records, err := parseFASTA(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: the file has no FASTA records", ErrInvalidSample)
+	}
+	if len(records) > maxFASTARecords {
+		return nil, fmt.Errorf("%w: at most %d records at once", ErrInvalidSample, maxFASTARecords)
+	}
+
+	source := strings.ToLower(strings.TrimSpace(req.AccessionSource))
+	sequences := make([]model.Sequence, 0, len(records))
+	for _, record := range records {
+		sequence := model.Sequence{
+			SampleID: sampleID,
+			Marker:   strings.TrimSpace(req.Marker),
+			Sequence: record.Sequence,
+			UserID:   req.UserID,
+		}
+		if record.Header != "" {
+			sequence.Definition = &record.Header
+		}
+		if source != "" {
+			accession, _, _ := strings.Cut(record.Header, " ")
+			if accession == "" {
+				return nil, fmt.Errorf("%w: a record has no accession in its header", ErrInvalidSample)
+			}
+			sequence.AccessionSource = &source
+			sequence.Accession = &accession
+		}
+		sequences = append(sequences, sequence)
+	}
+	if err := s.createSequences(sequences); err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(sequences))
+	for i, sequence := range sequences {
+		ids[i] = sequence.SequenceID
+	}
+	return s.repo.FindSequences(repository.SequenceListParams{SequenceIDs: ids})
+}
+
+// createSequences は配列の項目と登録番号の重なりを確かめてから追加するのだ。追加した配列にはIDが入るのだ
+func (s *molecularService) createSequences(sequences []model.Sequence) error {
+	seen := map[string]bool{}
+	for _, sequence := range sequences {
+		if sequence.Marker == "" {
+			return fmt.Errorf("%w: marker is required", ErrInvalidSample)
+		}
+		if sequence.Accession == nil {
+			continue
+		}
+		if sequence.AccessionSource == nil {
+			return fmt.Errorf("%w: accession_source is required with accession", ErrInvalidSample)
+		}
+		source, accession := *sequence.AccessionSource, *sequence.Accession
+		if source != model.AccessionSourceGenBank && source != model.AccessionSourceBOLD {
+			return fmt.Errorf("%w: accession_source must be %s or %s", ErrInvalidSample, model.AccessionSourceGenBank, model.AccessionSourceBOLD)
+		}
+		key := source + ":" + accession
+		if seen[key] {
+			return fmt.Errorf("%w: accession %s appears twice", ErrInvalidSample, accession)
+		}
+		seen[key] = true
+		taken, err := s.repo.AccessionTaken(source, accession)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("%w: %s accession %s", ErrSampleCodeTaken, source, accession)
+		}
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.CreateSequences(tx, sequences)
+	})
+}
+
+// ExportFASTA は条件に合う配列をFASTAで書き出すのだ
+// 見出しは >登録番号|学名|遺伝子領域|登録番号(GenBank・BOLD) の形で、学名の空白は _ にするのだ
+func (s *molecularService) ExportFASTA(w io.Writer, req ExportFASTARequest) error {
+	sequences, err := s.repo.FindSequences(repository.SequenceListParams{
+		SequenceIDs:  req.SequenceIDs,
+		SampleID:     req.SampleID,
+		SpecimenID:   req.SpecimenID,
+		OccurrenceID: req.OccurrenceID,
+		Marker:       strings.TrimSpace(req.Marker),
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, sequence := range sequences {
+		if err := writeFASTA(&buf, fastaRecord{Header: fastaHeaderOf(&sequence), Sequence: sequence.Sequence}); err != nil {
+			return err
+		}
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (s *molecularService) DeleteSequence(id uint) error {
+	if _, err := s.repo.FindSequenceByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.DeleteSequence(tx, id)
+	})
+}
+
+// fastaHeaderOf は配列の見出しを作るのだ。登録番号のない標本は specimen-{id} にするのだ
+// | は区切りに使うので、中身に出てくる | と空白は _ にするのだ
+func fastaHeaderOf(sequence *model.Sequence) string {
+	clean := func(value string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '|' || r == ' ' || r == '\t' {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(value))
+	}
+
+	label := "unknown"
+	name := "unidentified"
+	if sample := sequence.Sample; sample != nil && sample.Specimen != nil {
+		specimen := sample.Specimen
+		label = fmt.Sprintf("specimen-%d", specimen.SpecimenID)
+		if specimen.CatalogNumber != nil && *specimen.CatalogNumber != "" {
+			label = *specimen.CatalogNumber
+		}
+		if n := scientificNameOf(decodeClassification(specimen.Occurrence.ClassificationJSON)); n != "" {
+			name = n
+		}
+	}
+
+	parts := []string{clean(label), clean(name), clean(sequence.Marker)}
+	if sequence.Accession != nil {
+		parts = append(parts, clean(*sequence.Accession))
+	}
+	return strings.Join(parts, "|")
+}
+
+// sampleTypeRank は試料の種類が取り分けの順で何番目かなのだ
+func sampleTypeRank(sampleType string) int {
+	return slices.Index(model.SampleTypes, sampleType)
+}
@@ -2,28 +2,37 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/saku-730/specimen-web/backend/internal/model"
 	"github.com/saku-730/specimen-web/backend/internal/repository"
 	"gorm.io/gorm"
 )
 
-
+// ErrInvalidObservation は観察情報の内容が正しくないときのエラーなのだ (ない発生情報、日時の形が違うなど)
+var ErrInvalidObservation = errors.New("invalid observation")
 
 // CreateObservationRequest は観察情報作成時のリクエストボディを表すのだ
+// ObservedAt は観察した日時 (2006-01-02T15:04、timezone の現地時刻) で、省略すると今なのだ
 type CreateObservationRequest struct {
 	UserID              uint   `json:"user_id"`
 	OccurrenceID        uint   `json:"occurrence_id"`
 	ObservationMethodID uint   `json:"observation_method_id"`
 	Behavior            string `json:"behavior"`
+	ObservedAt          string `json:"observed_at"`
 	Timezone            int16  `json:"timezone"`
 }
 
 // UpdateObservationRequest は観察情報更新時のリクエストボディを表すのだ
+// ObservedAt を省略すると観察した日時は変えないのだ
 type UpdateObservationRequest struct {
 	UserID              uint   `json:"user_id"`
 	OccurrenceID        uint   `json:"occurrence_id"`
 	ObservationMethodID uint   `json:"observation_method_id"`
 	Behavior            string `json:"behavior"`
+	ObservedAt          string `json:"observed_at"`
 	Timezone            int16  `json:"timezone"`
 }
 
@@ -31,6 +40,7 @@ type UpdateObservationRequest struct {
 type ObservationService interface {
 	GetObservationByID(id uint) (*model.Observation, error)
 	GetAllObservations() ([]model.Observation, error)
+	GetObservationsByOccurrence(occurrenceID uint) ([]model.Observation, error)
 	CreateObservation(req CreateObservationRequest) (*model.Observation, error)
 	UpdateObservation(id uint, req UpdateObservationRequest) (*model.Observation, error)
 	DeleteObservation(id uint) error
@@ -48,60 +58,89 @@ func NewObservationService(db *gorm.DB, repo repository.ObservationRepository) O
 }
 
 func (s *observationService) GetObservationByID(id uint) (*model.Observation, error) {
-	return s.repo.FindByID(id)
+	observation, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return observation, nil
 }
 
 func (s *observationService) GetAllObservations() ([]model.Observation, error) {
 	return s.repo.FindAll()
 }
 
+// GetObservationsByOccurrence は発生情報に付いた観察を、観察した順に返すのだ
+func (s *observationService) GetObservationsByOccurrence(occurrenceID uint) ([]model.Observation, error) {
+	ok, err := s.repo.OccurrenceExists(occurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.repo.FindByOccurrence(occurrenceID)
+}
+
 func (s *observationService) CreateObservation(req CreateObservationRequest) (*model.Observation, error) {
 	newObservation := &model.Observation{
 		UserID:              req.UserID,
 		OccurrenceID:        req.OccurrenceID,
 		ObservationMethodID: uintToPtr(req.ObservationMethodID),
 		Behavior:            req.Behavior,
+		ObservedAt:          time.Now(),
 		Timezone:            req.Timezone,
 	}
+	if err := s.applyObservedAt(newObservation, req.ObservedAt); err != nil {
+		return nil, err
+	}
+	if err := s.validateObservation(newObservation); err != nil {
+		return nil, err
+	}
 
-	var createdObservation *model.Observation
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var err error
-		createdObservation, err = s.repo.Create(tx, newObservation)
+		_, err := s.repo.Create(tx, newObservation)
 		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	return createdObservation, nil
+	return s.GetObservationByID(newObservation.ObservationsID)
 }
 
 func (s *observationService) UpdateObservation(id uint, req UpdateObservationRequest) (*model.Observation, error) {
-	var updatedObservation *model.Observation
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		target, err := s.repo.FindByID(id)
-		if err != nil {
-			return err
-		}
+	target, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
 
-		target.UserID = req.UserID
-		target.OccurrenceID = req.OccurrenceID
-		target.ObservationMethodID = uintToPtr(req.ObservationMethodID)
-		target.Behavior = req.Behavior
-		target.Timezone = req.Timezone
+	target.UserID = req.UserID
+	target.OccurrenceID = req.OccurrenceID
+	target.ObservationMethodID = uintToPtr(req.ObservationMethodID)
+	target.Behavior = req.Behavior
+	target.Timezone = req.Timezone
+	if err := s.applyObservedAt(target, req.ObservedAt); err != nil {
+		return nil, err
+	}
+	if err := s.validateObservation(target); err != nil {
+		return nil, err
+	}
 
-		updatedObservation, err = s.repo.Update(tx, target)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, target)
 		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	return updatedObservation, nil
+	return s.GetObservationByID(id)
 }
 
 func (s *observationService) DeleteObservation(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		return s.repo.Delete(tx, id)
 	})
@@ -109,9 +148,42 @@ func (s *observationService) DeleteObservation(id uint) error {
 
 // GetAllObservationMethods は全ての観察方法を取得するのだ
 func (s *observationService) GetAllObservationMethods() ([]model.ObservationMethod, error) {
-	var methods []model.ObservationMethod
-	if err := s.db.Find(&methods).Error; err != nil {
-		return nil, err
+	return s.repo.FindAllMethods()
+}
+
+// applyObservedAt は観察した日時を現地時刻として読むのだ。空なら今の値のままにするのだ
+func (s *observationService) applyObservedAt(observation *model.Observation, observedAt string) error {
+	if observedAt == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation(formDateTimeLayout, observedAt, time.FixedZone("", int(observation.Timezone)*3600))
+	if err != nil {
+		return fmt.Errorf("%w: observed_at must be YYYY-MM-DDThh:mm", ErrInvalidObservation)
+	}
+	observation.ObservedAt = t
+	return nil
+}
+
+// validateObservation は観察者と、付ける発生情報・観察方法があるか確かめるのだ
+func (s *observationService) validateObservation(observation *model.Observation) error {
+	if observation.UserID == 0 {
+		return fmt.Errorf("%w: user_id is required", ErrInvalidObservation)
+	}
+	ok, err := s.repo.OccurrenceExists(observation.OccurrenceID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: occurrence %d not found", ErrInvalidObservation, observation.OccurrenceID)
+	}
+	if observation.ObservationMethodID != nil {
+		ok, err := s.repo.MethodExists(*observation.ObservationMethodID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: observation method %d not found", ErrInvalidObservation, *observation.ObservationMethodID)
+		}
 	}
-	return methods, nil
+	return nil
 }
@@ -2,6 +2,11 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/saku-730/specimen-web/backend/internal/model"
@@ -12,12 +17,18 @@ import (
 
 // --- Structs for Occurrence Search ---
 
+// ErrInvalidSearchRequest は検索条件の形式が正しくないときのエラーなのだ
+var ErrInvalidSearchRequest = errors.New("invalid search parameters")
+
+// ErrInvalidPlace は場所の標高・水深・誤差半径などの入力が正しくないときのエラーなのだ
+var ErrInvalidPlace = errors.New("invalid place")
+
 type SearchRequest struct {
 //user_id
-	UserID      *uint   `form:"occ_user_id"`
+	UserID            *uint `form:"occ_user_id"`
 	ObservationUserID *uint `form:"obs_user_id"`
-	SpecimenUserID *uint `form:"spc_user_id"`
-	IdentUserID    *uint   `form:"ide_user_id"`
+	SpecimenUserID    *uint `form:"spc_user_id"`
+	IdentUserID       *uint `form:"ide_user_id"`
 //classification
 	Kingdom *string `form:"kingdom"`
 	Phylum  *string `form:"phylum"`
@@ -26,38 +37,70 @@ type SearchRequest struct {
 	Family  *string `form:"family"`
 	Genus   *string `form:"genus"`
 	Species *string `form:"species"`
-//Observation
-	ObsMethod     *string   `form:"obs_method"`
-	OccDataStart  *string   `form:"occ_date_start"`
-	OccDataend  *string   `form:"occ_date_end"`
-	ObsDataStart  *string   `form:"obs_date_start"`
-	ObsDataend  *string   `form:"obs_date_end"`
-	IdentDataStart  *string   `form:"ide_date_start"`
-	IdentDataEnd  *string   `form:"ide_date_end"`
-	SpecimenDataStart  *string   `form:"spc_date_start"`
-	SpecimenDataEnd  *string   `form:"spc_date_end"`
+//date (YYYY-MM-DD, 終了日はその日を含むのだ)
+	ObsMethod         *uint   `form:"obs_method"`
+	OccDateStart      *string `form:"occ_date_start"`
+	OccDateEnd        *string `form:"occ_date_end"`
+	ObsDateStart      *string `form:"obs_date_start"`
+	ObsDateEnd        *string `form:"obs_date_end"`
+	IdentDateStart    *string `form:"ide_date_start"`
+	IdentDateEnd      *string `form:"ide_date_end"`
+	SpecimenDateStart *string `form:"spc_date_start"`
+	SpecimenDateEnd   *string `form:"spc_date_end"`
 //
-	ProjectID  *string `form:"procject_id"`
+	ProjectID      *uint   `form:"project_id"`
 	SpcMethod      *uint   `form:"spc_method"`
-	UserID      *uint   `form:"occ_user_id"`
-	UserID      *uint   `form:"occ_user_id"`
-	UserID      *uint   `form:"occ_user_id"`
-	UserID      *uint   `form:"occ_user_id"`
-	UserID      *uint   `form:"occ_user_id"`
+	InstitutionID  *uint   `form:"institution_id"`
+	CollectionID   *uint   `form:"collection_id"`
+	SpecimenStatus *string `form:"spc_status"` // in_house か on_loan なのだ
+	Lifestage      *string `form:"lifestage"`
+	Sex            *string `form:"sex"`
+	Note           *string `form:"note"`
+	Behavior       *string `form:"behavior"`
+	SourceInfo     *string `form:"source_info"`
+	EventID        *uint   `form:"event_id"` // 下のまとまりの発生情報も探すのだ
+	IndividualID   *uint   `form:"individual_id"`
+//place
+	PlaceName    *string  `form:"place_name"`
+	ElevationMin *float64 `form:"elevation_min"`
+	ElevationMax *float64 `form:"elevation_max"`
+	DepthMin     *float64 `form:"depth_min"`
+	DepthMax     *float64 `form:"depth_max"`
+	Habitat      *string  `form:"habitat"`
+//measurement (範囲は計測の種類の単位なのだ)
+	MeasurementTypeID *uint    `form:"msr_type"`
+	MeasurementMin    *float64 `form:"msr_min"`
+	MeasurementMax    *float64 `form:"msr_max"`
+//paging
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
 }
 
 
 type SearchResponse struct {
-	UserID      *uint
+	OccurrenceID uint             `json:"occurrence_id"`
+	Note         string           `json:"note"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UserName     string           `json:"user_name"`
+	ProjectName  string           `json:"project_name"`
+	Species      string           `json:"species"`
+	Specimens    []SearchSpecimen `json:"specimens"`
+}
+
+// SearchSpecimen は検索結果に付ける標本の番号と貸し出しの状態なのだ
+type SearchSpecimen struct {
+	SpecimenID    uint    `json:"specimen_id"`
+	CatalogNumber *string `json:"catalog_number"`
+	Status        string  `json:"status"`
 }
 
 type ClassificationJSONB struct {
-	Kingdom string `json;"kingdom"`
-	Phylum string `json;"phylum"`
-	Class string `json;"class"`
-	Order string `json;"order"`
-	Family string `json;"family"`
-	Genus string `json;"genus"`
+	Kingdom string `json:"kingdom"`
+	Phylum  string `json:"phylum"`
+	Class   string `json:"class"`
+	Order   string `json:"order"`
+	Family  string `json:"family"`
+	Genus   string `json:"genus"`
 	Species string `json:"species"`
 	//others string `json:"others"`
 }
@@ -73,19 +116,22 @@ type FullOccurrenceRequest struct {
 	Specimen       SpecimenPayload       `json:"specimen"`
 	MakeSpecimen   MakeSpecimenPayload   `json:"make_specimen"`
 	Identification IdentificationPayload `json:"identification"`
+	Parts          []PartPayload         `json:"parts"` // 同じ個体から取り分ける標本 (交尾器のプレパラート・組織など) なのだ
 }
 
 type OccurrencePayload struct {
-	ProjectID    uint      `json:"project_id"`
-	UserID       uint      `json:"user_id"`
-	IndividualID *int      `json:"individual_id"`
-	Lifestage    string    `json:"lifestage"`	
-	Sex          string    `json:"sex"`
-	BodyLength   *float64  `json:"body_length"`
-	CreatedAt    string    `json:"created_at"`
-	Timezone     int16     `json:"timezone"`
-	LanguageID   uint      `json:"language_id"`
-	Note         string    `json:"note"`
+	ProjectID       uint      `json:"project_id"`
+	UserID          uint      `json:"user_id"`
+	IndividualID    *uint     `json:"individual_id"`
+	EventID         *uint     `json:"event_id"`
+	IndividualCount *int      `json:"individual_count"` // 数えた個体の数なのだ。省略すると1匹なのだ
+	Lifestage       string    `json:"lifestage"`	
+	Sex             string    `json:"sex"`
+	BodyLength      *float64  `json:"body_length"`
+	CreatedAt       string    `json:"created_at"`
+	Timezone        int16     `json:"timezone"`
+	LanguageID      uint      `json:"language_id"`
+	Note            string    `json:"note"`
 }
 
 type ClassificationPayload struct {
@@ -94,6 +140,20 @@ type ClassificationPayload struct {
 
 type PlacePayload struct {
 	Coordinates   string `json:"coordinates"`
+	Accuracy      *float64 `json:"accuracy"` // 誤差半径(m)なのだ。写真のGPSの誤差などを入れるのだ
+	// 度分秒・UTM・MGRS・メッシュコードなどで書かれた座標。あればCoordinatesより優先するのだ
+	VerbatimCoordinates string `json:"verbatim_coordinates"`
+	Datum               string `json:"datum"`
+	// 標高・水深(m)と生息環境、ジオリファレンスの記録なのだ
+	MinimumElevationInMeters *float64 `json:"minimum_elevation_in_meters"`
+	MaximumElevationInMeters *float64 `json:"maximum_elevation_in_meters"`
+	MinimumDepthInMeters     *float64 `json:"minimum_depth_in_meters"`
+	MaximumDepthInMeters     *float64 `json:"maximum_depth_in_meters"`
+	Habitat                  string   `json:"habitat"`
+	VerbatimLocality         string   `json:"verbatim_locality"`
+	GeoreferenceProtocol     string   `json:"georeference_protocol"`
+	GeoreferencedBy          string   `json:"georeferenced_by"`
+	GeoreferencedDate        string   `json:"georeferenced_date"` // YYYY-MM-DD
 	PlaceNameJSON struct {
 		ClassPlaceName datatypes.JSON `json:"class_place_name"`
 	} `json:"place_name_json"`
@@ -118,6 +178,21 @@ type MakeSpecimenPayload struct {
 	Date      string `json:"date"`
 	CreatedAt string `json:"created_at"`
 	Timezone  int16  `json:"timezone"`
+	// 省略すると標本の作製方法と done を使うのだ
+	SpecimenMethodID *uint  `json:"specimen_method_id"`
+	Status           string `json:"status"`
+	Note             string `json:"note"`
+}
+
+// PartPayload は本体の標本から取り分ける標本1つと、その作製なのだ
+// 機関とコレクションは本体と同じにするのだ。Date を省略すると作製日なしで、Status を省略すると pending なのだ
+type PartPayload struct {
+	Part             string `json:"part"`
+	SpecimenMethodID uint   `json:"specimen_method_id"`
+	UserID           uint   `json:"user_id"`
+	Date             string `json:"date"`
+	Status           string `json:"status"`
+	Note             string `json:"note"`
 }
 
 type IdentificationPayload struct {
@@ -128,58 +203,183 @@ type IdentificationPayload struct {
 }
 
 
+// FullOccurrenceResponse は一括登録の結果を表すのだ
+type FullOccurrenceResponse struct {
+	OccurrenceID      uint               `json:"occurrence_id"`
+	PlaceNameWarnings []PlaceNameWarning `json:"place_name_warnings"`
+}
+
 //---
 
 type OccurrenceService interface {
 	GetAllLanguages() ([]model.Language, error)
-	CreateFullOccurrence(req FullOccurrenceRequest) error
+	CreateFullOccurrence(req FullOccurrenceRequest) (*FullOccurrenceResponse, error)
 	Search(req SearchRequest)([]SearchResponse, error)
 }
 
 type occurrenceService struct {
-	db   *gorm.DB
-	repo repository.OccurrenceRepository
+	db          *gorm.DB
+	repo        repository.OccurrenceRepository
+	geocoder    GeocodingService
+	coordinates CoordinateService
+	terms       ControlledTermService
 }
 
 
 // NewOccurrenceService は新しいサービスを生成するのだ
-func NewOccurrenceService(db *gorm.DB, repo repository.OccurrenceRepository) OccurrenceService {
-	return &occurrenceService{db: db, repo: repo}
+func NewOccurrenceService(db *gorm.DB, repo repository.OccurrenceRepository, geocoder GeocodingService, coordinates CoordinateService, terms ControlledTermService) OccurrenceService {
+	return &occurrenceService{db: db, repo: repo, geocoder: geocoder, coordinates: coordinates, terms: terms}
 }
 
 // Search
 
-func(s *occurrenceService) Search(req SeqrchRequest)([]SearchResponse, error){
-
-	repoParams := repository.SearchParams{
-		UserID:		req.UserID,
-
+func (s *occurrenceService) Search(req SearchRequest) ([]SearchResponse, error) {
+	repoParams, err := toSearchParams(req)
+	if err != nil {
+		return nil, err
 	}
+	// 件数の指定がなくても一度に全件は返さないのだ
+	if repoParams.Limit <= 0 {
+		repoParams.Limit = defaultSearchLimit
+	}
+	repoParams.Limit = min(repoParams.Limit, maxSearchLimit)
+	repoParams.Offset = max(repoParams.Offset, 0)
 
-	raw_results, err := s.occurrenceRepo.Search(repoParams)
-	if err != nil{
+	raw_results, err := s.repo.Search(*repoParams)
+	if err != nil {
 		return nil, err
 	}
-	responses := make([]SearchOccurrencesResponse, 0, len(occurrences))
+	responses := make([]SearchResponse, 0, len(raw_results))
 	for _, search_results := range raw_results {
 		// JSONBデータからの値の取り出し（安全なチェック）
-		var classificationData ClassificationJSONB
-
-		if search_results.ClassificationJSON != nil && search_results.ClassificationJSON.ClassClassification.Valid {
-			var classificationData ClassificationJSONB
-
-			json.Unmarshal(search_results.ClassificationJSON.ClassClassification.RawMessage, &classificationData)
+		classificationData := decodeClassification(search_results.ClassificationJSON)
 
-		dto := SearchOccurrencesResponse{
-			OccurrenceID: search_results.ID,
+		dto := SearchResponse{
+			OccurrenceID: search_results.OccurrenceID,
 			Note:         search_results.Note,
 			CreatedAt:    search_results.CreatedAt,
-			UserName:     search_results.User.UserName,       // Preloadしたデータを使う
-			ProjectName:  search_results.Project.ProjectName,   // Preloadしたデータを使う
 			Species:      classificationData.Species,
 		}
+		if search_results.User != nil {
+			dto.UserName = search_results.User.UserName // Preloadしたデータを使う
+		}
+		if search_results.Project != nil {
+			dto.ProjectName = search_results.Project.ProjectName // Preloadしたデータを使う
+		}
+		dto.Specimens = make([]SearchSpecimen, 0, len(search_results.Specimens))
+		for _, spc := range search_results.Specimens {
+			dto.Specimens = append(dto.Specimens, SearchSpecimen{
+				SpecimenID:    spc.SpecimenID,
+				CatalogNumber: spc.CatalogNumber,
+				Status:        spc.Status,
+			})
+		}
 		responses = append(responses, dto)
 	}
+	return responses, nil
+}
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// toSearchParams はクエリパラメータをリポジトリの検索条件に変換するのだ
+func toSearchParams(req SearchRequest) (*repository.SearchParams, error) {
+	params := &repository.SearchParams{
+		UserID:              req.UserID,
+		ObservationUserID:   req.ObservationUserID,
+		SpecimenUserID:      req.SpecimenUserID,
+		IdentUserID:         req.IdentUserID,
+		Classification:      map[string]string{},
+		ObservationMethodID: req.ObsMethod,
+		SpecimenMethodID:    req.SpcMethod,
+		ProjectID:           req.ProjectID,
+		EventID:             req.EventID,
+		IndividualID:        req.IndividualID,
+		InstitutionID:       req.InstitutionID,
+		CollectionID:        req.CollectionID,
+		SpecimenStatus:      req.SpecimenStatus,
+		Lifestage:           req.Lifestage,
+		Sex:                 req.Sex,
+		Note:                req.Note,
+		Behavior:            req.Behavior,
+		SourceInfo:          req.SourceInfo,
+		PlaceName:           req.PlaceName,
+		ElevationMin:        req.ElevationMin,
+		ElevationMax:        req.ElevationMax,
+		DepthMin:            req.DepthMin,
+		DepthMax:            req.DepthMax,
+		Habitat:             req.Habitat,
+		MeasurementTypeID:   req.MeasurementTypeID,
+		MeasurementMin:      req.MeasurementMin,
+		MeasurementMax:      req.MeasurementMax,
+		Limit:               req.Limit,
+		Offset:              req.Offset,
+	}
+
+	for key, value := range map[string]*string{
+		"kingdom": req.Kingdom, "phylum": req.Phylum, "class": req.Class, "order": req.Order,
+		"family": req.Family, "genus": req.Genus, "species": req.Species,
+	} {
+		if value != nil && *value != "" {
+			params.Classification[key] = *value
+		}
+	}
+
+	dates := []struct {
+		value     *string
+		target    **time.Time
+		inclusive bool
+	}{
+		{req.OccDateStart, &params.OccurrenceDateStart, false},
+		{req.OccDateEnd, &params.OccurrenceDateEnd, true},
+		{req.ObsDateStart, &params.ObservationDateStart, false},
+		{req.ObsDateEnd, &params.ObservationDateEnd, true},
+		{req.IdentDateStart, &params.IdentificationDateStart, false},
+		{req.IdentDateEnd, &params.IdentificationDateEnd, true},
+		{req.SpecimenDateStart, &params.SpecimenDateStart, false},
+		{req.SpecimenDateEnd, &params.SpecimenDateEnd, true},
+	}
+	for _, d := range dates {
+		if d.value == nil || *d.value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", *d.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSearchRequest, *d.value)
+		}
+		if d.inclusive {
+			t = t.AddDate(0, 0, 1) // 終了日の翌日0時より前を探すのだ
+		}
+		*d.target = &t
+	}
+
+	if st := req.SpecimenStatus; st != nil && *st != model.SpecimenStatusInHouse && *st != model.SpecimenStatusOnLoan {
+		return nil, fmt.Errorf("%w: spc_status must be %s or %s", ErrInvalidSearchRequest, model.SpecimenStatusInHouse, model.SpecimenStatusOnLoan)
+	}
+	if req.ElevationMin != nil && req.ElevationMax != nil && *req.ElevationMin > *req.ElevationMax {
+		return nil, fmt.Errorf("%w: elevation_min > elevation_max", ErrInvalidSearchRequest)
+	}
+	if req.DepthMin != nil && req.DepthMax != nil && *req.DepthMin > *req.DepthMax {
+		return nil, fmt.Errorf("%w: depth_min > depth_max", ErrInvalidSearchRequest)
+	}
+	if (req.MeasurementMin != nil || req.MeasurementMax != nil) && req.MeasurementTypeID == nil {
+		return nil, fmt.Errorf("%w: msr_min and msr_max need msr_type", ErrInvalidSearchRequest)
+	}
+	if req.MeasurementMin != nil && req.MeasurementMax != nil && *req.MeasurementMin > *req.MeasurementMax {
+		return nil, fmt.Errorf("%w: msr_min > msr_max", ErrInvalidSearchRequest)
+	}
+	return params, nil
+}
+
+// decodeClassification は分類JSONを構造体に取り出すのだ。壊れていたら空を返すのだ
+func decodeClassification(classification *model.ClassificationJSON) ClassificationJSONB {
+	var data ClassificationJSONB
+	if classification != nil && len(classification.ClassClassification) > 0 {
+		_ = json.Unmarshal(classification.ClassClassification, &data)
+	}
+	return data
 }
 
 // uintToPtr は uint が 0 でなければそのポインタを、0 なら nil を返すのだ
@@ -191,8 +391,38 @@ func uintToPtr(val uint) *uint {
 }
 
 // CreateFullOccurrence はフォームからの全データを受け取ってまとめて登録するのだ
-func (s *occurrenceService) CreateFullOccurrence(req FullOccurrenceRequest) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+func (s *occurrenceService) CreateFullOccurrence(req FullOccurrenceRequest) (*FullOccurrenceResponse, error) {
+	// 性別と成長段階は語彙の値にそろえるのだ。語彙にない書き方は ErrInvalidTerm なのだ
+	sex, err := s.terms.Canonicalize("sex", req.Occurrence.Sex)
+	if err != nil {
+		return nil, err
+	}
+	lifestage, err := s.terms.Canonicalize("lifestage", req.Occurrence.Lifestage)
+	if err != nil {
+		return nil, err
+	}
+	// 存在しない個体や採集のまとまりは外部キーで弾かれて500になるので、先に確かめて400にするのだ
+	if id := req.Occurrence.IndividualID; id != nil {
+		ok, err := s.repo.IndividualExists(*id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: individual_id %d does not exist", ErrInvalidIndividual, *id)
+		}
+	}
+	if id := req.Occurrence.EventID; id != nil {
+		ok, err := s.repo.EventExists(*id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: event_id %d does not exist", ErrInvalidEvent, *id)
+		}
+	}
+
+	response := &FullOccurrenceResponse{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		// 1. Create Classification
 		classification := model.ClassificationJSON{
 			ClassClassification: req.Classification.ClassClassification,
@@ -202,20 +432,47 @@ func (s *occurrenceService) CreateFullOccurrence(req FullOccurrenceRequest) erro
 		}
 
 		// 2. Create Place
-		placeName := model.PlaceNameJSON{
-			ClassPlaceName: req.Place.PlaceNameJSON.ClassPlaceName,
-		}
-		if err := tx.Create(&placeName).Error; err != nil {
+		place, err := newPlaceFromPayload(req.Place)
+		if err != nil {
 			return err
 		}
 		var coords *string
-		if req.Place.Coordinates != "POINT( )" {
+		if req.Place.VerbatimCoordinates != "" {
+			// 野帳の表記のまま送られてきた座標はWGS84に変換し、元の文字列も残すのだ
+			parsed, err := s.coordinates.Parse(req.Place.VerbatimCoordinates, req.Place.Datum)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidCoordinates, err)
+			}
+			wkt := parsed.WKT()
+			coords = &wkt
+			if req.Place.Accuracy == nil {
+				// 誤差半径が入力されていなければ、表記の精度から求めた値を使うのだ
+				place.Accuracy = parsed.Uncertainty
+			}
+			place.VerbatimCoordinates = parsed.Verbatim
+			place.VerbatimCoordinateSystem = parsed.Notation
+			place.VerbatimDatum = parsed.Datum
+		} else if req.Place.Coordinates != "POINT( )" {
 			coords = &req.Place.Coordinates
 		}
-		place := model.Place{
-			Coordinates: coords,
-			PlaceNameID: placeName.PlaceNameID,
+		classPlaceName := req.Place.PlaceNameJSON.ClassPlaceName
+		if coords != nil {
+			// 座標から国・都道府県・市区町村を求めて地名に書き足すのだ
+			annotated, warnings, err := s.geocoder.AnnotatePlaceName(*coords, classPlaceName)
+			if err != nil {
+				return err
+			}
+			classPlaceName = annotated
+			response.PlaceNameWarnings = warnings
 		}
+		placeName := model.PlaceNameJSON{
+			ClassPlaceName: classPlaceName,
+		}
+		if err := tx.Create(&placeName).Error; err != nil {
+			return err
+		}
+		place.Coordinates = coords
+		place.PlaceNameID = placeName.PlaceNameID
 		if err := tx.Create(&place).Error; err != nil {
 			return err
 		}
@@ -230,8 +487,10 @@ func (s *occurrenceService) CreateFullOccurrence(req FullOccurrenceRequest) erro
 			ProjectID:        uintToPtr(req.Occurrence.ProjectID),
 			UserID:           req.Occurrence.UserID,
 			IndividualID:     req.Occurrence.IndividualID,
-			Lifestage:        req.Occurrence.Lifestage,
-			Sex:              req.Occurrence.Sex,
+			EventID:          req.Occurrence.EventID,
+			IndividualCount:  req.Occurrence.IndividualCount,
+			Lifestage:        lifestage,
+			Sex:              sex,
 			ClassificationID: classification.ClassificationID,
 			PlaceID:          uintToPtr(place.PlaceID),
 			BodyLength:       req.Occurrence.BodyLength,
@@ -281,44 +540,147 @@ func (s *occurrenceService) CreateFullOccurrence(req FullOccurrenceRequest) erro
 		if err != nil {
 			return err
 		}
+		makeStatus, err := preparationStatusOf(req.MakeSpecimen.Status, model.PreparationStatusDone)
+		if err != nil {
+			return err
+		}
 		makeSpecimen := model.MakeSpecimen{
 			OccurrenceID:     occurrence.OccurrenceID,
 			UserID:           req.MakeSpecimen.UserID,
 			SpecimenID:       specimen.SpecimenID,
 			Date:             &makeDate,
-			SpecimenMethodID: uintToPtr(req.Specimen.SpecimenMethodID), // 標本テーブルのメソッドIDを流用
+			SpecimenMethodID: uintToPtr(req.Specimen.SpecimenMethodID), // 作製方法を省略したら標本テーブルのメソッドIDを流用
 			CreatedAt:        makeCreatedAt,
 			Timezone:         req.MakeSpecimen.Timezone,
+			Status:           makeStatus,
+			Note:             trimmedOrNil(&req.MakeSpecimen.Note),
+		}
+		if req.MakeSpecimen.SpecimenMethodID != nil {
+			makeSpecimen.SpecimenMethodID = req.MakeSpecimen.SpecimenMethodID
 		}
 		if err := tx.Create(&makeSpecimen).Error; err != nil {
 			return err
 		}
 
+		// 6'. Create Parts (本体から取り分ける標本と、その作製)
+		for _, p := range req.Parts {
+			partStatus, err := preparationStatusOf(p.Status, model.PreparationStatusPending)
+			if err != nil {
+				return err
+			}
+			part := model.Specimen{
+				OccurrenceID:     occurrence.OccurrenceID,
+				SpecimenMethodID: uintToPtr(p.SpecimenMethodID),
+				InstitutionID:    specimen.InstitutionID,
+				CollectionID:     specimen.CollectionID,
+				Part:             trimmedOrNil(&p.Part),
+				ParentSpecimenID: &specimen.SpecimenID,
+			}
+			if err := tx.Create(&part).Error; err != nil {
+				return err
+			}
+			preparation := model.MakeSpecimen{
+				OccurrenceID:     occurrence.OccurrenceID,
+				UserID:           p.UserID,
+				SpecimenID:       part.SpecimenID,
+				SpecimenMethodID: part.SpecimenMethodID,
+				CreatedAt:        makeCreatedAt,
+				Timezone:         req.MakeSpecimen.Timezone,
+				Status:           partStatus,
+				Note:             trimmedOrNil(&p.Note),
+			}
+			if p.Date != "" {
+				date, err := time.Parse("2006-01-02", p.Date)
+				if err != nil {
+					return fmt.Errorf("%w: parts date must be YYYY-MM-DD", ErrInvalidPreparation)
+				}
+				preparation.Date = &date
+			}
+			if err := tx.Create(&preparation).Error; err != nil {
+				return err
+			}
+		}
+
 		// 7. Create Identification
 		identificatedAt, err := time.Parse(layout, req.Identification.IdentificatedAt)
 		if err != nil {
 			return err
 		}
 		identification := model.Identification{
-			UserID:          req.Identification.UserID,
-			OccurrenceID:    occurrence.OccurrenceID,
-			SourceInfo:      req.Identification.SourceInfo,
-			IdentificatedAt: identificatedAt,
-			Timezone:        req.Identification.Timezone,
+			UserID:           req.Identification.UserID,
+			OccurrenceID:     occurrence.OccurrenceID,
+			SourceInfo:       req.Identification.SourceInfo,
+			ClassificationID: &classification.ClassificationID,
+			IdentificatedAt:  identificatedAt,
+			Timezone:         req.Identification.Timezone,
 		}
 		if err := tx.Create(&identification).Error; err != nil {
 			return err
 		}
 
+		response.OccurrenceID = occurrence.OccurrenceID
 		return nil 
 	})
+
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// newPlaceFromPayload は標高・水深などの場所の属性を検証して詰めるのだ (座標は別で扱うのだ)
+func newPlaceFromPayload(p PlacePayload) (model.Place, error) {
+	place := model.Place{
+		MinimumElevationInMeters: p.MinimumElevationInMeters,
+		MaximumElevationInMeters: p.MaximumElevationInMeters,
+		MinimumDepthInMeters:     p.MinimumDepthInMeters,
+		MaximumDepthInMeters:     p.MaximumDepthInMeters,
+		Habitat:                  p.Habitat,
+		VerbatimLocality:         p.VerbatimLocality,
+		GeoreferenceProtocol:     p.GeoreferenceProtocol,
+		GeoreferencedBy:          p.GeoreferencedBy,
+	}
+	if p.MinimumElevationInMeters != nil && p.MaximumElevationInMeters != nil && *p.MinimumElevationInMeters > *p.MaximumElevationInMeters {
+		return place, fmt.Errorf("%w: 最低標高が最高標高より大きくなっています", ErrInvalidPlace)
+	}
+	if (p.MinimumDepthInMeters != nil && *p.MinimumDepthInMeters < 0) || (p.MaximumDepthInMeters != nil && *p.MaximumDepthInMeters < 0) {
+		return place, fmt.Errorf("%w: 水深は0以上で入力してください", ErrInvalidPlace)
+	}
+	if p.MinimumDepthInMeters != nil && p.MaximumDepthInMeters != nil && *p.MinimumDepthInMeters > *p.MaximumDepthInMeters {
+		return place, fmt.Errorf("%w: 最小水深が最大水深より大きくなっています", ErrInvalidPlace)
+	}
+	if p.Accuracy != nil {
+		if *p.Accuracy < 0 {
+			return place, fmt.Errorf("%w: 誤差半径は0以上で入力してください", ErrInvalidPlace)
+		}
+		place.Accuracy = *p.Accuracy
+	}
+	if p.GeoreferencedDate != "" {
+		date, err := time.Parse("2006-01-02", p.GeoreferencedDate)
+		if err != nil {
+			return place, fmt.Errorf("%w: georeferenced_date は YYYY-MM-DD で入力してください", ErrInvalidPlace)
+		}
+		place.GeoreferencedDate = &date
+	}
+	return place, nil
 }
 
-// GetAllLanguages は全ての言語を取得するのだ
+// GetAllLanguages は使うのをやめていない言語を並び順で取得するのだ
 func (s *occurrenceService) GetAllLanguages() ([]model.Language, error) {
 	var languages []model.Language
-	if err := s.db.Find(&languages).Error; err != nil {
+	if err := s.db.Where("NOT deprecated").Order("sort_order, language_id").Find(&languages).Error; err != nil {
 		return nil, err
 	}
 	return languages, nil
 }
+
+// preparationStatusOf は作製の進み具合を確かめるのだ。空なら def なのだ
+func preparationStatusOf(status, def string) (string, error) {
+	if status == "" {
+		return def, nil
+	}
+	if !slices.Contains(model.PreparationStatuses, status) {
+		return "", fmt.Errorf("%w: status must be one of %s", ErrInvalidPreparation, strings.Join(model.PreparationStatuses, ", "))
+	}
+	return status, nil
+}
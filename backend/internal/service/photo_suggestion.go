@@ -0,0 +1,107 @@
+// backend/internal/service/photo_suggestion.go
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+)
+
+// PhotoSuggestion は写真のEXIFから作った入力フォームの初期値なのだ
+// 形は FullOccurrenceRequest に合わせてあるので、フロントエンドはそのまま埋め込めるのだ
+// 分からない項目は省略されるのだ
+type PhotoSuggestion struct {
+	Place       *PlaceSuggestion       `json:"place,omitempty"`
+	Occurrence  *OccurrenceSuggestion  `json:"occurrence,omitempty"`
+	Observation *ObservationSuggestion `json:"observation,omitempty"`
+
+	// TimezoneOffsetMinutes は時差(分)なのだ。timezone は時間単位なので、30分ずれる地域ではこちらを見るのだ
+	TimezoneOffsetMinutes *int   `json:"timezone_offset_minutes,omitempty"`
+	TimezoneSource        string `json:"timezone_source,omitempty"`
+}
+
+type PlaceSuggestion struct {
+	Coordinates              string   `json:"coordinates"`
+	Accuracy                 *float64 `json:"accuracy,omitempty"`
+	MinimumElevationInMeters *float64 `json:"minimum_elevation_in_meters,omitempty"`
+	MaximumElevationInMeters *float64 `json:"maximum_elevation_in_meters,omitempty"`
+}
+
+type OccurrenceSuggestion struct {
+	CreatedAt string `json:"created_at"`
+	Timezone  *int16 `json:"timezone,omitempty"`
+}
+
+type ObservationSuggestion struct {
+	ObservedAt string `json:"observed_at"`
+	Timezone   *int16 `json:"timezone,omitempty"`
+}
+
+// formDateTimeLayout は登録フォームの日時の形式なのだ (現地時刻で、時差は timezone で別に持つのだ)
+const formDateTimeLayout = "2006-01-02T15:04"
+
+// applyPhotoMetadata はEXIFの撮影情報を添付ファイルの列に写すのだ
+func applyPhotoMetadata(attachment *model.Attachment, meta *PhotoMetadata) {
+	attachment.CameraMake = meta.CameraMake
+	attachment.CameraModel = meta.CameraModel
+	attachment.TakenAt = meta.TakenAt
+	if meta.OffsetMinutes != nil {
+		offset := int16(*meta.OffsetMinutes)
+		attachment.TakenAtOffsetMinutes = &offset
+	}
+	attachment.GPSLatitude = meta.Latitude
+	attachment.GPSLongitude = meta.Longitude
+	attachment.GPSAltitude = meta.Altitude
+	attachment.GPSAccuracy = meta.Accuracy
+}
+
+// suggestionFromAttachment は保存済みの撮影情報から入力フォームの初期値を作るのだ
+func suggestionFromAttachment(a *model.Attachment) *PhotoSuggestion {
+	meta := &PhotoMetadata{
+		TakenAt:   a.TakenAt,
+		Latitude:  a.GPSLatitude,
+		Longitude: a.GPSLongitude,
+		Altitude:  a.GPSAltitude,
+		Accuracy:  a.GPSAccuracy,
+	}
+	if a.TakenAtOffsetMinutes != nil {
+		minutes := int(*a.TakenAtOffsetMinutes)
+		meta.OffsetMinutes = &minutes
+	}
+	return suggestionFromMetadata(meta)
+}
+
+// suggestionFromMetadata はEXIFの撮影情報から入力フォームの初期値を作るのだ
+func suggestionFromMetadata(meta *PhotoMetadata) *PhotoSuggestion {
+	suggestion := &PhotoSuggestion{
+		TimezoneOffsetMinutes: meta.OffsetMinutes,
+		TimezoneSource:        meta.OffsetSource,
+	}
+
+	if meta.Latitude != nil && meta.Longitude != nil {
+		suggestion.Place = &PlaceSuggestion{
+			Coordinates:              fmt.Sprintf("POINT(%s %s)", formatFloat(meta.Longitude), formatFloat(meta.Latitude)),
+			Accuracy:                 meta.Accuracy,
+			MinimumElevationInMeters: meta.Altitude,
+			MaximumElevationInMeters: meta.Altitude,
+		}
+	}
+
+	if meta.TakenAt != nil {
+		local := meta.TakenAt.UTC()
+		var timezone *int16
+		if meta.OffsetMinutes != nil {
+			local = local.Add(time.Duration(*meta.OffsetMinutes) * time.Minute)
+			// この列は時間単位なので、ちょうど割り切れるときだけ入れるのだ
+			if *meta.OffsetMinutes%60 == 0 {
+				hours := int16(*meta.OffsetMinutes / 60)
+				timezone = &hours
+			}
+		}
+		text := local.Format(formDateTimeLayout)
+		suggestion.Occurrence = &OccurrenceSuggestion{CreatedAt: text, Timezone: timezone}
+		suggestion.Observation = &ObservationSuggestion{ObservedAt: text, Timezone: timezone}
+	}
+	return suggestion
+}
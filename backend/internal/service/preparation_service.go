@@ -0,0 +1,191 @@
+// backend/internal/service/preparation_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidPreparation は作製の記録の内容が正しくないときのエラーなのだ
+var ErrInvalidPreparation = errors.New("invalid preparation")
+
+// UpdatePreparationRequest は作製の記録の変更なのだ。省略した項目はそのままにするのだ
+// status を done にして date がなければ、今日を作製日にするのだ
+type UpdatePreparationRequest struct {
+	UserID           *uint   `json:"user_id"`
+	Date             *string `json:"date"` // 2006-01-02 で、空文字なら外すのだ
+	SpecimenMethodID *uint   `json:"specimen_method_id"`
+	Status           *string `json:"status"`
+	Note             *string `json:"note"`
+}
+
+// PreparationQueueRequest は作業待ちの一覧のクエリパラメータなのだ
+// status は , 区切りで、省略すると pending と in_progress なのだ
+type PreparationQueueRequest struct {
+	UserID *uint  `form:"user_id"`
+	Status string `form:"status"`
+}
+
+// PreparationQueue は作製する人1人分の作業待ちなのだ
+type PreparationQueue struct {
+	User         model.User           `json:"user"`
+	Count        int                  `json:"count"`
+	Preparations []model.MakeSpecimen `json:"preparations"`
+}
+
+// PreparationPart は個体から作った標本1つと、そこから取り分けた標本なのだ
+type PreparationPart struct {
+	*model.Specimen
+	Parts []*PreparationPart `json:"parts"`
+}
+
+// PreparationService は標本の作製の進み具合を扱うビジネスロジックのインターフェースなのだ
+type PreparationService interface {
+	GetOccurrencePreparations(occurrenceID uint) ([]*PreparationPart, error)
+	UpdatePreparation(id uint, req UpdatePreparationRequest) (*model.MakeSpecimen, error)
+	GetPreparationQueue(req PreparationQueueRequest) ([]PreparationQueue, error)
+}
+
+type preparationService struct {
+	db             *gorm.DB
+	specimenRepo   repository.SpecimenRepository
+	occurrenceRepo repository.OccurrenceRepository
+}
+
+// NewPreparationService は新しいサービスを生成するのだ
+func NewPreparationService(db *gorm.DB, specimenRepo repository.SpecimenRepository, occurrenceRepo repository.OccurrenceRepository) PreparationService {
+	return &preparationService{db: db, specimenRepo: specimenRepo, occurrenceRepo: occurrenceRepo}
+}
+
+// GetOccurrencePreparations は個体から作った標本を、取り分けた順の木にして作製の記録つきで返すのだ
+func (s *preparationService) GetOccurrencePreparations(occurrenceID uint) ([]*PreparationPart, error) {
+	if _, err := s.occurrenceRepo.FindByID(occurrenceID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	specimens, err := s.specimenRepo.FindByOccurrence(occurrenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[uint]*PreparationPart, len(specimens))
+	for i := range specimens {
+		parts[specimens[i].SpecimenID] = &PreparationPart{Specimen: &specimens[i], Parts: []*PreparationPart{}}
+	}
+	roots := []*PreparationPart{}
+	for i := range specimens {
+		part := parts[specimens[i].SpecimenID]
+		if parentID := specimens[i].ParentSpecimenID; parentID != nil {
+			if parent, ok := parts[*parentID]; ok {
+				parent.Parts = append(parent.Parts, part)
+				continue
+			}
+		}
+		roots = append(roots, part)
+	}
+	return roots, nil
+}
+
+// UpdatePreparation は作製の記録の作製者・日付・方法・進み具合を変えるのだ
+func (s *preparationService) UpdatePreparation(id uint, req UpdatePreparationRequest) (*model.MakeSpecimen, error) {
+	preparation, err := s.specimenRepo.FindMakeSpecimenByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+
+	if req.UserID != nil {
+		preparation.UserID = *req.UserID
+	}
+	if req.Date != nil {
+		preparation.Date = nil
+		if value := strings.TrimSpace(*req.Date); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidPreparation)
+			}
+			preparation.Date = &date
+		}
+	}
+	if req.SpecimenMethodID != nil {
+		ok, err := s.specimenRepo.MethodExists(*req.SpecimenMethodID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: specimen_method_id %d does not exist", ErrInvalidPreparation, *req.SpecimenMethodID)
+		}
+		preparation.SpecimenMethodID = req.SpecimenMethodID
+	}
+	if req.Status != nil {
+		if !slices.Contains(model.PreparationStatuses, *req.Status) {
+			return nil, fmt.Errorf("%w: status must be one of %s", ErrInvalidPreparation, strings.Join(model.PreparationStatuses, ", "))
+		}
+		preparation.Status = *req.Status
+	}
+	if req.Note != nil {
+		preparation.Note = trimmedOrNil(req.Note)
+	}
+	if preparation.Status == model.PreparationStatusDone && preparation.Date == nil {
+		date := today()
+		preparation.Date = &date
+	}
+
+	// 読み込んだ作製者や方法を書き戻さないように、列だけを渡すのだ
+	updated := &model.MakeSpecimen{
+		MakeSpecimenID:   id,
+		UserID:           preparation.UserID,
+		Date:             preparation.Date,
+		SpecimenMethodID: preparation.SpecimenMethodID,
+		Status:           preparation.Status,
+		Note:             preparation.Note,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.specimenRepo.UpdateMakeSpecimen(tx, updated)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.specimenRepo.FindMakeSpecimenByID(id)
+}
+
+// GetPreparationQueue はまだ終わっていない作製を、作製する人ごとにまとめて返すのだ
+func (s *preparationService) GetPreparationQueue(req PreparationQueueRequest) ([]PreparationQueue, error) {
+	statuses := []string{model.PreparationStatusPending, model.PreparationStatusInProgress}
+	if req.Status != "" {
+		statuses = nil
+		for _, status := range strings.Split(req.Status, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if !slices.Contains(model.PreparationStatuses, status) {
+				return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPreparation, status)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	preparations, err := s.specimenRepo.FindPreparations(repository.PreparationListParams{UserID: req.UserID, Statuses: statuses})
+	if err != nil {
+		return nil, err
+	}
+
+	// FindPreparations は作製する人の順に並んでいるので、変わったところで区切るのだ
+	queues := []PreparationQueue{}
+	for _, preparation := range preparations {
+		if n := len(queues); n == 0 || queues[n-1].User.UserID != preparation.UserID {
+			queues = append(queues, PreparationQueue{User: preparation.User})
+		}
+		queue := &queues[len(queues)-1]
+		queue.Preparations = append(queue.Preparations, preparation)
+		queue.Count++
+	}
+	return queues, nil
+}
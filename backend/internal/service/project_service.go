@@ -1,8 +1,10 @@
-
 // backend/internal/service/project_service.go
 package service
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/saku-730/specimen-web/backend/internal/model"
@@ -10,22 +12,50 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrInvalidProject はプロジェクトやメンバーの内容が正しくないときのエラーなのだ (名前がない、終わりの日が始まりより前など)
+var ErrInvalidProject = errors.New("invalid project")
+
+// ErrProjectInUse は発生情報が付いているプロジェクトを消そうとしたときのエラーなのだ
+var ErrProjectInUse = errors.New("project is in use")
+
+// ErrProjectMemberConflict はもうメンバーの人を追加したり、もう抜けたメンバーを抜けさせようとしたときのエラーなのだ
+var ErrProjectMemberConflict = errors.New("project member conflict")
+
 // CreateProjectRequest はプロジェクト作成時のリクエストボディを表すのだ
+// StartDay と FinishedDay は 2006-01-02 の形なのだ
 type CreateProjectRequest struct {
-	ProjectName string     `json:"project_name"`
-	Description string     `json:"description"`
-	StartDay     *time.Time `json:"start_day"`
-	FinishedDay  *time.Time `json:"finished_day"`
-	Note        string     `json:"note"`
+	ProjectName string `json:"project_name" binding:"required"`
+	Description string `json:"description"`
+	StartDay    string `json:"start_day"`
+	FinishedDay string `json:"finished_day"`
+	Note        string `json:"note"`
 }
 
-// UpdateProjectRequest はプロジェクト更新時のリクエストボディを表すのだ
+// UpdateProjectRequest はプロジェクト更新時のリクエストボディを表すのだ。省略した日は消すのだ
 type UpdateProjectRequest struct {
-	ProjectName string     `json:"project_name"`
-	Description string     `json:"description"`
-	StartDay     *time.Time `json:"start_day"`
-	FinishedDay  *time.Time `json:"finished_day"`
-	Note        string     `json:"note"`
+	ProjectName string `json:"project_name" binding:"required"`
+	Description string `json:"description"`
+	StartDay    string `json:"start_day"`
+	FinishedDay string `json:"finished_day"`
+	Note        string `json:"note"`
+}
+
+// AddProjectMemberRequest はメンバーを追加するリクエストボディなのだ。JoinDay を省略すると今日なのだ
+type AddProjectMemberRequest struct {
+	UserID  uint   `json:"user_id" binding:"required"`
+	JoinDay string `json:"join_day"`
+}
+
+// EndProjectMemberRequest はメンバーが抜けるリクエストボディなのだ。FinishDay を省略すると今日なのだ
+type EndProjectMemberRequest struct {
+	FinishDay string `json:"finish_day"`
+}
+
+// ProjectSummary はプロジェクトと、その発生情報のまとめなのだ
+type ProjectSummary struct {
+	ProjectID   uint   `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	*repository.ProjectSummary
 }
 
 // ProjectService はプロジェクト関連のビジネスロジックのインターフェースなのだ
@@ -35,6 +65,11 @@ type ProjectService interface {
 	CreateProject(req CreateProjectRequest) (*model.Project, error)
 	UpdateProject(id uint, req UpdateProjectRequest) (*model.Project, error)
 	DeleteProject(id uint) error
+	GetProjectSummary(id uint) (*ProjectSummary, error)
+
+	AddMember(projectID uint, req AddProjectMemberRequest) (*model.Project, error)
+	EndMember(projectID, memberID uint, req EndProjectMemberRequest) (*model.Project, error)
+	RemoveMember(projectID, memberID uint) (*model.Project, error)
 }
 
 type projectService struct {
@@ -49,7 +84,11 @@ func NewProjectService(db *gorm.DB, repo repository.ProjectRepository) ProjectSe
 
 // GetProjectByID はIDでプロジェクトを1件取得するのだ
 func (s *projectService) GetProjectByID(id uint) (*model.Project, error) {
-	return s.repo.FindByID(id)
+	project, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return project, nil
 }
 
 // GetAllProjects は全てのプロジェクトを取得するのだ
@@ -59,55 +98,202 @@ func (s *projectService) GetAllProjects() ([]model.Project, error) {
 
 // CreateProject は新しいプロジェクトを作成するのだ
 func (s *projectService) CreateProject(req CreateProjectRequest) (*model.Project, error) {
-	newProject := &model.Project{
-		ProjectName: req.ProjectName,
-		Description: req.Description,
-		StartDay:    req.StartDay,
-		FinishedDay: req.FinishedDay,
-		Note:        req.Note,
+	project, err := newProject(0, UpdateProjectRequest(req))
+	if err != nil {
+		return nil, err
 	}
 
-	var createdProject *model.Project
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var err error
-		createdProject, err = s.repo.Create(tx, newProject)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Create(tx, project)
 		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	return createdProject, nil
+	return s.GetProjectByID(project.ProjectID)
 }
 
 // UpdateProject はプロジェクトを更新するのだ
 func (s *projectService) UpdateProject(id uint, req UpdateProjectRequest) (*model.Project, error) {
-	var updatedProject *model.Project
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		target, err := s.repo.FindByID(id)
-		if err != nil {
-			return err
-		}
-
-		target.ProjectName = req.ProjectName
-		target.Description = req.Description
-		target.StartDay = req.StartDay
-		target.FinishedDay = req.FinishedDay
-		target.Note = req.Note
-
-		updatedProject, err = s.repo.Update(tx, target)
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	target, err := newProject(id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, target)
 		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	return updatedProject, nil
+	return s.GetProjectByID(id)
 }
 
 // DeleteProject はIDを元にプロジェクトを削除するのだ
+// 発生情報が付いているプロジェクトは、発生情報からたどれなくなるので消さないのだ
 func (s *projectService) DeleteProject(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	inUse, err := s.repo.HasOccurrences(id)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("%w: it still has occurrences", ErrProjectInUse)
+	}
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		return s.repo.Delete(tx, id)
 	})
 }
+
+// GetProjectSummary はプロジェクトの発生情報の数・種の数・期間と、記録の多い人を返すのだ
+func (s *projectService) GetProjectSummary(id uint) (*ProjectSummary, error) {
+	project, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	summary, err := s.repo.Summarize(id)
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectSummary{ProjectID: project.ProjectID, ProjectName: project.ProjectName, ProjectSummary: summary}, nil
+}
+
+// AddMember はプロジェクトにメンバーを追加するのだ。今メンバーの人は追加できないのだ
+func (s *projectService) AddMember(projectID uint, req AddProjectMemberRequest) (*model.Project, error) {
+	project, err := s.repo.FindByID(projectID)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	ok, err := s.repo.UserExists(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: user %d not found", ErrInvalidProject, req.UserID)
+	}
+	active, err := s.repo.IsActiveMember(projectID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, fmt.Errorf("%w: user %d is already a member of %s", ErrProjectMemberConflict, req.UserID, project.ProjectName)
+	}
+	joinDay, err := parseProjectDay("join_day", req.JoinDay)
+	if err != nil {
+		return nil, err
+	}
+	if joinDay == nil {
+		t := today()
+		joinDay = &t
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.AddMember(tx, &model.ProjectMember{ProjectID: projectID, UserID: req.UserID, JoinDay: joinDay})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProjectByID(projectID)
+}
+
+// EndMember はメンバーが抜けた日を記録するのだ
+func (s *projectService) EndMember(projectID, memberID uint, req EndProjectMemberRequest) (*model.Project, error) {
+	member, err := s.findMember(projectID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member.FinishDay != nil && !member.FinishDay.After(today()) {
+		return nil, fmt.Errorf("%w: the member already left on %s", ErrProjectMemberConflict, member.FinishDay.Format("2006-01-02"))
+	}
+	finishDay, err := parseProjectDay("finish_day", req.FinishDay)
+	if err != nil {
+		return nil, err
+	}
+	if finishDay == nil {
+		t := today()
+		finishDay = &t
+	}
+	if member.JoinDay != nil && finishDay.Before(*member.JoinDay) {
+		return nil, fmt.Errorf("%w: finish_day is before join_day", ErrInvalidProject)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.EndMember(tx, memberID, *finishDay)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProjectByID(projectID)
+}
+
+// RemoveMember はメンバーの記録を消すのだ。抜けた記録を残したいときは EndMember を使うのだ
+func (s *projectService) RemoveMember(projectID, memberID uint) (*model.Project, error) {
+	if _, err := s.findMember(projectID, memberID); err != nil {
+		return nil, err
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.RemoveMember(tx, memberID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProjectByID(projectID)
+}
+
+// findMember はプロジェクトのメンバーの記録を引くのだ。ほかのプロジェクトの記録は見つからないことにするのだ
+func (s *projectService) findMember(projectID, memberID uint) (*model.ProjectMember, error) {
+	member, err := s.repo.FindMemberByID(memberID)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	if member.ProjectID != projectID {
+		return nil, ErrNotFound
+	}
+	return member, nil
+}
+
+// newProject はリクエストを確かめてプロジェクトを作るのだ
+func newProject(id uint, req UpdateProjectRequest) (*model.Project, error) {
+	project := &model.Project{
+		ProjectID:   id,
+		ProjectName: strings.TrimSpace(req.ProjectName),
+		Description: req.Description,
+		Note:        req.Note,
+	}
+	if project.ProjectName == "" {
+		return nil, fmt.Errorf("%w: project_name is required", ErrInvalidProject)
+	}
+	var err error
+	if project.StartDay, err = parseProjectDay("start_day", req.StartDay); err != nil {
+		return nil, err
+	}
+	if project.FinishedDay, err = parseProjectDay("finished_day", req.FinishedDay); err != nil {
+		return nil, err
+	}
+	if project.StartDay != nil && project.FinishedDay != nil && project.FinishedDay.Before(*project.StartDay) {
+		return nil, fmt.Errorf("%w: finished_day is before start_day", ErrInvalidProject)
+	}
+	return project, nil
+}
+
+// parseProjectDay は 2006-01-02 の日付を読むのだ。空ならnilなのだ
+func parseProjectDay(field, value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be YYYY-MM-DD", ErrInvalidProject, field)
+	}
+	return &day, nil
+}
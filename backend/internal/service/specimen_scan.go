@@ -0,0 +1,232 @@
+// backend/internal/service/specimen_scan.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+)
+
+// ErrInvalidScanCode は読み取ったコードが登録番号にもURIにも見えないときのエラーなのだ
+var ErrInvalidScanCode = errors.New("invalid scan code")
+
+// maxInventoryCodes は棚卸しで一度に送れるコードの数の上限なのだ
+const maxInventoryCodes = 10000
+
+// InventoryRequest は棚卸しのリクエストボディなのだ
+// 読み取ったコードと、その場所にあるはずの標本の範囲 (機関・コレクション・登録番号の前方一致・置き場所) を送るのだ
+type InventoryRequest struct {
+	Codes               []string `json:"codes" binding:"required"`
+	InstitutionID       *uint    `json:"institution_id"`
+	CollectionID        *uint    `json:"collection_id"`
+	CatalogNumberPrefix string   `json:"catalog_number_prefix"`
+	StorageLocationID   *uint    `json:"storage_location_id"` // 下の置き場所にある標本も含めるのだ
+}
+
+// InventoryEntry は棚卸しの結果の標本1件なのだ。Code は読み取ったときのコードなのだ
+type InventoryEntry struct {
+	Code          string  `json:"code,omitempty"`
+	SpecimenID    uint    `json:"specimen_id"`
+	CatalogNumber *string `json:"catalog_number"`
+}
+
+// InventoryReport は棚卸しの結果なのだ
+//   - Found: 読み取れて、範囲の中にあった標本
+//   - OutOfScope: 読み取れたが、範囲の外の標本 (ほかの引き出しから紛れ込んだものなど)
+//   - Unknown: DBにない登録番号やURI
+//   - Unreadable: 登録番号にもURIにも見えないコード
+//   - Duplicates: 2回以上読み取ったコード
+//   - NotScanned: 範囲の中にあるはずなのに読み取られなかった標本
+type InventoryReport struct {
+	Scanned    int              `json:"scanned"`
+	Found      []InventoryEntry `json:"found"`
+	OutOfScope []InventoryEntry `json:"out_of_scope"`
+	Unknown    []string         `json:"unknown"`
+	Unreadable []string         `json:"unreadable"`
+	Duplicates []string         `json:"duplicates"`
+	NotScanned []InventoryEntry `json:"not_scanned"`
+}
+
+// scanTarget は読み取ったコードが指している標本なのだ。IDか登録番号のどちらかなのだ
+type scanTarget struct {
+	ID            uint
+	CatalogNumber string
+}
+
+// ResolveScan はラベルから読み取ったコード (登録番号かURI) から標本を引くのだ
+func (s *specimenService) ResolveScan(code string) (*model.Specimen, error) {
+	target, err := parseScanCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if target.CatalogNumber != "" {
+		return s.GetSpecimenByCatalogNumber(target.CatalogNumber)
+	}
+	return s.GetSpecimenByID(target.ID)
+}
+
+// Inventory は読み取ったコードの一覧と、範囲の中にあるはずの標本を突き合わせるのだ
+func (s *specimenService) Inventory(req InventoryRequest) (*InventoryReport, error) {
+	if len(req.Codes) > maxInventoryCodes {
+		return nil, fmt.Errorf("%w: at most %d codes at once", ErrInvalidScanCode, maxInventoryCodes)
+	}
+	// 範囲がないと全ての標本が「あるはずの標本」になってしまうので、どれか1つは指定してもらうのだ
+	if req.InstitutionID == nil && req.CollectionID == nil && strings.TrimSpace(req.CatalogNumberPrefix) == "" && req.StorageLocationID == nil {
+		return nil, fmt.Errorf("%w: specify institution_id, collection_id, catalog_number_prefix or storage_location_id", ErrInvalidScanCode)
+	}
+
+	report := &InventoryReport{
+		Found:      []InventoryEntry{},
+		OutOfScope: []InventoryEntry{},
+		Unknown:    []string{},
+		Unreadable: []string{},
+		Duplicates: []string{},
+		NotScanned: []InventoryEntry{},
+	}
+
+	// 読み取ったコードを解釈するのだ。同じ標本を2回読んだものは重複として1回に数えるのだ
+	type scanned struct {
+		code   string
+		target scanTarget
+	}
+	var scans []scanned
+	seen := map[string]bool{}
+	var ids []uint
+	var numbers []string
+	for _, code := range req.Codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		report.Scanned++
+		target, err := parseScanCode(code)
+		if err != nil {
+			report.Unreadable = append(report.Unreadable, code)
+			continue
+		}
+		key := target.key()
+		if seen[key] {
+			report.Duplicates = append(report.Duplicates, code)
+			continue
+		}
+		seen[key] = true
+		scans = append(scans, scanned{code: code, target: target})
+		if target.CatalogNumber != "" {
+			numbers = append(numbers, target.CatalogNumber)
+		} else {
+			ids = append(ids, target.ID)
+		}
+	}
+
+	matches, err := s.repo.FindSummariesByCodes(ids, numbers)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]repository.SpecimenSummary, len(matches))
+	byNumber := make(map[string]repository.SpecimenSummary, len(matches))
+	for _, m := range matches {
+		byID[m.SpecimenID] = m
+		if m.CatalogNumber != nil {
+			byNumber[strings.ToLower(*m.CatalogNumber)] = m
+		}
+	}
+
+	expected, err := s.repo.FindSummaries(repository.SpecimenListParams{
+		InstitutionID:     req.InstitutionID,
+		CollectionID:      req.CollectionID,
+		CatalogNumber:     strings.TrimSpace(req.CatalogNumberPrefix),
+		StorageLocationID: req.StorageLocationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	inScope := make(map[uint]bool, len(expected))
+	for _, e := range expected {
+		inScope[e.SpecimenID] = true
+	}
+
+	// 登録番号とURIで同じ標本を読んだときも重複にするのだ
+	found := map[uint]bool{}
+	for _, scan := range scans {
+		var match repository.SpecimenSummary
+		var ok bool
+		if scan.target.CatalogNumber != "" {
+			match, ok = byNumber[strings.ToLower(scan.target.CatalogNumber)]
+		} else {
+			match, ok = byID[scan.target.ID]
+		}
+		if !ok {
+			report.Unknown = append(report.Unknown, scan.code)
+			continue
+		}
+		if found[match.SpecimenID] {
+			report.Duplicates = append(report.Duplicates, scan.code)
+			continue
+		}
+		found[match.SpecimenID] = true
+
+		entry := InventoryEntry{Code: scan.code, SpecimenID: match.SpecimenID, CatalogNumber: match.CatalogNumber}
+		if inScope[match.SpecimenID] {
+			report.Found = append(report.Found, entry)
+		} else {
+			report.OutOfScope = append(report.OutOfScope, entry)
+		}
+	}
+
+	for _, e := range expected {
+		if !found[e.SpecimenID] {
+			report.NotScanned = append(report.NotScanned, InventoryEntry{SpecimenID: e.SpecimenID, CatalogNumber: e.CatalogNumber})
+		}
+	}
+	return report, nil
+}
+
+// parseScanCode は読み取ったコードを解釈するのだ
+// ラベルのURI (…/specimens/catalog/{登録番号} か …/specimens/{id}) は、ホスト名が変わっていても読めるようにパスだけを見るのだ
+// URIでなければ登録番号として扱うのだ
+func parseScanCode(code string) (scanTarget, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return scanTarget{}, fmt.Errorf("%w: empty code", ErrInvalidScanCode)
+	}
+	if !strings.Contains(code, "://") {
+		return scanTarget{CatalogNumber: code}, nil
+	}
+
+	u, err := url.Parse(code)
+	if err != nil {
+		return scanTarget{}, fmt.Errorf("%w: %s", ErrInvalidScanCode, code)
+	}
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	i := strings.LastIndex(path, "/specimens/")
+	if i < 0 {
+		return scanTarget{}, fmt.Errorf("%w: not a specimen URI: %s", ErrInvalidScanCode, code)
+	}
+	rest := path[i+len("/specimens/"):]
+
+	if number, ok := strings.CutPrefix(rest, "catalog/"); ok {
+		number, err := url.PathUnescape(number)
+		if err != nil || strings.TrimSpace(number) == "" {
+			return scanTarget{}, fmt.Errorf("%w: %s", ErrInvalidScanCode, code)
+		}
+		return scanTarget{CatalogNumber: number}, nil
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || id == 0 {
+		return scanTarget{}, fmt.Errorf("%w: not a specimen URI: %s", ErrInvalidScanCode, code)
+	}
+	return scanTarget{ID: uint(id)}, nil
+}
+
+// key は同じ標本を読んだかどうかを見分けるためのキーなのだ
+func (t scanTarget) key() string {
+	if t.CatalogNumber != "" {
+		return "n:" + strings.ToLower(t.CatalogNumber)
+	}
+	return "i:" + strconv.FormatUint(uint64(t.ID), 10)
+}
@@ -0,0 +1,578 @@
+// backend/internal/service/specimen_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSpecimen は標本の内容が正しくないときのエラーなのだ (存在しない発生情報や作製方法を指しているときなど)
+var ErrInvalidSpecimen = errors.New("invalid specimen")
+
+// ErrCatalogNumberTaken は登録番号がもう使われているときのエラーなのだ
+var ErrCatalogNumberTaken = errors.New("catalog number already in use")
+
+// ErrSpecimenOnLoan は貸し出し中の標本を消そうとしたときのエラーなのだ
+var ErrSpecimenOnLoan = errors.New("specimen is on loan")
+
+// ErrSpecimenHasParts は取り分けた標本がある標本を消そうとしたときのエラーなのだ
+var ErrSpecimenHasParts = errors.New("specimen has derived parts")
+
+// ErrCatalogNumberAssigned は登録番号が付いている標本に付け直そうとしたときのエラーなのだ
+var ErrCatalogNumberAssigned = errors.New("specimen already has a catalog number")
+
+// specimenCatalogNumberKey は登録番号の一意インデックスの名前なのだ
+const specimenCatalogNumberKey = "specimen_catalog_number_key"
+
+// ListSpecimensRequest は標本一覧のクエリパラメータなのだ
+type ListSpecimensRequest struct {
+	OccurrenceID      *uint  `form:"occurrence_id"`
+	InstitutionID     *uint  `form:"institution_id"`
+	CollectionID      *uint  `form:"collection_id"`
+	SpecimenMethodID  *uint  `form:"specimen_method_id"`
+	CatalogNumber     string `form:"catalog_number"`      // 前方一致なのだ
+	StorageLocationID *uint  `form:"storage_location_id"` // 下の置き場所にある標本も含めるのだ
+	Status            string `form:"status"`              // in_house か on_loan なのだ
+	Limit             int    `form:"limit"`
+	Offset            int    `form:"offset"`
+}
+
+// SpecimenList は標本一覧の1ページ分なのだ
+type SpecimenList struct {
+	Specimens []model.Specimen `json:"specimens"`
+	Total     int64            `json:"total"`
+	Limit     int              `json:"limit"`
+	Offset    int              `json:"offset"`
+}
+
+// CreateSpecimenRequest は標本作成時のリクエストボディを表すのだ
+// MakeSpecimen を付けると、作製の履歴も一緒に登録するのだ
+// CatalogNumber を省略すると、コレクションの形で採番するのだ (コレクションがなければ番号なしなのだ)
+// ParentSpecimenID を付けると、同じ個体の別の標本から取り分けた部位 (交尾器・組織など) になるのだ
+type CreateSpecimenRequest struct {
+	OccurrenceID     uint                       `json:"occurrence_id" binding:"required"`
+	CatalogNumber    string                     `json:"catalog_number"`
+	SpecimenMethodID *uint                      `json:"specimen_method_id"`
+	InstitutionID    *uint                      `json:"institution_id"`
+	CollectionID     *uint                      `json:"collection_id"`
+	Part             *string                    `json:"part"`
+	ParentSpecimenID *uint                      `json:"parent_specimen_id"`
+	MakeSpecimen     *CreateMakeSpecimenRequest `json:"make_specimen"`
+}
+
+// UpdateSpecimenRequest は標本更新時のリクエストボディを表すのだ。省略した項目は外すのだ
+type UpdateSpecimenRequest struct {
+	OccurrenceID     uint    `json:"occurrence_id" binding:"required"`
+	SpecimenMethodID *uint   `json:"specimen_method_id"`
+	InstitutionID    *uint   `json:"institution_id"`
+	CollectionID     *uint   `json:"collection_id"`
+	Part             *string `json:"part"`
+	ParentSpecimenID *uint   `json:"parent_specimen_id"`
+}
+
+// UpdateCatalogPatternRequest はコレクションの登録番号の形の変更なのだ。nullなら既定の形に戻すのだ
+type UpdateCatalogPatternRequest struct {
+	Pattern *string `json:"pattern"`
+}
+
+// CollectionWithExample はコレクションと、今の形で次に付く番号の見本なのだ
+type CollectionWithExample struct {
+	*model.CollectionIDCode
+	Example string `json:"example"`
+}
+
+// CreateMakeSpecimenRequest は標本作製の履歴1件なのだ
+// Date は作製日 (2006-01-02)、CreatedAt は記録日時 (2006-01-02T15:04) なのだ。方法を省略すると標本の作製方法を使うのだ
+// Status を省略すると作製が終わった記録 (done) なのだ。これからする作製は pending で登録するのだ
+type CreateMakeSpecimenRequest struct {
+	UserID           uint    `json:"user_id" binding:"required"`
+	Date             string  `json:"date"`
+	SpecimenMethodID *uint   `json:"specimen_method_id"`
+	CreatedAt        string  `json:"created_at"`
+	Timezone         int16   `json:"timezone"`
+	Status           string  `json:"status"`
+	Note             *string `json:"note"`
+}
+
+const (
+	defaultSpecimenLimit = 50
+	maxSpecimenLimit     = 500
+
+	// maxCatalogAttempts は手で付けた番号とぶつかったときに連番を進め直す回数の上限なのだ
+	maxCatalogAttempts = 100
+)
+
+// SpecimenService は標本関連のビジネスロジックのインターフェースなのだ
+type SpecimenService interface {
+	GetSpecimenByID(id uint) (*model.Specimen, error)
+	GetSpecimenByCatalogNumber(number string) (*model.Specimen, error)
+	ResolveScan(code string) (*model.Specimen, error)
+	Inventory(req InventoryRequest) (*InventoryReport, error)
+	ListSpecimens(req ListSpecimensRequest) (*SpecimenList, error)
+	CreateSpecimen(req CreateSpecimenRequest) (*model.Specimen, error)
+	UpdateSpecimen(id uint, req UpdateSpecimenRequest) (*model.Specimen, error)
+	DeleteSpecimen(id uint) error
+	AddMakeSpecimen(id uint, req CreateMakeSpecimenRequest) (*model.Specimen, error)
+	GetAllSpecimenMethods() ([]model.SpecimenMethod, error)
+	GetAllInstitutions() ([]model.InstitutionIDCode, error)
+
+	// 登録番号なのだ
+	AssignCatalogNumber(id uint) (*model.Specimen, error)
+	GetAllCollections() ([]CollectionWithExample, error)
+	UpdateCatalogPattern(collectionID uint, req UpdateCatalogPatternRequest) (*CollectionWithExample, error)
+}
+
+type specimenService struct {
+	db             *gorm.DB
+	repo           repository.SpecimenRepository
+	occurrenceRepo repository.OccurrenceRepository
+}
+
+// NewSpecimenService は新しいサービスを生成するのだ
+func NewSpecimenService(db *gorm.DB, repo repository.SpecimenRepository, occurrenceRepo repository.OccurrenceRepository) SpecimenService {
+	return &specimenService{db: db, repo: repo, occurrenceRepo: occurrenceRepo}
+}
+
+func (s *specimenService) GetSpecimenByID(id uint) (*model.Specimen, error) {
+	specimen, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return specimen, nil
+}
+
+// GetSpecimenByCatalogNumber はラベルの登録番号から標本を引くのだ
+func (s *specimenService) GetSpecimenByCatalogNumber(number string) (*model.Specimen, error) {
+	specimen, err := s.repo.FindByCatalogNumber(strings.TrimSpace(number))
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return specimen, nil
+}
+
+// ListSpecimens は機関・コレクション・作製方法などで絞り込んだ標本を新しい順に返すのだ
+func (s *specimenService) ListSpecimens(req ListSpecimensRequest) (*SpecimenList, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSpecimenLimit
+	}
+	limit = min(limit, maxSpecimenLimit)
+	offset := max(req.Offset, 0)
+
+	status := strings.TrimSpace(req.Status)
+	if status != "" && status != model.SpecimenStatusInHouse && status != model.SpecimenStatusOnLoan {
+		return nil, fmt.Errorf("%w: status must be %s or %s", ErrInvalidSpecimen, model.SpecimenStatusInHouse, model.SpecimenStatusOnLoan)
+	}
+
+	specimens, total, err := s.repo.List(repository.SpecimenListParams{
+		OccurrenceID:      req.OccurrenceID,
+		InstitutionID:     req.InstitutionID,
+		CollectionID:      req.CollectionID,
+		SpecimenMethodID:  req.SpecimenMethodID,
+		CatalogNumber:     strings.TrimSpace(req.CatalogNumber),
+		StorageLocationID: req.StorageLocationID,
+		Status:            status,
+		Limit:             limit,
+		Offset:            offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SpecimenList{Specimens: specimens, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+func (s *specimenService) CreateSpecimen(req CreateSpecimenRequest) (*model.Specimen, error) {
+	newSpecimen := &model.Specimen{
+		OccurrenceID:     req.OccurrenceID,
+		SpecimenMethodID: req.SpecimenMethodID,
+		InstitutionID:    req.InstitutionID,
+		CollectionID:     req.CollectionID,
+		Part:             trimmedOrNil(req.Part),
+		ParentSpecimenID: req.ParentSpecimenID,
+	}
+	if err := s.validateReferences(newSpecimen); err != nil {
+		return nil, err
+	}
+
+	var makeSpecimen *model.MakeSpecimen
+	if req.MakeSpecimen != nil {
+		var err error
+		makeSpecimen, err = s.newMakeSpecimen(newSpecimen, *req.MakeSpecimen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 登録番号は作成と同じトランザクションで決めるので、失敗したら連番も戻るのだ
+		if number := strings.TrimSpace(req.CatalogNumber); number != "" {
+			if err := validateCatalogNumber(number); err != nil {
+				return err
+			}
+			taken, err := s.repo.CatalogNumberExists(tx, number)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("%w: %s", ErrCatalogNumberTaken, number)
+			}
+			newSpecimen.CatalogNumber = &number
+		} else if newSpecimen.CollectionID != nil {
+			number, err := s.allocateCatalogNumber(tx, newSpecimen)
+			if err != nil {
+				return err
+			}
+			newSpecimen.CatalogNumber = &number
+		}
+
+		if _, err := s.repo.Create(tx, newSpecimen); err != nil {
+			return err
+		}
+		if makeSpecimen != nil {
+			makeSpecimen.SpecimenID = newSpecimen.SpecimenID
+			if _, err := s.repo.CreateMakeSpecimen(tx, makeSpecimen); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if isUniqueViolation(err, specimenCatalogNumberKey) {
+			// 確かめてから登録するまでの間に、同じ番号が登録されたのだ
+			return nil, fmt.Errorf("%w: %s", ErrCatalogNumberTaken, strings.TrimSpace(req.CatalogNumber))
+		}
+		return nil, err
+	}
+	return s.GetSpecimenByID(newSpecimen.SpecimenID)
+}
+
+func (s *specimenService) UpdateSpecimen(id uint, req UpdateSpecimenRequest) (*model.Specimen, error) {
+	if _, err := s.GetSpecimenByID(id); err != nil {
+		return nil, err
+	}
+
+	specimen := &model.Specimen{
+		SpecimenID:       id,
+		OccurrenceID:     req.OccurrenceID,
+		SpecimenMethodID: req.SpecimenMethodID,
+		InstitutionID:    req.InstitutionID,
+		CollectionID:     req.CollectionID,
+		Part:             trimmedOrNil(req.Part),
+		ParentSpecimenID: req.ParentSpecimenID,
+	}
+	if err := s.validateReferences(specimen); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, specimen)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSpecimenByID(id)
+}
+
+// DeleteSpecimen は標本と作製の履歴を削除するのだ。貸し出し中の標本は返ってくるまで消せないのだ
+func (s *specimenService) DeleteSpecimen(id uint) error {
+	specimen, err := s.GetSpecimenByID(id)
+	if err != nil {
+		return err
+	}
+	if specimen.Status == model.SpecimenStatusOnLoan {
+		return ErrSpecimenOnLoan
+	}
+	hasParts, err := s.repo.HasParts(id)
+	if err != nil {
+		return err
+	}
+	if hasParts {
+		return ErrSpecimenHasParts
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Delete(tx, id)
+	})
+}
+
+// AddMakeSpecimen は標本に作製の履歴を1件追加するのだ (展翅・再固定・液浸の入れ替えなど)
+func (s *specimenService) AddMakeSpecimen(id uint, req CreateMakeSpecimenRequest) (*model.Specimen, error) {
+	specimen, err := s.GetSpecimenByID(id)
+	if err != nil {
+		return nil, err
+	}
+	makeSpecimen, err := s.newMakeSpecimen(specimen, req)
+	if err != nil {
+		return nil, err
+	}
+	makeSpecimen.SpecimenID = specimen.SpecimenID
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.CreateMakeSpecimen(tx, makeSpecimen)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSpecimenByID(id)
+}
+
+func (s *specimenService) GetAllSpecimenMethods() ([]model.SpecimenMethod, error) {
+	return s.repo.FindAllMethods()
+}
+
+func (s *specimenService) GetAllInstitutions() ([]model.InstitutionIDCode, error) {
+	return s.repo.FindAllInstitutions()
+}
+
+// AssignCatalogNumber は登録番号のない標本 (番号ができる前に登録したものなど) にコレクションの形で番号を付けるのだ
+func (s *specimenService) AssignCatalogNumber(id uint) (*model.Specimen, error) {
+	specimen, err := s.GetSpecimenByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if specimen.CatalogNumber != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogNumberAssigned, *specimen.CatalogNumber)
+	}
+	if specimen.CollectionID == nil {
+		return nil, fmt.Errorf("%w: collection_id is required to allocate a catalog number", ErrInvalidSpecimen)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		number, err := s.allocateCatalogNumber(tx, specimen)
+		if err != nil {
+			return err
+		}
+		return s.repo.SetCatalogNumber(tx, id, number)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 同時に番号が付いたのだ
+			return nil, ErrCatalogNumberAssigned
+		}
+		if isUniqueViolation(err, specimenCatalogNumberKey) {
+			return nil, ErrCatalogNumberTaken
+		}
+		return nil, err
+	}
+	return s.GetSpecimenByID(id)
+}
+
+// GetAllCollections はコレクションと、次に付く番号の見本を返すのだ
+func (s *specimenService) GetAllCollections() ([]CollectionWithExample, error) {
+	collections, err := s.repo.FindAllCollections()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]CollectionWithExample, len(collections))
+	for i := range collections {
+		result[i] = CollectionWithExample{
+			CollectionIDCode: &collections[i],
+			Example:          renderCatalogNumber(catalogPatternOf(&collections[i]), exampleCatalogValues(&collections[i]), 1),
+		}
+	}
+	return result, nil
+}
+
+// UpdateCatalogPattern はコレクションの登録番号の形を変えるのだ
+// 連番は形を展開した範囲ごとに数えるので、形を変えても既存の番号と重ならないのだ
+func (s *specimenService) UpdateCatalogPattern(collectionID uint, req UpdateCatalogPatternRequest) (*CollectionWithExample, error) {
+	var pattern *string
+	if req.Pattern != nil && strings.TrimSpace(*req.Pattern) != "" {
+		trimmed := strings.TrimSpace(*req.Pattern)
+		if err := validateCatalogPattern(trimmed); err != nil {
+			return nil, err
+		}
+		pattern = &trimmed
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.UpdateCollectionPattern(tx, collectionID, pattern)
+	})
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+
+	collection, err := s.repo.FindCollectionByID(collectionID)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return &CollectionWithExample{
+		CollectionIDCode: collection,
+		Example:          renderCatalogNumber(catalogPatternOf(collection), exampleCatalogValues(collection), 1),
+	}, nil
+}
+
+// allocateCatalogNumber はコレクションの形で次の登録番号を決めるのだ
+// 手で付けた番号とぶつかったときは、空いている番号まで連番を進めるのだ
+func (s *specimenService) allocateCatalogNumber(tx *gorm.DB, specimen *model.Specimen) (string, error) {
+	collection, err := s.repo.FindCollectionByID(*specimen.CollectionID)
+	if err != nil {
+		return "", err
+	}
+	pattern := catalogPatternOf(collection)
+
+	values := catalogValues{Collection: collection.CollectionCode, Date: time.Now()}
+	if specimen.InstitutionID != nil {
+		institution, err := s.repo.FindInstitutionByID(*specimen.InstitutionID)
+		if err != nil {
+			return "", err
+		}
+		values.Institution = institution.InstitutionCode
+	} else if strings.Contains(pattern, "{institution}") {
+		return "", fmt.Errorf("%w: institution_id is required by the catalog number pattern", ErrInvalidSpecimen)
+	}
+
+	scope := catalogScope(pattern, values)
+	for range maxCatalogAttempts {
+		seq, err := s.repo.NextCatalogSequence(tx, collection.CollectionID, scope)
+		if err != nil {
+			return "", err
+		}
+		number := renderCatalogNumber(pattern, values, seq)
+		taken, err := s.repo.CatalogNumberExists(tx, number)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return number, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free number in %s", ErrCatalogNumberTaken, scope)
+}
+
+// catalogPatternOf はコレクションの登録番号の形なのだ。決められていなければ既定の形なのだ
+func catalogPatternOf(collection *model.CollectionIDCode) string {
+	if collection.CatalogNumberPattern != nil && *collection.CatalogNumberPattern != "" {
+		return *collection.CatalogNumberPattern
+	}
+	return defaultCatalogPattern
+}
+
+// exampleCatalogValues は見本の番号に使う値なのだ。機関は標本ごとに違うので、仮の値にするのだ
+func exampleCatalogValues(collection *model.CollectionIDCode) catalogValues {
+	return catalogValues{Institution: "INST", Collection: collection.CollectionCode, Date: time.Now()}
+}
+
+// validateReferences は標本が指している発生情報・作製方法・機関・コレクションがあるか確かめるのだ
+func (s *specimenService) validateReferences(specimen *model.Specimen) error {
+	if _, err := s.occurrenceRepo.FindByID(specimen.OccurrenceID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: occurrence %d does not exist", ErrInvalidSpecimen, specimen.OccurrenceID)
+		}
+		return err
+	}
+
+	checks := []struct {
+		name  string
+		id    *uint
+		exist func(uint) (bool, error)
+	}{
+		{"specimen_method_id", specimen.SpecimenMethodID, s.repo.MethodExists},
+		{"institution_id", specimen.InstitutionID, s.repo.InstitutionExists},
+		{"collection_id", specimen.CollectionID, s.repo.CollectionExists},
+	}
+	for _, check := range checks {
+		if check.id == nil {
+			continue
+		}
+		ok, err := check.exist(*check.id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s %d does not exist", ErrInvalidSpecimen, check.name, *check.id)
+		}
+	}
+	return s.validateParent(specimen)
+}
+
+// validateParent は取り分ける前の標本が同じ個体のものか確かめるのだ
+// 親をたどって自分に戻ってくると輪になってしまうので、それも確かめるのだ
+func (s *specimenService) validateParent(specimen *model.Specimen) error {
+	parentID := specimen.ParentSpecimenID
+	for parentID != nil {
+		if specimen.SpecimenID != 0 && *parentID == specimen.SpecimenID {
+			return fmt.Errorf("%w: a specimen cannot be derived from itself", ErrInvalidSpecimen)
+		}
+		parent, err := s.repo.FindByID(*parentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: parent_specimen_id %d does not exist", ErrInvalidSpecimen, *parentID)
+			}
+			return err
+		}
+		if parent.OccurrenceID != specimen.OccurrenceID {
+			return fmt.Errorf("%w: parent specimen %d belongs to another occurrence", ErrInvalidSpecimen, parent.SpecimenID)
+		}
+		parentID = parent.ParentSpecimenID
+	}
+	return nil
+}
+
+// newMakeSpecimen は作製の履歴を作るのだ。日付の形式と作製方法を確かめるのだ
+func (s *specimenService) newMakeSpecimen(specimen *model.Specimen, req CreateMakeSpecimenRequest) (*model.MakeSpecimen, error) {
+	makeSpecimen := &model.MakeSpecimen{
+		OccurrenceID:     specimen.OccurrenceID,
+		UserID:           req.UserID,
+		SpecimenMethodID: specimen.SpecimenMethodID,
+		Timezone:         req.Timezone,
+		Status:           model.PreparationStatusDone,
+		Note:             trimmedOrNil(req.Note),
+	}
+	if req.Status != "" {
+		if !slices.Contains(model.PreparationStatuses, req.Status) {
+			return nil, fmt.Errorf("%w: status must be one of %s", ErrInvalidSpecimen, strings.Join(model.PreparationStatuses, ", "))
+		}
+		makeSpecimen.Status = req.Status
+	}
+
+	if req.Date != "" {
+		date, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidSpecimen)
+		}
+		makeSpecimen.Date = &date
+	}
+
+	if req.CreatedAt != "" {
+		createdAt, err := time.Parse(formDateTimeLayout, req.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_at must be YYYY-MM-DDThh:mm", ErrInvalidSpecimen)
+		}
+		makeSpecimen.CreatedAt = createdAt
+	} else {
+		makeSpecimen.CreatedAt = time.Now()
+	}
+
+	if req.SpecimenMethodID != nil {
+		ok, err := s.repo.MethodExists(*req.SpecimenMethodID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: specimen_method_id %d does not exist", ErrInvalidSpecimen, *req.SpecimenMethodID)
+		}
+		makeSpecimen.SpecimenMethodID = req.SpecimenMethodID
+	}
+	return makeSpecimen, nil
+}
+
+// trimmedOrNil は前後の空白を落として、空になったらnilにするのだ
+func trimmedOrNil(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
@@ -0,0 +1,399 @@
+// backend/internal/service/storage_location_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saku-730/specimen-web/backend/internal/model"
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidStorageLocation は置き場所の内容が正しくないときのエラーなのだ (段の順が逆、親が自分の下にあるなど)
+var ErrInvalidStorageLocation = errors.New("invalid storage location")
+
+// ErrStorageLocationTaken は同じ親の下に同じ記号の置き場所があるときのエラーなのだ
+var ErrStorageLocationTaken = errors.New("storage location code already in use")
+
+// ErrStorageLocationInUse は標本や下の置き場所、移動の履歴がある置き場所を消そうとしたときのエラーなのだ
+var ErrStorageLocationInUse = errors.New("storage location is in use")
+
+// maxMoveSpecimens は一度に動かせる標本の数の上限なのだ (引き出しをまるごと動かせるくらいにしてあるのだ)
+const maxMoveSpecimens = 5000
+
+// ListStorageLocationsRequest は置き場所の検索のクエリパラメータなのだ
+// 条件がなければ一番上の置き場所 (建物など) を返すのだ
+type ListStorageLocationsRequest struct {
+	Code     string `form:"code"` // 前方一致なのだ
+	Level    string `form:"level"`
+	ParentID *uint  `form:"parent_id"`
+}
+
+// StorageLocationRequest は置き場所の作成・更新のリクエストボディなのだ
+// 更新で parent_id を省略すると一番上の置き場所になるのだ
+type StorageLocationRequest struct {
+	ParentID *uint   `json:"parent_id"`
+	Level    string  `json:"level" binding:"required"`
+	Code     string  `json:"code" binding:"required"`
+	Name     *string `json:"name"`
+	Note     *string `json:"note"`
+}
+
+// MoveSpecimensRequest は標本を動かすリクエストボディなのだ
+// storage_location_id が null なら棚から出す (貸し出しなど) のだ
+// MovedAt は動かした日時 (2006-01-02T15:04、現地時刻) で、省略すると今なのだ
+type MoveSpecimensRequest struct {
+	SpecimenIDs       []uint  `json:"specimen_ids"`
+	StorageLocationID *uint   `json:"storage_location_id"`
+	UserID            *uint   `json:"user_id"`
+	MovedAt           string  `json:"moved_at"`
+	Timezone          int16   `json:"timezone"`
+	Note              *string `json:"note"`
+}
+
+// MoveResult は標本を動かした結果なのだ。もうその場所にあった標本は履歴を残さないのだ
+type MoveResult struct {
+	Moved     []uint `json:"moved"`
+	Unchanged []uint `json:"unchanged"`
+}
+
+// StorageLocationNode は置き場所の木の1つの節なのだ
+type StorageLocationNode struct {
+	*model.StorageLocation
+	Children []*StorageLocationNode `json:"children"`
+}
+
+// StorageLocationDetail は置き場所と、上の置き場所・すぐ下の置き場所・標本の数なのだ
+// Path は一番上から自分までで、画面に 本館 > 101 > C > C-12 と出すのに使うのだ
+type StorageLocationDetail struct {
+	*model.StorageLocation
+	Path               []model.StorageLocation `json:"path"`
+	Children           []model.StorageLocation `json:"children"`
+	SpecimenCount      int64                   `json:"specimen_count"`
+	TotalSpecimenCount int64                   `json:"total_specimen_count"` // 下の置き場所の標本も含めた数なのだ
+}
+
+// StorageLocationService は置き場所と標本の移動のビジネスロジックのインターフェースなのだ
+type StorageLocationService interface {
+	ListStorageLocations(req ListStorageLocationsRequest) ([]model.StorageLocation, error)
+	GetStorageLocationTree() ([]*StorageLocationNode, error)
+	GetStorageLocation(id uint) (*StorageLocationDetail, error)
+	CreateStorageLocation(req StorageLocationRequest) (*StorageLocationDetail, error)
+	UpdateStorageLocation(id uint, req StorageLocationRequest) (*StorageLocationDetail, error)
+	DeleteStorageLocation(id uint) error
+
+	MoveSpecimens(req MoveSpecimensRequest) (*MoveResult, error)
+	GetSpecimenMovements(specimenID uint) ([]model.SpecimenMovement, error)
+}
+
+type storageLocationService struct {
+	db           *gorm.DB
+	repo         repository.StorageLocationRepository
+	specimenRepo repository.SpecimenRepository
+}
+
+// NewStorageLocationService は新しいサービスを生成するのだ
+func NewStorageLocationService(db *gorm.DB, repo repository.StorageLocationRepository, specimenRepo repository.SpecimenRepository) StorageLocationService {
+	return &storageLocationService{db: db, repo: repo, specimenRepo: specimenRepo}
+}
+
+func (s *storageLocationService) ListStorageLocations(req ListStorageLocationsRequest) ([]model.StorageLocation, error) {
+	params := repository.StorageLocationSearchParams{
+		Code:     strings.TrimSpace(req.Code),
+		Level:    strings.TrimSpace(req.Level),
+		ParentID: req.ParentID,
+	}
+	if params.Level != "" && !slices.Contains(model.StorageLevels, params.Level) {
+		return nil, fmt.Errorf("%w: unknown level %q", ErrInvalidStorageLocation, params.Level)
+	}
+	params.RootOnly = params.Code == "" && params.Level == "" && params.ParentID == nil
+	return s.repo.Search(params)
+}
+
+// GetStorageLocationTree は全ての置き場所を木にして返すのだ
+func (s *storageLocationService) GetStorageLocationTree() ([]*StorageLocationNode, error) {
+	locations, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[uint]*StorageLocationNode, len(locations))
+	for i := range locations {
+		nodes[locations[i].StorageLocationID] = &StorageLocationNode{StorageLocation: &locations[i], Children: []*StorageLocationNode{}}
+	}
+	// FindAll は記号の順なので、子も記号の順に並ぶのだ
+	roots := []*StorageLocationNode{}
+	for i := range locations {
+		node := nodes[locations[i].StorageLocationID]
+		if parentID := locations[i].ParentID; parentID != nil {
+			if parent, ok := nodes[*parentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+func (s *storageLocationService) GetStorageLocation(id uint) (*StorageLocationDetail, error) {
+	location, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	path, err := s.repo.FindAncestors(id)
+	if err != nil {
+		return nil, err
+	}
+	children, err := s.repo.FindChildren(id)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountSpecimens(id, false)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.repo.CountSpecimens(id, true)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageLocationDetail{
+		StorageLocation:    location,
+		Path:               path,
+		Children:           children,
+		SpecimenCount:      count,
+		TotalSpecimenCount: total,
+	}, nil
+}
+
+func (s *storageLocationService) CreateStorageLocation(req StorageLocationRequest) (*StorageLocationDetail, error) {
+	location, err := s.newStorageLocation(0, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Create(tx, location)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetStorageLocation(location.StorageLocationID)
+}
+
+// UpdateStorageLocation は置き場所を書き換えるのだ。親を変えると下の置き場所と標本もまとめて動くのだ
+func (s *storageLocationService) UpdateStorageLocation(id uint, req StorageLocationRequest) (*StorageLocationDetail, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, notFoundOr(err)
+	}
+	location, err := s.newStorageLocation(id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Update(tx, location)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetStorageLocation(id)
+}
+
+// DeleteStorageLocation は空の置き場所を削除するのだ
+// 履歴に出てくる置き場所を消すと、どこから動かしたのか分からなくなるので消さないのだ
+func (s *storageLocationService) DeleteStorageLocation(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return notFoundOr(err)
+	}
+	hasChildren, err := s.repo.HasChildren(id)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("%w: it still contains other storage locations", ErrStorageLocationInUse)
+	}
+	count, err := s.repo.CountSpecimens(id, false)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: it still holds %d specimens", ErrStorageLocationInUse, count)
+	}
+	hasMovements, err := s.repo.HasMovements(id)
+	if err != nil {
+		return err
+	}
+	if hasMovements {
+		return fmt.Errorf("%w: it appears in the movement history", ErrStorageLocationInUse)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Delete(tx, id)
+	})
+}
+
+// MoveSpecimens は標本を1件でもまとめてでも動かし、1件ずつ移動の履歴を残すのだ
+func (s *storageLocationService) MoveSpecimens(req MoveSpecimensRequest) (*MoveResult, error) {
+	ids := slices.Compact(slices.Sorted(slices.Values(req.SpecimenIDs)))
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: specimen_ids is empty", ErrInvalidStorageLocation)
+	}
+	if len(ids) > maxMoveSpecimens {
+		return nil, fmt.Errorf("%w: at most %d specimens at once", ErrInvalidStorageLocation, maxMoveSpecimens)
+	}
+	if req.StorageLocationID != nil {
+		if _, err := s.repo.FindByID(*req.StorageLocationID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: storage_location_id %d does not exist", ErrInvalidStorageLocation, *req.StorageLocationID)
+			}
+			return nil, err
+		}
+	}
+
+	movedAt := time.Now()
+	if req.MovedAt != "" {
+		var err error
+		movedAt, err = time.ParseInLocation(formDateTimeLayout, req.MovedAt, time.FixedZone("", int(req.Timezone)*3600))
+		if err != nil {
+			return nil, fmt.Errorf("%w: moved_at must be YYYY-MM-DDThh:mm", ErrInvalidStorageLocation)
+		}
+	}
+
+	result := &MoveResult{Moved: []uint{}, Unchanged: []uint{}}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		specimens, err := s.repo.FindSpecimensForUpdate(tx, ids)
+		if err != nil {
+			return err
+		}
+		if len(specimens) < len(ids) {
+			found := make(map[uint]bool, len(specimens))
+			for _, specimen := range specimens {
+				found[specimen.SpecimenID] = true
+			}
+			var missing []string
+			for _, id := range ids {
+				if !found[id] {
+					missing = append(missing, fmt.Sprint(id))
+				}
+			}
+			return fmt.Errorf("%w: specimens not found: %s", ErrNotFound, strings.Join(missing, ", "))
+		}
+
+		var movements []model.SpecimenMovement
+		for _, specimen := range specimens {
+			if equalUintPtr(specimen.StorageLocationID, req.StorageLocationID) {
+				result.Unchanged = append(result.Unchanged, specimen.SpecimenID)
+				continue
+			}
+			result.Moved = append(result.Moved, specimen.SpecimenID)
+			movements = append(movements, model.SpecimenMovement{
+				SpecimenID:     specimen.SpecimenID,
+				FromLocationID: specimen.StorageLocationID,
+				ToLocationID:   req.StorageLocationID,
+				UserID:         req.UserID,
+				MovedAt:        movedAt,
+				Timezone:       req.Timezone,
+				Note:           req.Note,
+			})
+		}
+		if len(result.Moved) == 0 {
+			return nil
+		}
+		if err := s.repo.MoveSpecimens(tx, result.Moved, req.StorageLocationID); err != nil {
+			return err
+		}
+		return s.repo.CreateMovements(tx, movements)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSpecimenMovements は標本の移動の履歴を古い順に返すのだ
+func (s *storageLocationService) GetSpecimenMovements(specimenID uint) ([]model.SpecimenMovement, error) {
+	if _, err := s.specimenRepo.FindByID(specimenID); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.repo.FindMovements(specimenID)
+}
+
+// newStorageLocation はリクエストから置き場所を作るのだ。段・記号・親を確かめるのだ
+// id は更新するときの自分で、作成のときは0なのだ
+func (s *storageLocationService) newStorageLocation(id uint, req StorageLocationRequest) (*model.StorageLocation, error) {
+	location := &model.StorageLocation{
+		StorageLocationID: id,
+		ParentID:          req.ParentID,
+		Level:             strings.TrimSpace(req.Level),
+		Code:              strings.TrimSpace(req.Code),
+		Name:              req.Name,
+		Note:              req.Note,
+	}
+	if !slices.Contains(model.StorageLevels, location.Level) {
+		return nil, fmt.Errorf("%w: level must be one of %s", ErrInvalidStorageLocation, strings.Join(model.StorageLevels, ", "))
+	}
+	if location.Code == "" {
+		return nil, fmt.Errorf("%w: code is empty", ErrInvalidStorageLocation)
+	}
+
+	if location.ParentID != nil {
+		parent, err := s.repo.FindByID(*location.ParentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: parent_id %d does not exist", ErrInvalidStorageLocation, *location.ParentID)
+			}
+			return nil, err
+		}
+		if storageLevelRank(parent.Level) >= storageLevelRank(location.Level) {
+			return nil, fmt.Errorf("%w: %s cannot be placed in %s", ErrInvalidStorageLocation, location.Level, parent.Level)
+		}
+		// 自分の下に自分を入れると輪になってしまうのだ
+		if id != 0 {
+			inside, err := s.repo.IsInSubtree(id, parent.StorageLocationID)
+			if err != nil {
+				return nil, err
+			}
+			if inside {
+				return nil, fmt.Errorf("%w: cannot move a storage location into itself", ErrInvalidStorageLocation)
+			}
+		}
+	}
+
+	// 段を変えたときに、今ある下の置き場所より下の段にならないようにするのだ
+	if id != 0 {
+		children, err := s.repo.FindChildren(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if storageLevelRank(child.Level) <= storageLevelRank(location.Level) {
+				return nil, fmt.Errorf("%w: %s %s cannot hold %s %s", ErrInvalidStorageLocation, location.Level, location.Code, child.Level, child.Code)
+			}
+		}
+	}
+
+	taken, err := s.repo.CodeTaken(location.ParentID, location.Code, id)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: %s", ErrStorageLocationTaken, location.Code)
+	}
+	return location, nil
+}
+
+// storageLevelRank は段の深さなのだ。建物が0で、下の段ほど大きいのだ
+func storageLevelRank(level string) int {
+	return slices.Index(model.StorageLevels, level)
+}
+
+func equalUintPtr(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
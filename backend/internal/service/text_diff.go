@@ -0,0 +1,103 @@
+// backend/internal/service/text_diff.go
+package service
+
+import "strings"
+
+// 差分の行の種類なのだ
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine は差分の1行なのだ。行番号は1から数え、その側にない行は0なのだ
+type DiffLine struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// maxDiffCells はLCSの表の大きさの上限なのだ。これを超える巨大な本文は全行の置き換えとして扱うのだ
+const maxDiffCells = 4_000_000
+
+// diffLines は2つの文章を行ごとに比べて、最長共通部分列(LCS)から差分を作るのだ
+func diffLines(oldText, newText string) []DiffLine {
+	a, b := splitLines(oldText), splitLines(newText)
+
+	// 先頭と末尾の同じ行は表を作らずに済ませるのだ
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []DiffLine
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		oi, ni := len(a)-suffix+i, len(b)-suffix+i
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[oi], OldLine: oi + 1, NewLine: ni + 1})
+	}
+	return lines
+}
+
+func diffMiddle(a, b []string, oldOffset, newOffset int) []DiffLine {
+	n, m := len(a), len(b)
+	var lines []DiffLine
+	if n*m > maxDiffCells {
+		for i, line := range a {
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: line, OldLine: oldOffset + i + 1})
+		}
+		for j, line := range b {
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: line, NewLine: newOffset + j + 1})
+		}
+		return lines
+	}
+
+	// lcs[i][j] は a[i:] と b[j:] の共通部分列の長さなのだ
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: a[i], OldLine: oldOffset + i + 1, NewLine: newOffset + j + 1})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: b[j], NewLine: newOffset + j + 1})
+			j++
+		default:
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: a[i], OldLine: oldOffset + i + 1})
+			i++
+		}
+	}
+	return lines
+}
+
+// splitLines は改行コードをそろえて行に分けるのだ。空の文章は0行なのだ
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,222 @@
+// backend/internal/service/text_search_service.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTextSearch は全文検索の条件が正しくないときのエラーなのだ
+var ErrInvalidTextSearch = errors.New("invalid text search")
+
+// TextSearchRequest は全文検索のクエリパラメータなのだ
+// q は英語なら websearch の書き方 ("語句", -除外, or) が使えるのだ。types はカンマ区切りなのだ
+type TextSearchRequest struct {
+	Q      string `form:"q"`
+	Types  string `form:"types"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+// TextSearchHit は全文検索で当たった1件なのだ
+// Snippet は当たった語を <mark> で囲んだHTMLなのだ。本文はエスケープ済みなのでそのまま埋め込めるのだ
+type TextSearchHit struct {
+	Type         string  `json:"type"`
+	ID           uint    `json:"id"`
+	PageID       *uint   `json:"page_id,omitempty"`
+	OccurrenceID *uint   `json:"occurrence_id,omitempty"`
+	Title        *string `json:"title,omitempty"`
+	Snippet      string  `json:"snippet"`
+	Rank         float64 `json:"rank"`
+}
+
+// TextSearchResult は全文検索の結果の1ページ分なのだ
+type TextSearchResult struct {
+	Query  string          `json:"query"`
+	Total  int64           `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Hits   []TextSearchHit `json:"hits"`
+}
+
+const (
+	defaultTextSearchLimit = 20
+	maxTextSearchLimit     = 100
+	maxTextSearchQuery     = 200 // 文字数なのだ
+)
+
+// 抜粋の中で当たった語を囲む印なのだ。本文に出てこない私用領域の文字を使い、エスケープのあとで <mark> に置き換えるのだ
+const (
+	snippetMarkStart = "\ue000"
+	snippetMarkEnd   = "\ue001"
+)
+
+var textSearchHeadlineOptions = fmt.Sprintf(
+	`StartSel="%s", StopSel="%s", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`,
+	snippetMarkStart, snippetMarkEnd,
+)
+
+// cjkRunPattern はかな・漢字の並びなのだ (DB側の cjk_bigrams と同じ範囲なのだ)
+var cjkRunPattern = regexp.MustCompile(`[々\x{3040}-\x{30ff}\x{3400}-\x{4dbf}\x{4e00}-\x{9fff}\x{f900}-\x{faff}\x{ff66}-\x{ff9f}]+`)
+
+// 日本語で当たったときの抜粋の前後の文字数なのだ
+const (
+	snippetRunesBefore = 40
+	snippetRunesAfter  = 80
+)
+
+// TextSearchService はWikiとメモ類の全文検索のインターフェースなのだ
+type TextSearchService interface {
+	Search(req TextSearchRequest) (*TextSearchResult, error)
+}
+
+type textSearchService struct {
+	db   *gorm.DB
+	repo repository.TextSearchRepository
+}
+
+func NewTextSearchService(db *gorm.DB, repo repository.TextSearchRepository) TextSearchService {
+	return &textSearchService{db: db, repo: repo}
+}
+
+// Search はWikiの本文・発生情報のメモ・観察の行動・同定の根拠をまとめて検索し、関連度の高い順に返すのだ
+func (s *textSearchService) Search(req TextSearchRequest) (*TextSearchResult, error) {
+	query := strings.TrimSpace(req.Q)
+	if query == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidTextSearch)
+	}
+	if utf8.RuneCountInString(query) > maxTextSearchQuery {
+		return nil, fmt.Errorf("%w: q is too long", ErrInvalidTextSearch)
+	}
+
+	types, err := parseTextSearchTypes(req.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTextSearchLimit
+	}
+	limit = min(limit, maxTextSearchLimit)
+	offset := max(req.Offset, 0)
+
+	rows, err := s.repo.Search(repository.TextSearchParams{
+		Query:           query,
+		Types:           types,
+		HeadlineOptions: textSearchHeadlineOptions,
+		Limit:           limit,
+		Offset:          offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	terms := cjkRunPattern.FindAllString(query, -1)
+	result := &TextSearchResult{Query: query, Limit: limit, Offset: offset, Hits: make([]TextSearchHit, 0, len(rows))}
+	for _, row := range rows {
+		result.Total = row.Total
+		result.Hits = append(result.Hits, TextSearchHit{
+			Type:         row.Type,
+			ID:           row.ID,
+			PageID:       row.PageID,
+			OccurrenceID: row.OccurrenceID,
+			Title:        row.Title,
+			Snippet:      buildSnippet(row.Headline, row.Body, terms),
+			Rank:         row.Rank,
+		})
+	}
+	return result, nil
+}
+
+// parseTextSearchTypes はカンマ区切りの種類を確かめるのだ。空なら全ての種類なのだ
+func parseTextSearchTypes(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	known := repository.TextSearchTypes()
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(known, t) {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidTextSearch, t)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// buildSnippet は抜粋をエスケープして、当たった語を <mark> で囲むのだ
+// 英語は ts_headline が語幹まで見て印を付けるので、それを使うのだ
+// 日本語は ts_headline では印が付かないので、検索語の並びをそのまま探して囲むのだ
+func buildSnippet(headline, body string, cjkTerms []string) string {
+	text := headline
+	if !strings.Contains(text, snippetMarkStart) {
+		if window := cjkWindow(body, cjkTerms); window != "" {
+			text = window
+		}
+	}
+
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, snippetMarkStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, snippetMarkEnd, "</mark>")
+
+	if pattern := termsPattern(cjkTerms); pattern != nil {
+		escaped = pattern.ReplaceAllString(escaped, "<mark>$0</mark>")
+	}
+	return escaped
+}
+
+// cjkWindow は本文の中で最初に検索語が出てくるあたりを切り出すのだ。見つからなければ空なのだ
+func cjkWindow(body string, terms []string) string {
+	start := -1
+	for _, term := range terms {
+		if i := strings.Index(body, term); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+
+	runes := []rune(body)
+	at := utf8.RuneCountInString(body[:start])
+	from := max(0, at-snippetRunesBefore)
+	to := min(len(runes), at+snippetRunesAfter)
+
+	window := string(runes[from:to])
+	if from > 0 {
+		window = "…" + window
+	}
+	if to < len(runes) {
+		window += "…"
+	}
+	return window
+}
+
+// termsPattern は検索語のどれかに当たる正規表現なのだ。長い語を先に試すのだ
+func termsPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(terms)
+	slices.SortFunc(sorted, func(a, b string) int { return len(b) - len(a) })
+	quoted := make([]string, len(sorted))
+	for i, t := range sorted {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile(strings.Join(quoted, "|"))
+}
@@ -0,0 +1,126 @@
+// backend/internal/service/thumbnail.go
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+
+	// 対応する画像形式のデコーダを登録するのだ
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// ThumbnailSize はサムネイルの大きさの名前と長辺のピクセル数なのだ
+type ThumbnailSize struct {
+	Name     string
+	LongEdge int
+}
+
+// thumbnailSizes は作るサムネイルの一覧なのだ。一覧表示・詳細表示・拡大表示で使い分けるのだ
+var thumbnailSizes = []ThumbnailSize{
+	{Name: "small", LongEdge: 200},
+	{Name: "medium", LongEdge: 800},
+	{Name: "large", LongEdge: 1600},
+}
+
+const thumbnailQuality = 85
+
+// thumbnailImage はできあがったサムネイル1枚なのだ
+type thumbnailImage struct {
+	Size   ThumbnailSize
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// makeThumbnails は画像をEXIFの向きに合わせて回転し、各サイズのJPEGを作るのだ
+// 元画像より大きくはしないので、小さい画像では同じ大きさのものが並ぶことがあるのだ
+func makeThumbnails(src image.Image, orientation int) ([]thumbnailImage, error) {
+	// 大きな写真を1画素ずつ回転すると遅いので、先に一番大きいサイズまで縮めてから回転するのだ
+	base := applyOrientation(scaleToFit(src, thumbnailSizes[len(thumbnailSizes)-1].LongEdge), orientation)
+
+	thumbnails := make([]thumbnailImage, 0, len(thumbnailSizes))
+	for _, size := range thumbnailSizes {
+		dst := scaleToFit(base, size.LongEdge)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+			return nil, err
+		}
+		b := dst.Bounds()
+		thumbnails = append(thumbnails, thumbnailImage{Size: size, Width: b.Dx(), Height: b.Dy(), Data: buf.Bytes()})
+	}
+	return thumbnails, nil
+}
+
+// scaleToFit は長辺が limit 以下になるように縮小するのだ。もともと小さければそのまま返すのだ
+func scaleToFit(src image.Image, limit int) image.Image {
+	b := src.Bounds()
+	w, h := fitLongEdge(b.Dx(), b.Dy(), limit)
+	if w == b.Dx() && h == b.Dy() {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// fitLongEdge は縦横比を保って長辺を limit 以下にした大きさを返すのだ
+func fitLongEdge(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(1, h*limit/w)
+	}
+	return max(1, w*limit/h), limit
+}
+
+// orientedSize はEXIFの向きを適用したあとの幅と高さなのだ (5〜8は縦横が入れ替わるのだ)
+func orientedSize(w, h, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return h, w
+	}
+	return w, h
+}
+
+// applyOrientation はEXIFの Orientation (1〜8) に従って画像を回転・反転するのだ
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := orientedSize(w, h, orientation)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 左右反転
+				dx, dy = w-1-x, y
+			case 3: // 180度回転
+				dx, dy = w-1-x, h-1-y
+			case 4: // 上下反転
+				dx, dy = x, h-1-y
+			case 5: // 左上と右下を結ぶ線で反転
+				dx, dy = y, x
+			case 6: // 時計回りに90度回転
+				dx, dy = h-1-y, x
+			case 7: // 右上と左下を結ぶ線で反転
+				dx, dy = h-1-y, w-1-x
+			case 8: // 反時計回りに90度回転
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
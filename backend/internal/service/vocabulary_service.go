@@ -0,0 +1,255 @@
+// backend/internal/service/vocabulary_service.go
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/saku-730/specimen-web/backend/internal/repository"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownVocabulary は管理できる語彙にない名前を指定したときのエラーなのだ
+var ErrUnknownVocabulary = errors.New("unknown vocabulary")
+
+// ErrInvalidVocabulary は語彙の値の内容が正しくないときのエラーなのだ (名前がない、表示名のキーが知らない言語など)
+var ErrInvalidVocabulary = errors.New("invalid vocabulary item")
+
+// ErrVocabularyConflict はほかの値と名前やコードがかぶったときのエラーなのだ
+var ErrVocabularyConflict = errors.New("vocabulary item conflict")
+
+// ListVocabularyRequest は語彙の一覧のクエリパラメータなのだ
+// Lang は表示名の言語 (languages の language_short) で、その表示名がない値は名前をそのまま出すのだ
+type ListVocabularyRequest struct {
+	IncludeDeprecated bool   `form:"include_deprecated"`
+	Lang              string `form:"lang"`
+}
+
+// VocabularyRequest は語彙の値の作成・更新のリクエストボディなのだ
+// Code は言語の短い名前 (en, jp) で、言語のときだけ要るのだ
+// SortOrder を省略すると、作成では一番後ろに、更新では今の順番のままにするのだ
+// Labels は言語の短い名前をキーにした表示名のオブジェクトで、省略すると作成では空に、更新では今のままにするのだ
+type VocabularyRequest struct {
+	Name       string         `json:"name" binding:"required"`
+	Code       string         `json:"code"`
+	SortOrder  *int           `json:"sort_order"`
+	Deprecated bool           `json:"deprecated"`
+	Labels     datatypes.JSON `json:"labels"`
+}
+
+// VocabularyService は選択肢のテーブル (作製方法・機関コード・コレクションコード・観察方法・ファイルの種類・言語・計測の種類) を管理するビジネスロジックのインターフェースなのだ
+// 使われている値は消すと参照がたどれなくなるので、削除のかわりに使うのをやめた印を付けるのだ
+type VocabularyService interface {
+	GetKinds() []string
+	GetItems(kind string, req ListVocabularyRequest) ([]repository.VocabularyItem, error)
+	GetItem(kind string, id uint) (*repository.VocabularyItem, error)
+	CreateItem(kind string, req VocabularyRequest) (*repository.VocabularyItem, error)
+	UpdateItem(kind string, id uint, req VocabularyRequest) (*repository.VocabularyItem, error)
+	DeleteItem(kind string, id uint) (*repository.VocabularyItem, error)
+}
+
+type vocabularyService struct {
+	db   *gorm.DB
+	repo repository.VocabularyRepository
+}
+
+// NewVocabularyService は新しいサービスを生成するのだ
+func NewVocabularyService(db *gorm.DB, repo repository.VocabularyRepository) VocabularyService {
+	return &vocabularyService{db: db, repo: repo}
+}
+
+// GetKinds は管理できる語彙の名前を返すのだ
+func (s *vocabularyService) GetKinds() []string {
+	return s.repo.Kinds()
+}
+
+// GetItems は語彙の値を並び順で、使っている数と表示名つきで返すのだ
+func (s *vocabularyService) GetItems(kind string, req ListVocabularyRequest) ([]repository.VocabularyItem, error) {
+	if !s.repo.HasKind(kind) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVocabulary, kind)
+	}
+	items, err := s.repo.FindAll(kind, req.IncludeDeprecated)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Label = labelOf(items[i].Labels, items[i].Name, req.Lang)
+	}
+	return items, nil
+}
+
+// GetItem は語彙の値を1件返すのだ
+func (s *vocabularyService) GetItem(kind string, id uint) (*repository.VocabularyItem, error) {
+	if !s.repo.HasKind(kind) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVocabulary, kind)
+	}
+	item, err := s.repo.FindByID(kind, id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	item.Label = item.Name
+	return item, nil
+}
+
+// CreateItem は語彙に値を追加するのだ
+func (s *vocabularyService) CreateItem(kind string, req VocabularyRequest) (*repository.VocabularyItem, error) {
+	if !s.repo.HasKind(kind) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVocabulary, kind)
+	}
+	item := &repository.VocabularyItem{Deprecated: req.Deprecated, Labels: datatypes.JSON("{}")}
+	if req.SortOrder != nil {
+		item.SortOrder = *req.SortOrder
+	} else {
+		next, err := s.repo.NextSortOrder(kind)
+		if err != nil {
+			return nil, err
+		}
+		item.SortOrder = next
+	}
+	if err := s.applyVocabularyRequest(kind, item, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.repo.Create(tx, kind, item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetItem(kind, item.ID)
+}
+
+// UpdateItem は語彙の値を書き換えるのだ。deprecated を false にすると、また選択肢に出すのだ
+func (s *vocabularyService) UpdateItem(kind string, id uint, req VocabularyRequest) (*repository.VocabularyItem, error) {
+	item, err := s.GetItem(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	item.Deprecated = req.Deprecated
+	if req.SortOrder != nil {
+		item.SortOrder = *req.SortOrder
+	}
+	if err := s.applyVocabularyRequest(kind, item, req); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Update(tx, kind, item)
+	})
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return s.GetItem(kind, id)
+}
+
+// DeleteItem は使われていない値を削除するのだ
+// 使われている値は消さずに使うのをやめた印を付けて、その値を返すのだ。削除したときは nil を返すのだ
+func (s *vocabularyService) DeleteItem(kind string, id uint) (*repository.VocabularyItem, error) {
+	if !s.repo.HasKind(kind) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVocabulary, kind)
+	}
+	var deprecated *repository.VocabularyItem
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		item, err := s.repo.FindForUpdate(tx, kind, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		if item.UsageCount == 0 {
+			return s.repo.Delete(tx, kind, id)
+		}
+		item.Deprecated = true
+		if err := s.repo.Update(tx, kind, item); err != nil {
+			return err
+		}
+		deprecated = item
+		return nil
+	})
+	if err != nil || deprecated == nil {
+		return nil, err
+	}
+	deprecated.Label = deprecated.Name
+	return deprecated, nil
+}
+
+// applyVocabularyRequest はリクエストの名前・コード・表示名を確かめて値に入れるのだ
+func (s *vocabularyService) applyVocabularyRequest(kind string, item *repository.VocabularyItem, req VocabularyRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidVocabulary)
+	}
+	taken, err := s.repo.NameTaken(kind, name, item.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: %q is already used", ErrVocabularyConflict, name)
+	}
+	item.Name = name
+
+	if s.repo.HasCode(kind) {
+		code := strings.TrimSpace(req.Code)
+		if code == "" {
+			return fmt.Errorf("%w: code is required", ErrInvalidVocabulary)
+		}
+		taken, err := s.repo.CodeTaken(kind, code, item.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("%w: code %q is already used", ErrVocabularyConflict, code)
+		}
+		item.Code = &code
+	}
+
+	if len(req.Labels) > 0 {
+		codes, err := s.repo.LanguageCodes()
+		if err != nil {
+			return err
+		}
+		labels, err := normalizeLabels(req.Labels, codes)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidVocabulary, err)
+		}
+		item.Labels = labels
+	}
+	return nil
+}
+
+// normalizeLabels は表示名のキーが登録された言語 (codes) か確かめて、空の表示名を落とすのだ
+func normalizeLabels(raw datatypes.JSON, codes []string) (datatypes.JSON, error) {
+	var labels map[string]string
+	if err := json.Unmarshal(raw, &labels); err != nil {
+		return nil, errors.New("labels must be an object of language code to string")
+	}
+	normalized := make(map[string]string, len(labels))
+	for lang, label := range labels {
+		if !slices.Contains(codes, lang) {
+			return nil, fmt.Errorf("unknown language %q in labels", lang)
+		}
+		if label = strings.TrimSpace(label); label != "" {
+			normalized[lang] = label
+		}
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+// labelOf は言語の表示名を返すのだ。言語の指定がないか、その表示名がなければ name なのだ
+func labelOf(labels datatypes.JSON, name, lang string) string {
+	if lang == "" {
+		return name
+	}
+	var byLang map[string]string
+	if err := json.Unmarshal(labels, &byLang); err == nil && byLang[lang] != "" {
+		return byLang[lang]
+	}
+	return name
+}
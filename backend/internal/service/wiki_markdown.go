@@ -0,0 +1,39 @@
+// backend/internal/service/wiki_markdown.go
+package service
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// wikiMarkdown はWikiの本文をHTMLにする変換器なのだ (表・取り消し線・チェックリストが使えるのだ)
+var wikiMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// wikiHTMLPolicy は出力したHTMLから危ないタグや属性を取り除く決まりなのだ
+// 誰でも編集できるページなので、スクリプトやイベント属性は必ず消すのだ
+var wikiHTMLPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	// チェックリストの四角だけは残すのだ
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// renderWikiMarkdown はMarkdownを安全なHTMLに変換するのだ
+func renderWikiMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := wikiMarkdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return wikiHTMLPolicy.Sanitize(buf.String()), nil
+}
@@ -1,34 +1,134 @@
-
 // backend/internal/service/wiki_service.go
 package service
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
 	"github.com/saku-730/specimen-web/backend/internal/model"
 	"github.com/saku-730/specimen-web/backend/internal/repository"
 	"gorm.io/gorm"
 )
 
+// ErrInvalidWikiPage はタイトルが空などで保存できないWikiページのエラーなのだ
+var ErrInvalidWikiPage = errors.New("invalid wiki page")
+
+// ErrWikiPageInUse は手法から参照されているページを消そうとしたときのエラーなのだ
+var ErrWikiPageInUse = errors.New("wiki page is linked from methods")
+
+// ErrWikiEditConflict は編集中に他の人が先に保存していたときのエラーなのだ
+var ErrWikiEditConflict = errors.New("wiki page was edited by someone else")
+
 // CreateWikiPageRequest はWikiページ作成時のリクエストボディを表すのだ
 type CreateWikiPageRequest struct {
-	Title       string `json:"title"`
-	UserID      uint   `json:"user_id"`
-	ContentPath string `json:"content_path"`
+	Title   string `json:"title"`
+	UserID  uint   `json:"user_id"`
+	Content string `json:"content"` // Markdownなのだ
+	Summary string `json:"summary"`
 }
 
 // UpdateWikiPageRequest はWikiページ更新時のリクエストボディを表すのだ
+// BaseRevision に編集を始めたときの版番号を入れると、その後に他の人が保存していた場合は衝突として断るのだ
 type UpdateWikiPageRequest struct {
-	Title       string `json:"title"`
-	UserID      uint   `json:"user_id"`
+	Title        string `json:"title"`
+	UserID       uint   `json:"user_id"`
+	Content      string `json:"content"`
+	Summary      string `json:"summary"`
+	BaseRevision *int   `json:"base_revision"`
+}
+
+// RevertWikiPageRequest は過去の版に戻すリクエストなのだ
+type RevertWikiPageRequest struct {
+	RevisionNumber int    `json:"revision_number" binding:"required"`
+	UserID         uint   `json:"user_id"`
+	Summary        string `json:"summary"`
+}
+
+// ListWikiPagesRequest はWikiページ一覧のクエリパラメータなのだ
+type ListWikiPagesRequest struct {
+	Title  string `form:"title"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+// WikiPageList はWikiページ一覧の1ページ分なのだ
+type WikiPageList struct {
+	Pages  []model.WikiPage `json:"pages"`
+	Total  int64            `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+// WikiPageDetail はWikiページと、それを手順書として参照している手法なのだ
+type WikiPageDetail struct {
+	*model.WikiPage
+	LinkedMethods []repository.LinkedMethod `json:"linked_methods"`
+}
+
+// 一覧の1ページあたりの件数なのだ
+const (
+	defaultWikiPageLimit = 20
+	maxWikiPageLimit     = 100
+)
+
+// WikiDiff は2つの版の差分なのだ
+type WikiDiff struct {
+	PageID    uint       `json:"page_id"`
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	FromTitle string     `json:"from_title"`
+	ToTitle   string     `json:"to_title"`
+	Lines     []DiffLine `json:"lines"`
+}
+
+// RenderedWikiPage はHTMLに変換した本文なのだ
+type RenderedWikiPage struct {
+	PageID         uint   `json:"page_id"`
+	RevisionNumber int    `json:"revision_number"`
+	Title          string `json:"title"`
+	HTML           string `json:"html"`
+}
+
+// MigrateWikiContentRequest は content_path のファイルにある本文を最初の版に移す条件なのだ
+// content_path は Files からの相対パスとして読むのだ (先頭の / は外すのだ)
+type MigrateWikiContentRequest struct {
+	Files  fs.FS
+	DryRun bool
+}
+
+// WikiMigrationResult は本文の移行結果なのだ
+type WikiMigrationResult struct {
+	Migrated []uint              `json:"migrated"`
+	Failed   []WikiMigrationFail `json:"failed"`
+}
+
+// WikiMigrationFail は移せなかったページと理由なのだ
+type WikiMigrationFail struct {
+	PageID      uint   `json:"page_id"`
 	ContentPath string `json:"content_path"`
+	Reason      string `json:"reason"`
 }
 
 // WikiService はWikiページ関連のビジネスロジックのインターフェースなのだ
 type WikiService interface {
 	GetWikiPageByID(id uint) (*model.WikiPage, error)
-	GetAllWikiPages() ([]model.WikiPage, error)
+	GetWikiPageDetail(id uint) (*WikiPageDetail, error)
+	ListWikiPages(req ListWikiPagesRequest) (*WikiPageList, error)
 	CreateWikiPage(req CreateWikiPageRequest) (*model.WikiPage, error)
 	UpdateWikiPage(id uint, req UpdateWikiPageRequest) (*model.WikiPage, error)
 	DeleteWikiPage(id uint) error
+
+	GetRevisions(pageID uint) ([]model.WikiRevision, error)
+	GetRevision(pageID uint, number int) (*model.WikiRevision, error)
+	DiffRevisions(pageID uint, from, to int) (*WikiDiff, error)
+	RevertWikiPage(pageID uint, req RevertWikiPageRequest) (*model.WikiPage, error)
+	RenderWikiPage(pageID uint, revision *int) (*RenderedWikiPage, error)
+
+	MigrateLegacyContent(req MigrateWikiContentRequest) (*WikiMigrationResult, error)
 }
 
 type wikiService struct {
@@ -42,57 +142,301 @@ func NewWikiService(db *gorm.DB, repo repository.WikiRepository) WikiService {
 }
 
 func (s *wikiService) GetWikiPageByID(id uint) (*model.WikiPage, error) {
-	return s.repo.FindByID(id)
+	page, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return page, nil
 }
 
-func (s *wikiService) GetAllWikiPages() ([]model.WikiPage, error) {
-	return s.repo.FindAll()
+// GetWikiPageDetail はページと、それを参照している手法の一覧を返すのだ
+func (s *wikiService) GetWikiPageDetail(id uint) (*WikiPageDetail, error) {
+	page, err := s.GetWikiPageByID(id)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := s.repo.FindLinkedMethods(id)
+	if err != nil {
+		return nil, err
+	}
+	return &WikiPageDetail{WikiPage: page, LinkedMethods: methods}, nil
+}
+
+// ListWikiPages はタイトルで絞り込んだページを、更新の新しい順にページ送りで返すのだ
+func (s *wikiService) ListWikiPages(req ListWikiPagesRequest) (*WikiPageList, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultWikiPageLimit
+	}
+	limit = min(limit, maxWikiPageLimit)
+	offset := max(req.Offset, 0)
+
+	pages, total, err := s.repo.List(repository.WikiListParams{
+		Title:  strings.TrimSpace(req.Title),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WikiPageList{Pages: pages, Total: total, Limit: limit, Offset: offset}, nil
 }
 
+// CreateWikiPage はページを作り、本文を最初の版として保存するのだ
 func (s *wikiService) CreateWikiPage(req CreateWikiPageRequest) (*model.WikiPage, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, ErrInvalidWikiPage
+	}
 	newPage := &model.WikiPage{
-		Title:       req.Title,
-		UserID:      req.UserID,
-		ContentPath: req.ContentPath,
+		Title:  title,
+		UserID: req.UserID,
 	}
 
-	var createdPage *model.WikiPage
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var err error
-		createdPage, err = s.repo.Create(tx, newPage)
-		return err
+		if _, err := s.repo.Create(tx, newPage); err != nil {
+			return err
+		}
+		return s.addRevision(tx, newPage, &model.WikiRevision{
+			Title:   title,
+			Content: req.Content,
+			UserID:  userIDOrNil(req.UserID),
+			Summary: req.Summary,
+		})
 	})
-
 	if err != nil {
 		return nil, err
 	}
-	return createdPage, nil
+	return s.GetWikiPageByID(newPage.PageID)
 }
 
+// UpdateWikiPage は編集内容を新しい版として保存するのだ
+// タイトルも本文も変わっていなければ版は増やさないのだ
 func (s *wikiService) UpdateWikiPage(id uint, req UpdateWikiPageRequest) (*model.WikiPage, error) {
-	var updatedPage *model.WikiPage
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, ErrInvalidWikiPage
+	}
+
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		target, err := s.repo.FindByID(id)
+		target, err := s.repo.FindByIDForUpdate(tx, id)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		current, err := s.repo.FindLatestRevision(tx, target.PageID)
 		if err != nil {
 			return err
 		}
-
-		target.Title = req.Title
-		target.UserID = req.UserID
-		target.ContentPath = req.ContentPath
-
-		updatedPage, err = s.repo.Update(tx, target)
-		return err
+		if req.BaseRevision != nil && current != nil && current.RevisionNumber != *req.BaseRevision {
+			return ErrWikiEditConflict
+		}
+		if current != nil && current.Title == title && current.Content == req.Content {
+			return nil
+		}
+		return s.addRevision(tx, target, &model.WikiRevision{
+			Title:   title,
+			Content: req.Content,
+			UserID:  userIDOrNil(req.UserID),
+			Summary: req.Summary,
+		})
 	})
-
 	if err != nil {
 		return nil, err
 	}
-	return updatedPage, nil
+	return s.GetWikiPageByID(id)
 }
 
+// DeleteWikiPage はページと全ての版を削除するのだ
+// 観察手法・標本作製手法の手順書として使われているページは消せないのだ
 func (s *wikiService) DeleteWikiPage(id uint) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
+		// ページの行をロックしておくと、確認してから消すまでの間に手法から参照されることはないのだ
+		// (参照を付ける側は外部キーの確認でこのロックを待ち、消えた後なら制約で弾かれるのだ)
+		if _, err := s.repo.FindByIDForUpdate(tx, id); err != nil {
+			return notFoundOr(err)
+		}
+		count, err := s.repo.CountLinkedMethods(tx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %d件の手法から参照されています", ErrWikiPageInUse, count)
+		}
 		return s.repo.Delete(tx, id)
 	})
 }
+
+// GetRevisions は版の一覧を新しい順に返すのだ (本文は含まないのだ)
+func (s *wikiService) GetRevisions(pageID uint) ([]model.WikiRevision, error) {
+	if _, err := s.GetWikiPageByID(pageID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindRevisions(pageID)
+}
+
+// GetRevision は版を番号で1件返すのだ
+func (s *wikiService) GetRevision(pageID uint, number int) (*model.WikiRevision, error) {
+	revision, err := s.repo.FindRevision(pageID, number)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+	return revision, nil
+}
+
+// DiffRevisions は2つの版の本文を行ごとに比べるのだ
+func (s *wikiService) DiffRevisions(pageID uint, from, to int) (*WikiDiff, error) {
+	older, err := s.GetRevision(pageID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := s.GetRevision(pageID, to)
+	if err != nil {
+		return nil, err
+	}
+	return &WikiDiff{
+		PageID:    pageID,
+		From:      from,
+		To:        to,
+		FromTitle: older.Title,
+		ToTitle:   newer.Title,
+		Lines:     diffLines(older.Content, newer.Content),
+	}, nil
+}
+
+// RevertWikiPage は過去の版の内容をそのまま新しい版として保存するのだ。履歴は消さないのだ
+func (s *wikiService) RevertWikiPage(pageID uint, req RevertWikiPageRequest) (*model.WikiPage, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		target, err := s.repo.FindByIDForUpdate(tx, pageID)
+		if err != nil {
+			return notFoundOr(err)
+		}
+		old, err := s.GetRevision(pageID, req.RevisionNumber)
+		if err != nil {
+			return err
+		}
+		number := old.RevisionNumber
+		return s.addRevision(tx, target, &model.WikiRevision{
+			Title:      old.Title,
+			Content:    old.Content,
+			UserID:     userIDOrNil(req.UserID),
+			Summary:    req.Summary,
+			RevertedTo: &number,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWikiPageByID(pageID)
+}
+
+// RenderWikiPage は本文をサニタイズ済みのHTMLにするのだ。revision がnilなら最新の版なのだ
+func (s *wikiService) RenderWikiPage(pageID uint, revision *int) (*RenderedWikiPage, error) {
+	var rev *model.WikiRevision
+	if revision != nil {
+		found, err := s.GetRevision(pageID, *revision)
+		if err != nil {
+			return nil, err
+		}
+		rev = found
+	} else {
+		page, err := s.GetWikiPageByID(pageID)
+		if err != nil {
+			return nil, err
+		}
+		if page.CurrentRevision == nil {
+			return &RenderedWikiPage{PageID: pageID, Title: page.Title}, nil
+		}
+		rev = page.CurrentRevision
+	}
+
+	html, err := renderWikiMarkdown(rev.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedWikiPage{
+		PageID:         pageID,
+		RevisionNumber: rev.RevisionNumber,
+		Title:          rev.Title,
+		HTML:           html,
+	}, nil
+}
+
+// MigrateLegacyContent は本文をDBに持つ前に作られたページについて、content_path のファイルを最初の版として保存するのだ
+// 読めなかったファイルは飛ばして結果に残すので、直してからもう一度実行すればよいのだ
+func (s *wikiService) MigrateLegacyContent(req MigrateWikiContentRequest) (*WikiMigrationResult, error) {
+	pages, err := s.repo.FindLegacyPages()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WikiMigrationResult{Migrated: []uint{}, Failed: []WikiMigrationFail{}}
+	for _, page := range pages {
+		content, err := fs.ReadFile(req.Files, strings.TrimPrefix(path.Clean(page.ContentPath), "/"))
+		if err != nil {
+			result.Failed = append(result.Failed, WikiMigrationFail{PageID: page.PageID, ContentPath: page.ContentPath, Reason: err.Error()})
+			continue
+		}
+		if req.DryRun {
+			result.Migrated = append(result.Migrated, page.PageID)
+			continue
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			target, err := s.repo.FindByIDForUpdate(tx, page.PageID)
+			if err != nil {
+				return err
+			}
+			// 探してから移すまでの間に編集されていたら、その版を上書きしないように飛ばすのだ
+			if target.CurrentRevisionID != nil {
+				return errors.New("移行の間に新しい版が保存されました")
+			}
+			// 移した版の日時はページを最後に更新した日時にして、履歴の並びを保つのだ
+			createdAt := target.CreatedDate
+			if target.UpdatedDate != nil {
+				createdAt = *target.UpdatedDate
+			}
+			revision := &model.WikiRevision{
+				PageID:    target.PageID,
+				Title:     target.Title,
+				Content:   string(content),
+				UserID:    userIDOrNil(target.UserID),
+				Summary:   "content_path から移行",
+				CreatedAt: createdAt,
+			}
+			if _, err := s.repo.CreateRevision(tx, revision); err != nil {
+				return err
+			}
+			target.CurrentRevisionID = &revision.RevisionID
+			_, err = s.repo.Update(tx, target)
+			return err
+		})
+		if err != nil {
+			result.Failed = append(result.Failed, WikiMigrationFail{PageID: page.PageID, ContentPath: page.ContentPath, Reason: err.Error()})
+			continue
+		}
+		result.Migrated = append(result.Migrated, page.PageID)
+	}
+	return result, nil
+}
+
+// addRevision は版を追加し、ページのタイトル・更新日時・最新の版を合わせるのだ
+func (s *wikiService) addRevision(tx *gorm.DB, page *model.WikiPage, revision *model.WikiRevision) error {
+	revision.PageID = page.PageID
+	if _, err := s.repo.CreateRevision(tx, revision); err != nil {
+		return err
+	}
+	now := time.Now()
+	page.Title = revision.Title
+	page.UpdatedDate = &now
+	page.CurrentRevisionID = &revision.RevisionID
+	_, err := s.repo.Update(tx, page)
+	return err
+}
+
+// userIDOrNil は0(未指定)のユーザーIDをnilにするのだ
+func userIDOrNil(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
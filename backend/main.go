@@ -8,6 +8,7 @@ import (
 	"github.com/saku-730/specimen-web/backend/config"
 	"github.com/saku-730/specimen-web/backend/internal/handler"
 	"github.com/saku-730/specimen-web/backend/internal/infrastructure"
+	"github.com/saku-730/specimen-web/backend/internal/infrastructure/storage"
 	"github.com/saku-730/specimen-web/backend/internal/repository"
 	"github.com/saku-730/specimen-web/backend/internal/service"
 )
@@ -25,6 +26,12 @@ func main() {
 		log.Fatalf("Falied connect database: %v", err)
 	}
 
+	// 添付ファイルの保存先
+	fileStorage, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed setup storage: %v", err)
+	}
+
 	// New each Repository 
 	userRepo := repository.NewUserRepository(db)
 	occurrenceRepo := repository.NewOccurrenceRepository(db)
@@ -33,18 +40,43 @@ func main() {
 	identificationRepo := repository.NewIdentificationRepository(db)
 	observationRepo := repository.NewObservationRepository(db)
 	wikiRepo := repository.NewWikiRepository(db)
-	_ = repository.NewAttachmentRepository(db) // service/handlerがないので一旦変数に入れない
+	attachmentRepo := repository.NewAttachmentRepository(db)
 	_ = repository.NewLogRepository(db)
-	_ = repository.NewPlaceRepository(db)
+	placeRepo := repository.NewPlaceRepository(db)
+	adminBoundaryRepo := repository.NewAdminBoundaryRepository(db)
+	textSearchRepo := repository.NewTextSearchRepository(db)
+	storageLocationRepo := repository.NewStorageLocationRepository(db)
+	loanRepo := repository.NewLoanRepository(db)
+	molecularRepo := repository.NewMolecularRepository(db)
+	vocabularyRepo := repository.NewVocabularyRepository(db)
+	controlledTermRepo := repository.NewControlledTermRepository(db)
+	measurementRepo := repository.NewMeasurementRepository(db)
+	eventRepo := repository.NewEventRepository(db)
+	individualRepo := repository.NewIndividualRepository(db)
 
 	// Service層を初期化
 	userService := service.NewUserService(db, userRepo)
-	occurrenceService := service.NewOccurrenceService(db, occurrenceRepo)
+	geocodingService := service.NewGeocodingService(db, adminBoundaryRepo, placeRepo)
+	coordinateService := service.NewCoordinateService()
+	controlledTermService := service.NewControlledTermService(db, controlledTermRepo, vocabularyRepo)
+	occurrenceService := service.NewOccurrenceService(db, occurrenceRepo, geocodingService, coordinateService, controlledTermService)
+	darwinCoreService := service.NewDarwinCoreService(db, occurrenceRepo, eventRepo, controlledTermService, cfg.PublicBaseURL)
 	projectService := service.NewProjectService(db, projectRepo)
-	specimenService := service.NewSpecimenService(db, specimenRepo)
+	specimenService := service.NewSpecimenService(db, specimenRepo, occurrenceRepo)
 	identificationService := service.NewIdentificationService(db, identificationRepo)
 	observationService := service.NewObservationService(db, observationRepo)
 	wikiService := service.NewWikiService(db, wikiRepo)
+	attachmentService := service.NewAttachmentService(db, attachmentRepo, occurrenceRepo, fileStorage)
+	textSearchService := service.NewTextSearchService(db, textSearchRepo)
+	labelService := service.NewLabelService(db, specimenRepo, cfg.PublicBaseURL, cfg.LabelFontPath)
+	storageLocationService := service.NewStorageLocationService(db, storageLocationRepo, specimenRepo)
+	loanService := service.NewLoanService(db, loanRepo, specimenRepo)
+	preparationService := service.NewPreparationService(db, specimenRepo, occurrenceRepo)
+	molecularService := service.NewMolecularService(db, molecularRepo, specimenRepo, storageLocationRepo)
+	vocabularyService := service.NewVocabularyService(db, vocabularyRepo)
+	measurementService := service.NewMeasurementService(db, measurementRepo)
+	eventService := service.NewEventService(db, eventRepo)
+	individualService := service.NewIndividualService(db, individualRepo)
 
 	// Handler層を初期化
 	userHandler := handler.NewUserHandler(userService)
@@ -54,6 +86,20 @@ func main() {
 	identificationHandler := handler.NewIdentificationHandler(identificationService)
 	observationHandler := handler.NewObservationHandler(observationService)
 	wikiHandler := handler.NewWikiHandler(wikiService)
+	placeHandler := handler.NewPlaceHandler(geocodingService, coordinateService)
+	exportHandler := handler.NewExportHandler(darwinCoreService)
+	attachmentHandler := handler.NewAttachmentHandler(attachmentService)
+	textSearchHandler := handler.NewTextSearchHandler(textSearchService)
+	labelHandler := handler.NewLabelHandler(labelService)
+	storageLocationHandler := handler.NewStorageLocationHandler(storageLocationService)
+	loanHandler := handler.NewLoanHandler(loanService)
+	preparationHandler := handler.NewPreparationHandler(preparationService)
+	molecularHandler := handler.NewMolecularHandler(molecularService)
+	vocabularyHandler := handler.NewVocabularyHandler(vocabularyService)
+	controlledTermHandler := handler.NewControlledTermHandler(controlledTermService)
+	measurementHandler := handler.NewMeasurementHandler(measurementService)
+	eventHandler := handler.NewEventHandler(eventService)
+	individualHandler := handler.NewIndividualHandler(individualService)
 
 	//setup router
 	router := gin.Default()
@@ -71,6 +117,20 @@ func main() {
 		identificationHandler.RegisterIdentificationRoutes(apiV0_0_1)
 		observationHandler.RegisterObservationRoutes(apiV0_0_1)
 		wikiHandler.RegisterWikiRoutes(apiV0_0_1)
+		placeHandler.RegisterPlaceRoutes(apiV0_0_1)
+		exportHandler.RegisterExportRoutes(apiV0_0_1)
+		attachmentHandler.RegisterAttachmentRoutes(apiV0_0_1)
+		textSearchHandler.RegisterTextSearchRoutes(apiV0_0_1)
+		labelHandler.RegisterLabelRoutes(apiV0_0_1)
+		storageLocationHandler.RegisterStorageLocationRoutes(apiV0_0_1)
+		loanHandler.RegisterLoanRoutes(apiV0_0_1)
+		preparationHandler.RegisterPreparationRoutes(apiV0_0_1)
+		molecularHandler.RegisterMolecularRoutes(apiV0_0_1)
+		vocabularyHandler.RegisterVocabularyRoutes(apiV0_0_1)
+		controlledTermHandler.RegisterControlledTermRoutes(apiV0_0_1)
+		measurementHandler.RegisterMeasurementRoutes(apiV0_0_1)
+		eventHandler.RegisterEventRoutes(apiV0_0_1)
+		individualHandler.RegisterIndividualRoutes(apiV0_0_1)
 	}
 
 	// start server
@@ -0,0 +1,30 @@
+-- 添付ファイルの保存に必要な情報を追加するのだ
+ALTER TABLE attachments ADD COLUMN original_name TEXT;
+ALTER TABLE attachments ADD COLUMN content_type TEXT;
+ALTER TABLE attachments ADD COLUMN size_bytes BIGINT;
+ALTER TABLE attachments ADD COLUMN checksum_sha256 CHAR(64);
+ALTER TABLE attachments ADD COLUMN created_at TIMESTAMP WITH TIME ZONE DEFAULT now();
+
+CREATE UNIQUE INDEX attachments_file_path_key ON attachments (file_path);
+CREATE INDEX attachment_goup_attachment_idx ON attachment_goup (attachment_ID);
+
+-- 拡張子は小文字・ドットなしで一意にするのだ (MIMEの判定結果からここを引くのだ)
+CREATE UNIQUE INDEX file_extensions_extension_text_key ON file_extensions (extension_text);
+CREATE UNIQUE INDEX file_types_type_name_key ON file_types (type_name);
+
+INSERT INTO file_types (type_name) VALUES
+    ('image'), ('audio'), ('video'), ('document'), ('data')
+ON CONFLICT (type_name) DO NOTHING;
+
+INSERT INTO file_extensions (extension_text, file_type_ID)
+SELECT v.ext, t.file_type_ID
+FROM (VALUES
+    ('jpg', 'image'), ('png', 'image'), ('gif', 'image'), ('webp', 'image'),
+    ('tiff', 'image'), ('heic', 'image'), ('bmp', 'image'),
+    ('mp3', 'audio'), ('wav', 'audio'), ('flac', 'audio'), ('ogg', 'audio'), ('m4a', 'audio'),
+    ('mp4', 'video'), ('mov', 'video'), ('webm', 'video'),
+    ('pdf', 'document'), ('txt', 'document'),
+    ('csv', 'data'), ('json', 'data'), ('zip', 'data')
+) AS v(ext, type_name)
+JOIN file_types t ON t.type_name = v.type_name
+ON CONFLICT (extension_text) DO NOTHING;
@@ -0,0 +1,24 @@
+-- 写真のEXIFから取り出した情報なのだ (発生情報の場所・日時の初期値として使うのだ)
+ALTER TABLE attachments ADD COLUMN width INT;
+ALTER TABLE attachments ADD COLUMN height INT;
+ALTER TABLE attachments ADD COLUMN camera_make TEXT;
+ALTER TABLE attachments ADD COLUMN camera_model TEXT;
+ALTER TABLE attachments ADD COLUMN taken_at TIMESTAMP WITH TIME ZONE;
+-- 撮影地の時差(分)なのだ。NULLなら時差が分からず、taken_at は現地の時刻をUTCとして入れてあるのだ
+ALTER TABLE attachments ADD COLUMN taken_at_offset_minutes SMALLINT;
+ALTER TABLE attachments ADD COLUMN gps_latitude NUMERIC;
+ALTER TABLE attachments ADD COLUMN gps_longitude NUMERIC;
+ALTER TABLE attachments ADD COLUMN gps_altitude NUMERIC;
+ALTER TABLE attachments ADD COLUMN gps_accuracy NUMERIC;
+
+-- サムネイルなのだ。元の添付ファイルを消したら一緒に消えるのだ
+CREATE TABLE attachment_thumbnails (
+    thumbnail_ID SERIAL PRIMARY KEY,
+    attachment_ID INT NOT NULL REFERENCES attachments(attachment_ID) ON DELETE CASCADE,
+    size_name TEXT NOT NULL,
+    file_path TEXT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+
+    UNIQUE (attachment_ID, size_name)
+);
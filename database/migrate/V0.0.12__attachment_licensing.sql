@@ -0,0 +1,11 @@
+-- 公開のための権利情報と、Audubon Core の説明項目なのだ
+-- license はSPDX形式の識別子 (CC-BY-4.0 など) なのだ。NULLのものは公開用の出力に含めないのだ
+ALTER TABLE attachments ADD COLUMN license TEXT;
+ALTER TABLE attachments ADD COLUMN rights_holder TEXT;
+ALTER TABLE attachments ADD COLUMN creator TEXT;
+ALTER TABLE attachments ADD COLUMN caption TEXT;
+-- 写っている部位なのだ (例: dorsal habitus, head, genitalia)
+ALTER TABLE attachments ADD COLUMN subject_part TEXT;
+ALTER TABLE attachments ADD COLUMN capture_device TEXT;
+
+CREATE INDEX attachments_license_idx ON attachments (license);
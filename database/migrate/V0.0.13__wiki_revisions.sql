@@ -0,0 +1,18 @@
+-- Wikiの本文は版(リビジョン)ごとにDBへ保存するのだ
+-- 編集するたびに新しい版を追加し、wiki_pages は最新の版を指すのだ
+CREATE TABLE wiki_revisions (
+    revision_ID SERIAL PRIMARY KEY,
+    page_ID INT NOT NULL REFERENCES wiki_pages(page_ID) ON DELETE CASCADE,
+    revision_number INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    user_ID INT REFERENCES users(user_ID),
+    summary TEXT,
+    -- 差し戻しで作った版なら、戻し先の版番号なのだ
+    reverted_to INT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+
+    UNIQUE (page_ID, revision_number)
+);
+
+ALTER TABLE wiki_pages ADD COLUMN current_revision_ID INT REFERENCES wiki_revisions(revision_ID);
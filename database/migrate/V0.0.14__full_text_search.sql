@@ -0,0 +1,89 @@
+-- Wikiの本文とメモ類の全文検索なのだ
+-- 英語は english 辞書の tsvector (語幹でまとめる) で、日本語は文字の2-gramで引くのだ
+-- pg_bigm が入っていない環境でも動くように、2-gramは自前で作って tsvector に入れるのだ
+
+-- cjk_bigrams は文字列の中のかな・漢字の並びを2文字ずつに切ったものなのだ
+-- 1文字だけの並びはそのまま1文字で入れるのだ
+CREATE OR REPLACE FUNCTION cjk_bigrams(input TEXT) RETURNS TEXT[]
+LANGUAGE plpgsql IMMUTABLE PARALLEL SAFE AS $$
+DECLARE
+    run TEXT;
+    grams TEXT[] := '{}';
+    i INT;
+BEGIN
+    IF input IS NULL THEN
+        RETURN grams;
+    END IF;
+    FOR run IN
+        SELECT m[1]
+        FROM regexp_matches(input, '([々぀-ヿ㐀-䶿一-鿿豈-﫿ｦ-ﾟ]+)', 'g') AS m
+    LOOP
+        IF char_length(run) = 1 THEN
+            grams := grams || run;
+        ELSE
+            FOR i IN 1 .. char_length(run) - 1 LOOP
+                grams := grams || substr(run, i, 2);
+            END LOOP;
+        END IF;
+    END LOOP;
+    RETURN grams;
+END
+$$;
+
+-- text_search_vector は英語の語と日本語の2-gramをまとめた tsvector なのだ
+-- 2-gramは array_to_tsvector で入れるので、DBのロケールに関係なくそのまま索引に載るのだ
+CREATE OR REPLACE FUNCTION text_search_vector(input TEXT) RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT to_tsvector('english'::regconfig, coalesce(input, ''))
+        || array_to_tsvector(cjk_bigrams(input))
+$$;
+
+-- text_search_query は検索語を tsquery にするのだ
+-- 英語の部分は websearch_to_tsquery の書き方 ("語句", -除外, or) が使えて、日本語の部分は2-gramの AND になるのだ
+CREATE OR REPLACE FUNCTION text_search_query(input TEXT) RETURNS tsquery
+LANGUAGE plpgsql IMMUTABLE PARALLEL SAFE AS $$
+DECLARE
+    latin tsquery;
+    grams TEXT[];
+    cjk tsquery;
+BEGIN
+    latin := websearch_to_tsquery('english'::regconfig,
+        regexp_replace(coalesce(input, ''), '[々぀-ヿ㐀-䶿一-鿿豈-﫿ｦ-ﾟ]+', ' ', 'g'));
+    grams := cjk_bigrams(input);
+
+    IF cardinality(grams) > 0 THEN
+        -- 1文字だけの語は、その文字で始まる2-gramにも当たるように前方一致にするのだ
+        cjk := array_to_string(ARRAY(
+            SELECT quote_literal(g) || CASE WHEN char_length(g) = 1 THEN ':*' ELSE '' END
+            FROM unnest(grams) AS g
+        ), ' & ')::tsquery;
+    END IF;
+
+    IF cjk IS NULL THEN
+        RETURN latin;
+    END IF;
+    IF numnode(latin) = 0 THEN
+        RETURN cjk;
+    END IF;
+    RETURN latin && cjk;
+END
+$$;
+
+-- Wikiは版ごとに持つのだ (検索では各ページの最新の版だけを見るのだ)。タイトルに当たったものを上に出すのだ
+ALTER TABLE wiki_revisions ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(text_search_vector(title), 'A') || setweight(text_search_vector(content), 'B')
+    ) STORED;
+CREATE INDEX wiki_revisions_search_idx ON wiki_revisions USING GIN (search_vector);
+
+ALTER TABLE occurrence ADD COLUMN note_search tsvector
+    GENERATED ALWAYS AS (text_search_vector(note)) STORED;
+CREATE INDEX occurrence_note_search_idx ON occurrence USING GIN (note_search);
+
+ALTER TABLE observations ADD COLUMN behavior_search tsvector
+    GENERATED ALWAYS AS (text_search_vector(behavior)) STORED;
+CREATE INDEX observations_behavior_search_idx ON observations USING GIN (behavior_search);
+
+ALTER TABLE identifications ADD COLUMN source_info_search tsvector
+    GENERATED ALWAYS AS (text_search_vector(source_info)) STORED;
+CREATE INDEX identifications_source_info_search_idx ON identifications USING GIN (source_info_search);
@@ -0,0 +1,17 @@
+-- 標本の登録番号 (catalogue number) なのだ
+-- コレクションごとに番号の形 (例: {institution}-{collection}-{yyyy}-{seq:05}) を決めて、作成のトランザクションの中で採番するのだ
+ALTER TABLE collection_ID_code ADD COLUMN catalog_number_pattern TEXT;
+
+ALTER TABLE specimen ADD COLUMN catalog_number TEXT;
+-- 大文字小文字を区別せずに一意で、前方一致の検索にも使えるのだ
+CREATE UNIQUE INDEX specimen_catalog_number_key ON specimen (lower(catalog_number) text_pattern_ops);
+
+-- 採番の連番なのだ。scope は番号の形から連番を除いて展開したもの (例: NSMT-ENT-2026-{seq}) で、年が変わると1から数え直すのだ
+-- 標本を消しても連番は戻さないので、同じ番号が二度使われることはないのだ
+CREATE TABLE catalog_number_sequences (
+    collection_ID INT NOT NULL REFERENCES collection_ID_code(collection_ID) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    last_value BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (collection_ID, scope)
+);
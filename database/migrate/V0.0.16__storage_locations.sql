@@ -0,0 +1,32 @@
+-- 標本の置き場所なのだ
+-- 建物 > 部屋 > キャビネット > 引き出し > ユニットトレイ の入れ子にするのだ。途中の段は飛ばしてもいいのだ
+CREATE TABLE storage_locations (
+    storage_location_ID SERIAL PRIMARY KEY,
+    parent_ID INT REFERENCES storage_locations(storage_location_ID),
+    level TEXT NOT NULL CHECK (level IN ('building', 'room', 'cabinet', 'drawer', 'unit_tray')),
+    code TEXT NOT NULL, -- 引き出しの札などに書いてある記号なのだ (例: C-12)
+    name TEXT,
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- 同じ親の下で記号が重ならないようにするのだ。一番上の段は親がないので別に見るのだ
+CREATE UNIQUE INDEX storage_locations_code_key ON storage_locations (parent_ID, lower(code)) WHERE parent_ID IS NOT NULL;
+CREATE UNIQUE INDEX storage_locations_root_code_key ON storage_locations (lower(code)) WHERE parent_ID IS NULL;
+CREATE INDEX storage_locations_lower_code_idx ON storage_locations (lower(code) text_pattern_ops);
+
+-- 標本が今ある場所なのだ
+ALTER TABLE specimen ADD COLUMN storage_location_ID INT REFERENCES storage_locations(storage_location_ID);
+CREATE INDEX specimen_storage_location_idx ON specimen (storage_location_ID);
+
+-- 標本を動かした履歴なのだ。from が NULL なら初めて置いたとき、to が NULL なら棚から出したとき (貸し出しなど) なのだ
+CREATE TABLE specimen_movements (
+    specimen_movement_ID SERIAL PRIMARY KEY,
+    specimen_ID INT NOT NULL REFERENCES specimen(specimen_ID) ON DELETE CASCADE,
+    from_location_ID INT REFERENCES storage_locations(storage_location_ID),
+    to_location_ID INT REFERENCES storage_locations(storage_location_ID),
+    user_ID INT REFERENCES users(user_ID),
+    moved_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    timezone SMALLINT NOT NULL DEFAULT 0,
+    note TEXT
+);
+CREATE INDEX specimen_movements_specimen_idx ON specimen_movements (specimen_ID, moved_at);
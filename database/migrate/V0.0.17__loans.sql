@@ -0,0 +1,35 @@
+-- 標本の貸し出しなのだ
+-- 借りる機関は institution_ID_code を使うのだ。全部返ってきたら closed_date を入れて閉じるのだ
+CREATE TABLE loans (
+    loan_ID SERIAL PRIMARY KEY,
+    borrower_institution_ID INT NOT NULL REFERENCES institution_ID_code(institution_ID),
+    contact_name TEXT NOT NULL,
+    contact_email TEXT,
+    purpose TEXT,
+    loan_date DATE NOT NULL,
+    due_date DATE,
+    closed_date DATE,
+    note TEXT,
+    user_ID INT REFERENCES users(user_ID),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (due_date IS NULL OR due_date >= loan_date)
+);
+CREATE INDEX loans_open_due_idx ON loans (due_date) WHERE closed_date IS NULL;
+CREATE INDEX loans_borrower_idx ON loans (borrower_institution_ID);
+
+-- 貸し出した標本なのだ。一部だけ返ってくることもあるので、標本ごとに返却日を持つのだ
+CREATE TABLE loan_specimens (
+    loan_ID INT NOT NULL REFERENCES loans(loan_ID) ON DELETE CASCADE,
+    specimen_ID INT NOT NULL REFERENCES specimen(specimen_ID) ON DELETE CASCADE, -- 貸し出し中の標本はサービスで消せないようにしているのだ
+    returned_date DATE,
+    return_note TEXT,
+
+    PRIMARY KEY (loan_ID, specimen_ID)
+);
+-- 同じ標本を2つの貸し出しに同時に入れないようにするのだ
+CREATE UNIQUE INDEX loan_specimens_outstanding_key ON loan_specimens (specimen_ID) WHERE returned_date IS NULL;
+
+-- 標本が館内にあるか貸し出し中かなのだ。検索で絞り込めるように列で持って、貸し出しと返却のトランザクションで書き換えるのだ
+ALTER TABLE specimen ADD COLUMN status TEXT NOT NULL DEFAULT 'in_house' CHECK (status IN ('in_house', 'on_loan'));
+CREATE INDEX specimen_status_idx ON specimen (status);
@@ -0,0 +1,13 @@
+-- 1個体からいくつもの標本 (乾燥標本・交尾器のプレパラート・エタノール漬けの組織など) を作れるようにするのだ
+-- 標本ごとに部位を持ち、どの標本から取り分けたかを parent_specimen_ID でたどるのだ
+ALTER TABLE specimen ADD COLUMN part TEXT;
+ALTER TABLE specimen ADD COLUMN parent_specimen_ID INT REFERENCES specimen(specimen_ID);
+CREATE INDEX specimen_parent_idx ON specimen (parent_specimen_ID);
+CREATE INDEX specimen_occurrence_idx ON specimen (occurrence_ID);
+
+-- 作製の記録に進み具合を持たせて、これからする作製も登録できるようにするのだ
+-- 今までの記録は作製が終わったものなので done にするのだ
+ALTER TABLE make_specimen ADD COLUMN status TEXT NOT NULL DEFAULT 'done' CHECK (status IN ('pending', 'in_progress', 'done'));
+ALTER TABLE make_specimen ADD COLUMN note TEXT;
+-- 作製する人ごとの作業待ちの一覧に使うのだ
+CREATE INDEX make_specimen_queue_idx ON make_specimen (user_ID, created_at) WHERE status <> 'done';
@@ -0,0 +1,39 @@
+-- 標本から取り分けたDNA用の試料 (組織・DNA抽出液・PCR産物) と、その塩基配列なのだ
+-- 組織からDNAを抽出し、抽出液からPCRをかけるので、parent_sample_ID でたどれるようにするのだ
+CREATE TABLE molecular_samples (
+    sample_ID SERIAL PRIMARY KEY,
+    specimen_ID INT NOT NULL REFERENCES specimen(specimen_ID) ON DELETE CASCADE,
+    parent_sample_ID INT REFERENCES molecular_samples(sample_ID),
+    sample_type TEXT NOT NULL CHECK (sample_type IN ('tissue', 'dna_extract', 'pcr_product')),
+    sample_code TEXT, -- チューブに書いた番号なのだ
+    storage_location_ID INT REFERENCES storage_locations(storage_location_ID),
+    concentration_ng_per_ul DOUBLE PRECISION CHECK (concentration_ng_per_ul >= 0),
+    volume_ul DOUBLE PRECISION CHECK (volume_ul >= 0),
+    extraction_method TEXT, -- 抽出キットやプロトコルなのだ (例: DNeasy Blood & Tissue)
+    user_ID INT REFERENCES users(user_ID), -- 作った人なのだ
+    prepared_date DATE,
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX molecular_samples_code_key ON molecular_samples (lower(sample_code));
+CREATE INDEX molecular_samples_specimen_idx ON molecular_samples (specimen_ID);
+CREATE INDEX molecular_samples_parent_idx ON molecular_samples (parent_sample_ID);
+
+-- 試料から読んだ塩基配列なのだ。登録番号 (GenBank・BOLD) は登録前なら空なのだ
+CREATE TABLE sequences (
+    sequence_ID SERIAL PRIMARY KEY,
+    sample_ID INT NOT NULL REFERENCES molecular_samples(sample_ID) ON DELETE CASCADE,
+    marker TEXT NOT NULL, -- 遺伝子領域なのだ (例: COI, 16S, ITS)
+    accession_source TEXT CHECK (accession_source IN ('genbank', 'bold')),
+    accession TEXT,
+    definition TEXT, -- FASTAから読んだときの見出しなのだ
+    sequence TEXT NOT NULL,
+    sequence_length INT GENERATED ALWAYS AS (length(sequence)) STORED,
+    user_ID INT REFERENCES users(user_ID),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (accession IS NULL OR accession_source IS NOT NULL)
+);
+CREATE INDEX sequences_sample_idx ON sequences (sample_ID);
+CREATE INDEX sequences_marker_idx ON sequences (lower(marker));
+CREATE UNIQUE INDEX sequences_accession_key ON sequences (accession_source, accession);
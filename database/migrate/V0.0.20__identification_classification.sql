@@ -0,0 +1,18 @@
+-- 同定ごとに、そのとき決めた分類を持たせるのだ
+-- 発生情報の classification_ID は一番新しい同定の分類に合わせるので、同定の履歴から今の同定をたどれるのだ
+ALTER TABLE identifications ADD COLUMN classification_ID INT REFERENCES classification_json(classification_ID);
+
+-- 今までの同定は分類を持っていなかったので、発生情報の今の分類を一番新しい同定に付けておくのだ
+UPDATE identifications i
+SET classification_ID = o.classification_ID
+FROM occurrence o
+WHERE o.occurrence_ID = i.occurrence_ID
+  AND i.identification_ID = (
+      SELECT latest.identification_ID
+      FROM identifications latest
+      WHERE latest.occurrence_ID = i.occurrence_ID
+      ORDER BY latest.identificated_at DESC, latest.identification_ID DESC
+      LIMIT 1
+  );
+
+CREATE INDEX identifications_occurrence_idx ON identifications (occurrence_ID, identificated_at DESC);
@@ -0,0 +1,44 @@
+-- projects の説明の列名が disscription になっていたので、アプリと同じ description に直すのだ
+ALTER TABLE projects RENAME COLUMN disscription TO description;
+
+CREATE INDEX project_members_project_idx ON project_members (project_ID);
+CREATE INDEX occurrence_project_idx ON occurrence (project_ID);
+
+-- updated_day はプロジェクトか、そのメンバー・発生情報が変わった日にするのだ
+-- アプリから書き忘れないように、トリガーで付けるのだ
+CREATE OR REPLACE FUNCTION set_project_updated_day() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.updated_day := current_date;
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER projects_updated_day
+    BEFORE INSERT OR UPDATE ON projects
+    FOR EACH ROW EXECUTE FUNCTION set_project_updated_day();
+
+-- touch_project_updated_day はメンバーや発生情報の行が付いているプロジェクトの updated_day を今日にするのだ
+-- 別のプロジェクトに付け替えたときは、前と後の両方を今日にするのだ
+CREATE OR REPLACE FUNCTION touch_project_updated_day() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.project_ID IS NOT NULL THEN
+        UPDATE projects SET updated_day = current_date
+        WHERE project_ID = OLD.project_ID AND updated_day IS DISTINCT FROM current_date;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.project_ID IS NOT NULL THEN
+        UPDATE projects SET updated_day = current_date
+        WHERE project_ID = NEW.project_ID AND updated_day IS DISTINCT FROM current_date;
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER project_members_touch_project
+    AFTER INSERT OR UPDATE OR DELETE ON project_members
+    FOR EACH ROW EXECUTE FUNCTION touch_project_updated_day();
+
+CREATE TRIGGER occurrence_touch_project
+    AFTER INSERT OR UPDATE OR DELETE ON occurrence
+    FOR EACH ROW EXECUTE FUNCTION touch_project_updated_day();
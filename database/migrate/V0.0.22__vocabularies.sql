@@ -0,0 +1,52 @@
+-- 作製方法・機関コード・コレクションコード・観察方法・ファイルの種類・言語を、管理画面から直せる語彙にするのだ
+-- sort_order は選択肢に出す順、deprecated は使われていて消せない値をこれからの選択肢から外す印なのだ
+-- labels は言語ごとの表示名で、languages.language_short をキーにしたオブジェクトなのだ (例: {"en": "Dried", "jp": "乾燥"})
+ALTER TABLE specimen_methods
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE institution_ID_code
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE collection_ID_code
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE observation_methods
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE file_types
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE languages
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN deprecated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+
+-- 今までの順番 (IDの順) のままになるようにするのだ
+UPDATE specimen_methods SET sort_order = specimen_methods_ID;
+UPDATE institution_ID_code SET sort_order = institution_ID;
+UPDATE collection_ID_code SET sort_order = collection_ID;
+UPDATE observation_methods SET sort_order = observation_method_ID;
+UPDATE file_types SET sort_order = file_type_ID;
+UPDATE languages SET sort_order = language_ID;
+
+-- 言語の初めの行はIDを指定して入れたので、シーケンスが進んでいないのだ。追加した言語とぶつからないように進めておくのだ
+SELECT setval(pg_get_serial_sequence('languages', 'language_id'), COALESCE(MAX(language_ID), 0) + 1, false) FROM languages;
+
+-- 使っている数を数えるときのために、参照している列に索引を付けるのだ
+CREATE INDEX specimen_method_idx ON specimen (specimen_method_ID);
+CREATE INDEX specimen_institution_idx ON specimen (institution_ID);
+CREATE INDEX specimen_collection_idx ON specimen (collection_id);
+CREATE INDEX make_specimen_method_idx ON make_specimen (specimen_method_ID);
+CREATE INDEX observations_method_idx ON observations (observation_method_ID);
+CREATE INDEX file_extensions_type_idx ON file_extensions (file_type_ID);
+CREATE INDEX occurrence_language_idx ON occurrence (language_ID);
@@ -0,0 +1,30 @@
+-- 性別・成長段階のように決まった値から選ぶ項目の語彙なのだ
+-- field は発生情報の列の名前で、value が発生情報に入れる値なのだ
+-- dwc_value はDarwin Coreで出すときの推奨の値で、NULLなら出さないのだ
+-- synonyms は前に自由に書かれていた書き方 (M、♂、オスなど) で、入力と既存データの読み替えに使うのだ
+-- labels は languages.language_short をキーにした表示名なのだ
+CREATE TABLE controlled_terms (
+    term_ID SERIAL PRIMARY KEY,
+    field TEXT NOT NULL CHECK (field IN ('sex', 'lifestage')),
+    value TEXT NOT NULL,
+    dwc_value TEXT,
+    synonyms JSONB NOT NULL DEFAULT '[]',
+    labels JSONB NOT NULL DEFAULT '{}',
+    sort_order INT NOT NULL DEFAULT 0,
+    deprecated BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX controlled_terms_value_idx ON controlled_terms (field, lower(value));
+
+INSERT INTO controlled_terms (field, value, dwc_value, synonyms, labels, sort_order) VALUES
+('sex', 'male', 'male', '["m", "♂", "オス", "おす", "雄"]', '{"en": "Male", "jp": "オス"}', 1),
+('sex', 'female', 'female', '["f", "♀", "メス", "めす", "雌"]', '{"en": "Female", "jp": "メス"}', 2),
+('sex', 'hermaphrodite', 'hermaphrodite', '["⚥", "雌雄同体"]', '{"en": "Hermaphrodite", "jp": "雌雄同体"}', 3),
+('sex', 'unknown', NULL, '["?", "不明"]', '{"en": "Unknown", "jp": "不明"}', 4),
+('lifestage', 'egg', 'egg', '["卵"]', '{"en": "Egg", "jp": "卵"}', 1),
+('lifestage', 'larva', 'larva', '["larvae", "幼虫"]', '{"en": "Larva", "jp": "幼虫"}', 2),
+('lifestage', 'nymph', 'nymph', '["若虫"]', '{"en": "Nymph", "jp": "若虫"}', 3),
+('lifestage', 'pupa', 'pupa', '["pupae", "蛹", "さなぎ"]', '{"en": "Pupa", "jp": "蛹"}', 4),
+('lifestage', 'juvenile', 'juvenile', '["幼体"]', '{"en": "Juvenile", "jp": "幼体"}', 5),
+('lifestage', 'adult', 'adult', '["imago", "成虫", "成体"]', '{"en": "Adult", "jp": "成体"}', 6),
+('lifestage', 'unknown', NULL, '["?", "不明"]', '{"en": "Unknown", "jp": "不明"}', 7);
@@ -0,0 +1,45 @@
+-- 体長・前翅長・体重などの計測値や形質を、発生情報ごとにいくつでも記録できるようにするのだ
+-- 計測の種類は語彙で、unit はその種類の値をそろえる単位なのだ。形質 (色など) のように数でない種類は NULL なのだ
+CREATE TABLE measurement_types (
+    measurement_type_ID SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    unit TEXT,
+    sort_order INT NOT NULL DEFAULT 0,
+    deprecated BOOLEAN NOT NULL DEFAULT false,
+    labels JSONB NOT NULL DEFAULT '{}'
+);
+CREATE UNIQUE INDEX measurement_types_name_key ON measurement_types (lower(name));
+
+INSERT INTO measurement_types (name, unit, labels, sort_order) VALUES
+('body length', 'mm', '{"en": "Body length", "jp": "体長"}', 1),
+('forewing length', 'mm', '{"en": "Forewing length", "jp": "前翅長"}', 2),
+('body mass', 'g', '{"en": "Body mass", "jp": "体重"}', 3),
+('color', NULL, '{"en": "Color", "jp": "体色"}', 4);
+
+-- 計測値なのだ。標本を測ったときは specimen_ID も入れるのだ
+-- value と unit は入力されたまま、value_number は種類の単位にそろえた数で、範囲の絞り込みに使うのだ
+-- accuracy はDarwin Coreの measurementAccuracy と同じく、単位まで含めた書き方 (例: 0.1 mm) なのだ
+CREATE TABLE measurements (
+    measurement_ID SERIAL PRIMARY KEY,
+    occurrence_ID INT NOT NULL REFERENCES occurrence(occurrence_ID) ON DELETE CASCADE,
+    specimen_ID INT REFERENCES specimen(specimen_ID) ON DELETE CASCADE,
+    measurement_type_ID INT NOT NULL REFERENCES measurement_types(measurement_type_ID),
+    value TEXT NOT NULL,
+    unit TEXT,
+    value_number NUMERIC,
+    accuracy TEXT,
+    method TEXT,
+    measured_by_user_ID INT REFERENCES users(user_ID),
+    measured_on DATE,
+    remarks TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX measurements_occurrence_idx ON measurements (occurrence_ID);
+CREATE INDEX measurements_specimen_idx ON measurements (specimen_ID);
+CREATE INDEX measurements_type_value_idx ON measurements (measurement_type_ID, value_number);
+
+-- 今までの体長 (mm で書かれていたのだ) を計測値に移すのだ。occurrence.body_length は古い画面のために残すのだ
+INSERT INTO measurements (occurrence_ID, measurement_type_ID, value, unit, value_number)
+SELECT o.occurrence_ID, t.measurement_type_ID, o.body_length::TEXT, 'mm', o.body_length
+FROM occurrence o, measurement_types t
+WHERE o.body_length IS NOT NULL AND t.name = 'body length';
@@ -0,0 +1,42 @@
+-- 採集のまとまり (調査 > 地点の訪問 > 1つの罠や1回のすくい取り) なのだ
+-- 同じ罠の一晩で採れた発生情報は、同じ event にまとめるのだ
+-- sample_size_value と sample_size_unit は努力量 (例: 3 trap-nights) で、量の比較に使うのだ
+-- sampling_effort は努力量の書き方 (例: 2人で30分) で、そのままDarwin Coreに出すのだ
+CREATE TABLE events (
+    event_ID SERIAL PRIMARY KEY,
+    parent_event_ID INT REFERENCES events(event_ID),
+    event_type TEXT NOT NULL CHECK (event_type IN ('survey', 'site_visit', 'sample')),
+    event_code TEXT, -- 野帳に書いた番号なのだ (Darwin Core の fieldNumber)
+    name TEXT,
+    project_ID INT REFERENCES projects(project_ID),
+    place_ID INT REFERENCES places(place_ID),
+    sampling_protocol TEXT,
+    sample_size_value NUMERIC CHECK (sample_size_value > 0),
+    sample_size_unit TEXT,
+    sampling_effort TEXT,
+    start_at TIMESTAMPTZ,
+    end_at TIMESTAMPTZ,
+    timezone SMALLINT NOT NULL DEFAULT 0,
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (start_at IS NULL OR end_at IS NULL OR start_at <= end_at),
+    CHECK ((sample_size_value IS NULL) = (sample_size_unit IS NULL))
+);
+CREATE INDEX events_parent_idx ON events (parent_event_ID);
+CREATE INDEX events_project_idx ON events (project_ID);
+CREATE UNIQUE INDEX events_code_key ON events (lower(event_code));
+
+-- 採集に行った人なのだ。role は (例: leader, driver) のような自由な書き方なのだ
+CREATE TABLE event_participants (
+    event_ID INT NOT NULL REFERENCES events(event_ID) ON DELETE CASCADE,
+    user_ID INT NOT NULL REFERENCES users(user_ID),
+    role TEXT,
+    PRIMARY KEY (event_ID, user_ID)
+);
+
+-- 発生情報をまとまりに付けるのだ。individual_count は1つの発生情報で数えた個体の数で、NULLは1匹なのだ
+ALTER TABLE occurrence
+    ADD COLUMN event_ID INT REFERENCES events(event_ID),
+    ADD COLUMN individual_count INT CHECK (individual_count > 0);
+CREATE INDEX occurrence_event_idx ON occurrence (event_ID);
@@ -0,0 +1,27 @@
+-- 標識再捕獲で追いかける個体なのだ
+-- mark_code は個体に付けた標識の番号 (例: 背中のペイントの組み合わせ、タグの番号) で、同じプロジェクトの中で重ならないのだ
+-- mark_type は標識の付け方 (例: paint, tag, notch) なのだ
+CREATE TABLE individuals (
+    individual_ID SERIAL PRIMARY KEY,
+    project_ID INT REFERENCES projects(project_ID),
+    mark_code TEXT NOT NULL CHECK (btrim(mark_code) <> ''),
+    mark_type TEXT,
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX individuals_mark_code_key ON individuals (COALESCE(project_ID, 0), lower(mark_code));
+
+-- いままでの individual_ID はただの番号だったので、番号ごとに個体を作って、番号をそのまま標識の番号にするのだ
+-- 番号を使っている発生情報のプロジェクトが1つだけなら、個体もそのプロジェクトに入れるのだ
+INSERT INTO individuals (individual_ID, project_ID, mark_code)
+SELECT individual_ID,
+       CASE WHEN COUNT(DISTINCT project_ID) = 1 THEN MIN(project_ID) END,
+       individual_ID::text
+FROM occurrence
+WHERE individual_ID IS NOT NULL
+GROUP BY individual_ID;
+SELECT setval(pg_get_serial_sequence('individuals', 'individual_id'), COALESCE((SELECT MAX(individual_ID) FROM individuals), 0) + 1, false);
+
+ALTER TABLE occurrence
+    ADD CONSTRAINT occurrence_individual_fkey FOREIGN KEY (individual_ID) REFERENCES individuals(individual_ID);
+CREATE INDEX occurrence_individual_idx ON occurrence (individual_ID);
@@ -0,0 +1,15 @@
+-- =====================
+-- Administrative boundaries (逆ジオコーディング用)
+-- =====================
+-- admin_level: 0 = country, 1 = prefecture, 2 = municipality
+CREATE TABLE admin_boundaries (
+    boundary_ID SERIAL PRIMARY KEY,
+    admin_level SMALLINT NOT NULL,
+    name TEXT NOT NULL,
+    code TEXT,
+    source TEXT,
+    geom GEOMETRY(MultiPolygon, 4326) NOT NULL
+);
+
+CREATE INDEX admin_boundaries_geom_idx ON admin_boundaries USING GIST (geom);
+CREATE INDEX admin_boundaries_level_idx ON admin_boundaries (admin_level);
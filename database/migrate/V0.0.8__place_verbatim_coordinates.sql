@@ -0,0 +1,5 @@
+-- 野帳に書かれたままの座標と、その表記法・測地系を残すのだ
+-- places.coordinates には常にWGS84に変換した値を入れ、accuracy には誤差半径(m)を入れるのだ
+ALTER TABLE places ADD COLUMN verbatim_coordinates TEXT;
+ALTER TABLE places ADD COLUMN verbatim_coordinate_system TEXT;
+ALTER TABLE places ADD COLUMN verbatim_datum TEXT;
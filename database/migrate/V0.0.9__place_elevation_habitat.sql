@@ -0,0 +1,20 @@
+-- 標高・水深・生息環境と、ジオリファレンスの記録なのだ (Darwin Core の Location の項目に合わせるのだ)
+ALTER TABLE places ADD COLUMN minimum_elevation_in_meters NUMERIC;
+ALTER TABLE places ADD COLUMN maximum_elevation_in_meters NUMERIC;
+ALTER TABLE places ADD COLUMN minimum_depth_in_meters NUMERIC;
+ALTER TABLE places ADD COLUMN maximum_depth_in_meters NUMERIC;
+ALTER TABLE places ADD COLUMN habitat TEXT;
+ALTER TABLE places ADD COLUMN verbatim_locality TEXT;
+ALTER TABLE places ADD COLUMN georeference_protocol TEXT;
+ALTER TABLE places ADD COLUMN georeferenced_by TEXT;
+ALTER TABLE places ADD COLUMN georeferenced_date DATE;
+
+ALTER TABLE places ADD CONSTRAINT places_elevation_range_check
+    CHECK (minimum_elevation_in_meters IS NULL OR maximum_elevation_in_meters IS NULL
+           OR minimum_elevation_in_meters <= maximum_elevation_in_meters);
+ALTER TABLE places ADD CONSTRAINT places_depth_range_check
+    CHECK (minimum_depth_in_meters IS NULL OR maximum_depth_in_meters IS NULL
+           OR minimum_depth_in_meters <= maximum_depth_in_meters);
+
+CREATE INDEX places_elevation_idx ON places (minimum_elevation_in_meters, maximum_elevation_in_meters);
+CREATE INDEX places_depth_idx ON places (minimum_depth_in_meters, maximum_depth_in_meters);